@@ -8,7 +8,14 @@ import (
 	"nofx/auth"
 	"nofx/config"
 	"nofx/decision"
+	"nofx/export"
+	"nofx/logger"
 	"nofx/manager"
+	"nofx/market"
+	"nofx/market/indicator"
+	"nofx/mcpserver"
+	"nofx/pool"
+	"nofx/trader"
 	"strconv"
 	"strings"
 	"time"
@@ -22,6 +29,7 @@ type Server struct {
 	router        *gin.Engine
 	traderManager *manager.TraderManager
 	database      *config.Database
+	mcpServer     *mcpserver.Server
 	port          int
 }
 
@@ -35,10 +43,15 @@ func NewServer(traderManager *manager.TraderManager, database *config.Database,
 	// 启用CORS
 	router.Use(corsMiddleware())
 
+	// MCP服务端（交易类工具需在系统配置中显式开启）
+	allowTradingStr, _ := database.GetSystemConfig("mcp_allow_trading")
+	mcpServer := mcpserver.New(traderManager, database, allowTradingStr == "true")
+
 	s := &Server{
 		router:        router,
 		traderManager: traderManager,
 		database:      database,
+		mcpServer:     mcpServer,
 		port:          port,
 	}
 
@@ -88,6 +101,10 @@ func (s *Server) setupRoutes() {
 		// 系统提示词模板管理（无需认证）
 		api.GET("/prompt-templates", s.handleGetPromptTemplates)
 		api.GET("/prompt-templates/:name", s.handleGetPromptTemplate)
+
+		// 决策流程列表（无需认证）
+		api.GET("/decision-pipelines", s.handleGetDecisionPipelines)
+		api.GET("/indicators", s.handleGetIndicators)
 		
 		// 公开的竞赛数据（无需认证）
 		api.GET("/traders", s.handlePublicTraderList)
@@ -109,6 +126,14 @@ func (s *Server) setupRoutes() {
 			protected.POST("/traders/:id/start", s.handleStartTrader)
 			protected.POST("/traders/:id/stop", s.handleStopTrader)
 			protected.PUT("/traders/:id/prompt", s.handleUpdateTraderPrompt)
+			protected.GET("/traders/:id/cot-stream", s.handleCoTStream)
+			protected.POST("/traders/:id/abort-ai", s.handleAbortAI)
+			protected.GET("/traders/:id/symbol-rules", s.handleGetSymbolRules)
+			protected.PUT("/traders/:id/symbol-rules", s.handleUpdateSymbolRules)
+			protected.POST("/traders/:id/cooldowns", s.handleAddSymbolCooldown)
+			protected.DELETE("/traders/:id/cooldowns/:symbol", s.handleDeleteSymbolCooldown)
+			protected.GET("/traders/:id/retention", s.handleGetRetention)
+			protected.PUT("/traders/:id/retention", s.handleUpdateRetention)
 
 			// AI模型配置
 			protected.GET("/models", s.handleGetModelConfigs)
@@ -130,6 +155,19 @@ func (s *Server) setupRoutes() {
 			protected.GET("/decisions/latest", s.handleLatestDecisions)
 			protected.GET("/statistics", s.handleStatistics)
 			protected.GET("/performance", s.handlePerformance)
+			protected.GET("/trades", s.handleTrades)
+			protected.GET("/export/:dataset", s.handleExport)
+			protected.GET("/storage", s.handleStorage)
+
+			// 行情警报
+			protected.GET("/market/alerts", s.handleMarketAlerts)
+			protected.GET("/market/alerts/stream", s.handleMarketAlertStream)
+			protected.GET("/market/ws-health", s.handleMarketWSHealth)
+
+			// MCP (Model Context Protocol) 服务端
+			protected.GET("/mcp/sse", s.mcpServer.HandleSSE)
+			protected.POST("/mcp/message", s.mcpServer.HandleSSEMessage)
+			protected.POST("/mcp", s.mcpServer.HandleHTTP)
 		}
 	}
 }
@@ -225,9 +263,16 @@ type CreateTraderRequest struct {
 	CustomPrompt         string  `json:"custom_prompt"`
 	OverrideBasePrompt   bool    `json:"override_base_prompt"`
 	SystemPromptTemplate string  `json:"system_prompt_template"` // 系统提示词模板名称
+	DecisionPipeline     string  `json:"decision_pipeline"`      // 决策流程（空或single=单次调用，multi_agent=多智能体）
+	Timeframes           string  `json:"timeframes"`             // 额外K线周期，如 "15m:100,1h:60"
+	UniverseFilters      string  `json:"universe_filters"`       // 自动可交易池筛选条件JSON，如 {"min_oi_value":20000000}
 	IsCrossMargin        *bool   `json:"is_cross_margin"`        // 指针类型，nil表示使用默认值true
 	UseCoinPool          bool    `json:"use_coin_pool"`
 	UseOITop             bool    `json:"use_oi_top"`
+
+	SymbolBlacklist  string `json:"symbol_blacklist"`           // 禁止开仓的币种，逗号分隔
+	SymbolWhitelist  string `json:"symbol_whitelist"`           // 只允许开仓的币种，逗号分隔（空=不限制）
+	StopLossCooldown int    `json:"stop_loss_cooldown_minutes"` // 止损后自动冷却时长（分钟），0=不自动冷却
 }
 
 type ModelConfig struct {
@@ -341,6 +386,33 @@ func (s *Server) handleCreateTrader(c *gin.Context) {
 		systemPromptTemplate = req.SystemPromptTemplate
 	}
 
+	// 校验决策流程
+	if req.DecisionPipeline != "" {
+		if _, ok := decision.GetPipeline(req.DecisionPipeline); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("决策流程不存在: %s", req.DecisionPipeline)})
+			return
+		}
+	}
+
+	// 校验并规范化额外K线周期
+	timeframes, tfErr := market.ParseTimeframes(req.Timeframes)
+	if tfErr != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": tfErr.Error()})
+		return
+	}
+
+	// 校验可交易池筛选条件
+	universeFilters := strings.TrimSpace(req.UniverseFilters)
+	if _, err := pool.UniverseFor(universeFilters); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.StopLossCooldown < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "止损冷却时长不能为负数"})
+		return
+	}
+
 	// 设置扫描间隔默认值
 	scanIntervalMinutes := req.ScanIntervalMinutes
 	if scanIntervalMinutes <= 0 {
@@ -363,9 +435,15 @@ func (s *Server) handleCreateTrader(c *gin.Context) {
 		CustomPrompt:         req.CustomPrompt,
 		OverrideBasePrompt:   req.OverrideBasePrompt,
 		SystemPromptTemplate: systemPromptTemplate,
+		DecisionPipeline:     req.DecisionPipeline,
+		Timeframes:           market.FormatTimeframes(timeframes),
+		UniverseFilters:      universeFilters,
 		IsCrossMargin:        isCrossMargin,
 		ScanIntervalMinutes:  scanIntervalMinutes,
 		IsRunning:            false,
+		SymbolBlacklist:      formatSymbolList(req.SymbolBlacklist),
+		SymbolWhitelist:      formatSymbolList(req.SymbolWhitelist),
+		StopLossCooldown:     req.StopLossCooldown,
 	}
 
 	// 保存到数据库
@@ -404,7 +482,14 @@ type UpdateTraderRequest struct {
 	TradingSymbols      string  `json:"trading_symbols"`
 	CustomPrompt        string  `json:"custom_prompt"`
 	OverrideBasePrompt  bool    `json:"override_base_prompt"`
+	DecisionPipeline    string  `json:"decision_pipeline"` // 为空时保持原值
+	Timeframes          *string `json:"timeframes"`        // nil时保持原值，空字符串表示清除
+	UniverseFilters     *string `json:"universe_filters"`  // nil时保持原值，空字符串表示使用全局配置
 	IsCrossMargin       *bool   `json:"is_cross_margin"`
+
+	SymbolBlacklist  *string `json:"symbol_blacklist"`           // nil时保持原值
+	SymbolWhitelist  *string `json:"symbol_whitelist"`           // nil时保持原值，空字符串表示不限制
+	StopLossCooldown *int    `json:"stop_loss_cooldown_minutes"` // nil时保持原值
 }
 
 // handleUpdateTrader 更新交易员配置
@@ -460,6 +545,54 @@ func (s *Server) handleUpdateTrader(c *gin.Context) {
 		scanIntervalMinutes = existingTrader.ScanIntervalMinutes // 保持原值
 	}
 
+	// 设置决策流程，允许更新
+	decisionPipeline := existingTrader.DecisionPipeline // 保持原值
+	if req.DecisionPipeline != "" {
+		if _, ok := decision.GetPipeline(req.DecisionPipeline); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("决策流程不存在: %s", req.DecisionPipeline)})
+			return
+		}
+		decisionPipeline = req.DecisionPipeline
+	}
+
+	// 设置额外K线周期，允许更新
+	timeframes := existingTrader.Timeframes // 保持原值
+	if req.Timeframes != nil {
+		parsed, err := market.ParseTimeframes(*req.Timeframes)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		timeframes = market.FormatTimeframes(parsed)
+	}
+
+	// 设置可交易池筛选条件，允许更新
+	universeFilters := existingTrader.UniverseFilters // 保持原值
+	if req.UniverseFilters != nil {
+		universeFilters = strings.TrimSpace(*req.UniverseFilters)
+		if _, err := pool.UniverseFor(universeFilters); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// 设置币种黑白名单和止损冷却时长，允许更新
+	symbolBlacklist, symbolWhitelist := existingTrader.SymbolBlacklist, existingTrader.SymbolWhitelist // 保持原值
+	if req.SymbolBlacklist != nil {
+		symbolBlacklist = formatSymbolList(*req.SymbolBlacklist)
+	}
+	if req.SymbolWhitelist != nil {
+		symbolWhitelist = formatSymbolList(*req.SymbolWhitelist)
+	}
+	stopLossCooldown := existingTrader.StopLossCooldown // 保持原值
+	if req.StopLossCooldown != nil {
+		if *req.StopLossCooldown < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "止损冷却时长不能为负数"})
+			return
+		}
+		stopLossCooldown = *req.StopLossCooldown
+	}
+
 	// 更新交易员配置
 	trader := &config.TraderRecord{
 		ID:                   traderID,
@@ -474,9 +607,15 @@ func (s *Server) handleUpdateTrader(c *gin.Context) {
 		CustomPrompt:         req.CustomPrompt,
 		OverrideBasePrompt:   req.OverrideBasePrompt,
 		SystemPromptTemplate: existingTrader.SystemPromptTemplate, // 保持原值
+		DecisionPipeline:     decisionPipeline,
+		Timeframes:           timeframes,
+		UniverseFilters:      universeFilters,
 		IsCrossMargin:        isCrossMargin,
 		ScanIntervalMinutes:  scanIntervalMinutes,
 		IsRunning:            existingTrader.IsRunning, // 保持原值
+		SymbolBlacklist:      symbolBlacklist,
+		SymbolWhitelist:      symbolWhitelist,
+		StopLossCooldown:     stopLossCooldown,
 	}
 
 	// 更新数据库
@@ -492,6 +631,9 @@ func (s *Server) handleUpdateTrader(c *gin.Context) {
 		log.Printf("⚠️ 重新加载用户交易员到内存失败: %v", err)
 	}
 
+	// 已在内存中的交易员不会重新加载，直接更新其币种限制
+	s.applySymbolRules(traderID, symbolBlacklist, symbolWhitelist, stopLossCooldown)
+
 	log.Printf("✓ 更新交易员成功: %s (模型: %s, 交易所: %s)", req.Name, req.AIModelID, req.ExchangeID)
 
 	c.JSON(http.StatusOK, gin.H{
@@ -608,6 +750,191 @@ func (s *Server) handleStopTrader(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "交易员已停止"})
 }
 
+// cotKeepAliveInterval 实时思维链SSE的保活间隔
+const cotKeepAliveInterval = 15 * time.Second
+
+// handleCoTStream 以SSE推送交易员的实时思维链（AI输出增量）
+func (s *Server) handleCoTStream(c *gin.Context) {
+	userID := c.GetString("user_id")
+	traderID := c.Param("id")
+
+	// 校验交易员是否属于当前用户
+	if _, _, _, err := s.database.GetTraderConfig(userID, traderID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在或无访问权限"})
+		return
+	}
+
+	trader, err := s.traderManager.GetTrader(traderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在"})
+		return
+	}
+
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "当前连接不支持流式响应"})
+		return
+	}
+
+	events, unsubscribe := trader.SubscribeCoT()
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(cotKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(c.Writer, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case ev := <-events:
+			data, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", ev.Type, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// handleMarketAlerts 获取最近的行情警报和警报评分排名
+func (s *Server) handleMarketAlerts(c *gin.Context) {
+	monitor := market.WSMonitorCli
+	if monitor == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "行情监控未启动"})
+		return
+	}
+
+	limit := 50
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的limit参数"})
+			return
+		}
+		limit = n
+	}
+
+	alerts := monitor.RecentAlerts(c.Query("symbol"), limit)
+	if alerts == nil {
+		alerts = []market.Alert{}
+	}
+	ranking := monitor.RankedSymbols(0, limit)
+	if ranking == nil {
+		ranking = []market.SymbolScore{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"alerts":  alerts,
+		"ranking": ranking,
+	})
+}
+
+// handleMarketWSHealth 实时行情WebSocket健康状况（连接状态、重连次数、延迟、过期的流）
+func (s *Server) handleMarketWSHealth(c *gin.Context) {
+	monitor := market.WSMonitorCli
+	if monitor == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "行情监控未启动"})
+		return
+	}
+	c.JSON(http.StatusOK, monitor.Health())
+}
+
+// handleMarketAlertStream 以SSE推送实时行情警报（可用 ?symbol=xxx 过滤）
+func (s *Server) handleMarketAlertStream(c *gin.Context) {
+	monitor := market.WSMonitorCli
+	if monitor == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "行情监控未启动"})
+		return
+	}
+
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "当前连接不支持流式响应"})
+		return
+	}
+
+	symbol := ""
+	if v := c.Query("symbol"); v != "" {
+		symbol = market.Normalize(v)
+	}
+
+	alerts, unsubscribe := monitor.SubscribeAlerts()
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(cotKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(c.Writer, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case alert := <-alerts:
+			if symbol != "" && alert.Symbol != symbol {
+				continue
+			}
+			data, err := json.Marshal(alert)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", alert.Type, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// handleAbortAI 中止交易员进行中的AI请求（例如推理卡住时）
+func (s *Server) handleAbortAI(c *gin.Context) {
+	userID := c.GetString("user_id")
+	traderID := c.Param("id")
+
+	// 校验交易员是否属于当前用户
+	if _, _, _, err := s.database.GetTraderConfig(userID, traderID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在或无访问权限"})
+		return
+	}
+
+	trader, err := s.traderManager.GetTrader(traderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在"})
+		return
+	}
+
+	if !trader.AbortAIRequest() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "当前没有可中止的AI请求"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "已中止AI请求"})
+}
+
 // handleUpdateTraderPrompt 更新交易员自定义Prompt
 func (s *Server) handleUpdateTraderPrompt(c *gin.Context) {
 	traderID := c.Param("id")
@@ -641,6 +968,247 @@ func (s *Server) handleUpdateTraderPrompt(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "自定义prompt已更新"})
 }
 
+// formatSymbolList 标准化逗号分隔的币种列表后再保存
+func formatSymbolList(list string) string {
+	return strings.Join(trader.ParseSymbolList(list), ",")
+}
+
+// applySymbolRules 更新内存中交易员的币种黑白名单和止损冷却时长（交易员不在内存中时忽略）
+func (s *Server) applySymbolRules(traderID, blacklist, whitelist string, stopLossCooldown int) {
+	at, err := s.traderManager.GetTrader(traderID)
+	if err != nil {
+		return
+	}
+	rules := at.SymbolRules()
+	rules.SetLists(trader.ParseSymbolList(blacklist), trader.ParseSymbolList(whitelist))
+	rules.SetStopLossCooldown(time.Duration(stopLossCooldown) * time.Minute)
+	log.Printf("✓ 已更新交易员 %s 的币种限制 (黑名单: %s, 白名单: %s)", at.GetName(), blacklist, whitelist)
+}
+
+// handleGetSymbolRules 获取交易员的币种黑白名单和冷却期
+func (s *Server) handleGetSymbolRules(c *gin.Context) {
+	traderID := c.Param("id")
+	userID := c.GetString("user_id")
+
+	// 交易员在内存中时返回实时状态（包含自动添加的冷却期）
+	if at, err := s.traderManager.GetTrader(traderID); err == nil {
+		if _, _, _, err := s.database.GetTraderConfig(userID, traderID); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在"})
+			return
+		}
+		c.JSON(http.StatusOK, at.SymbolRules().Snapshot())
+		return
+	}
+
+	traderConfig, _, _, err := s.database.GetTraderConfig(userID, traderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在"})
+		return
+	}
+	records, err := s.database.GetSymbolCooldowns(traderID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取冷却期失败: %v", err)})
+		return
+	}
+	cooldowns := make([]trader.SymbolCooldown, 0, len(records))
+	for _, record := range records {
+		cooldowns = append(cooldowns, trader.SymbolCooldown{Symbol: record.Symbol, Until: record.Until, Reason: record.Reason})
+	}
+	rules := trader.NewSymbolRules(
+		trader.ParseSymbolList(traderConfig.SymbolBlacklist),
+		trader.ParseSymbolList(traderConfig.SymbolWhitelist),
+		cooldowns,
+		time.Duration(traderConfig.StopLossCooldown)*time.Minute,
+	)
+	c.JSON(http.StatusOK, rules.Snapshot())
+}
+
+// handleUpdateSymbolRules 更新交易员的币种黑白名单和止损冷却时长（运行中立即生效）
+func (s *Server) handleUpdateSymbolRules(c *gin.Context) {
+	traderID := c.Param("id")
+	userID := c.GetString("user_id")
+
+	var req struct {
+		Blacklist        string `json:"symbol_blacklist"`
+		Whitelist        string `json:"symbol_whitelist"`
+		StopLossCooldown int    `json:"stop_loss_cooldown_minutes"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.StopLossCooldown < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "止损冷却时长不能为负数"})
+		return
+	}
+
+	if _, _, _, err := s.database.GetTraderConfig(userID, traderID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在"})
+		return
+	}
+
+	blacklist, whitelist := formatSymbolList(req.Blacklist), formatSymbolList(req.Whitelist)
+	err := s.database.UpdateTraderSymbolRules(userID, traderID, blacklist, whitelist, req.StopLossCooldown)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("更新币种限制失败: %v", err)})
+		return
+	}
+
+	// 如果trader在内存中，立即更新其币种限制
+	s.applySymbolRules(traderID, blacklist, whitelist, req.StopLossCooldown)
+
+	c.JSON(http.StatusOK, gin.H{"message": "币种限制已更新"})
+}
+
+// handleGetRetention 交易员的决策日志保留策略、存储占用和已汇总的每日统计
+func (s *Server) handleGetRetention(c *gin.Context) {
+	traderID := c.Param("id")
+	userID := c.GetString("user_id")
+
+	if _, _, _, err := s.database.GetTraderConfig(userID, traderID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在"})
+		return
+	}
+
+	override, err := s.database.GetRetentionOverride(traderID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defaults := s.database.DefaultRetentionPolicy()
+	result := gin.H{
+		"trader_id":      traderID,
+		"policy":         override.Apply(defaults),
+		"override":       override,
+		"default_policy": defaults,
+	}
+
+	if at, err := s.traderManager.GetTrader(traderID); err == nil {
+		decisionLogger := at.GetDecisionLogger()
+		usage, err := decisionLogger.StorageUsage(s.database.DecisionArchiveDir(traderID))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("统计存储占用失败: %v", err)})
+			return
+		}
+		daily, err := decisionLogger.GetDailyStats()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取每日统计失败: %v", err)})
+			return
+		}
+		result["usage"] = usage
+		result["daily_stats"] = daily
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// handleUpdateRetention 设置交易员单独的保留天数（字段为 null 时使用全局默认值），下次执行保留策略时生效
+func (s *Server) handleUpdateRetention(c *gin.Context) {
+	traderID := c.Param("id")
+	userID := c.GetString("user_id")
+
+	var req config.RetentionOverride
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	policy := req.Apply(s.database.DefaultRetentionPolicy())
+	if err := policy.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, _, _, err := s.database.GetTraderConfig(userID, traderID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在"})
+		return
+	}
+	if err := s.database.SetRetentionOverride(traderID, &req); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("更新保留策略失败: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "保留策略已更新", "policy": policy})
+}
+
+// handleAddSymbolCooldown 为交易员添加币种冷却期，到期前禁止开仓
+func (s *Server) handleAddSymbolCooldown(c *gin.Context) {
+	traderID := c.Param("id")
+	userID := c.GetString("user_id")
+
+	var req struct {
+		Symbol  string  `json:"symbol" binding:"required"`
+		Minutes int     `json:"minutes"`
+		Hours   float64 `json:"hours"`
+		Reason  string  `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	symbols := trader.ParseSymbolList(req.Symbol)
+	if len(symbols) != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的币种"})
+		return
+	}
+	duration := time.Duration(req.Minutes)*time.Minute + time.Duration(req.Hours*float64(time.Hour))
+	if duration <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "冷却时长必须大于0（minutes 或 hours）"})
+		return
+	}
+	if req.Reason == "" {
+		req.Reason = "手动冷却"
+	}
+
+	if _, _, _, err := s.database.GetTraderConfig(userID, traderID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在"})
+		return
+	}
+
+	// 交易员在内存中时由其冷却回调写入数据库，否则直接写入数据库，下次加载时生效
+	var cooldown trader.SymbolCooldown
+	if at, err := s.traderManager.GetTrader(traderID); err == nil {
+		cooldown = at.SymbolRules().AddCooldown(symbols[0], duration, req.Reason)
+	} else {
+		cooldown = trader.SymbolCooldown{
+			Symbol: symbols[0],
+			Until:  time.Now().Add(duration),
+			Reason: req.Reason,
+		}
+		if err := s.database.SetSymbolCooldown(traderID, cooldown.Symbol, cooldown.Until, cooldown.Reason); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("保存冷却期失败: %v", err)})
+			return
+		}
+	}
+
+	log.Printf("🧊 交易员 %s 的 %s 冷却至 %s (%s)", traderID, cooldown.Symbol, cooldown.Until.Local().Format("01-02 15:04"), cooldown.Reason)
+	c.JSON(http.StatusOK, cooldown)
+}
+
+// handleDeleteSymbolCooldown 解除交易员的币种冷却期
+func (s *Server) handleDeleteSymbolCooldown(c *gin.Context) {
+	traderID := c.Param("id")
+	userID := c.GetString("user_id")
+
+	if _, _, _, err := s.database.GetTraderConfig(userID, traderID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在"})
+		return
+	}
+
+	symbols := trader.ParseSymbolList(c.Param("symbol"))
+	if len(symbols) != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的币种"})
+		return
+	}
+	if err := s.database.DeleteSymbolCooldown(traderID, symbols[0]); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("解除冷却期失败: %v", err)})
+		return
+	}
+	if at, err := s.traderManager.GetTrader(traderID); err == nil {
+		at.SymbolRules().RemoveCooldown(symbols[0])
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("%s 冷却期已解除", symbols[0])})
+}
+
 // handleGetModelConfigs 获取AI模型配置
 func (s *Server) handleGetModelConfigs(c *gin.Context) {
 	userID := c.GetString("user_id")
@@ -853,10 +1421,17 @@ func (s *Server) handleGetTraderConfig(c *gin.Context) {
 		"trading_symbols":       traderConfig.TradingSymbols,
 		"custom_prompt":         traderConfig.CustomPrompt,
 		"override_base_prompt":  traderConfig.OverrideBasePrompt,
+		"decision_pipeline":     traderConfig.DecisionPipeline,
+		"timeframes":            traderConfig.Timeframes,
+		"universe_filters":      traderConfig.UniverseFilters,
 		"is_cross_margin":       traderConfig.IsCrossMargin,
 		"use_coin_pool":         traderConfig.UseCoinPool,
 		"use_oi_top":            traderConfig.UseOITop,
 		"is_running":            isRunning,
+
+		"symbol_blacklist":           traderConfig.SymbolBlacklist,
+		"symbol_whitelist":           traderConfig.SymbolWhitelist,
+		"stop_loss_cooldown_minutes": traderConfig.StopLossCooldown,
 	}
 
 	c.JSON(http.StatusOK, result)
@@ -1056,8 +1631,8 @@ func (s *Server) handleEquityHistory(c *gin.Context) {
 	}
 
 	// 获取尽可能多的历史数据（几天的数据）
-	// 每3分钟一个周期：10000条 = 约20天的数据
-	records, err := trader.GetDecisionLogger().GetLatestRecords(10000)
+	// 每3分钟一个周期：10000条 = 约20天的数据（只读取账户快照）
+	records, err := trader.GetDecisionLogger().GetAccountHistory(10000)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": fmt.Sprintf("获取历史数据失败: %v", err),
@@ -1128,6 +1703,7 @@ func (s *Server) handleEquityHistory(c *gin.Context) {
 }
 
 // handlePerformance AI历史表现分析（用于展示AI学习和反思）
+// 支持 range=24h/7d/30d/1y/all 或 start/end（RFC3339 或 2006-01-02）选择时间范围，不指定时分析最近100个周期
 func (s *Server) handlePerformance(c *gin.Context) {
 	_, traderID, err := s.getTraderFromQuery(c)
 	if err != nil {
@@ -1135,15 +1711,26 @@ func (s *Server) handlePerformance(c *gin.Context) {
 		return
 	}
 
+	start, end, ranged, err := parseTimeRangeQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	trader, err := s.traderManager.GetTrader(traderID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 分析最近100个周期的交易表现（避免长期持仓的交易记录丢失）
-	// 假设每3分钟一个周期，100个周期 = 5小时，足够覆盖大部分交易
-	performance, err := trader.GetDecisionLogger().AnalyzePerformance(100)
+	var performance *logger.PerformanceAnalysis
+	if ranged {
+		performance, err = trader.GetDecisionLogger().AnalyzePerformanceBetween(start, end)
+	} else {
+		// 分析最近100个周期的交易表现（避免长期持仓的交易记录丢失）
+		// 假设每3分钟一个周期，100个周期 = 5小时，足够覆盖大部分交易
+		performance, err = trader.GetDecisionLogger().AnalyzePerformance(100)
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": fmt.Sprintf("分析历史表现失败: %v", err),
@@ -1151,9 +1738,172 @@ func (s *Server) handlePerformance(c *gin.Context) {
 		return
 	}
 
+	// 同期BTC买入持有对比（行情获取失败时不返回对比）
+	if !performance.StartTime.IsZero() {
+		startPrice, err := market.PriceAt("BTCUSDT", performance.StartTime)
+		if err == nil {
+			var endPrice float64
+			if endPrice, err = market.PriceAt("BTCUSDT", performance.EndTime); err == nil {
+				performance.SetBenchmark("BTCUSDT", startPrice, endPrice)
+			}
+		}
+		if err != nil {
+			log.Printf("⚠️  获取BTC基准价格失败: %v", err)
+		}
+	}
+
 	c.JSON(http.StatusOK, performance)
 }
 
+// parseTimeRangeQuery 解析 range/start/end 时间范围参数（表现分析、数据导出），ranged=false 表示未指定范围
+func parseTimeRangeQuery(c *gin.Context) (start, end time.Time, ranged bool, err error) {
+	return export.ParseTimeRange(c.Query("range"), c.Query("start"), c.Query("end"))
+}
+
+// handleExport 导出交易员的数据集（decisions/actions/trades/equity/prompts）
+// 支持 format=csv/jsonl/parquet，时间范围参数与表现分析相同，不指定时导出全部
+func (s *Server) handleExport(c *gin.Context) {
+	_, traderID, err := s.getTraderFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 校验交易员是否属于当前用户
+	if _, _, _, err := s.database.GetTraderConfig(c.GetString("user_id"), traderID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在或无访问权限"})
+		return
+	}
+
+	dataset, err := export.ParseDataset(c.Param("dataset"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	format, err := export.ParseFormat(c.Query("format"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	start, end, _, err := parseTimeRangeQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req := export.Request{TraderID: traderID, Dataset: dataset, Format: format, Start: start, End: end,
+		ArchiveDir: s.database.DecisionArchiveDir(traderID)}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	trader, err := s.traderManager.GetTrader(traderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", req.Format.ContentType())
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", req.Filename()))
+	if err := export.Write(c.Writer, trader.GetDecisionLogger(), req); err != nil {
+		// 已经开始输出时无法再返回错误响应，只能中断
+		if !c.Writer.Written() {
+			c.Header("Content-Type", "")
+			c.Header("Content-Disposition", "")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("导出失败: %v", err)})
+			return
+		}
+		log.Printf("❌ [%s] 导出%s失败: %v", traderID, dataset, err)
+	}
+}
+
+// handleStorage 当前用户各交易员决策日志的存储占用（记录 + gzip归档）和生效的保留策略
+func (s *Server) handleStorage(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if err := s.traderManager.LoadUserTraders(s.database, userID); err != nil {
+		log.Printf("⚠️ 加载用户 %s 的交易员失败: %v", userID, err)
+	}
+
+	traders, err := s.database.GetTraders(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取交易员列表失败: %v", err)})
+		return
+	}
+
+	var totalBytes int64
+	result := make([]gin.H, 0, len(traders))
+	for _, traderRecord := range traders {
+		entry := gin.H{
+			"trader_id":   traderRecord.ID,
+			"trader_name": traderRecord.Name,
+		}
+		if policy, err := s.database.RetentionPolicy(traderRecord.ID); err == nil {
+			entry["policy"] = policy
+		}
+		if at, err := s.traderManager.GetTrader(traderRecord.ID); err == nil {
+			usage, err := at.GetDecisionLogger().StorageUsage(s.database.DecisionArchiveDir(traderRecord.ID))
+			if err != nil {
+				entry["error"] = err.Error()
+			} else {
+				entry["usage"] = usage
+				totalBytes += usage.TotalBytes
+			}
+		}
+		result = append(result, entry)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"traders": result, "total_bytes": totalBytes})
+}
+
+// handleTrades 交易账本：未平仓交易和最近平仓的交易
+func (s *Server) handleTrades(c *gin.Context) {
+	_, traderID, err := s.getTraderFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 校验交易员是否属于当前用户
+	if _, _, _, err := s.database.GetTraderConfig(c.GetString("user_id"), traderID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在或无访问权限"})
+		return
+	}
+
+	trader, err := s.traderManager.GetTrader(traderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit := 100
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的limit参数"})
+			return
+		}
+		limit = n
+	}
+
+	ledger := trader.TradeLedger()
+	openTrades, err := ledger.OpenTrades()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取未平仓交易失败: %v", err)})
+		return
+	}
+	closedTrades, err := ledger.ClosedTrades(time.Time{}, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取已平仓交易失败: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"open":   openTrades,
+		"closed": closedTrades,
+	})
+}
+
 // authMiddleware JWT认证中间件
 func (s *Server) authMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -1472,10 +2222,14 @@ func (s *Server) Start() error {
 	log.Printf("  • GET  /api/equity-history?trader_id=xxx - 公开的收益率历史数据（无需认证，竞赛用）")
 	log.Printf("  • GET  /api/equity-history-batch?trader_ids=a,b,c - 批量获取历史数据（无需认证，表现对比优化）")
 	log.Printf("  • GET  /api/traders/:id/public-config - 公开的交易员配置（无需认证，不含敏感信息）")
+	log.Printf("  • GET  /api/decision-pipelines - 可选的决策流程（无需认证）")
+	log.Printf("  • GET  /api/indicators - 可在提示词模板中声明的技术指标（无需认证）")
 	log.Printf("  • POST /api/traders          - 创建新的AI交易员")
 	log.Printf("  • DELETE /api/traders/:id    - 删除AI交易员")
 	log.Printf("  • POST /api/traders/:id/start - 启动AI交易员")
 	log.Printf("  • POST /api/traders/:id/stop  - 停止AI交易员")
+	log.Printf("  • GET  /api/traders/:id/cot-stream - 实时思维链（SSE）")
+	log.Printf("  • POST /api/traders/:id/abort-ai   - 中止进行中的AI请求")
 	log.Printf("  • GET  /api/models           - 获取AI模型配置")
 	log.Printf("  • PUT  /api/models           - 更新AI模型配置")
 	log.Printf("  • GET  /api/exchanges        - 获取交易所配置")
@@ -1486,7 +2240,16 @@ func (s *Server) Start() error {
 	log.Printf("  • GET  /api/decisions?trader_id=xxx  - 指定trader的决策日志")
 	log.Printf("  • GET  /api/decisions/latest?trader_id=xxx - 指定trader的最新决策")
 	log.Printf("  • GET  /api/statistics?trader_id=xxx - 指定trader的统计信息")
-	log.Printf("  • GET  /api/performance?trader_id=xxx - 指定trader的AI学习表现分析")
+	log.Printf("  • GET  /api/performance?trader_id=xxx&range=7d - 指定trader的表现分析（回撤、年化比率、分组统计、BTC基准对比）")
+	log.Printf("  • GET  /api/trades?trader_id=xxx&limit=100 - 指定trader的交易账本（未平仓和最近平仓的交易）")
+	log.Printf("  • GET  /api/export/:dataset?trader_id=xxx&format=csv&range=30d - 导出决策/动作/交易/净值/提示词样本（csv、jsonl、parquet）")
+	log.Printf("  • GET  /api/storage          - 各交易员决策日志的存储占用和保留策略")
+	log.Printf("  • GET/PUT /api/traders/:id/retention - 交易员的决策日志保留策略（完整/压缩保留天数）")
+	log.Printf("  • GET  /api/market/alerts?symbol=xxx&limit=50 - 最近的行情警报和警报评分排名")
+	log.Printf("  • GET  /api/market/alerts/stream - 实时行情警报（SSE）")
+	log.Printf("  • GET  /api/market/ws-health - 实时行情WebSocket健康状况")
+	log.Printf("  • GET  /api/mcp/sse          - MCP服务端（SSE传输，交易工具: %t）", s.mcpServer.AllowTrading())
+	log.Printf("  • POST /api/mcp              - MCP服务端（HTTP传输）")
 	log.Println()
 
 	return s.router.Run(addr)
@@ -1526,6 +2289,20 @@ func (s *Server) handleGetPromptTemplate(c *gin.Context) {
 	})
 }
 
+// handleGetDecisionPipelines 获取所有可选的决策流程
+func (s *Server) handleGetDecisionPipelines(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"pipelines": decision.GetAllPipelines(),
+	})
+}
+
+// handleGetIndicators 获取所有已注册的技术指标（提示词模板 "@indicators:" 指令可用）
+func (s *Server) handleGetIndicators(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"indicators": indicator.List(),
+	})
+}
+
 // handlePublicTraderList 获取公开的交易员列表（无需认证）
 func (s *Server) handlePublicTraderList(c *gin.Context) {
 	// 从所有用户获取交易员信息
@@ -1671,7 +2448,7 @@ func (s *Server) getEquityHistoryForTraders(traderIDs []string) map[string]inter
 		}
 		
 		// 获取历史数据（用于对比展示，限制数据量）
-		records, err := trader.GetDecisionLogger().GetLatestRecords(500)
+		records, err := trader.GetDecisionLogger().GetAccountHistory(500)
 		if err != nil {
 			errors[traderID] = fmt.Sprintf("获取历史数据失败: %v", err)
 			continue
@@ -111,6 +111,17 @@ func (d *Database) createTables() error {
 			FOREIGN KEY (exchange_id) REFERENCES exchanges(id)
 		)`,
 
+		// 交易员币种冷却期表（到期前禁止开仓）
+		`CREATE TABLE IF NOT EXISTS trader_symbol_cooldowns (
+			trader_id TEXT NOT NULL,
+			symbol TEXT NOT NULL,
+			until DATETIME NOT NULL,
+			reason TEXT DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (trader_id, symbol),
+			FOREIGN KEY (trader_id) REFERENCES traders(id) ON DELETE CASCADE
+		)`,
+
 		// 用户表
 		`CREATE TABLE IF NOT EXISTS users (
 			id TEXT PRIMARY KEY,
@@ -138,6 +149,113 @@ func (d *Database) createTables() error {
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		)`,
 
+		// 决策记录表（每个周期一条，提示词/思维链/持仓等字段以JSON保存在 record_json）
+		`CREATE TABLE IF NOT EXISTS decision_records (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			trader_id TEXT NOT NULL,
+			cycle_number INTEGER NOT NULL,
+			timestamp DATETIME NOT NULL,
+			success BOOLEAN DEFAULT 0,
+			error_message TEXT DEFAULT '',
+			record_json TEXT NOT NULL,
+			compacted BOOLEAN DEFAULT 0,
+			aggregated BOOLEAN DEFAULT 0,
+			UNIQUE (trader_id, timestamp, cycle_number)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_decision_records_trader_time ON decision_records(trader_id, timestamp)`,
+
+		// 决策记录汇总后的每日统计（超过保留期的记录只保留账户快照和动作）
+		`CREATE TABLE IF NOT EXISTS decision_daily_stats (
+			trader_id TEXT NOT NULL,
+			date TEXT NOT NULL,
+			cycles INTEGER DEFAULT 0,
+			successful_cycles INTEGER DEFAULT 0,
+			open_positions INTEGER DEFAULT 0,
+			close_positions INTEGER DEFAULT 0,
+			first_time DATETIME,
+			last_time DATETIME,
+			start_equity REAL DEFAULT 0,
+			end_equity REAL DEFAULT 0,
+			min_equity REAL DEFAULT 0,
+			max_equity REAL DEFAULT 0,
+			PRIMARY KEY (trader_id, date)
+		)`,
+
+		// 交易员单独设置的决策日志保留天数（NULL 表示使用全局默认值）
+		`CREATE TABLE IF NOT EXISTS decision_retention (
+			trader_id TEXT PRIMARY KEY,
+			full_days INTEGER,
+			compact_days INTEGER
+		)`,
+
+		// 决策动作表
+		`CREATE TABLE IF NOT EXISTS decision_actions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			record_id INTEGER NOT NULL,
+			trader_id TEXT NOT NULL,
+			seq INTEGER NOT NULL,
+			action TEXT NOT NULL,
+			symbol TEXT NOT NULL,
+			quantity REAL DEFAULT 0,
+			leverage INTEGER DEFAULT 0,
+			price REAL DEFAULT 0,
+			order_id INTEGER DEFAULT 0,
+			timestamp DATETIME NOT NULL,
+			success BOOLEAN DEFAULT 0,
+			error TEXT DEFAULT '',
+			estimated_slippage_pct REAL DEFAULT 0,
+			FOREIGN KEY (record_id) REFERENCES decision_records(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_decision_actions_record ON decision_actions(record_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_decision_actions_trader_time ON decision_actions(trader_id, timestamp)`,
+		`CREATE INDEX IF NOT EXISTS idx_decision_actions_trader_symbol ON decision_actions(trader_id, symbol, timestamp)`,
+
+		// 账户快照表（每个决策周期一条）
+		`CREATE TABLE IF NOT EXISTS account_snapshots (
+			record_id INTEGER PRIMARY KEY,
+			trader_id TEXT NOT NULL,
+			timestamp DATETIME NOT NULL,
+			total_balance REAL DEFAULT 0,
+			available_balance REAL DEFAULT 0,
+			total_unrealized_profit REAL DEFAULT 0,
+			position_count INTEGER DEFAULT 0,
+			margin_used_pct REAL DEFAULT 0,
+			FOREIGN KEY (record_id) REFERENCES decision_records(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_account_snapshots_trader_time ON account_snapshots(trader_id, timestamp)`,
+
+		// 交易账本表（每笔交易从开仓到平仓一条记录）
+		`CREATE TABLE IF NOT EXISTS trades (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			trader_id TEXT NOT NULL,
+			symbol TEXT NOT NULL,
+			side TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'open',
+			quantity REAL DEFAULT 0,
+			leverage INTEGER DEFAULT 0,
+			entry_price REAL DEFAULT 0,
+			entry_time DATETIME NOT NULL,
+			entry_order_id INTEGER DEFAULT 0,
+			exit_price REAL DEFAULT 0,
+			exit_time DATETIME DEFAULT NULL,
+			exit_order_id INTEGER DEFAULT 0,
+			exit_reason TEXT DEFAULT '',
+			stop_loss REAL DEFAULT 0,
+			take_profit REAL DEFAULT 0,
+			fees REAL DEFAULT 0,
+			funding REAL DEFAULT 0,
+			realized_pnl REAL DEFAULT 0,
+			net_pnl REAL DEFAULT 0,
+			open_source TEXT DEFAULT '',
+			open_cycle INTEGER DEFAULT 0,
+			close_cycle INTEGER DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_trades_trader_status ON trades(trader_id, status)`,
+		`CREATE INDEX IF NOT EXISTS idx_trades_trader_exit ON trades(trader_id, exit_time)`,
+		`CREATE INDEX IF NOT EXISTS idx_trades_trader_symbol ON trades(trader_id, symbol, entry_time)`,
+
 		// 触发器：自动更新 updated_at
 		`CREATE TRIGGER IF NOT EXISTS update_users_updated_at
 			AFTER UPDATE ON users
@@ -199,6 +317,15 @@ func (d *Database) createTables() error {
 		`ALTER TABLE traders ADD COLUMN use_coin_pool BOOLEAN DEFAULT 0`,               // 是否使用COIN POOL信号源
 		`ALTER TABLE traders ADD COLUMN use_oi_top BOOLEAN DEFAULT 0`,                  // 是否使用OI TOP信号源
 		`ALTER TABLE traders ADD COLUMN system_prompt_template TEXT DEFAULT 'default'`, // 系统提示词模板名称
+		`ALTER TABLE traders ADD COLUMN decision_pipeline TEXT DEFAULT ''`,             // 决策流程（空=单次调用）
+		`ALTER TABLE traders ADD COLUMN timeframes TEXT DEFAULT ''`,                    // 额外K线周期，如 15m:100,1h:60
+		`ALTER TABLE traders ADD COLUMN universe_filters TEXT DEFAULT ''`,              // 自动可交易池筛选条件（JSON，覆盖全局配置）
+		`ALTER TABLE traders ADD COLUMN symbol_blacklist TEXT DEFAULT ''`,              // 禁止开仓的币种，逗号分隔
+		`ALTER TABLE traders ADD COLUMN symbol_whitelist TEXT DEFAULT ''`,              // 只允许开仓的币种，逗号分隔（空=不限制）
+		`ALTER TABLE traders ADD COLUMN stop_loss_cooldown_minutes INTEGER DEFAULT 0`,  // 止损后自动冷却时长（分钟）
+		`ALTER TABLE decision_actions ADD COLUMN trade_id INTEGER DEFAULT 0`,           // 对应交易账本中的交易ID
+		`ALTER TABLE decision_records ADD COLUMN compacted BOOLEAN DEFAULT 0`,           // 是否已压缩（提示词已移入归档）
+		`ALTER TABLE decision_records ADD COLUMN aggregated BOOLEAN DEFAULT 0`,          // 是否已汇总为每日统计（只保留账户快照和动作）
 		`ALTER TABLE ai_models ADD COLUMN custom_api_url TEXT DEFAULT ''`,              // 自定义API地址
 		`ALTER TABLE ai_models ADD COLUMN custom_model_name TEXT DEFAULT ''`,           // 自定义模型名称
 	}
@@ -269,6 +396,22 @@ func (d *Database) initDefaultData() error {
 		"btc_eth_leverage":      "5",                                                                                   // BTC/ETH杠杆倍数
 		"altcoin_leverage":      "5",                                                                                   // 山寨币杠杆倍数
 		"jwt_secret":            "",                                                                                    // JWT密钥，默认为空，由config.json或系统生成
+		"mcp_allow_trading":     "false",                                                                               // MCP服务端是否开放交易类工具（默认只读）
+		"ai_requests_per_minute": "20",                                                                               // 同一AI账号每分钟最多请求数（所有交易员共享）
+		"ai_max_in_flight":      "2",                                                                                   // 同一AI账号同时进行中的最大请求数
+		"ai_record_dir":         "",                                                                                    // AI请求/响应录制目录（为空不录制）
+		"ai_streaming":          "true",                                                                                // AI请求使用流式输出（实时思维链）
+		"ai_stream_idle_timeout_seconds": "90",                                                                       // 流式响应空闲超时（秒），超时后中止并重试
+		"market_store_path":     "market_data/market.db",                                                               // 本地行情存储（K线/持仓量/资金费率）路径（目录不存在时自动创建），为空不启用
+		"max_slippage_pct":      "1.0",                                                                                 // 开仓允许的最大预估滑点（%），0 表示不检查
+		"signal_source_weights": `{"ai500":1,"oi_top":1,"market_alerts":1}`,                                            // 币种池信号源权重（JSON），可选 static/top_movers/funding_extremes/universe
+		"signal_file":           "",                                                                                    // static 信号源的自选币种文件（JSON数组或每行一个币种）
+		"universe_enabled":      "false",                                                                               // 是否为所有交易员启用自动可交易池（交易员可单独开启/关闭）
+		"universe_filters":      `{"min_quote_volume":50000000,"min_oi_value":15000000,"min_atr_pct":0.3,"max_atr_pct":5,"min_listing_days":30,"max_spread_pct":0.05,"limit":20}`, // 自动可交易池默认筛选条件（JSON），持仓价值下限同时用于候选币种流动性过滤
+		"decision_log_storage":  "sqlite",                                                                              // 决策日志存储：sqlite（写入本数据库）或 file（每周期一个JSON文件）
+		"decision_full_retention_days":    "7",                                                                       // 决策记录保留完整内容的天数，之后去掉提示词并归档为gzip（0 不压缩）
+		"decision_compact_retention_days": "90",                                                                      // 决策记录保留天数，之后汇总为每日统计并精简（0 不汇总）
+		"decision_archive_dir":            "decision_archive",                                                        // 压缩前完整决策记录的gzip归档目录（每个交易员一个子目录）
 	}
 
 	for key, value := range systemConfigs {
@@ -429,9 +572,25 @@ type TraderRecord struct {
 	CustomPrompt         string    `json:"custom_prompt"`          // 自定义交易策略prompt
 	OverrideBasePrompt   bool      `json:"override_base_prompt"`   // 是否覆盖基础prompt
 	SystemPromptTemplate string    `json:"system_prompt_template"` // 系统提示词模板名称
+	DecisionPipeline     string    `json:"decision_pipeline"`      // 决策流程（空或single=单次调用，multi_agent=多智能体）
+	Timeframes           string    `json:"timeframes"`             // 额外K线周期，格式 "15m:100,1h:60"（空=使用模板声明）
+	UniverseFilters      string    `json:"universe_filters"`       // 自动可交易池筛选条件JSON（空=使用全局配置）
 	IsCrossMargin        bool      `json:"is_cross_margin"`        // 是否为全仓模式（true=全仓，false=逐仓）
 	CreatedAt            time.Time `json:"created_at"`
 	UpdatedAt            time.Time `json:"updated_at"`
+
+	SymbolBlacklist  string `json:"symbol_blacklist"`           // 禁止开仓的币种，逗号分隔
+	SymbolWhitelist  string `json:"symbol_whitelist"`           // 只允许开仓的币种，逗号分隔（空=不限制）
+	StopLossCooldown int    `json:"stop_loss_cooldown_minutes"` // 止损后自动冷却时长（分钟），0=不自动冷却
+}
+
+// SymbolCooldown 交易员的币种冷却期
+type SymbolCooldown struct {
+	TraderID  string    `json:"trader_id"`
+	Symbol    string    `json:"symbol"`
+	Until     time.Time `json:"until"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 // UserSignalSource 用户信号源配置
@@ -781,9 +940,9 @@ func (d *Database) CreateExchange(userID, id, name, typ string, enabled bool, ap
 // CreateTrader 创建交易员
 func (d *Database) CreateTrader(trader *TraderRecord) error {
 	_, err := d.db.Exec(`
-		INSERT INTO traders (id, user_id, name, ai_model_id, exchange_id, initial_balance, scan_interval_minutes, is_running, btc_eth_leverage, altcoin_leverage, trading_symbols, use_coin_pool, use_oi_top, custom_prompt, override_base_prompt, system_prompt_template, is_cross_margin)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, trader.ID, trader.UserID, trader.Name, trader.AIModelID, trader.ExchangeID, trader.InitialBalance, trader.ScanIntervalMinutes, trader.IsRunning, trader.BTCETHLeverage, trader.AltcoinLeverage, trader.TradingSymbols, trader.UseCoinPool, trader.UseOITop, trader.CustomPrompt, trader.OverrideBasePrompt, trader.SystemPromptTemplate, trader.IsCrossMargin)
+		INSERT INTO traders (id, user_id, name, ai_model_id, exchange_id, initial_balance, scan_interval_minutes, is_running, btc_eth_leverage, altcoin_leverage, trading_symbols, use_coin_pool, use_oi_top, custom_prompt, override_base_prompt, system_prompt_template, decision_pipeline, timeframes, universe_filters, symbol_blacklist, symbol_whitelist, stop_loss_cooldown_minutes, is_cross_margin)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, trader.ID, trader.UserID, trader.Name, trader.AIModelID, trader.ExchangeID, trader.InitialBalance, trader.ScanIntervalMinutes, trader.IsRunning, trader.BTCETHLeverage, trader.AltcoinLeverage, trader.TradingSymbols, trader.UseCoinPool, trader.UseOITop, trader.CustomPrompt, trader.OverrideBasePrompt, trader.SystemPromptTemplate, trader.DecisionPipeline, trader.Timeframes, trader.UniverseFilters, trader.SymbolBlacklist, trader.SymbolWhitelist, trader.StopLossCooldown, trader.IsCrossMargin)
 	return err
 }
 
@@ -796,6 +955,11 @@ func (d *Database) GetTraders(userID string) ([]*TraderRecord, error) {
 		       COALESCE(use_coin_pool, 0) as use_coin_pool, COALESCE(use_oi_top, 0) as use_oi_top,
 		       COALESCE(custom_prompt, '') as custom_prompt, COALESCE(override_base_prompt, 0) as override_base_prompt,
 		       COALESCE(system_prompt_template, 'default') as system_prompt_template,
+		       COALESCE(decision_pipeline, '') as decision_pipeline,
+		       COALESCE(timeframes, '') as timeframes,
+		       COALESCE(universe_filters, '') as universe_filters,
+		       COALESCE(symbol_blacklist, '') as symbol_blacklist, COALESCE(symbol_whitelist, '') as symbol_whitelist,
+		       COALESCE(stop_loss_cooldown_minutes, 0) as stop_loss_cooldown_minutes,
 		       COALESCE(is_cross_margin, 1) as is_cross_margin, created_at, updated_at
 		FROM traders WHERE user_id = ? ORDER BY created_at DESC
 	`, userID)
@@ -813,7 +977,8 @@ func (d *Database) GetTraders(userID string) ([]*TraderRecord, error) {
 			&trader.BTCETHLeverage, &trader.AltcoinLeverage, &trader.TradingSymbols,
 			&trader.UseCoinPool, &trader.UseOITop,
 			&trader.CustomPrompt, &trader.OverrideBasePrompt, &trader.SystemPromptTemplate,
-			&trader.IsCrossMargin,
+			&trader.DecisionPipeline, &trader.Timeframes, &trader.UniverseFilters,
+			&trader.SymbolBlacklist, &trader.SymbolWhitelist, &trader.StopLossCooldown, &trader.IsCrossMargin,
 			&trader.CreatedAt, &trader.UpdatedAt,
 		)
 		if err != nil {
@@ -838,12 +1003,14 @@ func (d *Database) UpdateTrader(trader *TraderRecord) error {
 			name = ?, ai_model_id = ?, exchange_id = ?, initial_balance = ?,
 			scan_interval_minutes = ?, btc_eth_leverage = ?, altcoin_leverage = ?,
 			trading_symbols = ?, custom_prompt = ?, override_base_prompt = ?,
-			system_prompt_template = ?, is_cross_margin = ?, updated_at = CURRENT_TIMESTAMP
+			system_prompt_template = ?, decision_pipeline = ?, timeframes = ?, universe_filters = ?,
+			symbol_blacklist = ?, symbol_whitelist = ?, stop_loss_cooldown_minutes = ?, is_cross_margin = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ? AND user_id = ?
 	`, trader.Name, trader.AIModelID, trader.ExchangeID, trader.InitialBalance,
 		trader.ScanIntervalMinutes, trader.BTCETHLeverage, trader.AltcoinLeverage,
 		trader.TradingSymbols, trader.CustomPrompt, trader.OverrideBasePrompt,
-		trader.SystemPromptTemplate, trader.IsCrossMargin, trader.ID, trader.UserID)
+		trader.SystemPromptTemplate, trader.DecisionPipeline, trader.Timeframes, trader.UniverseFilters,
+		trader.SymbolBlacklist, trader.SymbolWhitelist, trader.StopLossCooldown, trader.IsCrossMargin, trader.ID, trader.UserID)
 	return err
 }
 
@@ -853,6 +1020,65 @@ func (d *Database) UpdateTraderCustomPrompt(userID, id string, customPrompt stri
 	return err
 }
 
+// UpdateTraderSymbolRules 更新交易员的币种黑白名单和止损冷却时长
+func (d *Database) UpdateTraderSymbolRules(userID, id, blacklist, whitelist string, stopLossCooldown int) error {
+	_, err := d.db.Exec(`
+		UPDATE traders SET symbol_blacklist = ?, symbol_whitelist = ?, stop_loss_cooldown_minutes = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND user_id = ?
+	`, blacklist, whitelist, stopLossCooldown, id, userID)
+	return err
+}
+
+// SetSymbolCooldown 设置交易员的币种冷却期（同一币种覆盖旧记录）
+func (d *Database) SetSymbolCooldown(traderID, symbol string, until time.Time, reason string) error {
+	_, err := d.db.Exec(`
+		INSERT OR REPLACE INTO trader_symbol_cooldowns (trader_id, symbol, until, reason, created_at)
+		VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`, traderID, symbol, until.UTC(), reason)
+	return err
+}
+
+// DeleteSymbolCooldown 解除交易员的币种冷却期
+func (d *Database) DeleteSymbolCooldown(traderID, symbol string) error {
+	_, err := d.db.Exec(`DELETE FROM trader_symbol_cooldowns WHERE trader_id = ? AND symbol = ?`, traderID, symbol)
+	return err
+}
+
+// GetSymbolCooldowns 获取交易员未到期的币种冷却期（同时清理已到期的记录）
+func (d *Database) GetSymbolCooldowns(traderID string) ([]*SymbolCooldown, error) {
+	rows, err := d.db.Query(`
+		SELECT trader_id, symbol, until, COALESCE(reason, ''), created_at
+		FROM trader_symbol_cooldowns WHERE trader_id = ? ORDER BY until
+	`, traderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cooldowns []*SymbolCooldown
+	var expired []string
+	for rows.Next() {
+		var cooldown SymbolCooldown
+		if err := rows.Scan(&cooldown.TraderID, &cooldown.Symbol, &cooldown.Until, &cooldown.Reason, &cooldown.CreatedAt); err != nil {
+			return nil, err
+		}
+		if time.Now().After(cooldown.Until) {
+			expired = append(expired, cooldown.Symbol)
+			continue
+		}
+		cooldowns = append(cooldowns, &cooldown)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for _, symbol := range expired {
+		d.DeleteSymbolCooldown(traderID, symbol)
+	}
+	return cooldowns, nil
+}
+
 // DeleteTrader 删除交易员
 func (d *Database) DeleteTrader(userID, id string) error {
 	_, err := d.db.Exec(`DELETE FROM traders WHERE id = ? AND user_id = ?`, id, userID)
@@ -868,6 +1094,11 @@ func (d *Database) GetTraderConfig(userID, traderID string) (*TraderRecord, *AIM
 	err := d.db.QueryRow(`
 		SELECT 
 			t.id, t.user_id, t.name, t.ai_model_id, t.exchange_id, t.initial_balance, t.scan_interval_minutes, t.is_running, t.created_at, t.updated_at,
+			COALESCE(t.decision_pipeline, '') as decision_pipeline,
+			COALESCE(t.timeframes, '') as timeframes,
+			COALESCE(t.universe_filters, '') as universe_filters,
+			COALESCE(t.symbol_blacklist, '') as symbol_blacklist, COALESCE(t.symbol_whitelist, '') as symbol_whitelist,
+			COALESCE(t.stop_loss_cooldown_minutes, 0) as stop_loss_cooldown_minutes,
 			a.id, a.user_id, a.name, a.provider, a.enabled, a.api_key, a.created_at, a.updated_at,
 			e.id, e.user_id, e.name, e.type, e.enabled, e.api_key, e.secret_key, e.testnet,
 			COALESCE(e.hyperliquid_wallet_addr, '') as hyperliquid_wallet_addr,
@@ -883,6 +1114,8 @@ func (d *Database) GetTraderConfig(userID, traderID string) (*TraderRecord, *AIM
 		&trader.ID, &trader.UserID, &trader.Name, &trader.AIModelID, &trader.ExchangeID,
 		&trader.InitialBalance, &trader.ScanIntervalMinutes, &trader.IsRunning,
 		&trader.CreatedAt, &trader.UpdatedAt,
+		&trader.DecisionPipeline, &trader.Timeframes, &trader.UniverseFilters,
+		&trader.SymbolBlacklist, &trader.SymbolWhitelist, &trader.StopLossCooldown,
 		&aiModel.ID, &aiModel.UserID, &aiModel.Name, &aiModel.Provider, &aiModel.Enabled, &aiModel.APIKey,
 		&aiModel.CreatedAt, &aiModel.UpdatedAt,
 		&exchange.ID, &exchange.UserID, &exchange.Name, &exchange.Type, &exchange.Enabled,
@@ -0,0 +1,583 @@
+package config
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"nofx/logger"
+	"time"
+)
+
+// DecisionStore 单个交易员的决策记录SQLite存储（实现 logger.DecisionStore）
+// 动作和账户快照单独建表并建立索引，其余字段（提示词、思维链、持仓等）以JSON保存在 decision_records.record_json
+type DecisionStore struct {
+	db       *Database
+	traderID string
+}
+
+// DecisionStore 获取交易员的决策记录存储
+func (d *Database) DecisionStore(traderID string) *DecisionStore {
+	return &DecisionStore{db: d, traderID: traderID}
+}
+
+// Save 保存一条决策记录（同一交易员、时间、周期的记录已存在时跳过）
+func (s *DecisionStore) Save(record *logger.DecisionRecord) error {
+	_, err := s.insert(record)
+	return err
+}
+
+// insert 在一个事务中写入记录、动作和账户快照，返回是否新写入
+func (s *DecisionStore) insert(record *logger.DecisionRecord) (bool, error) {
+	data, err := recordJSON(record)
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := s.db.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	timestamp := record.Timestamp.UTC()
+	result, err := tx.Exec(`
+		INSERT OR IGNORE INTO decision_records (trader_id, cycle_number, timestamp, success, error_message, record_json, compacted)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, s.traderID, record.CycleNumber, timestamp, record.Success, record.ErrorMessage, data, record.Compacted)
+	if err != nil {
+		return false, fmt.Errorf("写入决策记录失败: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	recordID, err := result.LastInsertId()
+	if err != nil {
+		return false, err
+	}
+
+	for i, action := range record.Decisions {
+		_, err := tx.Exec(`
+			INSERT INTO decision_actions (record_id, trader_id, seq, action, symbol, quantity, leverage, price,
+				order_id, timestamp, success, error, estimated_slippage_pct, trade_id)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, recordID, s.traderID, i, action.Action, action.Symbol, action.Quantity, action.Leverage, action.Price,
+			action.OrderID, action.Timestamp.UTC(), action.Success, action.Error, action.EstimatedSlippagePct, action.TradeID)
+		if err != nil {
+			return false, fmt.Errorf("写入决策动作失败: %w", err)
+		}
+	}
+
+	account := record.AccountState
+	_, err = tx.Exec(`
+		INSERT INTO account_snapshots (record_id, trader_id, timestamp, total_balance, available_balance,
+			total_unrealized_profit, position_count, margin_used_pct)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, recordID, s.traderID, timestamp, account.TotalBalance, account.AvailableBalance,
+		account.TotalUnrealizedProfit, account.PositionCount, account.MarginUsedPct)
+	if err != nil {
+		return false, fmt.Errorf("写入账户快照失败: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// recordJSON record_json 列的内容：动作和账户快照已单独建表，不再重复保存
+func recordJSON(record *logger.DecisionRecord) (string, error) {
+	rest := *record
+	rest.Decisions = nil
+	rest.AccountState = logger.AccountSnapshot{}
+	data, err := json.Marshal(&rest)
+	if err != nil {
+		return "", fmt.Errorf("序列化决策记录失败: %w", err)
+	}
+	return string(data), nil
+}
+
+// Latest 最近n条记录（按时间正序：从旧到新）
+func (s *DecisionStore) Latest(n int) ([]*logger.DecisionRecord, error) {
+	records, err := s.queryRecords(`WHERE r.trader_id = ? ORDER BY r.timestamp DESC, r.id DESC LIMIT ?`, s.traderID, n)
+	if err != nil {
+		return nil, err
+	}
+	reverseRecords(records)
+	return records, nil
+}
+
+// ByDate 指定日期（本地时区）的所有记录
+func (s *DecisionStore) ByDate(date time.Time) ([]*logger.DecisionRecord, error) {
+	start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.Local)
+	return s.queryRecords(`WHERE r.trader_id = ? AND r.timestamp >= ? AND r.timestamp < ? ORDER BY r.timestamp, r.id`,
+		s.traderID, start.UTC(), start.AddDate(0, 0, 1).UTC())
+}
+
+// AccountHistory 最近n个周期的账户快照（按时间正序），只读取快照表
+func (s *DecisionStore) AccountHistory(n int) ([]*logger.DecisionRecord, error) {
+	records, err := s.queryAccountHistory(`WHERE s.trader_id = ? ORDER BY s.timestamp DESC, s.record_id DESC LIMIT ?`, s.traderID, n)
+	if err != nil {
+		return nil, err
+	}
+	reverseRecords(records)
+	return records, nil
+}
+
+// AccountHistoryBetween [start, end] 内的账户快照（按时间正序）
+func (s *DecisionStore) AccountHistoryBetween(start, end time.Time) ([]*logger.DecisionRecord, error) {
+	return s.queryAccountHistory(`WHERE s.trader_id = ? AND s.timestamp >= ? AND s.timestamp <= ? ORDER BY s.timestamp, s.record_id`,
+		s.traderID, start.UTC(), end.UTC())
+}
+
+// walkBatchSize Walk 每次从数据库读取的记录数
+const walkBatchSize = 200
+
+// Walk 按时间正序分批读取 [start, end] 内的完整记录
+func (s *DecisionStore) Walk(start, end time.Time, fn func(*logger.DecisionRecord) error) error {
+	for offset := 0; ; offset += walkBatchSize {
+		records, err := s.queryRecords(`WHERE r.trader_id = ? AND r.timestamp >= ? AND r.timestamp <= ? ORDER BY r.timestamp, r.id LIMIT ? OFFSET ?`,
+			s.traderID, start.UTC(), end.UTC(), walkBatchSize, offset)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+		if len(records) < walkBatchSize {
+			return nil
+		}
+	}
+}
+
+func (s *DecisionStore) queryAccountHistory(where string, args ...interface{}) ([]*logger.DecisionRecord, error) {
+	rows, err := s.db.db.Query(`
+		SELECT r.cycle_number, s.timestamp, s.total_balance, s.available_balance,
+			s.total_unrealized_profit, s.position_count, s.margin_used_pct
+		FROM account_snapshots s JOIN decision_records r ON r.id = s.record_id
+		`+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询账户快照失败: %w", err)
+	}
+	defer rows.Close()
+
+	var records []*logger.DecisionRecord
+	for rows.Next() {
+		var record logger.DecisionRecord
+		account := &record.AccountState
+		if err := rows.Scan(&record.CycleNumber, &record.Timestamp, &account.TotalBalance, &account.AvailableBalance,
+			&account.TotalUnrealizedProfit, &account.PositionCount, &account.MarginUsedPct); err != nil {
+			return nil, err
+		}
+		record.Timestamp = record.Timestamp.Local()
+		records = append(records, &record)
+	}
+	return records, rows.Err()
+}
+
+// DeleteBefore 删除 cutoff 之前的记录及其动作和账户快照
+func (s *DecisionStore) DeleteBefore(cutoff time.Time) (int, error) {
+	tx, err := s.db.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	n, err := s.deleteBefore(tx, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (s *DecisionStore) deleteBefore(tx *sql.Tx, cutoff time.Time) (int, error) {
+	cutoff = cutoff.UTC()
+	for _, query := range []string{
+		`DELETE FROM decision_actions WHERE record_id IN (SELECT id FROM decision_records WHERE trader_id = ? AND timestamp < ?)`,
+		`DELETE FROM account_snapshots WHERE record_id IN (SELECT id FROM decision_records WHERE trader_id = ? AND timestamp < ?)`,
+	} {
+		if _, err := tx.Exec(query, s.traderID, cutoff); err != nil {
+			return 0, fmt.Errorf("删除旧记录失败: %w", err)
+		}
+	}
+	result, err := tx.Exec(`DELETE FROM decision_records WHERE trader_id = ? AND timestamp < ?`, s.traderID, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("删除旧记录失败: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
+
+// Statistics 全部记录的统计信息
+func (s *DecisionStore) Statistics() (*logger.Statistics, error) {
+	stats := &logger.Statistics{}
+	err := s.db.db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(CASE WHEN success THEN 1 ELSE 0 END), 0)
+		FROM decision_records WHERE trader_id = ? AND aggregated = 0
+	`, s.traderID).Scan(&stats.TotalCycles, &stats.SuccessfulCycles)
+	if err != nil {
+		return nil, fmt.Errorf("统计决策记录失败: %w", err)
+	}
+	stats.FailedCycles = stats.TotalCycles - stats.SuccessfulCycles
+
+	err = s.db.db.QueryRow(`
+		SELECT COALESCE(SUM(CASE WHEN action IN ('open_long', 'open_short') THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN action IN ('close_long', 'close_short') THEN 1 ELSE 0 END), 0)
+		FROM decision_actions a JOIN decision_records r ON r.id = a.record_id
+		WHERE a.trader_id = ? AND a.success AND r.aggregated = 0
+	`, s.traderID).Scan(&stats.TotalOpenPositions, &stats.TotalClosePositions)
+	if err != nil {
+		return nil, fmt.Errorf("统计决策动作失败: %w", err)
+	}
+
+	// 已汇总的记录
+	daily, err := s.DailyStats()
+	if err != nil {
+		return nil, err
+	}
+	for _, day := range daily {
+		stats.AddDaily(day)
+	}
+	return stats, nil
+}
+
+// Compact 分批压缩 before 之前尚未压缩的记录（完整记录先交给 archive）
+func (s *DecisionStore) Compact(before time.Time, archive func([]*logger.DecisionRecord) error) (int, error) {
+	compacted := 0
+	for {
+		records, err := s.queryRecords(`WHERE r.trader_id = ? AND r.compacted = 0 AND r.timestamp < ? ORDER BY r.timestamp, r.id LIMIT ?`,
+			s.traderID, before.UTC(), walkBatchSize)
+		if err != nil {
+			return compacted, err
+		}
+		if len(records) == 0 {
+			return compacted, nil
+		}
+		if err := archive(records); err != nil {
+			return compacted, err
+		}
+		if err := s.replaceCompacted(records); err != nil {
+			return compacted, err
+		}
+		compacted += len(records)
+	}
+}
+
+// replaceCompacted 在一个事务中把一批记录替换为压缩后的记录
+func (s *DecisionStore) replaceCompacted(records []*logger.DecisionRecord) error {
+	tx, err := s.db.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, record := range records {
+		data, err := recordJSON(logger.CompactRecord(record))
+		if err != nil {
+			return err
+		}
+		result, err := tx.Exec(`
+			UPDATE decision_records SET record_json = ?, compacted = 1
+			WHERE trader_id = ? AND timestamp = ? AND cycle_number = ?
+		`, data, s.traderID, record.Timestamp.UTC(), record.CycleNumber)
+		if err != nil {
+			return fmt.Errorf("压缩决策记录失败: %w", err)
+		}
+		// 没有更新到记录时下一批会读到同一条记录，直接报错避免死循环
+		if n, _ := result.RowsAffected(); n == 0 {
+			return fmt.Errorf("压缩决策记录失败: 找不到周期 #%d (%s)", record.CycleNumber, record.Timestamp.Format("2006-01-02 15:04:05"))
+		}
+	}
+	return tx.Commit()
+}
+
+// Aggregate 在一个事务中合并每日统计，并把 before 之前尚未汇总的记录精简为只剩账户快照和动作（快照和动作表不变）
+func (s *DecisionStore) Aggregate(before time.Time, stats []*logger.DailyStats) (int, error) {
+	existing, err := s.DailyStats()
+	if err != nil {
+		return 0, err
+	}
+	existingByDate := make(map[string]*logger.DailyStats, len(existing))
+	for _, day := range existing {
+		existingByDate[day.Date] = day
+	}
+
+	tx, err := s.db.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	for _, day := range stats {
+		merged := *day
+		if current, ok := existingByDate[day.Date]; ok {
+			merged = *current
+			merged.Merge(day)
+		}
+		_, err := tx.Exec(`
+			INSERT OR REPLACE INTO decision_daily_stats (trader_id, date, cycles, successful_cycles, open_positions, close_positions,
+				first_time, last_time, start_equity, end_equity, min_equity, max_equity)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, s.traderID, merged.Date, merged.Cycles, merged.SuccessfulCycles, merged.OpenPositions, merged.ClosePositions,
+			merged.FirstTime.UTC(), merged.LastTime.UTC(), merged.StartEquity, merged.EndEquity, merged.MinEquity, merged.MaxEquity)
+		if err != nil {
+			return 0, fmt.Errorf("保存每日统计失败: %w", err)
+		}
+	}
+
+	n, err := s.shrinkAggregated(tx, before)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// shrinkAggregated 分批把 before 之前尚未汇总的记录替换为 AggregatedRecord 的结果，返回精简条数
+func (s *DecisionStore) shrinkAggregated(tx *sql.Tx, before time.Time) (int, error) {
+	type row struct {
+		id   int64
+		data string
+	}
+	aggregated := 0
+	for {
+		rows, err := tx.Query(`
+			SELECT id, record_json FROM decision_records
+			WHERE trader_id = ? AND aggregated = 0 AND timestamp < ? ORDER BY timestamp, id LIMIT ?
+		`, s.traderID, before.UTC(), walkBatchSize)
+		if err != nil {
+			return aggregated, fmt.Errorf("查询待汇总记录失败: %w", err)
+		}
+		var batch []row
+		for rows.Next() {
+			var r row
+			if err := rows.Scan(&r.id, &r.data); err != nil {
+				rows.Close()
+				return aggregated, err
+			}
+			batch = append(batch, r)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return aggregated, err
+		}
+		if len(batch) == 0 {
+			return aggregated, nil
+		}
+
+		for _, r := range batch {
+			// 动作和账户快照在单独的表中，record_json 本来就不包含
+			var record logger.DecisionRecord
+			if err := json.Unmarshal([]byte(r.data), &record); err != nil {
+				record = logger.DecisionRecord{}
+			}
+			data, err := recordJSON(logger.AggregatedRecord(&record))
+			if err != nil {
+				return aggregated, err
+			}
+			if _, err := tx.Exec(`UPDATE decision_records SET record_json = ?, compacted = 1, aggregated = 1 WHERE id = ?`, data, r.id); err != nil {
+				return aggregated, fmt.Errorf("精简已汇总记录失败: %w", err)
+			}
+			aggregated++
+		}
+	}
+}
+
+// DailyStats 已汇总的每日统计（按日期正序）
+func (s *DecisionStore) DailyStats() ([]*logger.DailyStats, error) {
+	rows, err := s.db.db.Query(`
+		SELECT date, cycles, successful_cycles, open_positions, close_positions, first_time, last_time,
+			start_equity, end_equity, min_equity, max_equity
+		FROM decision_daily_stats WHERE trader_id = ? ORDER BY date
+	`, s.traderID)
+	if err != nil {
+		return nil, fmt.Errorf("查询每日统计失败: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []*logger.DailyStats
+	for rows.Next() {
+		var day logger.DailyStats
+		if err := rows.Scan(&day.Date, &day.Cycles, &day.SuccessfulCycles, &day.OpenPositions, &day.ClosePositions,
+			&day.FirstTime, &day.LastTime, &day.StartEquity, &day.EndEquity, &day.MinEquity, &day.MaxEquity); err != nil {
+			return nil, err
+		}
+		day.FirstTime, day.LastTime = day.FirstTime.Local(), day.LastTime.Local()
+		stats = append(stats, &day)
+	}
+	return stats, rows.Err()
+}
+
+// actionRowBytes、snapshotRowBytes 动作和账户快照每行的估算占用（用于存储统计）
+const (
+	actionRowBytes   = 160
+	snapshotRowBytes = 80
+)
+
+// Usage 记录的存储占用：record_json 大小加上动作和账户快照的估算大小
+func (s *DecisionStore) Usage() (*logger.StorageUsage, error) {
+	usage := &logger.StorageUsage{}
+	err := s.db.db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(CASE WHEN compacted THEN 1 ELSE 0 END), 0), COALESCE(SUM(LENGTH(record_json)), 0)
+		FROM decision_records WHERE trader_id = ?
+	`, s.traderID).Scan(&usage.Records, &usage.CompactedRecords, &usage.RecordBytes)
+	if err != nil {
+		return nil, fmt.Errorf("统计决策记录占用失败: %w", err)
+	}
+
+	var actions int64
+	if err := s.db.db.QueryRow(`SELECT COUNT(*) FROM decision_actions WHERE trader_id = ?`, s.traderID).Scan(&actions); err != nil {
+		return nil, fmt.Errorf("统计决策动作占用失败: %w", err)
+	}
+	usage.RecordBytes += actions*actionRowBytes + int64(usage.Records)*snapshotRowBytes
+
+	if err := s.db.db.QueryRow(`SELECT COUNT(*) FROM decision_daily_stats WHERE trader_id = ?`, s.traderID).Scan(&usage.AggregatedDays); err != nil {
+		return nil, fmt.Errorf("统计每日汇总失败: %w", err)
+	}
+
+	if usage.Records > 0 {
+		err := s.db.db.QueryRow(`SELECT timestamp FROM decision_records WHERE trader_id = ? ORDER BY timestamp LIMIT 1`, s.traderID).Scan(&usage.OldestRecord)
+		if err != nil {
+			return nil, fmt.Errorf("查询最早的决策记录失败: %w", err)
+		}
+		usage.OldestRecord = usage.OldestRecord.Local()
+	}
+	return usage, nil
+}
+
+// LastCycleNumber 已保存的最大周期编号
+func (s *DecisionStore) LastCycleNumber() (int, error) {
+	var cycle int
+	err := s.db.db.QueryRow(`SELECT COALESCE(MAX(cycle_number), 0) FROM decision_records WHERE trader_id = ?`, s.traderID).Scan(&cycle)
+	return cycle, err
+}
+
+// Count 已保存的记录数
+func (s *DecisionStore) Count() (int, error) {
+	var count int
+	err := s.db.db.QueryRow(`SELECT COUNT(*) FROM decision_records WHERE trader_id = ?`, s.traderID).Scan(&count)
+	return count, err
+}
+
+// ImportDir 导入旧版 decision_logs/<trader_id> 目录下的JSON决策记录，已导入过的记录会被跳过
+// 返回新导入的记录数
+func (s *DecisionStore) ImportDir(dir string) (int, error) {
+	records, err := logger.ReadDecisionLogDir(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	imported := 0
+	for _, record := range records {
+		inserted, err := s.insert(record)
+		if err != nil {
+			return imported, fmt.Errorf("导入 %s 周期 #%d 失败: %w", record.Timestamp.Format("2006-01-02 15:04:05"), record.CycleNumber, err)
+		}
+		if inserted {
+			imported++
+		}
+	}
+	return imported, nil
+}
+
+// queryRecords 按条件查询记录，并补全动作和账户快照
+func (s *DecisionStore) queryRecords(where string, args ...interface{}) ([]*logger.DecisionRecord, error) {
+	rows, err := s.db.db.Query(`
+		SELECT r.id, r.record_json, r.cycle_number, r.timestamp,
+			COALESCE(s.total_balance, 0), COALESCE(s.available_balance, 0), COALESCE(s.total_unrealized_profit, 0),
+			COALESCE(s.position_count, 0), COALESCE(s.margin_used_pct, 0)
+		FROM decision_records r LEFT JOIN account_snapshots s ON s.record_id = r.id
+	`+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询决策记录失败: %w", err)
+	}
+	defer rows.Close()
+
+	var records []*logger.DecisionRecord
+	byID := make(map[int64]*logger.DecisionRecord)
+	var minID, maxID int64
+	for rows.Next() {
+		var id int64
+		var data string
+		var cycle int
+		var timestamp time.Time
+		var account logger.AccountSnapshot
+		if err := rows.Scan(&id, &data, &cycle, &timestamp, &account.TotalBalance, &account.AvailableBalance,
+			&account.TotalUnrealizedProfit, &account.PositionCount, &account.MarginUsedPct); err != nil {
+			return nil, err
+		}
+
+		var record logger.DecisionRecord
+		if err := json.Unmarshal([]byte(data), &record); err != nil {
+			continue
+		}
+		record.CycleNumber = cycle
+		record.Timestamp = timestamp.Local()
+		record.AccountState = account
+
+		records = append(records, &record)
+		byID[id] = &record
+		if minID == 0 || id < minID {
+			minID = id
+		}
+		if id > maxID {
+			maxID = id
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if len(byID) == 0 {
+		return records, nil
+	}
+	if err := s.loadActions(byID, minID, maxID); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// loadActions 按 record_id 区间批量读取动作（避免 IN 子句参数过多）
+func (s *DecisionStore) loadActions(byID map[int64]*logger.DecisionRecord, minID, maxID int64) error {
+	rows, err := s.db.db.Query(`
+		SELECT record_id, action, symbol, quantity, leverage, price, order_id, timestamp, success,
+			COALESCE(error, ''), estimated_slippage_pct, COALESCE(trade_id, 0)
+		FROM decision_actions WHERE trader_id = ? AND record_id BETWEEN ? AND ?
+		ORDER BY record_id, seq
+	`, s.traderID, minID, maxID)
+	if err != nil {
+		return fmt.Errorf("查询决策动作失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var recordID int64
+		var action logger.DecisionAction
+		if err := rows.Scan(&recordID, &action.Action, &action.Symbol, &action.Quantity, &action.Leverage, &action.Price,
+			&action.OrderID, &action.Timestamp, &action.Success, &action.Error, &action.EstimatedSlippagePct, &action.TradeID); err != nil {
+			return err
+		}
+		record, ok := byID[recordID]
+		if !ok {
+			continue
+		}
+		action.Timestamp = action.Timestamp.Local()
+		record.Decisions = append(record.Decisions, action)
+	}
+	return rows.Err()
+}
+
+func reverseRecords(records []*logger.DecisionRecord) {
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+}
+
+var _ logger.DecisionStore = (*DecisionStore)(nil)
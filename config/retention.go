@@ -0,0 +1,106 @@
+package config
+
+import (
+	"database/sql"
+	"fmt"
+	"nofx/logger"
+	"path/filepath"
+	"strconv"
+)
+
+// RetentionOverride 交易员单独设置的决策日志保留天数（nil 表示使用全局默认值）
+type RetentionOverride struct {
+	FullDays    *int `json:"full_days"`
+	CompactDays *int `json:"compact_days"`
+}
+
+// DefaultRetentionPolicy 全局默认的决策日志保留策略（system_config）
+func (d *Database) DefaultRetentionPolicy() logger.RetentionPolicy {
+	return logger.RetentionPolicy{
+		FullDays:    d.systemConfigInt("decision_full_retention_days", 7),
+		CompactDays: d.systemConfigInt("decision_compact_retention_days", 90),
+	}
+}
+
+// GetRetentionOverride 获取交易员单独设置的保留天数（没有设置时字段均为 nil）
+func (d *Database) GetRetentionOverride(traderID string) (*RetentionOverride, error) {
+	var fullDays, compactDays sql.NullInt64
+	err := d.db.QueryRow(`SELECT full_days, compact_days FROM decision_retention WHERE trader_id = ?`, traderID).Scan(&fullDays, &compactDays)
+	if err == sql.ErrNoRows {
+		return &RetentionOverride{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询保留策略失败: %w", err)
+	}
+
+	override := &RetentionOverride{}
+	if fullDays.Valid {
+		v := int(fullDays.Int64)
+		override.FullDays = &v
+	}
+	if compactDays.Valid {
+		v := int(compactDays.Int64)
+		override.CompactDays = &v
+	}
+	return override, nil
+}
+
+// SetRetentionOverride 设置交易员单独的保留天数（字段为 nil 时恢复使用全局默认值）
+func (d *Database) SetRetentionOverride(traderID string, override *RetentionOverride) error {
+	if override.FullDays == nil && override.CompactDays == nil {
+		_, err := d.db.Exec(`DELETE FROM decision_retention WHERE trader_id = ?`, traderID)
+		return err
+	}
+	_, err := d.db.Exec(`
+		INSERT OR REPLACE INTO decision_retention (trader_id, full_days, compact_days) VALUES (?, ?, ?)
+	`, traderID, override.FullDays, override.CompactDays)
+	return err
+}
+
+// RetentionPolicy 交易员实际生效的保留策略（单独设置覆盖全局默认值）
+func (d *Database) RetentionPolicy(traderID string) (logger.RetentionPolicy, error) {
+	policy := d.DefaultRetentionPolicy()
+	override, err := d.GetRetentionOverride(traderID)
+	if err != nil {
+		return policy, err
+	}
+	return override.Apply(policy), nil
+}
+
+// Apply 用单独设置覆盖 policy 中对应的天数
+func (o *RetentionOverride) Apply(policy logger.RetentionPolicy) logger.RetentionPolicy {
+	if o.FullDays != nil {
+		policy.FullDays = *o.FullDays
+	}
+	if o.CompactDays != nil {
+		policy.CompactDays = *o.CompactDays
+	}
+	return policy
+}
+
+// DecisionArchiveDir 交易员的决策记录gzip归档目录
+func (d *Database) DecisionArchiveDir(traderID string) string {
+	dir, _ := d.GetSystemConfig("decision_archive_dir")
+	if dir == "" {
+		dir = "decision_archive"
+	}
+	return filepath.Join(dir, traderID)
+}
+
+// Vacuum 回收已删除数据占用的空间（压缩、汇总大量记录后执行）
+func (d *Database) Vacuum() error {
+	_, err := d.db.Exec(`VACUUM`)
+	return err
+}
+
+func (d *Database) systemConfigInt(key string, defaultValue int) int {
+	value, err := d.GetSystemConfig(key)
+	if err != nil || value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return defaultValue
+	}
+	return n
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"database/sql"
+	"fmt"
+	"nofx/logger"
+	"time"
+)
+
+// TradeLedger 单个交易员的交易账本SQLite存储（实现 logger.TradeLedger）
+type TradeLedger struct {
+	db       *Database
+	traderID string
+}
+
+// TradeLedger 获取交易员的交易账本
+func (d *Database) TradeLedger(traderID string) *TradeLedger {
+	return &TradeLedger{db: d, traderID: traderID}
+}
+
+// OpenTrade 记录新开仓
+func (l *TradeLedger) OpenTrade(trade *logger.Trade) error {
+	result, err := l.db.db.Exec(`
+		INSERT INTO trades (trader_id, symbol, side, status, quantity, leverage, entry_price, entry_time, entry_order_id,
+			exit_price, exit_time, exit_order_id, exit_reason, stop_loss, take_profit, fees, funding, realized_pnl, net_pnl,
+			open_source, open_cycle, close_cycle)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, l.traderID, trade.Symbol, trade.Side, trade.Status, trade.Quantity, trade.Leverage, trade.EntryPrice,
+		trade.EntryTime.UTC(), trade.EntryOrderID, trade.ExitPrice, nullTime(trade.ExitTime), trade.ExitOrderID,
+		trade.ExitReason, trade.StopLoss, trade.TakeProfit, trade.Fees, trade.Funding, trade.RealizedPnL, trade.NetPnL,
+		trade.OpenSource, trade.OpenCycle, trade.CloseCycle)
+	if err != nil {
+		return fmt.Errorf("写入交易失败: %w", err)
+	}
+	trade.ID, err = result.LastInsertId()
+	return err
+}
+
+// UpdateTrade 更新交易
+func (l *TradeLedger) UpdateTrade(trade *logger.Trade) error {
+	_, err := l.db.db.Exec(`
+		UPDATE trades SET status = ?, quantity = ?, leverage = ?, entry_price = ?, entry_time = ?, entry_order_id = ?,
+			exit_price = ?, exit_time = ?, exit_order_id = ?, exit_reason = ?, stop_loss = ?, take_profit = ?,
+			fees = ?, funding = ?, realized_pnl = ?, net_pnl = ?, open_cycle = ?, close_cycle = ?,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND trader_id = ?
+	`, trade.Status, trade.Quantity, trade.Leverage, trade.EntryPrice, trade.EntryTime.UTC(), trade.EntryOrderID,
+		trade.ExitPrice, nullTime(trade.ExitTime), trade.ExitOrderID, trade.ExitReason, trade.StopLoss, trade.TakeProfit,
+		trade.Fees, trade.Funding, trade.RealizedPnL, trade.NetPnL, trade.OpenCycle, trade.CloseCycle,
+		trade.ID, l.traderID)
+	if err != nil {
+		return fmt.Errorf("更新交易 #%d 失败: %w", trade.ID, err)
+	}
+	return nil
+}
+
+// LinkDecision 关联交易与决策周期
+func (l *TradeLedger) LinkDecision(tradeID int64, cycle int, open bool) error {
+	column := "close_cycle"
+	if open {
+		column = "open_cycle"
+	}
+	_, err := l.db.db.Exec(`UPDATE trades SET `+column+` = ? WHERE id = ? AND trader_id = ?`, cycle, tradeID, l.traderID)
+	return err
+}
+
+// OpenTrades 所有未平仓交易
+func (l *TradeLedger) OpenTrades() ([]*logger.Trade, error) {
+	return l.queryTrades(`WHERE trader_id = ? AND status = ? ORDER BY entry_time`, l.traderID, logger.TradeOpen)
+}
+
+// ClosedTrades since 之后平仓的交易（按平仓时间正序），limit>0 时只返回最近 limit 笔
+func (l *TradeLedger) ClosedTrades(since time.Time, limit int) ([]*logger.Trade, error) {
+	if limit <= 0 {
+		return l.queryTrades(`WHERE trader_id = ? AND status = ? AND exit_time >= ? ORDER BY exit_time, id`,
+			l.traderID, logger.TradeClosed, since.UTC())
+	}
+	trades, err := l.queryTrades(`WHERE trader_id = ? AND status = ? AND exit_time >= ? ORDER BY exit_time DESC, id DESC LIMIT ?`,
+		l.traderID, logger.TradeClosed, since.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(trades)-1; i < j; i, j = i+1, j-1 {
+		trades[i], trades[j] = trades[j], trades[i]
+	}
+	return trades, nil
+}
+
+// Count 交易总数
+func (l *TradeLedger) Count() (int, error) {
+	var count int
+	err := l.db.db.QueryRow(`SELECT COUNT(*) FROM trades WHERE trader_id = ?`, l.traderID).Scan(&count)
+	return count, err
+}
+
+func (l *TradeLedger) queryTrades(where string, args ...interface{}) ([]*logger.Trade, error) {
+	rows, err := l.db.db.Query(`
+		SELECT id, symbol, side, status, quantity, leverage, entry_price, entry_time, entry_order_id,
+			exit_price, exit_time, exit_order_id, COALESCE(exit_reason, ''), stop_loss, take_profit,
+			fees, funding, realized_pnl, net_pnl, COALESCE(open_source, ''), open_cycle, close_cycle
+		FROM trades `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询交易失败: %w", err)
+	}
+	defer rows.Close()
+
+	var trades []*logger.Trade
+	for rows.Next() {
+		var trade logger.Trade
+		var exitTime sql.NullTime
+		if err := rows.Scan(&trade.ID, &trade.Symbol, &trade.Side, &trade.Status, &trade.Quantity, &trade.Leverage,
+			&trade.EntryPrice, &trade.EntryTime, &trade.EntryOrderID, &trade.ExitPrice, &exitTime, &trade.ExitOrderID,
+			&trade.ExitReason, &trade.StopLoss, &trade.TakeProfit, &trade.Fees, &trade.Funding, &trade.RealizedPnL,
+			&trade.NetPnL, &trade.OpenSource, &trade.OpenCycle, &trade.CloseCycle); err != nil {
+			return nil, err
+		}
+		trade.EntryTime = trade.EntryTime.Local()
+		if exitTime.Valid {
+			trade.ExitTime = exitTime.Time.Local()
+		}
+		trades = append(trades, &trade)
+	}
+	return trades, rows.Err()
+}
+
+// nullTime 零值时间写入 NULL
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC()
+}
+
+var _ logger.TradeLedger = (*TradeLedger)(nil)
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"nofx/market"
+	"nofx/market/indicator"
 	"nofx/mcp"
 	"nofx/pool"
 	"strings"
@@ -40,7 +41,8 @@ type AccountInfo struct {
 // CandidateCoin 候选币种（来自币种池）
 type CandidateCoin struct {
 	Symbol  string   `json:"symbol"`
-	Sources []string `json:"sources"` // 来源: "ai500" 和/或 "oi_top"
+	Sources []string `json:"sources"`         // 来源: "ai500"、"oi_top"、"market_alerts" 等
+	Score   float64  `json:"score,omitempty"` // 币种池按信号源权重合并的综合评分
 }
 
 // OITopData 持仓量增长Top数据（用于AI决策参考）
@@ -66,6 +68,13 @@ type Context struct {
 	Performance     interface{}             `json:"-"` // 历史表现分析（logger.PerformanceAnalysis）
 	BTCETHLeverage  int                     `json:"-"` // BTC/ETH杠杆倍数（从配置读取）
 	AltcoinLeverage int                     `json:"-"` // 山寨币杠杆倍数（从配置读取）
+	Timeframes      []market.Timeframe      `json:"-"` // 额外需要的K线周期（交易员或模板声明）
+	Indicators      []indicator.Spec        `json:"-"` // 额外需要的技术指标（模板声明）
+	MarketProvider  market.Provider         `json:"-"` // 行情来源（与交易员所在交易所一致，为空时使用币安）
+	CoinPool        *pool.Pool              `json:"-"` // 交易员的币种池（为空时使用全局币种池）
+	MinOIValue      float64                 `json:"-"` // 候选币种持仓价值下限（USD），0 表示不过滤
+
+	SymbolCheck func(symbol string) error `json:"-"` // 开仓币种限制（黑白名单/冷却期），为空不检查
 }
 
 // Decision AI的交易决策
@@ -88,15 +97,20 @@ type FullDecision struct {
 	CoTTrace     string     `json:"cot_trace"`     // 思维链分析（AI输出）
 	Decisions    []Decision `json:"decisions"`     // 具体决策列表
 	Timestamp    time.Time  `json:"timestamp"`
+
+	CallStats *mcp.CallStats `json:"call_stats,omitempty"` // AI调用统计（排队等待、重试次数）
+
+	Pipeline string            `json:"pipeline,omitempty"` // 决策流程（为空表示单次调用）
+	Stages   []StageTranscript `json:"stages,omitempty"`   // 多阶段流程中每个阶段的记录
 }
 
 // GetFullDecision 获取AI的完整交易决策（批量分析所有币种和持仓）
-func GetFullDecision(ctx *Context, mcpClient *mcp.Client) (*FullDecision, error) {
+func GetFullDecision(ctx *Context, mcpClient mcp.AIClient) (*FullDecision, error) {
 	return GetFullDecisionWithCustomPrompt(ctx, mcpClient, "", false, "")
 }
 
 // GetFullDecisionWithCustomPrompt 获取AI的完整交易决策（支持自定义prompt和模板选择）
-func GetFullDecisionWithCustomPrompt(ctx *Context, mcpClient *mcp.Client, customPrompt string, overrideBase bool, templateName string) (*FullDecision, error) {
+func GetFullDecisionWithCustomPrompt(ctx *Context, mcpClient mcp.AIClient, customPrompt string, overrideBase bool, templateName string) (*FullDecision, error) {
 	// 1. 为所有币种获取市场数据
 	if err := fetchMarketDataForContext(ctx); err != nil {
 		return nil, fmt.Errorf("获取市场数据失败: %w", err)
@@ -107,13 +121,25 @@ func GetFullDecisionWithCustomPrompt(ctx *Context, mcpClient *mcp.Client, custom
 	userPrompt := buildUserPrompt(ctx)
 
 	// 3. 调用AI API（使用 system + user prompt）
-	aiResponse, err := mcpClient.CallWithMessages(systemPrompt, userPrompt)
+	aiResponse, callStats, err := mcpClient.CallWithMessagesStats(systemPrompt, userPrompt)
 	if err != nil {
-		return nil, fmt.Errorf("调用AI API失败: %w", err)
+		// 返回已构建的prompt和调用统计，便于记录与调试
+		return &FullDecision{
+			SystemPrompt: systemPrompt,
+			UserPrompt:   userPrompt,
+			Timestamp:    time.Now(),
+			CallStats:    callStats,
+		}, fmt.Errorf("调用AI API失败: %w", err)
 	}
 
 	// 4. 解析AI响应
 	decision, err := parseFullDecisionResponse(aiResponse, ctx.Account.TotalEquity, ctx.BTCETHLeverage, ctx.AltcoinLeverage)
+	if decision != nil {
+		decision.CallStats = callStats
+	}
+	if err == nil {
+		decision.Decisions = filterSymbolRestrictions(decision.Decisions, ctx.SymbolCheck)
+	}
 	if err != nil {
 		return decision, fmt.Errorf("解析AI响应失败: %w", err)
 	}
@@ -124,6 +150,25 @@ func GetFullDecisionWithCustomPrompt(ctx *Context, mcpClient *mcp.Client, custom
 	return decision, nil
 }
 
+// DecideFromPrompts 直接使用已构建好的prompt获取决策（不拉取行情）
+// 配合 mcp.ReplayClient 使用，可以离线、确定性地重跑决策日志中记录的历史周期
+func DecideFromPrompts(mcpClient mcp.AIClient, systemPrompt, userPrompt string, accountEquity float64, btcEthLeverage, altcoinLeverage int) (*FullDecision, error) {
+	aiResponse, callStats, err := mcpClient.CallWithMessagesStats(systemPrompt, userPrompt)
+	if err != nil {
+		return nil, fmt.Errorf("调用AI API失败: %w", err)
+	}
+
+	decision, err := parseFullDecisionResponse(aiResponse, accountEquity, btcEthLeverage, altcoinLeverage)
+	decision.SystemPrompt = systemPrompt
+	decision.UserPrompt = userPrompt
+	decision.Timestamp = time.Now()
+	decision.CallStats = callStats
+	if err != nil {
+		return decision, fmt.Errorf("解析AI响应失败: %w", err)
+	}
+	return decision, nil
+}
+
 // fetchMarketDataForContext 为上下文中的所有币种获取市场数据和OI数据
 func fetchMarketDataForContext(ctx *Context) error {
 	ctx.MarketDataMap = make(map[string]*market.Data)
@@ -154,23 +199,26 @@ func fetchMarketDataForContext(ctx *Context) error {
 	}
 
 	for symbol := range symbolSet {
-		data, err := market.Get(symbol)
+		data, err := market.GetWithOptions(symbol, market.Options{
+			Provider:   ctx.MarketProvider,
+			Timeframes: ctx.Timeframes,
+			Indicators: ctx.Indicators,
+		})
 		if err != nil {
 			// 单个币种失败不影响整体，只记录错误
 			continue
 		}
 
-		// ⚠️ 流动性过滤：持仓价值低于15M USD的币种不做（多空都不做）
+		// ⚠️ 流动性过滤：持仓价值低于 ctx.MinOIValue 的币种不做（多空都不做）
 		// 持仓价值 = 持仓量 × 当前价格
 		// 但现有持仓必须保留（需要决策是否平仓）
 		isExistingPosition := positionSymbols[symbol]
-		if !isExistingPosition && data.OpenInterest != nil && data.CurrentPrice > 0 {
+		if ctx.MinOIValue > 0 && !isExistingPosition && data.OpenInterest != nil && data.CurrentPrice > 0 {
 			// 计算持仓价值（USD）= 持仓量 × 当前价格
 			oiValue := data.OpenInterest.Latest * data.CurrentPrice
-			oiValueInMillions := oiValue / 1_000_000 // 转换为百万美元单位
-			if oiValueInMillions < 15 {
-				log.Printf("⚠️  %s 持仓价值过低(%.2fM USD < 15M)，跳过此币种 [持仓量:%.0f × 价格:%.4f]",
-					symbol, oiValueInMillions, data.OpenInterest.Latest, data.CurrentPrice)
+			if oiValue < ctx.MinOIValue {
+				log.Printf("⚠️  %s 持仓价值过低(%.2fM USD < %.2fM)，跳过此币种 [持仓量:%.0f × 价格:%.4f]",
+					symbol, oiValue/1_000_000, ctx.MinOIValue/1_000_000, data.OpenInterest.Latest, data.CurrentPrice)
 				continue
 			}
 		}
@@ -179,7 +227,11 @@ func fetchMarketDataForContext(ctx *Context) error {
 	}
 
 	// 加载OI Top数据（不影响主流程）
-	oiPositions, err := pool.GetOITopPositions()
+	coinPool := ctx.CoinPool
+	if coinPool == nil {
+		coinPool = pool.Default()
+	}
+	oiPositions, err := coinPool.GetOITopPositions()
 	if err == nil {
 		for _, pos := range oiPositions {
 			// 标准化符号匹配
@@ -261,14 +313,29 @@ func buildSystemPrompt(accountEquity float64, btcEthLeverage, altcoinLeverage in
 	}
 
 	// 2. 硬约束（风险控制）- 动态生成
+	sb.WriteString(buildHardConstraints(accountEquity, btcEthLeverage, altcoinLeverage))
+
+	// 3. 输出格式 - 动态生成
+	sb.WriteString(buildOutputFormat(accountEquity, btcEthLeverage))
+
+	return sb.String()
+}
+
+// buildHardConstraints 构建硬约束（风险控制）部分
+func buildHardConstraints(accountEquity float64, btcEthLeverage, altcoinLeverage int) string {
+	var sb strings.Builder
 	sb.WriteString("# 硬约束（风险控制）\n\n")
 	sb.WriteString("1. 风险回报比: 必须 ≥ 1:3（冒1%风险，赚3%+收益）\n")
 	sb.WriteString("2. 最多持仓: 3个币种（质量>数量）\n")
 	sb.WriteString(fmt.Sprintf("3. 单币仓位: 山寨%.0f-%.0f U(%dx杠杆) | BTC/ETH %.0f-%.0f U(%dx杠杆)\n",
 		accountEquity*0.8, accountEquity*1.5, altcoinLeverage, accountEquity*5, accountEquity*10, btcEthLeverage))
 	sb.WriteString("4. 保证金: 总使用率 ≤ 90%\n\n")
+	return sb.String()
+}
 
-	// 3. 输出格式 - 动态生成
+// buildOutputFormat 构建输出格式说明部分
+func buildOutputFormat(accountEquity float64, btcEthLeverage int) string {
+	var sb strings.Builder
 	sb.WriteString("#输出格式\n\n")
 	sb.WriteString("第一步: 思维链（纯文本）\n")
 	sb.WriteString("简洁分析你的思考过程\n\n")
@@ -281,7 +348,6 @@ func buildSystemPrompt(accountEquity float64, btcEthLeverage, altcoinLeverage in
 	sb.WriteString("- `action`: open_long | open_short | close_long | close_short | hold | wait\n")
 	sb.WriteString("- `confidence`: 0-100（开仓建议≥75）\n")
 	sb.WriteString("- 开仓时必填: leverage, position_size_usd, stop_loss, take_profit, confidence, risk_usd, reasoning\n\n")
-
 	return sb.String()
 }
 
@@ -301,36 +367,13 @@ func buildUserPrompt(ctx *Context) string {
 	}
 
 	// 账户
-	sb.WriteString(fmt.Sprintf("账户: 净值%.2f | 余额%.2f (%.1f%%) | 盈亏%+.2f%% | 保证金%.1f%% | 持仓%d个\n\n",
-		ctx.Account.TotalEquity,
-		ctx.Account.AvailableBalance,
-		(ctx.Account.AvailableBalance/ctx.Account.TotalEquity)*100,
-		ctx.Account.TotalPnLPct,
-		ctx.Account.MarginUsedPct,
-		ctx.Account.PositionCount))
+	sb.WriteString(formatAccountLine(ctx))
 
 	// 持仓（完整市场数据）
 	if len(ctx.Positions) > 0 {
 		sb.WriteString("## 当前持仓\n")
 		for i, pos := range ctx.Positions {
-			// 计算持仓时长
-			holdingDuration := ""
-			if pos.UpdateTime > 0 {
-				durationMs := time.Now().UnixMilli() - pos.UpdateTime
-				durationMin := durationMs / (1000 * 60) // 转换为分钟
-				if durationMin < 60 {
-					holdingDuration = fmt.Sprintf(" | 持仓时长%d分钟", durationMin)
-				} else {
-					durationHour := durationMin / 60
-					durationMinRemainder := durationMin % 60
-					holdingDuration = fmt.Sprintf(" | 持仓时长%d小时%d分钟", durationHour, durationMinRemainder)
-				}
-			}
-
-			sb.WriteString(fmt.Sprintf("%d. %s %s | 入场价%.4f 当前价%.4f | 盈亏%+.2f%% | 杠杆%dx | 保证金%.0f | 强平价%.4f%s\n\n",
-				i+1, pos.Symbol, strings.ToUpper(pos.Side),
-				pos.EntryPrice, pos.MarkPrice, pos.UnrealizedPnLPct,
-				pos.Leverage, pos.MarginUsed, pos.LiquidationPrice, holdingDuration))
+			sb.WriteString(formatPositionLine(i+1, pos))
 
 			// 使用FormatMarketData输出完整市场数据
 			if marketData, ok := ctx.MarketDataMap[pos.Symbol]; ok {
@@ -352,11 +395,9 @@ func buildUserPrompt(ctx *Context) string {
 		}
 		displayedCount++
 
-		sourceTags := ""
-		if len(coin.Sources) > 1 {
-			sourceTags = " (AI500+OI_Top双重信号)"
-		} else if len(coin.Sources) == 1 && coin.Sources[0] == "oi_top" {
-			sourceTags = " (OI_Top持仓增长)"
+		sourceTags := candidateSourceTags(coin.Sources)
+		if coin.Score > 0 {
+			sourceTags += fmt.Sprintf(" [信号评分 %.2f]", coin.Score)
 		}
 
 		// 使用FormatMarketData输出完整市场数据
@@ -367,18 +408,7 @@ func buildUserPrompt(ctx *Context) string {
 	sb.WriteString("\n")
 
 	// 夏普比率（直接传值，不要复杂格式化）
-	if ctx.Performance != nil {
-		// 直接从interface{}中提取SharpeRatio
-		type PerformanceData struct {
-			SharpeRatio float64 `json:"sharpe_ratio"`
-		}
-		var perfData PerformanceData
-		if jsonData, err := json.Marshal(ctx.Performance); err == nil {
-			if err := json.Unmarshal(jsonData, &perfData); err == nil {
-				sb.WriteString(fmt.Sprintf("## 📊 夏普比率: %.2f\n\n", perfData.SharpeRatio))
-			}
-		}
-	}
+	sb.WriteString(formatPerformanceLine(ctx))
 
 	sb.WriteString("---\n\n")
 	sb.WriteString("现在请分析并输出决策（思维链 + JSON）\n")
@@ -386,6 +416,91 @@ func buildUserPrompt(ctx *Context) string {
 	return sb.String()
 }
 
+// candidateSourceTags 候选币种来源标签
+func candidateSourceTags(sources []string) string {
+	has := make(map[string]bool, len(sources))
+	for _, s := range sources {
+		has[s] = true
+	}
+
+	var tags []string
+	if has["ai500"] && has["oi_top"] {
+		tags = append(tags, "AI500+OI_Top双重信号")
+	} else if has["oi_top"] {
+		tags = append(tags, "OI_Top持仓增长")
+	}
+	if has["market_alerts"] {
+		tags = append(tags, "行情异动警报")
+	}
+	if has["top_movers"] {
+		tags = append(tags, "24h涨跌幅领先")
+	}
+	if has["funding_extremes"] {
+		tags = append(tags, "资金费率极端")
+	}
+	if has["static"] {
+		tags = append(tags, "自选")
+	}
+	if has["universe"] {
+		tags = append(tags, "流动性筛选")
+	}
+	if len(tags) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(tags, ", ") + ")"
+}
+
+// formatAccountLine 账户概要（一行）
+func formatAccountLine(ctx *Context) string {
+	return fmt.Sprintf("账户: 净值%.2f | 余额%.2f (%.1f%%) | 盈亏%+.2f%% | 保证金%.1f%% | 持仓%d个\n\n",
+		ctx.Account.TotalEquity,
+		ctx.Account.AvailableBalance,
+		(ctx.Account.AvailableBalance/ctx.Account.TotalEquity)*100,
+		ctx.Account.TotalPnLPct,
+		ctx.Account.MarginUsedPct,
+		ctx.Account.PositionCount)
+}
+
+// formatPositionLine 单个持仓概要
+func formatPositionLine(index int, pos PositionInfo) string {
+	// 计算持仓时长
+	holdingDuration := ""
+	if pos.UpdateTime > 0 {
+		durationMs := time.Now().UnixMilli() - pos.UpdateTime
+		durationMin := durationMs / (1000 * 60) // 转换为分钟
+		if durationMin < 60 {
+			holdingDuration = fmt.Sprintf(" | 持仓时长%d分钟", durationMin)
+		} else {
+			durationHour := durationMin / 60
+			durationMinRemainder := durationMin % 60
+			holdingDuration = fmt.Sprintf(" | 持仓时长%d小时%d分钟", durationHour, durationMinRemainder)
+		}
+	}
+
+	return fmt.Sprintf("%d. %s %s | 入场价%.4f 当前价%.4f | 盈亏%+.2f%% | 杠杆%dx | 保证金%.0f | 强平价%.4f%s\n\n",
+		index, pos.Symbol, strings.ToUpper(pos.Side),
+		pos.EntryPrice, pos.MarkPrice, pos.UnrealizedPnLPct,
+		pos.Leverage, pos.MarginUsed, pos.LiquidationPrice, holdingDuration)
+}
+
+// formatPerformanceLine 历史表现概要（目前只输出夏普比率）
+func formatPerformanceLine(ctx *Context) string {
+	if ctx.Performance == nil {
+		return ""
+	}
+	// 直接从interface{}中提取SharpeRatio
+	type PerformanceData struct {
+		SharpeRatio float64 `json:"sharpe_ratio"`
+	}
+	var perfData PerformanceData
+	if jsonData, err := json.Marshal(ctx.Performance); err == nil {
+		if err := json.Unmarshal(jsonData, &perfData); err == nil {
+			return fmt.Sprintf("## 📊 夏普比率（年化）: %.2f\n\n", perfData.SharpeRatio)
+		}
+	}
+	return ""
+}
+
 // parseFullDecisionResponse 解析AI的完整决策响应
 func parseFullDecisionResponse(aiResponse string, accountEquity float64, btcEthLeverage, altcoinLeverage int) (*FullDecision, error) {
 	// 1. 提取思维链
@@ -478,6 +593,24 @@ func validateDecisions(decisions []Decision, accountEquity float64, btcEthLevera
 	return nil
 }
 
+// filterSymbolRestrictions 去掉违反交易员币种限制（黑白名单/冷却期）的开仓决策，其余决策照常执行
+func filterSymbolRestrictions(decisions []Decision, check func(symbol string) error) []Decision {
+	if check == nil {
+		return decisions
+	}
+	kept := decisions[:0]
+	for i, d := range decisions {
+		if d.Action == "open_long" || d.Action == "open_short" {
+			if err := check(d.Symbol); err != nil {
+				log.Printf("⚠️  忽略决策 #%d (%s %s): %v", i+1, d.Symbol, d.Action, err)
+				continue
+			}
+		}
+		kept = append(kept, d)
+	}
+	return kept
+}
+
 // findMatchingBracket 查找匹配的右括号
 func findMatchingBracket(s string, start int) int {
 	if start >= len(s) || s[start] != '[' {
@@ -500,6 +633,11 @@ func findMatchingBracket(s string, start int) int {
 	return -1
 }
 
+// ValidateDecision 验证单个决策（供手动下单等外部入口复用AI决策的同一套风控约束）
+func ValidateDecision(d *Decision, accountEquity float64, btcEthLeverage, altcoinLeverage int) error {
+	return validateDecision(d, accountEquity, btcEthLeverage, altcoinLeverage)
+}
+
 // validateDecision 验证单个决策的有效性
 func validateDecision(d *Decision, accountEquity float64, btcEthLeverage, altcoinLeverage int) error {
 	// 验证action
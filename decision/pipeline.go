@@ -0,0 +1,241 @@
+package decision
+
+import (
+	"fmt"
+	"log"
+	"nofx/mcp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 决策流程名称
+const (
+	PipelineSingle     = "single"      // 单次调用：一个prompt完成分析、仓位与风控（默认）
+	PipelineMultiAgent = "multi_agent" // 多智能体：分析师 → 风控经理 → 执行者
+)
+
+// 阶段角色
+const (
+	StageAnalyst     = "analyst"      // 市场分析师：提出交易建议
+	StageRiskManager = "risk_manager" // 风控经理：结合账户与持仓审核、削减建议
+	StageExecutor    = "executor"     // 执行者：输出最终 []Decision
+)
+
+// stageLabels 阶段角色的中文名称（用于prompt与日志）
+var stageLabels = map[string]string{
+	StageAnalyst:     "市场分析师",
+	StageRiskManager: "风控经理",
+	StageExecutor:    "执行者",
+}
+
+// PipelineStage 流程中的一个阶段
+type PipelineStage struct {
+	Role     string `json:"role"`     // 阶段角色
+	Template string `json:"template"` // 阶段提示词模板（prompts/pipeline/ 下的文件名，不含扩展名）
+}
+
+// Pipeline 决策流程定义
+type Pipeline struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Stages      []PipelineStage `json:"stages"`
+}
+
+// pipelines 内置的决策流程
+var pipelines = map[string]*Pipeline{
+	PipelineSingle: {
+		Name:        PipelineSingle,
+		Description: "单次调用：一个prompt完成市场分析、仓位与风控",
+	},
+	PipelineMultiAgent: {
+		Name:        PipelineMultiAgent,
+		Description: "多智能体：分析师提出建议 → 风控经理审核削减 → 执行者输出最终决策",
+		Stages: []PipelineStage{
+			{Role: StageAnalyst, Template: "analyst"},
+			{Role: StageRiskManager, Template: "risk_manager"},
+			{Role: StageExecutor, Template: "executor"},
+		},
+	},
+}
+
+// StageTranscript 单个阶段的完整记录
+type StageTranscript struct {
+	Role         string `json:"role"`
+	Template     string `json:"template"`
+	SystemPrompt string `json:"system_prompt"`
+	UserPrompt   string `json:"user_prompt"`
+	Response     string `json:"response"`
+	DurationMs   int64  `json:"duration_ms"`
+	Error        string `json:"error,omitempty"`
+}
+
+// GetPipeline 获取指定名称的决策流程
+func GetPipeline(name string) (*Pipeline, bool) {
+	p, ok := pipelines[name]
+	return p, ok
+}
+
+// GetAllPipelines 获取所有决策流程（按名称排序）
+func GetAllPipelines() []*Pipeline {
+	result := make([]*Pipeline, 0, len(pipelines))
+	for _, p := range pipelines {
+		result = append(result, p)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// GetFullDecisionWithPipeline 按交易员配置的决策流程获取完整决策
+// pipelineName 为空或 single 时与 GetFullDecisionWithCustomPrompt 完全一致
+func GetFullDecisionWithPipeline(ctx *Context, mcpClient mcp.AIClient, pipelineName string, customPrompt string, overrideBase bool, templateName string) (*FullDecision, error) {
+	if pipelineName == "" || pipelineName == PipelineSingle {
+		return GetFullDecisionWithCustomPrompt(ctx, mcpClient, customPrompt, overrideBase, templateName)
+	}
+
+	pipeline, ok := GetPipeline(pipelineName)
+	if !ok || len(pipeline.Stages) == 0 {
+		log.Printf("⚠️  决策流程 '%s' 不存在，使用单次调用", pipelineName)
+		return GetFullDecisionWithCustomPrompt(ctx, mcpClient, customPrompt, overrideBase, templateName)
+	}
+
+	return runPipeline(ctx, mcpClient, pipeline, customPrompt, overrideBase, templateName)
+}
+
+// runPipeline 依次执行各阶段，最后一个阶段的输出被解析为最终决策
+func runPipeline(ctx *Context, mcpClient mcp.AIClient, pipeline *Pipeline, customPrompt string, overrideBase bool, templateName string) (*FullDecision, error) {
+	// 1. 为所有币种获取市场数据
+	if err := fetchMarketDataForContext(ctx); err != nil {
+		return nil, fmt.Errorf("获取市场数据失败: %w", err)
+	}
+
+	// 2. 交易策略（分析师使用）与行情数据
+	strategyPrompt := buildSystemPromptWithCustom(ctx.Account.TotalEquity, ctx.BTCETHLeverage, ctx.AltcoinLeverage, customPrompt, overrideBase, templateName)
+	marketPrompt := buildUserPrompt(ctx)
+
+	// 记录的系统提示词为交易策略，各阶段实际使用的提示词保存在 Stages 中
+	fd := &FullDecision{
+		Pipeline:     pipeline.Name,
+		SystemPrompt: strategyPrompt,
+		UserPrompt:   marketPrompt,
+		CallStats:    &mcp.CallStats{},
+	}
+
+	// 3. 逐个阶段调用AI
+	for i, stage := range pipeline.Stages {
+		systemPrompt, userPrompt := buildStagePrompts(ctx, stage, strategyPrompt, marketPrompt, fd.Stages)
+		log.Printf("🧩 决策流程 [%s] 阶段 %d/%d: %s", pipeline.Name, i+1, len(pipeline.Stages), stageLabel(stage.Role))
+
+		start := time.Now()
+		response, stats, err := mcpClient.CallWithMessagesStats(systemPrompt, userPrompt)
+		if stats != nil {
+			fd.CallStats.QueueWait += stats.QueueWait
+			fd.CallStats.RetryWait += stats.RetryWait
+			fd.CallStats.Attempts += stats.Attempts
+		}
+
+		transcript := StageTranscript{
+			Role:         stage.Role,
+			Template:     stage.Template,
+			SystemPrompt: systemPrompt,
+			UserPrompt:   userPrompt,
+			Response:     response,
+			DurationMs:   time.Since(start).Milliseconds(),
+		}
+
+		if err != nil {
+			transcript.Error = err.Error()
+			fd.Stages = append(fd.Stages, transcript)
+			fd.Timestamp = time.Now()
+			return fd, fmt.Errorf("%s阶段调用AI失败: %w", stageLabel(stage.Role), err)
+		}
+		fd.Stages = append(fd.Stages, transcript)
+	}
+
+	// 4. 解析最后一个阶段的输出
+	final := fd.Stages[len(fd.Stages)-1].Response
+	parsed, err := parseFullDecisionResponse(final, ctx.Account.TotalEquity, ctx.BTCETHLeverage, ctx.AltcoinLeverage)
+	fd.CoTTrace = parsed.CoTTrace
+	fd.Decisions = parsed.Decisions
+	fd.Timestamp = time.Now()
+	if err == nil {
+		fd.Decisions = filterSymbolRestrictions(fd.Decisions, ctx.SymbolCheck)
+	}
+	if err != nil {
+		return fd, fmt.Errorf("解析AI响应失败: %w", err)
+	}
+
+	return fd, nil
+}
+
+// buildStagePrompts 构建某个阶段的 system / user prompt
+func buildStagePrompts(ctx *Context, stage PipelineStage, strategyPrompt, marketPrompt string, previous []StageTranscript) (string, string) {
+	var sb strings.Builder
+	sb.WriteString(loadStageTemplate(stage))
+	sb.WriteString("\n\n")
+
+	switch stage.Role {
+	case StageAnalyst:
+		// 分析师：角色说明 + 交易员选择的策略模板，输入完整行情
+		sb.WriteString(strategyPrompt)
+		return sb.String(), marketPrompt
+
+	case StageRiskManager:
+		sb.WriteString(buildHardConstraints(ctx.Account.TotalEquity, ctx.BTCETHLeverage, ctx.AltcoinLeverage))
+		sb.WriteString(buildOutputFormat(ctx.Account.TotalEquity, ctx.BTCETHLeverage))
+		return sb.String(), buildReviewPrompt(ctx, previous, "现在请逐条审核以上建议，并输出审核后的决策（思维链 + JSON）")
+
+	default:
+		// 执行者（以及未来扩展的其他阶段）：基于之前所有阶段的输出给出最终决策
+		sb.WriteString(buildHardConstraints(ctx.Account.TotalEquity, ctx.BTCETHLeverage, ctx.AltcoinLeverage))
+		sb.WriteString(buildOutputFormat(ctx.Account.TotalEquity, ctx.BTCETHLeverage))
+		return sb.String(), buildReviewPrompt(ctx, previous, "现在请输出最终要执行的决策（思维链 + JSON）")
+	}
+}
+
+// buildReviewPrompt 审核类阶段的输入：账户与持仓概要 + 之前各阶段的输出
+func buildReviewPrompt(ctx *Context, previous []StageTranscript, instruction string) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("时间: %s | 周期: #%d | 运行: %d分钟\n\n",
+		ctx.CurrentTime, ctx.CallCount, ctx.RuntimeMinutes))
+	sb.WriteString(formatAccountLine(ctx))
+
+	if len(ctx.Positions) > 0 {
+		sb.WriteString("## 当前持仓\n")
+		for i, pos := range ctx.Positions {
+			sb.WriteString(formatPositionLine(i+1, pos))
+		}
+	} else {
+		sb.WriteString("当前持仓: 无\n\n")
+	}
+	sb.WriteString(formatPerformanceLine(ctx))
+
+	for _, t := range previous {
+		sb.WriteString(fmt.Sprintf("## %s的输出\n\n", stageLabel(t.Role)))
+		sb.WriteString(strings.TrimSpace(t.Response))
+		sb.WriteString("\n\n")
+	}
+
+	sb.WriteString("---\n\n")
+	sb.WriteString(instruction)
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// loadStageTemplate 加载阶段提示词模板，缺失时使用内置的简短说明
+func loadStageTemplate(stage PipelineStage) string {
+	if template, err := GetPipelinePromptTemplate(stage.Template); err == nil {
+		return template.Content
+	}
+	log.Printf("⚠️  决策流程阶段模板 '%s' 不存在，使用内置说明", stage.Template)
+	return fmt.Sprintf("# 角色：%s\n\n你是多智能体交易决策流程中的%s。", stageLabel(stage.Role), stageLabel(stage.Role))
+}
+
+// stageLabel 阶段角色的中文名称
+func stageLabel(role string) string {
+	if label, ok := stageLabels[role]; ok {
+		return label
+	}
+	return role
+}
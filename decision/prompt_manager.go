@@ -3,6 +3,8 @@ package decision
 import (
 	"fmt"
 	"log"
+	"nofx/market"
+	"nofx/market/indicator"
 	"os"
 	"path/filepath"
 	"strings"
@@ -11,8 +13,50 @@ import (
 
 // PromptTemplate 系统提示词模板
 type PromptTemplate struct {
-	Name    string // 模板名称（文件名，不含扩展名）
-	Content string // 模板内容
+	Name       string             // 模板名称（文件名，不含扩展名）
+	Content    string             // 模板内容（不含指令行）
+	Timeframes []market.Timeframe // 模板需要的额外K线周期（来自 "@timeframes:" 指令行）
+	Indicators []indicator.Spec   // 模板需要的额外技术指标（来自 "@indicators:" 指令行）
+}
+
+// timeframesDirective 模板中声明额外K线周期的指令行前缀，例如:
+//
+//	@timeframes: 15m:100,1h:60
+const timeframesDirective = "@timeframes:"
+
+// indicatorsDirective 模板中声明额外技术指标的指令行前缀（名称见 GET /api/indicators），例如:
+//
+//	@indicators: bb(20,2), adx(14), supertrend(10,3), vwap
+const indicatorsDirective = "@indicators:"
+
+// parseTemplateDirectives 解析并移除模板中的指令行
+func parseTemplateDirectives(name, content string) (string, []market.Timeframe, []indicator.Spec) {
+	var timeframes []market.Timeframe
+	var indicators []indicator.Spec
+	lines := strings.Split(content, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, timeframesDirective):
+			parsed, err := market.ParseTimeframes(strings.TrimPrefix(trimmed, timeframesDirective))
+			if err != nil {
+				log.Printf("⚠️  提示词模板 %s 的周期声明无效: %v", name, err)
+				continue
+			}
+			timeframes = append(timeframes, parsed...)
+		case strings.HasPrefix(trimmed, indicatorsDirective):
+			parsed, err := indicator.ParseSpecs(strings.TrimPrefix(trimmed, indicatorsDirective))
+			if err != nil {
+				log.Printf("⚠️  提示词模板 %s 的指标声明无效: %v", name, err)
+				continue
+			}
+			indicators = append(indicators, parsed...)
+		default:
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n"), timeframes, indicators
 }
 
 // PromptManager 提示词管理器
@@ -24,6 +68,8 @@ type PromptManager struct {
 var (
 	// globalPromptManager 全局提示词管理器
 	globalPromptManager *PromptManager
+	// pipelinePromptManager 多阶段决策流程的阶段提示词管理器
+	pipelinePromptManager *PromptManager
 	// promptsDir 提示词文件夹路径
 	promptsDir = "prompts"
 )
@@ -36,6 +82,16 @@ func init() {
 	} else {
 		log.Printf("✓ 已加载 %d 个系统提示词模板", len(globalPromptManager.templates))
 	}
+
+	pipelinePromptManager = NewPromptManager()
+	if err := pipelinePromptManager.LoadTemplates(pipelinePromptsDir()); err != nil {
+		log.Printf("⚠️  加载决策流程阶段提示词失败: %v", err)
+	}
+}
+
+// pipelinePromptsDir 阶段提示词目录
+func pipelinePromptsDir() string {
+	return filepath.Join(promptsDir, "pipeline")
 }
 
 // NewPromptManager 创建提示词管理器
@@ -80,9 +136,12 @@ func (pm *PromptManager) LoadTemplates(dir string) error {
 		templateName := strings.TrimSuffix(fileName, filepath.Ext(fileName))
 
 		// 存储模板
+		templateContent, timeframes, indicators := parseTemplateDirectives(templateName, string(content))
 		pm.templates[templateName] = &PromptTemplate{
-			Name:    templateName,
-			Content: string(content),
+			Name:       templateName,
+			Content:    templateContent,
+			Timeframes: timeframes,
+			Indicators: indicators,
 		}
 
 		log.Printf("  📄 加载提示词模板: %s (%s)", templateName, fileName)
@@ -158,5 +217,13 @@ func GetAllPromptTemplates() []*PromptTemplate {
 
 // ReloadPromptTemplates 重新加载所有模板（全局函数）
 func ReloadPromptTemplates() error {
+	if err := pipelinePromptManager.ReloadTemplates(pipelinePromptsDir()); err != nil {
+		log.Printf("⚠️  重新加载决策流程阶段提示词失败: %v", err)
+	}
 	return globalPromptManager.ReloadTemplates(promptsDir)
 }
+
+// GetPipelinePromptTemplate 获取决策流程阶段提示词模板（全局函数）
+func GetPipelinePromptTemplate(name string) (*PromptTemplate, error) {
+	return pipelinePromptManager.GetTemplate(name)
+}
@@ -0,0 +1,479 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"nofx/logger"
+	"strings"
+	"time"
+)
+
+// Dataset 导出的数据集
+type Dataset string
+
+const (
+	DatasetDecisions Dataset = "decisions" // 决策记录（每个周期一行）
+	DatasetActions   Dataset = "actions"   // 执行的开平仓动作
+	DatasetTrades    Dataset = "trades"    // 交易账本中的完整交易（按开仓时间筛选）
+	DatasetEquity    Dataset = "equity"    // 账户净值快照
+	DatasetPrompts   Dataset = "prompts"   // (系统提示词, 用户提示词, 模型输出, 结果) 样本，只支持 JSONL
+)
+
+// Datasets 所有数据集
+var Datasets = []Dataset{DatasetDecisions, DatasetActions, DatasetTrades, DatasetEquity, DatasetPrompts}
+
+// ParseDataset 解析数据集名称
+func ParseDataset(s string) (Dataset, error) {
+	for _, dataset := range Datasets {
+		if string(dataset) == s {
+			return dataset, nil
+		}
+	}
+	names := make([]string, len(Datasets))
+	for i, dataset := range Datasets {
+		names[i] = string(dataset)
+	}
+	return "", fmt.Errorf("未知的数据集: %s（支持 %s）", s, strings.Join(names, "、"))
+}
+
+// Request 一次导出
+type Request struct {
+	TraderID string
+	Dataset  Dataset
+	Format   Format
+	Start    time.Time // 零值表示从最早的记录开始
+	End      time.Time
+	// ArchiveDir 决策记录的gzip归档目录：prompts 数据集从这里找回已压缩记录的提示词
+	// 为空（或归档中没有）时跳过已压缩的记录，样本只覆盖保留完整记录的时间段
+	ArchiveDir string
+}
+
+// Validate 检查数据集与格式的组合
+func (r *Request) Validate() error {
+	if r.Dataset == DatasetPrompts && r.Format != FormatJSONL {
+		return fmt.Errorf("prompts 数据集只支持 jsonl 格式")
+	}
+	if r.End.IsZero() {
+		r.End = time.Now()
+	}
+	if !r.Start.IsZero() && !r.Start.Before(r.End) {
+		return fmt.Errorf("开始时间必须早于结束时间")
+	}
+	return nil
+}
+
+// Filename 建议的导出文件名
+func (r *Request) Filename() string {
+	start := "all"
+	if !r.Start.IsZero() {
+		start = r.Start.Format("20060102")
+	}
+	return fmt.Sprintf("%s_%s_%s_%s.%s", r.TraderID, r.Dataset, start, r.End.Format("20060102"), r.Format.Extension())
+}
+
+// Write 把交易员的数据集写入 w
+func Write(w io.Writer, decisionLogger *logger.DecisionLogger, req Request) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	switch req.Dataset {
+	case DatasetDecisions:
+		return writeDecisions(w, decisionLogger, req)
+	case DatasetActions:
+		return writeActions(w, decisionLogger, req)
+	case DatasetTrades:
+		return writeTrades(w, decisionLogger, req)
+	case DatasetEquity:
+		return writeEquity(w, decisionLogger, req)
+	case DatasetPrompts:
+		return writePrompts(w, decisionLogger, req)
+	}
+	return fmt.Errorf("未知的数据集: %s", req.Dataset)
+}
+
+var decisionColumns = []Column{
+	{Name: "trader_id", Type: TypeString},
+	{Name: "cycle_number", Type: TypeInt},
+	{Name: "timestamp", Type: TypeTime},
+	{Name: "success", Type: TypeBool},
+	{Name: "error_message", Type: TypeString},
+	{Name: "total_equity", Type: TypeFloat},
+	{Name: "available_balance", Type: TypeFloat},
+	{Name: "total_pnl", Type: TypeFloat},
+	{Name: "position_count", Type: TypeInt},
+	{Name: "margin_used_pct", Type: TypeFloat},
+	{Name: "candidate_coins", Type: TypeString},
+	{Name: "action_count", Type: TypeInt},
+	{Name: "pipeline", Type: TypeString},
+	{Name: "ai_attempts", Type: TypeInt},
+	{Name: "ai_queue_wait_ms", Type: TypeInt},
+	{Name: "decision_json", Type: TypeString},
+	{Name: "cot_trace", Type: TypeString},
+	{Name: "system_prompt", Type: TypeString},
+	{Name: "input_prompt", Type: TypeString},
+}
+
+// writeDecisions 决策记录：JSONL 输出完整记录（含持仓、动作、多阶段流程），CSV/Parquet 输出扁平化的列
+func writeDecisions(w io.Writer, decisionLogger *logger.DecisionLogger, req Request) error {
+	if req.Format == FormatJSONL {
+		enc := json.NewEncoder(w)
+		return decisionLogger.WalkRecords(req.Start, req.End, func(record *logger.DecisionRecord) error {
+			return enc.Encode(struct {
+				TraderID string `json:"trader_id"`
+				*logger.DecisionRecord
+			}{req.TraderID, record})
+		})
+	}
+
+	rows, err := newRowWriter(w, req.Format, decisionColumns)
+	if err != nil {
+		return err
+	}
+	err = decisionLogger.WalkRecords(req.Start, req.End, func(record *logger.DecisionRecord) error {
+		account := record.AccountState
+		return rows.WriteRow([]interface{}{
+			req.TraderID, record.CycleNumber, record.Timestamp, record.Success, record.ErrorMessage,
+			account.TotalBalance, account.AvailableBalance, account.TotalUnrealizedProfit, account.PositionCount, account.MarginUsedPct,
+			strings.Join(record.CandidateCoins, ","), len(record.Decisions), record.Pipeline, record.AIAttempts, record.AIQueueWaitMs,
+			record.DecisionJSON, record.CoTTrace, record.SystemPrompt, record.InputPrompt,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+var actionColumns = []Column{
+	{Name: "trader_id", Type: TypeString},
+	{Name: "cycle_number", Type: TypeInt},
+	{Name: "timestamp", Type: TypeTime},
+	{Name: "action", Type: TypeString},
+	{Name: "symbol", Type: TypeString},
+	{Name: "quantity", Type: TypeFloat},
+	{Name: "leverage", Type: TypeInt},
+	{Name: "price", Type: TypeFloat},
+	{Name: "order_id", Type: TypeInt},
+	{Name: "success", Type: TypeBool},
+	{Name: "error", Type: TypeString},
+	{Name: "estimated_slippage_pct", Type: TypeFloat},
+	{Name: "trade_id", Type: TypeInt},
+}
+
+// writeActions 执行的动作（每个动作一行）
+func writeActions(w io.Writer, decisionLogger *logger.DecisionLogger, req Request) error {
+	rows, err := newRowWriter(w, req.Format, actionColumns)
+	if err != nil {
+		return err
+	}
+	err = decisionLogger.WalkRecords(req.Start, req.End, func(record *logger.DecisionRecord) error {
+		for _, action := range record.Decisions {
+			err := rows.WriteRow([]interface{}{
+				req.TraderID, record.CycleNumber, action.Timestamp, action.Action, action.Symbol,
+				action.Quantity, action.Leverage, action.Price, action.OrderID, action.Success, action.Error,
+				action.EstimatedSlippagePct, action.TradeID,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+var tradeColumns = []Column{
+	{Name: "trader_id", Type: TypeString},
+	{Name: "trade_id", Type: TypeInt},
+	{Name: "symbol", Type: TypeString},
+	{Name: "side", Type: TypeString},
+	{Name: "status", Type: TypeString},
+	{Name: "quantity", Type: TypeFloat},
+	{Name: "leverage", Type: TypeInt},
+	{Name: "entry_price", Type: TypeFloat},
+	{Name: "entry_time", Type: TypeTime},
+	{Name: "entry_order_id", Type: TypeInt},
+	{Name: "exit_price", Type: TypeFloat},
+	{Name: "exit_time", Type: TypeTime, Nullable: true},
+	{Name: "exit_order_id", Type: TypeInt},
+	{Name: "exit_reason", Type: TypeString},
+	{Name: "stop_loss", Type: TypeFloat},
+	{Name: "take_profit", Type: TypeFloat},
+	{Name: "fees", Type: TypeFloat},
+	{Name: "funding", Type: TypeFloat},
+	{Name: "realized_pnl", Type: TypeFloat},
+	{Name: "net_pnl", Type: TypeFloat},
+	{Name: "open_source", Type: TypeString},
+	{Name: "open_cycle", Type: TypeInt},
+	{Name: "close_cycle", Type: TypeInt},
+}
+
+// writeTrades 开仓时间在范围内的交易（含未平仓交易）
+func writeTrades(w io.Writer, decisionLogger *logger.DecisionLogger, req Request) error {
+	trades, err := tradesBetween(decisionLogger, req.Start, req.End)
+	if err != nil {
+		return err
+	}
+
+	rows, err := newRowWriter(w, req.Format, tradeColumns)
+	if err != nil {
+		return err
+	}
+	for _, trade := range trades {
+		err := rows.WriteRow([]interface{}{
+			req.TraderID, trade.ID, trade.Symbol, trade.Side, trade.Status, trade.Quantity, trade.Leverage,
+			trade.EntryPrice, trade.EntryTime, trade.EntryOrderID, trade.ExitPrice, trade.ExitTime, trade.ExitOrderID,
+			trade.ExitReason, trade.StopLoss, trade.TakeProfit, trade.Fees, trade.Funding, trade.RealizedPnL, trade.NetPnL,
+			trade.OpenSource, trade.OpenCycle, trade.CloseCycle,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Close()
+}
+
+// tradesBetween 开仓时间在 [start, end] 内的交易（已平仓在前，按平仓时间排序；未平仓在后）
+func tradesBetween(decisionLogger *logger.DecisionLogger, start, end time.Time) ([]*logger.Trade, error) {
+	ledger := decisionLogger.TradeLedger()
+	if ledger == nil {
+		return nil, fmt.Errorf("交易员没有交易账本")
+	}
+	closed, err := ledger.ClosedTrades(start, 0)
+	if err != nil {
+		return nil, err
+	}
+	open, err := ledger.OpenTrades()
+	if err != nil {
+		return nil, err
+	}
+
+	var trades []*logger.Trade
+	for _, trade := range append(closed, open...) {
+		if !trade.EntryTime.Before(start) && !trade.EntryTime.After(end) {
+			trades = append(trades, trade)
+		}
+	}
+	return trades, nil
+}
+
+var equityColumns = []Column{
+	{Name: "trader_id", Type: TypeString},
+	{Name: "cycle_number", Type: TypeInt},
+	{Name: "timestamp", Type: TypeTime},
+	{Name: "total_equity", Type: TypeFloat},
+	{Name: "available_balance", Type: TypeFloat},
+	{Name: "total_pnl", Type: TypeFloat},
+	{Name: "position_count", Type: TypeInt},
+	{Name: "margin_used_pct", Type: TypeFloat},
+}
+
+// writeEquity 每个周期的账户净值快照
+func writeEquity(w io.Writer, decisionLogger *logger.DecisionLogger, req Request) error {
+	records, err := decisionLogger.GetAccountHistoryBetween(req.Start, req.End)
+	if err != nil {
+		return err
+	}
+
+	rows, err := newRowWriter(w, req.Format, equityColumns)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		account := record.AccountState
+		err := rows.WriteRow([]interface{}{
+			req.TraderID, record.CycleNumber, record.Timestamp, account.TotalBalance, account.AvailableBalance,
+			account.TotalUnrealizedProfit, account.PositionCount, account.MarginUsedPct,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Close()
+}
+
+// PromptSample 一条评估/微调样本
+type PromptSample struct {
+	TraderID     string        `json:"trader_id"`
+	CycleNumber  int           `json:"cycle_number"`
+	Timestamp    time.Time     `json:"timestamp"`
+	Stage        string        `json:"stage,omitempty"` // 多阶段流程中的阶段角色（为空表示单次调用或最终执行者）
+	SystemPrompt string        `json:"system_prompt"`
+	UserPrompt   string        `json:"user_prompt"`
+	ModelOutput  string        `json:"model_output"`
+	Outcome      SampleOutcome `json:"outcome"`
+}
+
+// SampleOutcome 决策的执行结果
+type SampleOutcome struct {
+	Success        bool            `json:"success"`                    // 决策周期是否成功
+	Error          string          `json:"error,omitempty"`            // 错误信息
+	Actions        []ActionOutcome `json:"actions"`                    // 执行的动作
+	EquityBefore   float64         `json:"equity_before"`              // 决策时的账户净值
+	EquityAfter    float64         `json:"equity_after,omitempty"`     // 下一个周期的账户净值（最后一个周期为空）
+	EquityChange   float64         `json:"equity_change,omitempty"`    // 到下一个周期的净值变化（USDT）
+	NextCycleAfter string          `json:"next_cycle_after,omitempty"` // 到下一个周期的时间间隔
+}
+
+// ActionOutcome 单个动作的结果（关联交易账本中的交易）
+type ActionOutcome struct {
+	Action     string  `json:"action"`
+	Symbol     string  `json:"symbol"`
+	Success    bool    `json:"success"`
+	Error      string  `json:"error,omitempty"`
+	TradeID    int64   `json:"trade_id,omitempty"`
+	TradeState string  `json:"trade_status,omitempty"` // open/closed
+	NetPnL     float64 `json:"net_pnl,omitempty"`      // 交易净盈亏（已平仓时）
+	ExitReason string  `json:"exit_reason,omitempty"`  // 平仓原因（已平仓时）
+}
+
+// writePrompts 输出 (系统提示词, 用户提示词, 模型输出, 结果) 样本，多阶段流程的每个阶段各一条
+func writePrompts(w io.Writer, decisionLogger *logger.DecisionLogger, req Request) error {
+	// 交易结果以账本为准（范围内开仓的交易可能在范围之后才平仓），范围开始后平仓的交易加上未平仓的交易即覆盖所有相关交易
+	tradeByID := make(map[int64]*logger.Trade)
+	if ledger := decisionLogger.TradeLedger(); ledger != nil {
+		closed, err := ledger.ClosedTrades(req.Start, 0)
+		if err != nil {
+			return err
+		}
+		open, err := ledger.OpenTrades()
+		if err != nil {
+			return err
+		}
+		for _, trade := range append(closed, open...) {
+			tradeByID[trade.ID] = trade
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	archive := logger.NewArchiveReader(req.ArchiveDir)
+	skipped := 0
+	// 结果需要下一个周期的净值，延迟一条写出
+	var pending *logger.DecisionRecord
+	flush := func(next *logger.DecisionRecord) error {
+		if pending == nil {
+			return nil
+		}
+		outcome := buildOutcome(pending, next, tradeByID)
+		record := pending
+		if record.Compacted {
+			// 压缩后的记录没有提示词，从归档找回；找不到时跳过，避免输出空提示词的样本
+			full, ok := archive.Find(record)
+			if !ok {
+				skipped++
+				return nil
+			}
+			restored := *record
+			restored.SystemPrompt, restored.InputPrompt, restored.PipelineStages = full.SystemPrompt, full.InputPrompt, full.PipelineStages
+			record = &restored
+		}
+		for _, sample := range promptSamples(req.TraderID, record, outcome) {
+			if err := enc.Encode(sample); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	err := decisionLogger.WalkRecords(req.Start, req.End, func(record *logger.DecisionRecord) error {
+		if err := flush(record); err != nil {
+			return err
+		}
+		pending = record
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := flush(nil); err != nil {
+		return err
+	}
+	if skipped > 0 {
+		log.Printf("⚠️  [%s] %d 条已压缩记录在归档中找不到提示词，未导出", req.TraderID, skipped)
+	}
+	return nil
+}
+
+func buildOutcome(record, next *logger.DecisionRecord, tradeByID map[int64]*logger.Trade) SampleOutcome {
+	outcome := SampleOutcome{
+		Success:      record.Success,
+		Error:        record.ErrorMessage,
+		Actions:      []ActionOutcome{},
+		EquityBefore: record.AccountState.TotalBalance,
+	}
+	if next != nil && next.AccountState.TotalBalance > 0 {
+		outcome.EquityAfter = next.AccountState.TotalBalance
+		outcome.EquityChange = outcome.EquityAfter - outcome.EquityBefore
+		outcome.NextCycleAfter = next.Timestamp.Sub(record.Timestamp).String()
+	}
+
+	for _, action := range record.Decisions {
+		result := ActionOutcome{
+			Action:  action.Action,
+			Symbol:  action.Symbol,
+			Success: action.Success,
+			Error:   action.Error,
+			TradeID: action.TradeID,
+		}
+		if trade, ok := tradeByID[action.TradeID]; ok {
+			result.TradeState = trade.Status
+			if trade.Status == logger.TradeClosed {
+				result.NetPnL = trade.NetPnL
+				result.ExitReason = trade.ExitReason
+			}
+		}
+		outcome.Actions = append(outcome.Actions, result)
+	}
+	return outcome
+}
+
+// promptSamples 单次调用的记录一条样本；多阶段流程每个阶段一条（结果相同），没有提示词的阶段跳过
+func promptSamples(traderID string, record *logger.DecisionRecord, outcome SampleOutcome) []PromptSample {
+	base := PromptSample{
+		TraderID:    traderID,
+		CycleNumber: record.CycleNumber,
+		Timestamp:   record.Timestamp,
+		Outcome:     outcome,
+	}
+	if len(record.PipelineStages) == 0 {
+		if record.InputPrompt == "" {
+			return nil
+		}
+		sample := base
+		sample.SystemPrompt = record.SystemPrompt
+		sample.UserPrompt = record.InputPrompt
+		sample.ModelOutput = modelOutput(record.CoTTrace, record.DecisionJSON)
+		return []PromptSample{sample}
+	}
+
+	samples := make([]PromptSample, 0, len(record.PipelineStages))
+	for _, stage := range record.PipelineStages {
+		if stage.UserPrompt == "" {
+			continue
+		}
+		sample := base
+		sample.Stage = stage.Role
+		sample.SystemPrompt = stage.SystemPrompt
+		sample.UserPrompt = stage.UserPrompt
+		sample.ModelOutput = stage.Response
+		samples = append(samples, sample)
+	}
+	return samples
+}
+
+// modelOutput 模型原始输出：思维链 + 决策JSON
+func modelOutput(cot, decisionJSON string) string {
+	switch {
+	case cot == "":
+		return decisionJSON
+	case decisionJSON == "":
+		return cot
+	}
+	return cot + "\n\n" + decisionJSON
+}
@@ -0,0 +1,157 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format 导出格式
+type Format string
+
+const (
+	FormatCSV     Format = "csv"
+	FormatJSONL   Format = "jsonl"
+	FormatParquet Format = "parquet"
+)
+
+// ParseFormat 解析导出格式（为空时为 csv）
+func ParseFormat(s string) (Format, error) {
+	switch Format(strings.ToLower(s)) {
+	case "", FormatCSV:
+		return FormatCSV, nil
+	case FormatJSONL, "json", "ndjson":
+		return FormatJSONL, nil
+	case FormatParquet:
+		return FormatParquet, nil
+	}
+	return "", fmt.Errorf("不支持的导出格式: %s（支持 csv、jsonl、parquet）", s)
+}
+
+// Extension 文件扩展名
+func (f Format) Extension() string {
+	return string(f)
+}
+
+// ContentType HTTP 响应类型
+func (f Format) ContentType() string {
+	switch f {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatJSONL:
+		return "application/x-ndjson"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// ColumnType 列类型
+type ColumnType int
+
+const (
+	TypeString ColumnType = iota
+	TypeInt
+	TypeFloat
+	TypeBool
+	TypeTime
+)
+
+// Column 表格列（Nullable 的时间列零值导出为空）
+type Column struct {
+	Name     string
+	Type     ColumnType
+	Nullable bool
+}
+
+// rowWriter 按行写出表格
+type rowWriter interface {
+	WriteRow(values []interface{}) error
+	Close() error
+}
+
+func newRowWriter(w io.Writer, format Format, columns []Column) (rowWriter, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w, columns)
+	case FormatJSONL:
+		return &jsonlWriter{enc: json.NewEncoder(w), columns: columns}, nil
+	case FormatParquet:
+		return newParquetWriter(w, columns)
+	}
+	return nil, fmt.Errorf("不支持的导出格式: %s", format)
+}
+
+// csvWriter CSV 输出（第一行为列名，时间为 RFC3339）
+type csvWriter struct {
+	w       *csv.Writer
+	columns []Column
+	row     []string
+}
+
+func newCSVWriter(w io.Writer, columns []Column) (*csvWriter, error) {
+	c := &csvWriter{w: csv.NewWriter(w), columns: columns, row: make([]string, len(columns))}
+	for i, column := range columns {
+		c.row[i] = column.Name
+	}
+	return c, c.w.Write(c.row)
+}
+
+func (c *csvWriter) WriteRow(values []interface{}) error {
+	for i, value := range values {
+		c.row[i] = formatCSVValue(value)
+	}
+	return c.w.Write(c.row)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func formatCSVValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.Format(time.RFC3339)
+	}
+	return fmt.Sprint(value)
+}
+
+// jsonlWriter 每行一个JSON对象（零值时间输出为 null）
+type jsonlWriter struct {
+	enc     *json.Encoder
+	columns []Column
+}
+
+func (j *jsonlWriter) WriteRow(values []interface{}) error {
+	row := make(map[string]interface{}, len(values))
+	for i, value := range values {
+		if t, ok := value.(time.Time); ok && t.IsZero() {
+			value = nil
+		}
+		row[j.columns[i].Name] = value
+	}
+	return j.enc.Encode(row)
+}
+
+func (j *jsonlWriter) Close() error {
+	return nil
+}
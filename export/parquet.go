@@ -0,0 +1,408 @@
+package export
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"math"
+	"time"
+)
+
+// 最小化的 Parquet 写入器：未压缩、PLAIN 编码、每个行组的每列一个 v1 数据页，
+// 只支持本包用到的扁平列（字符串、整数、浮点、布尔、时间戳），足以被 pandas/pyarrow/DuckDB/Spark 读取
+
+// parquetRowGroupBytes 行组缓冲超过该大小时写出
+const parquetRowGroupBytes = 64 << 20
+
+// Parquet 物理类型
+const (
+	parquetBoolean   = 0
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+)
+
+// Parquet 逻辑类型（ConvertedType）
+const (
+	parquetUTF8            = 0
+	parquetTimestampMillis = 9
+)
+
+// Parquet 编码
+const (
+	parquetPlain = 0
+	parquetRLE   = 3
+)
+
+// parquetColumn 一列在当前行组中缓冲的数据
+type parquetColumn struct {
+	column  Column
+	values  []byte // PLAIN 编码的非空值（布尔值单独保存）
+	bools   []bool
+	defined []bool // 每行是否有值（只对可空列记录）
+	rows    int
+}
+
+// parquetChunk 已写出的列块
+type parquetChunk struct {
+	offset           int64
+	size             int64
+	numValues        int64
+	uncompressedSize int64
+}
+
+type parquetRowGroup struct {
+	chunks []parquetChunk
+	rows   int64
+	size   int64
+}
+
+// parquetWriter 按行写入、按列缓冲，行组满或关闭时写出
+type parquetWriter struct {
+	bw        *bufio.Writer
+	w         *countingWriter
+	columns   []*parquetColumn
+	buffered  int
+	rowGroups []parquetRowGroup
+}
+
+func newParquetWriter(w io.Writer, columns []Column) (*parquetWriter, error) {
+	bw := bufio.NewWriter(w)
+	p := &parquetWriter{bw: bw, w: &countingWriter{w: bw}}
+	if _, err := p.w.Write([]byte("PAR1")); err != nil {
+		return nil, err
+	}
+	for _, column := range columns {
+		p.columns = append(p.columns, &parquetColumn{column: column})
+	}
+	return p, nil
+}
+
+func (p *parquetWriter) WriteRow(values []interface{}) error {
+	for i, col := range p.columns {
+		col.rows++
+		value := values[i]
+		if col.column.Type == TypeTime {
+			if t, ok := value.(time.Time); !ok || t.IsZero() {
+				value = nil
+			}
+		}
+		if col.column.Nullable {
+			col.defined = append(col.defined, value != nil)
+			if value == nil {
+				continue
+			}
+		}
+
+		switch col.column.Type {
+		case TypeString:
+			s, _ := value.(string)
+			col.values = binary.LittleEndian.AppendUint32(col.values, uint32(len(s)))
+			col.values = append(col.values, s...)
+			p.buffered += len(s) + 4
+		case TypeInt:
+			col.values = binary.LittleEndian.AppendUint64(col.values, uint64(toInt64(value)))
+			p.buffered += 8
+		case TypeFloat:
+			f, _ := value.(float64)
+			col.values = binary.LittleEndian.AppendUint64(col.values, math.Float64bits(f))
+			p.buffered += 8
+		case TypeBool:
+			b, _ := value.(bool)
+			col.bools = append(col.bools, b)
+			p.buffered++
+		case TypeTime:
+			t, _ := value.(time.Time)
+			col.values = binary.LittleEndian.AppendUint64(col.values, uint64(t.UnixMilli()))
+			p.buffered += 8
+		}
+	}
+	if p.buffered >= parquetRowGroupBytes {
+		return p.flushRowGroup()
+	}
+	return nil
+}
+
+// flushRowGroup 写出缓冲的行组
+func (p *parquetWriter) flushRowGroup() error {
+	rows := p.columns[0].rows
+	if rows == 0 {
+		return nil
+	}
+
+	group := parquetRowGroup{rows: int64(rows)}
+	for _, col := range p.columns {
+		var page []byte
+		if col.column.Nullable {
+			levels := encodeDefinitionLevels(col.defined)
+			page = binary.LittleEndian.AppendUint32(page, uint32(len(levels)))
+			page = append(page, levels...)
+		}
+		if col.column.Type == TypeBool {
+			page = append(page, packBools(col.bools)...)
+		} else {
+			page = append(page, col.values...)
+		}
+
+		var header thriftWriter
+		header.structBegin()
+		header.i32Field(1, 0) // DATA_PAGE
+		header.i32Field(2, int32(len(page)))
+		header.i32Field(3, int32(len(page)))
+		header.fieldBegin(5, thriftStruct)
+		header.structBegin()
+		header.i32Field(1, int32(rows))
+		header.i32Field(2, parquetPlain)
+		header.i32Field(3, parquetRLE)
+		header.i32Field(4, parquetRLE)
+		header.structEnd()
+		header.structEnd()
+
+		chunk := parquetChunk{offset: p.w.n, numValues: int64(rows)}
+		if _, err := p.w.Write(header.buf); err != nil {
+			return err
+		}
+		if _, err := p.w.Write(page); err != nil {
+			return err
+		}
+		chunk.size = p.w.n - chunk.offset
+		chunk.uncompressedSize = chunk.size
+		group.chunks = append(group.chunks, chunk)
+		group.size += chunk.size
+
+		col.values, col.bools, col.defined, col.rows = col.values[:0], col.bools[:0], col.defined[:0], 0
+	}
+	p.rowGroups = append(p.rowGroups, group)
+	p.buffered = 0
+	return nil
+}
+
+// Close 写出剩余数据和文件元数据
+func (p *parquetWriter) Close() error {
+	if err := p.flushRowGroup(); err != nil {
+		return err
+	}
+
+	var totalRows int64
+	for _, group := range p.rowGroups {
+		totalRows += group.rows
+	}
+
+	var meta thriftWriter
+	meta.structBegin()
+	meta.i32Field(1, 1) // version
+
+	// schema：根节点 + 每列一个叶子节点
+	meta.listField(2, thriftStruct, len(p.columns)+1)
+	meta.structBegin()
+	meta.binaryField(4, "schema")
+	meta.i32Field(5, int32(len(p.columns)))
+	meta.structEnd()
+	for _, col := range p.columns {
+		meta.structBegin()
+		meta.i32Field(1, col.column.physicalType())
+		repetition := int32(0) // REQUIRED
+		if col.column.Nullable {
+			repetition = 1 // OPTIONAL
+		}
+		meta.i32Field(3, repetition)
+		meta.binaryField(4, col.column.Name)
+		if converted, ok := col.column.convertedType(); ok {
+			meta.i32Field(6, converted)
+		}
+		meta.structEnd()
+	}
+
+	meta.i64Field(3, totalRows)
+
+	meta.listField(4, thriftStruct, len(p.rowGroups))
+	for _, group := range p.rowGroups {
+		meta.structBegin()
+		meta.listField(1, thriftStruct, len(group.chunks))
+		for i, chunk := range group.chunks {
+			col := p.columns[i]
+			meta.structBegin()
+			meta.i64Field(2, chunk.offset)
+			meta.fieldBegin(3, thriftStruct)
+			meta.structBegin()
+			meta.i32Field(1, col.column.physicalType())
+			meta.listField(2, thriftI32, 2)
+			meta.varint(zigzag(parquetPlain))
+			meta.varint(zigzag(parquetRLE))
+			meta.listField(3, thriftBinary, 1)
+			meta.binary(col.column.Name)
+			meta.i32Field(4, 0) // UNCOMPRESSED
+			meta.i64Field(5, chunk.numValues)
+			meta.i64Field(6, chunk.uncompressedSize)
+			meta.i64Field(7, chunk.size)
+			meta.i64Field(9, chunk.offset)
+			meta.structEnd()
+			meta.structEnd()
+		}
+		meta.i64Field(2, group.size)
+		meta.i64Field(3, group.rows)
+		meta.structEnd()
+	}
+	meta.binaryField(6, "nofx")
+	meta.structEnd()
+
+	if _, err := p.w.Write(meta.buf); err != nil {
+		return err
+	}
+	footer := binary.LittleEndian.AppendUint32(nil, uint32(len(meta.buf)))
+	footer = append(footer, "PAR1"...)
+	if _, err := p.w.Write(footer); err != nil {
+		return err
+	}
+	return p.bw.Flush()
+}
+
+func (c Column) physicalType() int32 {
+	switch c.Type {
+	case TypeString:
+		return parquetByteArray
+	case TypeFloat:
+		return parquetDouble
+	case TypeBool:
+		return parquetBoolean
+	default:
+		return parquetInt64
+	}
+}
+
+func (c Column) convertedType() (int32, bool) {
+	switch c.Type {
+	case TypeString:
+		return parquetUTF8, true
+	case TypeTime:
+		return parquetTimestampMillis, true
+	}
+	return 0, false
+}
+
+// encodeDefinitionLevels 定义级别（0 为空，1 有值）的 RLE 编码，位宽为1
+func encodeDefinitionLevels(defined []bool) []byte {
+	var buf []byte
+	for i := 0; i < len(defined); {
+		j := i
+		for j < len(defined) && defined[j] == defined[i] {
+			j++
+		}
+		buf = binary.AppendUvarint(buf, uint64(j-i)<<1)
+		if defined[i] {
+			buf = append(buf, 1)
+		} else {
+			buf = append(buf, 0)
+		}
+		i = j
+	}
+	return buf
+}
+
+// packBools 布尔值的 PLAIN 编码（按位打包，低位在前）
+func packBools(values []bool) []byte {
+	buf := make([]byte, (len(values)+7)/8)
+	for i, v := range values {
+		if v {
+			buf[i/8] |= 1 << (i % 8)
+		}
+	}
+	return buf
+}
+
+func toInt64(value interface{}) int64 {
+	switch v := value.(type) {
+	case int:
+		return int64(v)
+	case int64:
+		return v
+	case int32:
+		return int64(v)
+	}
+	return 0
+}
+
+// countingWriter 记录已写出的字节数（列块偏移量）
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// Thrift compact 协议类型
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter Parquet 元数据使用的 Thrift compact 协议编码（只实现写入用到的部分）
+type thriftWriter struct {
+	buf       []byte
+	lastField []int16
+}
+
+func (t *thriftWriter) structBegin() {
+	t.lastField = append(t.lastField, 0)
+}
+
+func (t *thriftWriter) structEnd() {
+	t.buf = append(t.buf, 0) // STOP
+	t.lastField = t.lastField[:len(t.lastField)-1]
+}
+
+func (t *thriftWriter) fieldBegin(id int16, typ byte) {
+	last := &t.lastField[len(t.lastField)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		t.buf = append(t.buf, byte(delta)<<4|typ)
+	} else {
+		t.buf = append(t.buf, typ)
+		t.varint(zigzag(int64(id)))
+	}
+	*last = id
+}
+
+func (t *thriftWriter) i32Field(id int16, v int32) {
+	t.fieldBegin(id, thriftI32)
+	t.varint(zigzag(int64(v)))
+}
+
+func (t *thriftWriter) i64Field(id int16, v int64) {
+	t.fieldBegin(id, thriftI64)
+	t.varint(zigzag(v))
+}
+
+func (t *thriftWriter) binaryField(id int16, s string) {
+	t.fieldBegin(id, thriftBinary)
+	t.binary(s)
+}
+
+func (t *thriftWriter) listField(id int16, elemType byte, size int) {
+	t.fieldBegin(id, thriftList)
+	if size < 15 {
+		t.buf = append(t.buf, byte(size)<<4|elemType)
+	} else {
+		t.buf = append(t.buf, 0xF0|elemType)
+		t.varint(uint64(size))
+	}
+}
+
+func (t *thriftWriter) binary(s string) {
+	t.varint(uint64(len(s)))
+	t.buf = append(t.buf, s...)
+}
+
+func (t *thriftWriter) varint(v uint64) {
+	t.buf = binary.AppendUvarint(t.buf, v)
+}
+
+func zigzag(v int64) uint64 {
+	return uint64((v << 1) ^ (v >> 63))
+}
@@ -0,0 +1,72 @@
+package export
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ParseTimeRange 解析 range（24h/7d/4w/1y/all）或 start/end（RFC3339 或 2006-01-02）时间范围
+// start 优先于 range；end 默认为当前时间；ranged=false 表示三个参数都未指定
+func ParseTimeRange(rangeStr, startStr, endStr string) (start, end time.Time, ranged bool, err error) {
+	end = time.Now()
+	if endStr != "" {
+		t, dateOnly, err := parseTime(endStr)
+		if err != nil {
+			return start, end, false, fmt.Errorf("无效的end参数: %s", endStr)
+		}
+		end = t
+		if dateOnly {
+			end = t.AddDate(0, 0, 1).Add(-time.Nanosecond) // 包含当天
+		}
+		ranged = true
+	}
+
+	if startStr != "" {
+		if start, _, err = parseTime(startStr); err != nil {
+			return start, end, false, fmt.Errorf("无效的start参数: %s", startStr)
+		}
+		ranged = true
+	} else if rangeStr != "" {
+		if rangeStr != "all" {
+			span, err := parseSpan(rangeStr)
+			if err != nil {
+				return start, end, false, err
+			}
+			start = end.Add(-span)
+		}
+		ranged = true
+	}
+
+	if ranged && !start.IsZero() && !start.Before(end) {
+		return start, end, false, fmt.Errorf("start必须早于end")
+	}
+	return start, end, ranged, nil
+}
+
+// parseTime 解析 RFC3339 时间或 2006-01-02 日期（本地时区）
+func parseTime(v string) (t time.Time, dateOnly bool, err error) {
+	if t, err = time.Parse(time.RFC3339, v); err == nil {
+		return t, false, nil
+	}
+	t, err = time.ParseInLocation("2006-01-02", v, time.Local)
+	return t, true, err
+}
+
+// parseSpan 解析 24h / 7d / 4w / 1y 形式的时间跨度
+func parseSpan(v string) (time.Duration, error) {
+	units := map[byte]time.Duration{
+		'h': time.Hour,
+		'd': 24 * time.Hour,
+		'w': 7 * 24 * time.Hour,
+		'y': 365 * 24 * time.Hour,
+	}
+	if len(v) >= 2 {
+		if unit, ok := units[v[len(v)-1]]; ok {
+			if n, err := strconv.Atoi(v[:len(v)-1]); err == nil && n > 0 {
+				return time.Duration(n) * unit, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("无效的range参数: %s（支持 24h、7d、4w、1y、all）", v)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"nofx/config"
+	"nofx/export"
+	"nofx/logger"
+	"os"
+	"path/filepath"
+)
+
+// runExport 导出交易员的数据集:
+// nofx export -trader <id> -dataset decisions|actions|trades|equity|prompts [-format csv|jsonl|parquet] [-range 30d | -start 2025-01-01 -end 2025-02-01] [-out file] [-db config.db]
+// 数据来源与运行时一致：decision_log_storage=file 时读取 decision_logs/<id> 和 trade_ledger/<id>.json，否则读取数据库
+// 超过完整保留期（decision_full_retention_days）的记录已去掉提示词，prompts 数据集从 decision_archive_dir 下的gzip归档找回，
+// 归档缺失时这些周期不导出
+func runExport(args []string) {
+	// 未指定 -out 时数据写入 stdout，日志输出到 stderr
+	log.SetOutput(os.Stderr)
+
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	dbPath := fs.String("db", "config.db", "配置数据库路径")
+	traderID := fs.String("trader", "", "交易员ID（必填）")
+	datasetName := fs.String("dataset", "decisions", "数据集: decisions、actions、trades、equity、prompts")
+	formatName := fs.String("format", "csv", "导出格式: csv、jsonl、parquet（prompts 只支持 jsonl）")
+	rangeStr := fs.String("range", "", "时间范围: 24h、7d、4w、1y、all")
+	startStr := fs.String("start", "", "开始时间（RFC3339 或 2006-01-02），优先于 -range")
+	endStr := fs.String("end", "", "结束时间（RFC3339 或 2006-01-02），默认当前时间")
+	outPath := fs.String("out", "", "输出文件，默认写入 stdout")
+	fs.Parse(args)
+
+	if *traderID == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	dataset, err := export.ParseDataset(*datasetName)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	format, err := export.ParseFormat(*formatName)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	start, end, _, err := export.ParseTimeRange(*rangeStr, *startStr, *endStr)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	database, err := config.NewDatabase(*dbPath)
+	if err != nil {
+		log.Fatalf("❌ 初始化数据库失败: %v", err)
+	}
+	defer database.Close()
+
+	req := export.Request{TraderID: *traderID, Dataset: dataset, Format: format, Start: start, End: end,
+		ArchiveDir: database.DecisionArchiveDir(*traderID)}
+	if err := req.Validate(); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	decisionLogger := openDecisionLogger(database, *traderID)
+
+	out := os.Stdout
+	if *outPath != "" {
+		if out, err = os.Create(*outPath); err != nil {
+			log.Fatalf("❌ 创建输出文件失败: %v", err)
+		}
+	}
+	w := bufio.NewWriter(out)
+	if err := export.Write(w, decisionLogger, req); err != nil {
+		log.Fatalf("❌ 导出失败: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatalf("❌ 写入输出失败: %v", err)
+	}
+	if err := out.Close(); err != nil {
+		log.Fatalf("❌ 写入输出失败: %v", err)
+	}
+	if *outPath != "" {
+		fmt.Fprintf(os.Stderr, "✓ 已导出 %s 到 %s\n", dataset, *outPath)
+	}
+}
+
+// openDecisionLogger 按 decision_log_storage 打开交易员的决策日志和交易账本（与运行时使用同一份数据）
+func openDecisionLogger(database *config.Database, traderID string) *logger.DecisionLogger {
+	if storage, _ := database.GetSystemConfig("decision_log_storage"); storage == "file" {
+		decisionLogger := logger.NewDecisionLogger(filepath.Join("decision_logs", traderID))
+		ledger, err := logger.NewFileTradeLedger(filepath.Join("trade_ledger", traderID+".json"))
+		if err != nil {
+			log.Fatalf("❌ 加载交易账本失败: %v", err)
+		}
+		decisionLogger.SetTradeLedger(ledger)
+		return decisionLogger
+	}
+	decisionLogger := logger.NewDecisionLoggerWithStore(database.DecisionStore(traderID))
+	decisionLogger.SetTradeLedger(database.TradeLedger(traderID))
+	return decisionLogger
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"log"
+	"nofx/config"
+	"os"
+	"path/filepath"
+)
+
+// runImportDecisionLogs 把旧版JSON决策日志导入数据库: nofx import-decision-logs [dbPath] [logsDir]
+// logsDir（默认 decision_logs）下每个子目录对应一个交易员（目录名为交易员ID），已导入过的记录会被跳过
+func runImportDecisionLogs(args []string) {
+	dbPath := "config.db"
+	if len(args) > 0 {
+		dbPath = args[0]
+	}
+	logsDir := "decision_logs"
+	if len(args) > 1 {
+		logsDir = args[1]
+	}
+
+	database, err := config.NewDatabase(dbPath)
+	if err != nil {
+		log.Fatalf("❌ 初始化数据库失败: %v", err)
+	}
+	defer database.Close()
+
+	entries, err := os.ReadDir(logsDir)
+	if err != nil {
+		log.Fatalf("❌ 读取决策日志目录失败: %v", err)
+	}
+
+	total := 0
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		traderID := entry.Name()
+		imported, err := database.DecisionStore(traderID).ImportDir(filepath.Join(logsDir, traderID))
+		if err != nil {
+			log.Printf("⚠️  导入交易员 %s 的决策日志失败: %v", traderID, err)
+		}
+		log.Printf("📥 交易员 %s: 新导入 %d 条决策记录", traderID, imported)
+		total += imported
+	}
+	log.Printf("✅ 决策日志导入完成，共导入 %d 条记录", total)
+}
@@ -1,30 +1,45 @@
 package logger
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"math"
-	"os"
-	"path/filepath"
+	"sync"
 	"time"
 )
 
 // DecisionRecord 决策记录
 type DecisionRecord struct {
-	Timestamp      time.Time          `json:"timestamp"`       // 决策时间
-	CycleNumber    int                `json:"cycle_number"`    // 周期编号
-	SystemPrompt   string             `json:"system_prompt"`   // 系统提示词（发送给AI的系统prompt）
-	InputPrompt    string             `json:"input_prompt"`    // 发送给AI的输入prompt
-	CoTTrace       string             `json:"cot_trace"`       // AI思维链（输出）
-	DecisionJSON   string             `json:"decision_json"`   // 决策JSON
-	AccountState   AccountSnapshot    `json:"account_state"`   // 账户状态快照
-	Positions      []PositionSnapshot `json:"positions"`       // 持仓快照
-	CandidateCoins []string           `json:"candidate_coins"` // 候选币种列表
-	Decisions      []DecisionAction   `json:"decisions"`       // 执行的决策
-	ExecutionLog   []string           `json:"execution_log"`   // 执行日志
-	Success        bool               `json:"success"`         // 是否成功
-	ErrorMessage   string             `json:"error_message"`   // 错误信息（如果有）
+	Timestamp      time.Time             `json:"timestamp"`                  // 决策时间
+	CycleNumber    int                   `json:"cycle_number"`               // 周期编号
+	SystemPrompt   string                `json:"system_prompt"`              // 系统提示词（发送给AI的系统prompt）
+	InputPrompt    string                `json:"input_prompt"`               // 发送给AI的输入prompt
+	CoTTrace       string                `json:"cot_trace"`                  // AI思维链（输出）
+	DecisionJSON   string                `json:"decision_json"`              // 决策JSON
+	AccountState   AccountSnapshot       `json:"account_state"`              // 账户状态快照
+	Positions      []PositionSnapshot    `json:"positions"`                  // 持仓快照
+	CandidateCoins []string              `json:"candidate_coins"`            // 候选币种列表
+	Decisions      []DecisionAction      `json:"decisions"`                  // 执行的决策
+	ExecutionLog   []string              `json:"execution_log"`              // 执行日志
+	Success        bool                  `json:"success"`                    // 是否成功
+	ErrorMessage   string                `json:"error_message"`              // 错误信息（如果有）
+	AIQueueWaitMs  int64                 `json:"ai_queue_wait_ms,omitempty"` // AI请求在限流器中的排队时长（毫秒）
+	AIAttempts     int                   `json:"ai_attempts,omitempty"`      // AI请求次数（含重试）
+	Pipeline       string                `json:"pipeline,omitempty"`         // 决策流程（为空表示单次调用）
+	PipelineStages []PipelineStageRecord `json:"pipeline_stages,omitempty"`  // 多阶段流程中每个阶段的记录
+
+	Compacted        bool   `json:"compacted,omitempty"`          // 是否已压缩（提示词已移入gzip归档）
+	SystemPromptHash string `json:"system_prompt_hash,omitempty"` // 压缩时记录的系统提示词哈希（区分提示词版本）
+	Aggregated       bool   `json:"aggregated,omitempty"`         // 是否已汇总为每日统计（只保留账户快照和动作，统计时不再重复计入）
+}
+
+// PipelineStageRecord 多阶段决策流程中单个阶段的记录
+type PipelineStageRecord struct {
+	Role         string `json:"role"`          // 阶段角色（analyst / risk_manager / executor）
+	Template     string `json:"template"`      // 阶段提示词模板
+	SystemPrompt string `json:"system_prompt"` // 该阶段的系统提示词
+	UserPrompt   string `json:"user_prompt"`   // 该阶段的输入
+	Response     string `json:"response"`      // 该阶段的AI输出
+	DurationMs   int64  `json:"duration_ms"`   // 耗时（毫秒）
+	Error        string `json:"error,omitempty"`
 }
 
 // AccountSnapshot 账户状态快照
@@ -59,149 +74,121 @@ type DecisionAction struct {
 	Timestamp time.Time `json:"timestamp"` // 执行时间
 	Success   bool      `json:"success"`   // 是否成功
 	Error     string    `json:"error"`     // 错误信息
+
+	EstimatedSlippagePct float64 `json:"estimated_slippage_pct,omitempty"` // 下单前根据订单簿预估的滑点（%）
+	TradeID              int64   `json:"trade_id,omitempty"`               // 对应交易账本中的交易ID（开仓/平仓成功时）
+}
+
+// DecisionStore 决策记录的存储后端
+type DecisionStore interface {
+	// Save 保存一条记录（CycleNumber 和 Timestamp 已由 DecisionLogger 填写）
+	Save(record *DecisionRecord) error
+	// Latest 最近n条记录（按时间正序：从旧到新）
+	Latest(n int) ([]*DecisionRecord, error)
+	// AccountHistory 最近n条记录的账户快照，只保证 Timestamp、CycleNumber、AccountState 有值（按时间正序）
+	AccountHistory(n int) ([]*DecisionRecord, error)
+	// AccountHistoryBetween [start, end] 内的账户快照（start 为零值表示不限开始时间），字段保证同 AccountHistory
+	AccountHistoryBetween(start, end time.Time) ([]*DecisionRecord, error)
+	// Walk 按时间正序逐条读取 [start, end] 内的完整记录（用于导出，不一次性加载全部记录）
+	Walk(start, end time.Time, fn func(*DecisionRecord) error) error
+	// ByDate 指定日期的所有记录
+	ByDate(date time.Time) ([]*DecisionRecord, error)
+	// DeleteBefore 删除 cutoff 之前的记录，返回删除条数
+	DeleteBefore(cutoff time.Time) (int, error)
+	// Statistics 全部记录的统计信息
+	Statistics() (*Statistics, error)
+	// LastCycleNumber 已保存的最大周期编号（重启后从下一个周期继续编号）
+	LastCycleNumber() (int, error)
+	// Compact 按时间正序分批压缩 before 之前尚未压缩的记录：每批完整记录先交给 archive，成功后替换为 CompactRecord 的结果，返回压缩条数
+	Compact(before time.Time, archive func([]*DecisionRecord) error) (int, error)
+	// Aggregate 合并保存每日统计（由 before 之前尚未汇总的记录汇总而来），并把这些记录精简为 AggregatedRecord 的结果，返回精简条数
+	Aggregate(before time.Time, stats []*DailyStats) (int, error)
+	// DailyStats 已汇总的每日统计（按日期正序）
+	DailyStats() ([]*DailyStats, error)
+	// Usage 记录的存储占用（不含gzip归档）
+	Usage() (*StorageUsage, error)
 }
 
 // DecisionLogger 决策日志记录器
 type DecisionLogger struct {
-	logDir      string
+	store       DecisionStore
+	ledger      TradeLedger // 交易账本（交易表现统计的数据来源）
+	mu          sync.Mutex
 	cycleNumber int
 }
 
-// NewDecisionLogger 创建决策日志记录器
+// NewDecisionLogger 创建使用JSON文件存储的决策日志记录器
 func NewDecisionLogger(logDir string) *DecisionLogger {
-	if logDir == "" {
-		logDir = "decision_logs"
-	}
+	return NewDecisionLoggerWithStore(NewFileStore(logDir))
+}
 
-	// 确保日志目录存在
-	if err := os.MkdirAll(logDir, 0755); err != nil {
-		fmt.Printf("⚠ 创建日志目录失败: %v\n", err)
+// NewDecisionLoggerWithStore 创建使用指定存储后端的决策日志记录器
+func NewDecisionLoggerWithStore(store DecisionStore) *DecisionLogger {
+	cycleNumber, err := store.LastCycleNumber()
+	if err != nil {
+		fmt.Printf("⚠ 读取最近周期编号失败: %v\n", err)
 	}
-
 	return &DecisionLogger{
-		logDir:      logDir,
-		cycleNumber: 0,
+		store:       store,
+		cycleNumber: cycleNumber,
 	}
 }
 
 // LogDecision 记录决策
 func (l *DecisionLogger) LogDecision(record *DecisionRecord) error {
+	l.mu.Lock()
 	l.cycleNumber++
 	record.CycleNumber = l.cycleNumber
+	l.mu.Unlock()
 	record.Timestamp = time.Now()
 
-	// 生成文件名：decision_YYYYMMDD_HHMMSS_cycleN.json
-	filename := fmt.Sprintf("decision_%s_cycle%d.json",
-		record.Timestamp.Format("20060102_150405"),
-		record.CycleNumber)
-
-	filepath := filepath.Join(l.logDir, filename)
-
-	// 序列化为JSON（带缩进，方便阅读）
-	data, err := json.MarshalIndent(record, "", "  ")
-	if err != nil {
-		return fmt.Errorf("序列化决策记录失败: %w", err)
-	}
+	return l.store.Save(record)
+}
 
-	// 写入文件
-	if err := ioutil.WriteFile(filepath, data, 0644); err != nil {
-		return fmt.Errorf("写入决策记录失败: %w", err)
-	}
+// SetTradeLedger 设置交易账本
+func (l *DecisionLogger) SetTradeLedger(ledger TradeLedger) {
+	l.mu.Lock()
+	l.ledger = ledger
+	l.mu.Unlock()
+}
 
-	fmt.Printf("📝 决策记录已保存: %s\n", filename)
-	return nil
+// TradeLedger 获取交易账本（未设置时返回 nil）
+func (l *DecisionLogger) TradeLedger() TradeLedger {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.ledger
 }
 
 // GetLatestRecords 获取最近N条记录（按时间正序：从旧到新）
 func (l *DecisionLogger) GetLatestRecords(n int) ([]*DecisionRecord, error) {
-	files, err := ioutil.ReadDir(l.logDir)
-	if err != nil {
-		return nil, fmt.Errorf("读取日志目录失败: %w", err)
-	}
-
-	// 先按修改时间倒序收集（最新的在前）
-	var records []*DecisionRecord
-	count := 0
-	for i := len(files) - 1; i >= 0 && count < n; i-- {
-		file := files[i]
-		if file.IsDir() {
-			continue
-		}
-
-		filepath := filepath.Join(l.logDir, file.Name())
-		data, err := ioutil.ReadFile(filepath)
-		if err != nil {
-			continue
-		}
-
-		var record DecisionRecord
-		if err := json.Unmarshal(data, &record); err != nil {
-			continue
-		}
+	return l.store.Latest(n)
+}
 
-		records = append(records, &record)
-		count++
-	}
+// GetAccountHistory 获取最近N条记录的账户快照（按时间正序，不含提示词等大字段，用于收益曲线）
+func (l *DecisionLogger) GetAccountHistory(n int) ([]*DecisionRecord, error) {
+	return l.store.AccountHistory(n)
+}
 
-	// 反转数组，让时间从旧到新排列（用于图表显示）
-	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
-		records[i], records[j] = records[j], records[i]
-	}
+// GetAccountHistoryBetween 获取 [start, end] 内的账户快照（按时间正序）
+func (l *DecisionLogger) GetAccountHistoryBetween(start, end time.Time) ([]*DecisionRecord, error) {
+	return l.store.AccountHistoryBetween(start, end)
+}
 
-	return records, nil
+// WalkRecords 按时间正序逐条读取 [start, end] 内的完整记录
+func (l *DecisionLogger) WalkRecords(start, end time.Time, fn func(*DecisionRecord) error) error {
+	return l.store.Walk(start, end, fn)
 }
 
 // GetRecordByDate 获取指定日期的所有记录
 func (l *DecisionLogger) GetRecordByDate(date time.Time) ([]*DecisionRecord, error) {
-	dateStr := date.Format("20060102")
-	pattern := filepath.Join(l.logDir, fmt.Sprintf("decision_%s_*.json", dateStr))
-
-	files, err := filepath.Glob(pattern)
-	if err != nil {
-		return nil, fmt.Errorf("查找日志文件失败: %w", err)
-	}
-
-	var records []*DecisionRecord
-	for _, filepath := range files {
-		data, err := ioutil.ReadFile(filepath)
-		if err != nil {
-			continue
-		}
-
-		var record DecisionRecord
-		if err := json.Unmarshal(data, &record); err != nil {
-			continue
-		}
-
-		records = append(records, &record)
-	}
-
-	return records, nil
+	return l.store.ByDate(date)
 }
 
 // CleanOldRecords 清理N天前的旧记录
 func (l *DecisionLogger) CleanOldRecords(days int) error {
-	cutoffTime := time.Now().AddDate(0, 0, -days)
-
-	files, err := ioutil.ReadDir(l.logDir)
+	removedCount, err := l.store.DeleteBefore(time.Now().AddDate(0, 0, -days))
 	if err != nil {
-		return fmt.Errorf("读取日志目录失败: %w", err)
-	}
-
-	removedCount := 0
-	for _, file := range files {
-		if file.IsDir() {
-			continue
-		}
-
-		if file.ModTime().Before(cutoffTime) {
-			filepath := filepath.Join(l.logDir, file.Name())
-			if err := os.Remove(filepath); err != nil {
-				fmt.Printf("⚠ 删除旧记录失败 %s: %v\n", file.Name(), err)
-				continue
-			}
-			removedCount++
-		}
+		return err
 	}
 
 	if removedCount > 0 {
@@ -213,50 +200,7 @@ func (l *DecisionLogger) CleanOldRecords(days int) error {
 
 // GetStatistics 获取统计信息
 func (l *DecisionLogger) GetStatistics() (*Statistics, error) {
-	files, err := ioutil.ReadDir(l.logDir)
-	if err != nil {
-		return nil, fmt.Errorf("读取日志目录失败: %w", err)
-	}
-
-	stats := &Statistics{}
-
-	for _, file := range files {
-		if file.IsDir() {
-			continue
-		}
-
-		filepath := filepath.Join(l.logDir, file.Name())
-		data, err := ioutil.ReadFile(filepath)
-		if err != nil {
-			continue
-		}
-
-		var record DecisionRecord
-		if err := json.Unmarshal(data, &record); err != nil {
-			continue
-		}
-
-		stats.TotalCycles++
-
-		for _, action := range record.Decisions {
-			if action.Success {
-				switch action.Action {
-				case "open_long", "open_short":
-					stats.TotalOpenPositions++
-				case "close_long", "close_short":
-					stats.TotalClosePositions++
-				}
-			}
-		}
-
-		if record.Success {
-			stats.SuccessfulCycles++
-		} else {
-			stats.FailedCycles++
-		}
-	}
-
-	return stats, nil
+	return l.store.Statistics()
 }
 
 // Statistics 统计信息
@@ -268,6 +212,28 @@ type Statistics struct {
 	TotalClosePositions int `json:"total_close_positions"`
 }
 
+// add 把一条记录计入统计
+func (s *Statistics) add(record *DecisionRecord) {
+	s.TotalCycles++
+
+	for _, action := range record.Decisions {
+		if action.Success {
+			switch action.Action {
+			case "open_long", "open_short":
+				s.TotalOpenPositions++
+			case "close_long", "close_short":
+				s.TotalClosePositions++
+			}
+		}
+	}
+
+	if record.Success {
+		s.SuccessfulCycles++
+	} else {
+		s.FailedCycles++
+	}
+}
+
 // TradeOutcome 单笔交易结果
 type TradeOutcome struct {
 	Symbol        string    `json:"symbol"`         // 币种
@@ -284,6 +250,11 @@ type TradeOutcome struct {
 	OpenTime      time.Time `json:"open_time"`      // 开仓时间
 	CloseTime     time.Time `json:"close_time"`     // 平仓时间
 	WasStopLoss   bool      `json:"was_stop_loss"`  // 是否止损
+
+	TradeID    int64   `json:"trade_id,omitempty"` // 交易账本中的交易ID
+	ExitReason string  `json:"exit_reason"`        // 平仓原因
+	Fees       float64 `json:"fees"`               // 手续费（USDT）
+	Funding    float64 `json:"funding"`            // 资金费净额（USDT）
 }
 
 // PerformanceAnalysis 交易表现分析
@@ -295,11 +266,35 @@ type PerformanceAnalysis struct {
 	AvgWin        float64                       `json:"avg_win"`        // 平均盈利
 	AvgLoss       float64                       `json:"avg_loss"`       // 平均亏损
 	ProfitFactor  float64                       `json:"profit_factor"`  // 盈亏比
-	SharpeRatio   float64                       `json:"sharpe_ratio"`   // 夏普比率（风险调整后收益）
+	SharpeRatio   float64                       `json:"sharpe_ratio"`   // 年化夏普比率（风险调整后收益）
 	RecentTrades  []TradeOutcome                `json:"recent_trades"`  // 最近N笔交易
 	SymbolStats   map[string]*SymbolPerformance `json:"symbol_stats"`   // 各币种表现
 	BestSymbol    string                        `json:"best_symbol"`    // 表现最好的币种
 	WorstSymbol   string                        `json:"worst_symbol"`   // 表现最差的币种
+
+	// 净值曲线指标（比率均已年化，无风险利率按0计）
+	StartTime           time.Time `json:"start_time"`            // 分析开始时间（第一条账户快照）
+	EndTime             time.Time `json:"end_time"`              // 分析结束时间（最后一条账户快照）
+	StartEquity         float64   `json:"start_equity"`          // 开始净值
+	EndEquity           float64   `json:"end_equity"`            // 结束净值
+	TotalReturnPct      float64   `json:"total_return_pct"`      // 区间收益率（%）
+	AnnualizedReturnPct float64   `json:"annualized_return_pct"` // 年化收益率（%）
+	MaxDrawdown         *Drawdown `json:"max_drawdown"`          // 最大回撤
+	SortinoRatio        float64   `json:"sortino_ratio"`         // 年化索提诺比率（只计下行波动）
+	CalmarRatio         float64   `json:"calmar_ratio"`          // 卡玛比率（年化收益率 / 最大回撤）
+
+	// 交易明细统计
+	TotalPnL          float64            `json:"total_pnl"`           // 总净盈亏（USDT）
+	TotalFees         float64            `json:"total_fees"`          // 总手续费（USDT）
+	TotalFunding      float64            `json:"total_funding"`       // 总资金费净额（USDT）
+	Expectancy        float64            `json:"expectancy"`          // 每笔交易的期望净盈亏（USDT）
+	AvgHoldingMinutes float64            `json:"avg_holding_minutes"` // 平均持仓时长（分钟）
+	LongStats         *TradeGroupStats   `json:"long_stats"`          // 多单统计
+	ShortStats        *TradeGroupStats   `json:"short_stats"`         // 空单统计
+	HourlyStats       []*TradeGroupStats `json:"hourly_stats"`        // 按开仓小时（本地时间0-23点）统计
+	WeekdayStats      []*TradeGroupStats `json:"weekday_stats"`       // 按开仓星期（周日起）统计
+
+	Benchmark *BenchmarkComparison `json:"benchmark,omitempty"` // 同期买入持有基准对比
 }
 
 // SymbolPerformance 币种表现统计
@@ -313,169 +308,137 @@ type SymbolPerformance struct {
 	AvgPnL        float64 `json:"avg_pn_l"`       // 平均盈亏
 }
 
-// AnalyzePerformance 分析最近N个周期的交易表现
+// AnalyzePerformance 分析最近N个周期内平仓的交易表现
+// 交易来自交易账本（开仓可以早于分析窗口），没有账本时从窗口内的决策日志配对重建
 func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAnalysis, error) {
-	records, err := l.GetLatestRecords(lookbackCycles)
+	records, err := l.GetAccountHistory(lookbackCycles)
 	if err != nil {
 		return nil, fmt.Errorf("读取历史记录失败: %w", err)
 	}
 
-	if len(records) == 0 {
-		return &PerformanceAnalysis{
-			RecentTrades: []TradeOutcome{},
-			SymbolStats:  make(map[string]*SymbolPerformance),
-		}, nil
+	// 分析窗口从最早一条记录开始；记录不足N条时统计全部交易
+	var since time.Time
+	if len(records) >= lookbackCycles && len(records) > 0 {
+		since = records[0].Timestamp
 	}
 
-	analysis := &PerformanceAnalysis{
-		RecentTrades: []TradeOutcome{},
-		SymbolStats:  make(map[string]*SymbolPerformance),
+	var trades []*Trade
+	if ledger := l.TradeLedger(); ledger != nil {
+		trades, err = ledger.ClosedTrades(since, 0)
+		if err != nil {
+			return nil, fmt.Errorf("读取交易账本失败: %w", err)
+		}
+	} else {
+		fullRecords, err := l.GetLatestRecords(lookbackCycles)
+		if err != nil {
+			return nil, fmt.Errorf("读取历史记录失败: %w", err)
+		}
+		trades = TradesFromDecisionLogs(fullRecords)
+	}
+
+	analysis := AnalyzeTrades(trades)
+	analysis.applyEquityCurve(records)
+
+	return analysis, nil
+}
+
+// AnalyzePerformanceBetween 分析 [start, end] 时间范围内的表现（start 为零值表示从最早的记录开始）
+// 净值曲线取范围内的账户快照，交易取范围内平仓的交易
+func (l *DecisionLogger) AnalyzePerformanceBetween(start, end time.Time) (*PerformanceAnalysis, error) {
+	records, err := l.GetAccountHistoryBetween(start, end)
+	if err != nil {
+		return nil, fmt.Errorf("读取历史记录失败: %w", err)
 	}
 
-	// 追踪持仓状态：symbol_side -> {side, openPrice, openTime, quantity, leverage}
-	openPositions := make(map[string]map[string]interface{})
-
-	// 为了避免开仓记录在窗口外导致匹配失败，需要先从所有历史记录中找出未平仓的持仓
-	// 获取更多历史记录来构建完整的持仓状态（使用更大的窗口）
-	allRecords, err := l.GetLatestRecords(lookbackCycles * 3) // 扩大3倍窗口
-	if err == nil && len(allRecords) > len(records) {
-		// 先从扩大的窗口中收集所有开仓记录
-		for _, record := range allRecords {
-			for _, action := range record.Decisions {
-				if !action.Success {
-					continue
-				}
-
-				symbol := action.Symbol
-				side := ""
-				if action.Action == "open_long" || action.Action == "close_long" {
-					side = "long"
-				} else if action.Action == "open_short" || action.Action == "close_short" {
-					side = "short"
-				}
-				posKey := symbol + "_" + side
-
-				switch action.Action {
-				case "open_long", "open_short":
-					// 记录开仓
-					openPositions[posKey] = map[string]interface{}{
-						"side":      side,
-						"openPrice": action.Price,
-						"openTime":  action.Timestamp,
-						"quantity":  action.Quantity,
-						"leverage":  action.Leverage,
-					}
-				case "close_long", "close_short":
-					// 移除已平仓记录
-					delete(openPositions, posKey)
-				}
+	var trades []*Trade
+	if ledger := l.TradeLedger(); ledger != nil {
+		closed, err := ledger.ClosedTrades(start, 0)
+		if err != nil {
+			return nil, fmt.Errorf("读取交易账本失败: %w", err)
+		}
+		for _, trade := range closed {
+			if !trade.ExitTime.After(end) {
+				trades = append(trades, trade)
 			}
 		}
+	} else {
+		// 没有账本时从决策日志配对重建（只有文件存储返回完整记录）
+		trades = TradesFromDecisionLogs(records)
 	}
 
-	// 遍历分析窗口内的记录，生成交易结果
-	for _, record := range records {
-		for _, action := range record.Decisions {
-			if !action.Success {
-				continue
-			}
+	analysis := AnalyzeTrades(trades)
+	analysis.applyEquityCurve(records)
 
-			symbol := action.Symbol
-			side := ""
-			if action.Action == "open_long" || action.Action == "close_long" {
-				side = "long"
-			} else if action.Action == "open_short" || action.Action == "close_short" {
-				side = "short"
-			}
-			posKey := symbol + "_" + side // 使用symbol_side作为key，区分多空持仓
+	return analysis, nil
+}
 
-			switch action.Action {
-			case "open_long", "open_short":
-				// 更新开仓记录（可能已经在预填充时记录过了）
-				openPositions[posKey] = map[string]interface{}{
-					"side":      side,
-					"openPrice": action.Price,
-					"openTime":  action.Timestamp,
-					"quantity":  action.Quantity,
-					"leverage":  action.Leverage,
-				}
+// AnalyzeTrades 统计已平仓交易的表现（trades 按平仓时间正序，不计算净值曲线指标）
+func AnalyzeTrades(trades []*Trade) *PerformanceAnalysis {
+	analysis := &PerformanceAnalysis{
+		RecentTrades: []TradeOutcome{},
+		SymbolStats:  make(map[string]*SymbolPerformance),
+		MaxDrawdown:  &Drawdown{},
+	}
 
-			case "close_long", "close_short":
-				// 查找对应的开仓记录（可能来自预填充或当前窗口）
-				if openPos, exists := openPositions[posKey]; exists {
-					openPrice := openPos["openPrice"].(float64)
-					openTime := openPos["openTime"].(time.Time)
-					side := openPos["side"].(string)
-					quantity := openPos["quantity"].(float64)
-					leverage := openPos["leverage"].(int)
-
-					// 计算实际盈亏（USDT）
-					// 合约交易 PnL 计算：quantity × 价格差
-					// 注意：杠杆不影响绝对盈亏，只影响保证金需求
-					var pnl float64
-					if side == "long" {
-						pnl = quantity * (action.Price - openPrice)
-					} else {
-						pnl = quantity * (openPrice - action.Price)
-					}
-
-					// 计算盈亏百分比（相对保证金）
-					positionValue := quantity * openPrice
-					marginUsed := positionValue / float64(leverage)
-					pnlPct := 0.0
-					if marginUsed > 0 {
-						pnlPct = (pnl / marginUsed) * 100
-					}
-
-					// 记录交易结果
-					outcome := TradeOutcome{
-						Symbol:        symbol,
-						Side:          side,
-						Quantity:      quantity,
-						Leverage:      leverage,
-						OpenPrice:     openPrice,
-						ClosePrice:    action.Price,
-						PositionValue: positionValue,
-						MarginUsed:    marginUsed,
-						PnL:           pnl,
-						PnLPct:        pnlPct,
-						Duration:      action.Timestamp.Sub(openTime).String(),
-						OpenTime:      openTime,
-						CloseTime:     action.Timestamp,
-					}
-
-					analysis.RecentTrades = append(analysis.RecentTrades, outcome)
-					analysis.TotalTrades++
-
-					// 分类交易：盈利、亏损、持平（避免将pnl=0算入亏损）
-					if pnl > 0 {
-						analysis.WinningTrades++
-						analysis.AvgWin += pnl
-					} else if pnl < 0 {
-						analysis.LosingTrades++
-						analysis.AvgLoss += pnl
-					}
-					// pnl == 0 的交易不计入盈利也不计入亏损，但计入总交易数
-
-					// 更新币种统计
-					if _, exists := analysis.SymbolStats[symbol]; !exists {
-						analysis.SymbolStats[symbol] = &SymbolPerformance{
-							Symbol: symbol,
-						}
-					}
-					stats := analysis.SymbolStats[symbol]
-					stats.TotalTrades++
-					stats.TotalPnL += pnl
-					if pnl > 0 {
-						stats.WinningTrades++
-					} else if pnl < 0 {
-						stats.LosingTrades++
-					}
-
-					// 移除已平仓记录
-					delete(openPositions, posKey)
-				}
-			}
+	var closed []*Trade
+	for _, trade := range trades {
+		if trade.Status != TradeClosed {
+			continue
+		}
+		closed = append(closed, trade)
+		pnl := trade.NetPnL
+
+		// 盈亏百分比（相对保证金）
+		marginUsed := trade.MarginUsed()
+		pnlPct := 0.0
+		if marginUsed > 0 {
+			pnlPct = (pnl / marginUsed) * 100
+		}
+
+		analysis.RecentTrades = append(analysis.RecentTrades, TradeOutcome{
+			Symbol:        trade.Symbol,
+			Side:          trade.Side,
+			Quantity:      trade.Quantity,
+			Leverage:      trade.Leverage,
+			OpenPrice:     trade.EntryPrice,
+			ClosePrice:    trade.ExitPrice,
+			PositionValue: trade.Quantity * trade.EntryPrice,
+			MarginUsed:    marginUsed,
+			PnL:           pnl,
+			PnLPct:        pnlPct,
+			Duration:      trade.ExitTime.Sub(trade.EntryTime).String(),
+			OpenTime:      trade.EntryTime,
+			CloseTime:     trade.ExitTime,
+			WasStopLoss:   trade.ExitReason == ExitStopLoss,
+			TradeID:       trade.ID,
+			ExitReason:    trade.ExitReason,
+			Fees:          trade.Fees,
+			Funding:       trade.Funding,
+		})
+		analysis.TotalTrades++
+
+		// 分类交易：盈利、亏损、持平（避免将pnl=0算入亏损）
+		if pnl > 0 {
+			analysis.WinningTrades++
+			analysis.AvgWin += pnl
+		} else if pnl < 0 {
+			analysis.LosingTrades++
+			analysis.AvgLoss += pnl
+		}
+		// pnl == 0 的交易不计入盈利也不计入亏损，但计入总交易数
+
+		// 更新币种统计
+		stats, exists := analysis.SymbolStats[trade.Symbol]
+		if !exists {
+			stats = &SymbolPerformance{Symbol: trade.Symbol}
+			analysis.SymbolStats[trade.Symbol] = stats
+		}
+		stats.TotalTrades++
+		stats.TotalPnL += pnl
+		if pnl > 0 {
+			stats.WinningTrades++
+		} else if pnl < 0 {
+			stats.LosingTrades++
 		}
 	}
 
@@ -524,89 +487,14 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 	}
 
 	// 只保留最近的交易（倒序：最新的在前）
+	for i, j := 0, len(analysis.RecentTrades)-1; i < j; i, j = i+1, j-1 {
+		analysis.RecentTrades[i], analysis.RecentTrades[j] = analysis.RecentTrades[j], analysis.RecentTrades[i]
+	}
 	if len(analysis.RecentTrades) > 10 {
-		// 反转数组，让最新的在前
-		for i, j := 0, len(analysis.RecentTrades)-1; i < j; i, j = i+1, j-1 {
-			analysis.RecentTrades[i], analysis.RecentTrades[j] = analysis.RecentTrades[j], analysis.RecentTrades[i]
-		}
 		analysis.RecentTrades = analysis.RecentTrades[:10]
-	} else if len(analysis.RecentTrades) > 0 {
-		// 反转数组
-		for i, j := 0, len(analysis.RecentTrades)-1; i < j; i, j = i+1, j-1 {
-			analysis.RecentTrades[i], analysis.RecentTrades[j] = analysis.RecentTrades[j], analysis.RecentTrades[i]
-		}
 	}
 
-	// 计算夏普比率（需要至少2个数据点）
-	analysis.SharpeRatio = l.calculateSharpeRatio(records)
-
-	return analysis, nil
-}
-
-// calculateSharpeRatio 计算夏普比率
-// 基于账户净值的变化计算风险调整后收益
-func (l *DecisionLogger) calculateSharpeRatio(records []*DecisionRecord) float64 {
-	if len(records) < 2 {
-		return 0.0
-	}
-
-	// 提取每个周期的账户净值
-	// 注意：TotalBalance字段实际存储的是TotalEquity（账户总净值）
-	// TotalUnrealizedProfit字段实际存储的是TotalPnL（相对初始余额的盈亏）
-	var equities []float64
-	for _, record := range records {
-		// 直接使用TotalBalance，因为它已经是完整的账户净值
-		equity := record.AccountState.TotalBalance
-		if equity > 0 {
-			equities = append(equities, equity)
-		}
-	}
-
-	if len(equities) < 2 {
-		return 0.0
-	}
-
-	// 计算周期收益率（period returns）
-	var returns []float64
-	for i := 1; i < len(equities); i++ {
-		if equities[i-1] > 0 {
-			periodReturn := (equities[i] - equities[i-1]) / equities[i-1]
-			returns = append(returns, periodReturn)
-		}
-	}
-
-	if len(returns) == 0 {
-		return 0.0
-	}
-
-	// 计算平均收益率
-	sumReturns := 0.0
-	for _, r := range returns {
-		sumReturns += r
-	}
-	meanReturn := sumReturns / float64(len(returns))
-
-	// 计算收益率标准差
-	sumSquaredDiff := 0.0
-	for _, r := range returns {
-		diff := r - meanReturn
-		sumSquaredDiff += diff * diff
-	}
-	variance := sumSquaredDiff / float64(len(returns))
-	stdDev := math.Sqrt(variance)
-
-	// 避免除以零
-	if stdDev == 0 {
-		if meanReturn > 0 {
-			return 999.0 // 无波动的正收益
-		} else if meanReturn < 0 {
-			return -999.0 // 无波动的负收益
-		}
-		return 0.0
-	}
+	analysis.applyTradeBreakdowns(closed)
 
-	// 计算夏普比率（假设无风险利率为0）
-	// 注：直接返回周期级别的夏普比率（非年化），正常范围 -2 到 +2
-	sharpeRatio := meanReturn / stdDev
-	return sharpeRatio
+	return analysis
 }
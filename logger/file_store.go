@@ -0,0 +1,421 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileStore 决策记录的JSON文件存储：每个周期一个文件，文件名按时间排序
+type FileStore struct {
+	logDir string
+}
+
+// NewFileStore 创建JSON文件存储（目录不存在时自动创建）
+func NewFileStore(logDir string) *FileStore {
+	if logDir == "" {
+		logDir = "decision_logs"
+	}
+
+	// 确保日志目录存在
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		fmt.Printf("⚠ 创建日志目录失败: %v\n", err)
+	}
+
+	return &FileStore{logDir: logDir}
+}
+
+// Save 写入一条记录：decision_YYYYMMDD_HHMMSS_cycleN.json
+func (s *FileStore) Save(record *DecisionRecord) error {
+	filename := fmt.Sprintf("decision_%s_cycle%d.json",
+		record.Timestamp.Format("20060102_150405"),
+		record.CycleNumber)
+
+	// 序列化为JSON（带缩进，方便阅读）
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化决策记录失败: %w", err)
+	}
+
+	// 写入文件
+	if err := os.WriteFile(filepath.Join(s.logDir, filename), data, 0644); err != nil {
+		return fmt.Errorf("写入决策记录失败: %w", err)
+	}
+
+	fmt.Printf("📝 决策记录已保存: %s\n", filename)
+	return nil
+}
+
+// Latest 最近n条记录（按时间正序：从旧到新）
+func (s *FileStore) Latest(n int) ([]*DecisionRecord, error) {
+	files, err := os.ReadDir(s.logDir)
+	if err != nil {
+		return nil, fmt.Errorf("读取日志目录失败: %w", err)
+	}
+
+	// 先按文件名倒序收集（最新的在前）
+	var records []*DecisionRecord
+	for i := len(files) - 1; i >= 0 && len(records) < n; i-- {
+		if files[i].IsDir() {
+			continue
+		}
+		record, err := readRecordFile(filepath.Join(s.logDir, files[i].Name()))
+		if err != nil {
+			continue
+		}
+		records = append(records, record)
+	}
+
+	// 反转数组，让时间从旧到新排列（用于图表显示）
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+
+	return records, nil
+}
+
+// AccountHistory 文件存储没有单独的账户快照，等同于 Latest
+func (s *FileStore) AccountHistory(n int) ([]*DecisionRecord, error) {
+	return s.Latest(n)
+}
+
+// AccountHistoryBetween [start, end] 内的记录（按时间正序）
+func (s *FileStore) AccountHistoryBetween(start, end time.Time) ([]*DecisionRecord, error) {
+	var records []*DecisionRecord
+	err := s.Walk(start, end, func(record *DecisionRecord) error {
+		records = append(records, record)
+		return nil
+	})
+	return records, err
+}
+
+// Walk 按文件名（时间）顺序逐个读取 [start, end] 内的记录
+func (s *FileStore) Walk(start, end time.Time, fn func(*DecisionRecord) error) error {
+	files, err := os.ReadDir(s.logDir)
+	if err != nil {
+		return fmt.Errorf("读取日志目录失败: %w", err)
+	}
+
+	// 文件名以本地时间开头，按文件名跳过开始时间之前的文件（留1秒余量）
+	startName := "decision_" + start.Add(-time.Second).Format("20060102_150405")
+	for _, file := range files {
+		if file.IsDir() || (!start.IsZero() && file.Name() < startName) {
+			continue
+		}
+		record, err := readRecordFile(filepath.Join(s.logDir, file.Name()))
+		if err != nil || record.Timestamp.Before(start) || record.Timestamp.After(end) {
+			continue
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ByDate 指定日期的所有记录
+func (s *FileStore) ByDate(date time.Time) ([]*DecisionRecord, error) {
+	pattern := filepath.Join(s.logDir, fmt.Sprintf("decision_%s_*.json", date.Format("20060102")))
+
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("查找日志文件失败: %w", err)
+	}
+
+	var records []*DecisionRecord
+	for _, file := range files {
+		record, err := readRecordFile(file)
+		if err != nil {
+			continue
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// DeleteBefore 删除记录时间（文件名中的时间，无法解析时为修改时间）早于 cutoff 的记录文件
+func (s *FileStore) DeleteBefore(cutoff time.Time) (int, error) {
+	files, err := os.ReadDir(s.logDir)
+	if err != nil {
+		return 0, fmt.Errorf("读取日志目录失败: %w", err)
+	}
+
+	removedCount := 0
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		if t, ok := recordFileTime(file); !ok || !t.Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.logDir, file.Name())); err != nil {
+			fmt.Printf("⚠ 删除旧记录失败 %s: %v\n", file.Name(), err)
+			continue
+		}
+		removedCount++
+	}
+
+	return removedCount, nil
+}
+
+// Statistics 遍历全部记录文件统计（包含已汇总的每日统计）
+func (s *FileStore) Statistics() (*Statistics, error) {
+	records, err := ReadDecisionLogDir(s.logDir)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &Statistics{}
+	for _, record := range records {
+		if !record.Aggregated { // 已汇总的记录计入每日统计
+			stats.add(record)
+		}
+	}
+	daily, err := s.DailyStats()
+	if err != nil {
+		return nil, err
+	}
+	for _, day := range daily {
+		stats.AddDaily(day)
+	}
+	return stats, nil
+}
+
+// Compact 压缩 before 之前的记录文件：完整记录交给 archive 后，用压缩后的记录覆盖原文件
+func (s *FileStore) Compact(before time.Time, archive func([]*DecisionRecord) error) (int, error) {
+	files, err := os.ReadDir(s.logDir)
+	if err != nil {
+		return 0, fmt.Errorf("读取日志目录失败: %w", err)
+	}
+
+	compacted := 0
+	var batch []*DecisionRecord
+	var paths []string
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := archive(batch); err != nil {
+			return err
+		}
+		for i, record := range batch {
+			if err := writeRecordFile(paths[i], CompactRecord(record)); err != nil {
+				return err
+			}
+			compacted++
+		}
+		batch, paths = batch[:0], paths[:0]
+		return nil
+	}
+
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		if t, ok := recordFileTime(file); !ok || !t.Before(before) {
+			continue
+		}
+		path := filepath.Join(s.logDir, file.Name())
+		record, err := readRecordFile(path)
+		if err != nil || record.Compacted || !record.Timestamp.Before(before) {
+			continue
+		}
+		batch = append(batch, record)
+		paths = append(paths, path)
+		if len(batch) >= compactBatchSize {
+			if err := flush(); err != nil {
+				return compacted, err
+			}
+		}
+	}
+	return compacted, flush()
+}
+
+// Aggregate 把每日统计合并写入 aggregates/daily_stats.json，然后删除 before 之前的记录文件
+func (s *FileStore) Aggregate(before time.Time, stats []*DailyStats) (int, error) {
+	existing, err := s.DailyStats()
+	if err != nil {
+		return 0, err
+	}
+	data, err := json.MarshalIndent(MergeDailyStats(existing, stats), "", "  ")
+	if err != nil {
+		return 0, fmt.Errorf("序列化每日统计失败: %w", err)
+	}
+	path := s.dailyStatsPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, fmt.Errorf("创建汇总目录失败: %w", err)
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return 0, fmt.Errorf("写入每日统计失败: %w", err)
+	}
+	return s.shrinkAggregated(before)
+}
+
+// shrinkAggregated 把 before 之前尚未汇总的记录文件覆盖为 AggregatedRecord 的结果
+func (s *FileStore) shrinkAggregated(before time.Time) (int, error) {
+	files, err := os.ReadDir(s.logDir)
+	if err != nil {
+		return 0, fmt.Errorf("读取日志目录失败: %w", err)
+	}
+
+	aggregated := 0
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		if t, ok := recordFileTime(file); !ok || !t.Before(before) {
+			continue
+		}
+		path := filepath.Join(s.logDir, file.Name())
+		record, err := readRecordFile(path)
+		if err != nil || record.Aggregated || !record.Timestamp.Before(before) {
+			continue
+		}
+		if err := writeRecordFile(path, AggregatedRecord(record)); err != nil {
+			return aggregated, err
+		}
+		aggregated++
+	}
+	return aggregated, nil
+}
+
+// DailyStats 读取 aggregates/daily_stats.json（不存在时为空）
+func (s *FileStore) DailyStats() ([]*DailyStats, error) {
+	data, err := os.ReadFile(s.dailyStatsPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取每日统计失败: %w", err)
+	}
+	var stats []*DailyStats
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return nil, fmt.Errorf("解析每日统计失败: %w", err)
+	}
+	return stats, nil
+}
+
+// Usage 遍历记录文件统计占用
+func (s *FileStore) Usage() (*StorageUsage, error) {
+	files, err := os.ReadDir(s.logDir)
+	if err != nil {
+		return nil, fmt.Errorf("读取日志目录失败: %w", err)
+	}
+
+	usage := &StorageUsage{}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		record, err := readRecordFile(filepath.Join(s.logDir, file.Name()))
+		if err != nil {
+			continue
+		}
+		if info, err := file.Info(); err == nil {
+			usage.RecordBytes += info.Size()
+		}
+		usage.Records++
+		if record.Compacted {
+			usage.CompactedRecords++
+		}
+		if usage.OldestRecord.IsZero() || record.Timestamp.Before(usage.OldestRecord) {
+			usage.OldestRecord = record.Timestamp
+		}
+	}
+
+	daily, err := s.DailyStats()
+	if err != nil {
+		return nil, err
+	}
+	usage.AggregatedDays = len(daily)
+	if info, err := os.Stat(s.dailyStatsPath()); err == nil {
+		usage.RecordBytes += info.Size()
+	}
+	return usage, nil
+}
+
+func (s *FileStore) dailyStatsPath() string {
+	return filepath.Join(s.logDir, "aggregates", "daily_stats.json")
+}
+
+// compactBatchSize 压缩时每批归档的记录数
+const compactBatchSize = 200
+
+// recordFileTime 记录文件名中的时间（decision_YYYYMMDD_HHMMSS_cycleN.json，本地时区），无法解析时使用修改时间
+func recordFileTime(file os.DirEntry) (time.Time, bool) {
+	name := file.Name()
+	if len(name) >= len("decision_20060102_150405") {
+		if t, err := time.ParseInLocation("20060102_150405", name[len("decision_"):len("decision_20060102_150405")], time.Local); err == nil {
+			return t, true
+		}
+	}
+	info, err := file.Info()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return info.ModTime(), true
+}
+
+// writeRecordFile 覆盖写入记录文件
+func writeRecordFile(path string, record *DecisionRecord) error {
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化决策记录失败: %w", err)
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return fmt.Errorf("写入决策记录失败: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic 先写临时文件再重命名，避免写入中断时留下不完整的文件
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// LastCycleNumber 文件存储每次启动从第1个周期重新编号
+func (s *FileStore) LastCycleNumber() (int, error) {
+	return 0, nil
+}
+
+// ReadDecisionLogDir 读取目录下所有决策记录文件（按文件名即时间正序），无法解析的文件会被跳过
+func ReadDecisionLogDir(dir string) ([]*DecisionRecord, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取日志目录失败: %w", err)
+	}
+
+	var records []*DecisionRecord
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		record, err := readRecordFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func readRecordFile(path string) (*DecisionRecord, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var record DecisionRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
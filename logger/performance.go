@@ -0,0 +1,216 @@
+package logger
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// year 年化使用的一年时长
+const year = 365 * 24 * time.Hour
+
+// Drawdown 净值回撤
+type Drawdown struct {
+	Pct           float64   `json:"pct"`            // 回撤幅度（%，相对峰值）
+	Amount        float64   `json:"amount"`         // 回撤金额（USDT）
+	PeakTime      time.Time `json:"peak_time"`      // 回撤开始（净值峰值）时间
+	TroughTime    time.Time `json:"trough_time"`    // 净值谷底时间
+	RecoveryTime  time.Time `json:"recovery_time"`  // 净值回到峰值的时间（未恢复时为零值）
+	Recovered     bool      `json:"recovered"`      // 是否已恢复
+	DurationHours float64   `json:"duration_hours"` // 回撤持续时长（峰值到恢复，未恢复时到分析结束）
+}
+
+// TradeGroupStats 一组交易的统计（多空、时段等分组）
+type TradeGroupStats struct {
+	Label         string  `json:"label"`          // 分组名称
+	TotalTrades   int     `json:"total_trades"`   // 交易次数
+	WinningTrades int     `json:"winning_trades"` // 盈利次数
+	LosingTrades  int     `json:"losing_trades"`  // 亏损次数
+	WinRate       float64 `json:"win_rate"`       // 胜率
+	TotalPnL      float64 `json:"total_pnl"`      // 总净盈亏
+	AvgPnL        float64 `json:"avg_pnl"`        // 平均净盈亏
+}
+
+func (g *TradeGroupStats) add(pnl float64) {
+	g.TotalTrades++
+	g.TotalPnL += pnl
+	if pnl > 0 {
+		g.WinningTrades++
+	} else if pnl < 0 {
+		g.LosingTrades++
+	}
+	g.WinRate = float64(g.WinningTrades) / float64(g.TotalTrades) * 100
+	g.AvgPnL = g.TotalPnL / float64(g.TotalTrades)
+}
+
+// BenchmarkComparison 与买入持有基准的对比
+type BenchmarkComparison struct {
+	Symbol            string  `json:"symbol"`              // 基准币种
+	StartPrice        float64 `json:"start_price"`         // 分析开始时价格
+	EndPrice          float64 `json:"end_price"`           // 分析结束时价格
+	ReturnPct         float64 `json:"return_pct"`          // 买入持有收益率（%）
+	StrategyReturnPct float64 `json:"strategy_return_pct"` // 策略收益率（%）
+	ExcessReturnPct   float64 `json:"excess_return_pct"`   // 超额收益（%）
+}
+
+// SetBenchmark 设置同一时间范围内买入持有 symbol 的对比
+func (a *PerformanceAnalysis) SetBenchmark(symbol string, startPrice, endPrice float64) {
+	if startPrice <= 0 {
+		return
+	}
+	returnPct := (endPrice - startPrice) / startPrice * 100
+	a.Benchmark = &BenchmarkComparison{
+		Symbol:            symbol,
+		StartPrice:        startPrice,
+		EndPrice:          endPrice,
+		ReturnPct:         returnPct,
+		StrategyReturnPct: a.TotalReturnPct,
+		ExcessReturnPct:   a.TotalReturnPct - returnPct,
+	}
+}
+
+// applyTradeBreakdowns 期望值、平均持仓时长以及多空、开仓时段、开仓星期分组统计
+func (a *PerformanceAnalysis) applyTradeBreakdowns(trades []*Trade) {
+	a.LongStats = &TradeGroupStats{Label: "long"}
+	a.ShortStats = &TradeGroupStats{Label: "short"}
+	a.HourlyStats = make([]*TradeGroupStats, 24)
+	for hour := range a.HourlyStats {
+		a.HourlyStats[hour] = &TradeGroupStats{Label: fmt.Sprintf("%02d:00", hour)}
+	}
+	a.WeekdayStats = make([]*TradeGroupStats, 7)
+	for day := range a.WeekdayStats {
+		a.WeekdayStats[day] = &TradeGroupStats{Label: time.Weekday(day).String()}
+	}
+
+	var holding time.Duration
+	for _, trade := range trades {
+		pnl := trade.NetPnL
+		a.TotalPnL += pnl
+		a.TotalFees += trade.Fees
+		a.TotalFunding += trade.Funding
+		holding += trade.ExitTime.Sub(trade.EntryTime)
+
+		if trade.Side == "short" {
+			a.ShortStats.add(pnl)
+		} else {
+			a.LongStats.add(pnl)
+		}
+		entry := trade.EntryTime.Local()
+		a.HourlyStats[entry.Hour()].add(pnl)
+		a.WeekdayStats[entry.Weekday()].add(pnl)
+	}
+	if len(trades) > 0 {
+		a.Expectancy = a.TotalPnL / float64(len(trades))
+		a.AvgHoldingMinutes = holding.Minutes() / float64(len(trades))
+	}
+}
+
+// applyEquityCurve 根据账户净值曲线计算收益率、最大回撤和年化风险调整收益（无风险利率按0计）
+func (a *PerformanceAnalysis) applyEquityCurve(records []*DecisionRecord) {
+	var points []*DecisionRecord
+	for _, record := range records {
+		// TotalBalance 实际存储的是账户总净值
+		if record.AccountState.TotalBalance > 0 {
+			points = append(points, record)
+		}
+	}
+	if len(points) == 0 {
+		return
+	}
+
+	first, last := points[0], points[len(points)-1]
+	a.StartTime, a.EndTime = first.Timestamp, last.Timestamp
+	a.StartEquity, a.EndEquity = first.AccountState.TotalBalance, last.AccountState.TotalBalance
+	a.TotalReturnPct = (a.EndEquity - a.StartEquity) / a.StartEquity * 100
+	a.MaxDrawdown = maxDrawdown(points)
+	if len(points) < 2 {
+		return
+	}
+
+	span := a.EndTime.Sub(a.StartTime)
+	if span <= 0 {
+		return
+	}
+	// 按单利年化（与夏普比率一致）：短时间窗口按复利年化会得到天文数字
+	a.AnnualizedReturnPct = finite(a.TotalReturnPct * float64(year) / float64(span))
+	if a.MaxDrawdown.Pct > 0 {
+		a.CalmarRatio = finite(a.AnnualizedReturnPct / a.MaxDrawdown.Pct)
+	}
+
+	// 周期收益率，按平均周期间隔年化
+	returns := make([]float64, 0, len(points)-1)
+	for i := 1; i < len(points); i++ {
+		prev := points[i-1].AccountState.TotalBalance
+		returns = append(returns, (points[i].AccountState.TotalBalance-prev)/prev)
+	}
+	periodsPerYear := float64(year) / (float64(span) / float64(len(returns)))
+
+	mean := 0.0
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+
+	var variance, downside float64
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+		if r < 0 {
+			downside += r * r
+		}
+	}
+	stdDev := math.Sqrt(variance / float64(len(returns)))
+	downsideDev := math.Sqrt(downside / float64(len(returns)))
+
+	// 没有波动（或没有下行波动）时比率没有意义，记为0
+	if stdDev > 0 {
+		a.SharpeRatio = finite(mean / stdDev * math.Sqrt(periodsPerYear))
+	}
+	if downsideDev > 0 {
+		a.SortinoRatio = finite(mean / downsideDev * math.Sqrt(periodsPerYear))
+	}
+}
+
+// maxDrawdown 净值曲线的最大回撤（points 的净值均大于0）
+func maxDrawdown(points []*DecisionRecord) *Drawdown {
+	worst := &Drawdown{}
+	end := points[len(points)-1].Timestamp
+
+	peak := points[0]
+	var current *Drawdown // 当前回撤（从 peak 开始）
+	for _, point := range points[1:] {
+		equity := point.AccountState.TotalBalance
+		peakEquity := peak.AccountState.TotalBalance
+		if equity >= peakEquity {
+			if current != nil {
+				current.Recovered = true
+				current.RecoveryTime = point.Timestamp
+				current.DurationHours = point.Timestamp.Sub(current.PeakTime).Hours()
+				current = nil
+			}
+			peak = point
+			continue
+		}
+
+		if current == nil {
+			current = &Drawdown{PeakTime: peak.Timestamp}
+		}
+		if amount := peakEquity - equity; amount > current.Amount {
+			current.Amount = amount
+			current.Pct = amount / peakEquity * 100
+			current.TroughTime = point.Timestamp
+		}
+		current.DurationHours = end.Sub(current.PeakTime).Hours()
+		if current.Pct > worst.Pct {
+			worst = current
+		}
+	}
+	return worst
+}
+
+// finite 非有限值（溢出或除零）记为0，避免JSON序列化失败
+func finite(v float64) float64 {
+	if math.IsInf(v, 0) || math.IsNaN(v) {
+		return 0
+	}
+	return v
+}
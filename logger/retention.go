@@ -0,0 +1,418 @@
+package logger
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RetentionPolicy 决策日志分级保留策略（天数从记录时间算起，0 表示不启用该级）
+//   - FullDays 天内保留完整记录
+//   - 之后压缩：完整记录追加到 gzip 归档，存储中去掉提示词（系统提示词只保留哈希）
+//   - CompactDays 天后汇总为每日统计，记录只保留账户快照和动作（收益曲线和交易配对仍可用）
+type RetentionPolicy struct {
+	FullDays    int `json:"full_days"`    // 保留完整记录的天数
+	CompactDays int `json:"compact_days"` // 保留（压缩后）记录的天数，之后只保留每日统计
+}
+
+// Validate 检查天数设置
+func (p RetentionPolicy) Validate() error {
+	if p.FullDays < 0 || p.CompactDays < 0 {
+		return fmt.Errorf("保留天数不能为负数")
+	}
+	if p.FullDays > 0 && p.CompactDays > 0 && p.CompactDays <= p.FullDays {
+		return fmt.Errorf("压缩记录保留天数(%d)必须大于完整记录保留天数(%d)", p.CompactDays, p.FullDays)
+	}
+	return nil
+}
+
+// cutoffs 压缩和汇总的截止时间（零值表示不执行）
+// 汇总按整天进行，截止时间取当天零点；汇总前未压缩的记录也先写入归档
+func (p RetentionPolicy) cutoffs(now time.Time) (compactBefore, aggregateBefore time.Time) {
+	if p.CompactDays > 0 {
+		day := now.AddDate(0, 0, -p.CompactDays)
+		aggregateBefore = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, now.Location())
+		compactBefore = aggregateBefore
+	}
+	if p.FullDays > 0 {
+		compactBefore = now.AddDate(0, 0, -p.FullDays)
+	}
+	return compactBefore, aggregateBefore
+}
+
+// DailyStats 汇总后的每日决策统计（日期为本地时区）
+type DailyStats struct {
+	Date             string    `json:"date"` // 2006-01-02
+	Cycles           int       `json:"cycles"`
+	SuccessfulCycles int       `json:"successful_cycles"`
+	OpenPositions    int       `json:"open_positions"`  // 成功开仓次数
+	ClosePositions   int       `json:"close_positions"` // 成功平仓次数
+	FirstTime        time.Time `json:"first_time"`      // 当天第一条记录时间
+	LastTime         time.Time `json:"last_time"`       // 当天最后一条记录时间
+	StartEquity      float64   `json:"start_equity"`    // 第一条记录的账户净值
+	EndEquity        float64   `json:"end_equity"`      // 最后一条记录的账户净值
+	MinEquity        float64   `json:"min_equity"`
+	MaxEquity        float64   `json:"max_equity"`
+}
+
+// add 把一条记录计入当天统计
+func (d *DailyStats) add(record *DecisionRecord) {
+	var stats Statistics
+	stats.add(record)
+	equity := record.AccountState.TotalBalance
+	d.Merge(&DailyStats{
+		Cycles:           1,
+		SuccessfulCycles: stats.SuccessfulCycles,
+		OpenPositions:    stats.TotalOpenPositions,
+		ClosePositions:   stats.TotalClosePositions,
+		FirstTime:        record.Timestamp,
+		LastTime:         record.Timestamp,
+		StartEquity:      equity,
+		EndEquity:        equity,
+		MinEquity:        equity,
+		MaxEquity:        equity,
+	})
+}
+
+// Merge 合并同一天的另一份统计（例如多次汇总同一天的记录）
+func (d *DailyStats) Merge(other *DailyStats) {
+	if other.Cycles == 0 {
+		return
+	}
+	if d.Cycles == 0 {
+		date := d.Date
+		*d = *other
+		if date != "" {
+			d.Date = date
+		}
+		return
+	}
+
+	d.Cycles += other.Cycles
+	d.SuccessfulCycles += other.SuccessfulCycles
+	d.OpenPositions += other.OpenPositions
+	d.ClosePositions += other.ClosePositions
+	if other.FirstTime.Before(d.FirstTime) {
+		d.FirstTime = other.FirstTime
+		d.StartEquity = other.StartEquity
+	}
+	if other.LastTime.After(d.LastTime) {
+		d.LastTime = other.LastTime
+		d.EndEquity = other.EndEquity
+	}
+	if other.MinEquity < d.MinEquity {
+		d.MinEquity = other.MinEquity
+	}
+	if other.MaxEquity > d.MaxEquity {
+		d.MaxEquity = other.MaxEquity
+	}
+}
+
+// AddDaily 把汇总后的每日统计计入总统计（已汇总的记录不再单独计入，总数保持不变）
+func (s *Statistics) AddDaily(d *DailyStats) {
+	s.TotalCycles += d.Cycles
+	s.SuccessfulCycles += d.SuccessfulCycles
+	s.FailedCycles += d.Cycles - d.SuccessfulCycles
+	s.TotalOpenPositions += d.OpenPositions
+	s.TotalClosePositions += d.ClosePositions
+}
+
+// MergeDailyStats 把 stats 按日期合并进 existing，返回按日期正序的结果
+func MergeDailyStats(existing, stats []*DailyStats) []*DailyStats {
+	byDate := make(map[string]*DailyStats, len(existing)+len(stats))
+	var merged []*DailyStats
+	for _, list := range [][]*DailyStats{existing, stats} {
+		for _, day := range list {
+			if current, ok := byDate[day.Date]; ok {
+				current.Merge(day)
+				continue
+			}
+			copied := *day
+			byDate[day.Date] = &copied
+			merged = append(merged, &copied)
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Date < merged[j].Date })
+	return merged
+}
+
+// PromptHash 提示词哈希（sha256 前16字节的十六进制）
+func PromptHash(prompt string) string {
+	sum := sha256.Sum256([]byte(prompt))
+	return hex.EncodeToString(sum[:16])
+}
+
+// AggregatedRecord 汇总后保留的记录：只有周期、时间、结果、账户快照和动作
+func AggregatedRecord(record *DecisionRecord) *DecisionRecord {
+	return &DecisionRecord{
+		Timestamp:        record.Timestamp,
+		CycleNumber:      record.CycleNumber,
+		AccountState:     record.AccountState,
+		Decisions:        record.Decisions,
+		Success:          record.Success,
+		ErrorMessage:     record.ErrorMessage,
+		Pipeline:         record.Pipeline,
+		Compacted:        true,
+		SystemPromptHash: record.SystemPromptHash,
+		Aggregated:       true,
+	}
+}
+
+// CompactRecord 压缩后的记录：去掉系统提示词、用户提示词和各阶段的提示词，保留思维链、决策、动作和账户快照
+func CompactRecord(record *DecisionRecord) *DecisionRecord {
+	compacted := *record
+	compacted.Compacted = true
+	if record.SystemPrompt != "" {
+		compacted.SystemPromptHash = PromptHash(record.SystemPrompt)
+	}
+	compacted.SystemPrompt = ""
+	compacted.InputPrompt = ""
+	if len(record.PipelineStages) > 0 {
+		compacted.PipelineStages = make([]PipelineStageRecord, len(record.PipelineStages))
+		for i, stage := range record.PipelineStages {
+			stage.SystemPrompt = ""
+			stage.UserPrompt = ""
+			compacted.PipelineStages[i] = stage
+		}
+	}
+	return &compacted
+}
+
+// StorageUsage 单个交易员决策日志的存储占用
+type StorageUsage struct {
+	Records          int       `json:"records"`           // 记录数
+	CompactedRecords int       `json:"compacted_records"` // 其中已压缩的记录数
+	AggregatedDays   int       `json:"aggregated_days"`   // 已汇总为每日统计的天数
+	RecordBytes      int64     `json:"record_bytes"`      // 记录占用（JSON正文大小）
+	ArchiveFiles     int       `json:"archive_files"`     // gzip 归档文件数
+	ArchiveBytes     int64     `json:"archive_bytes"`     // gzip 归档占用
+	TotalBytes       int64     `json:"total_bytes"`       // 合计
+	OldestRecord     time.Time `json:"oldest_record"`     // 最早的记录时间（没有记录时为零值）
+}
+
+// RetentionResult 一次执行保留策略的结果
+type RetentionResult struct {
+	Compacted  int `json:"compacted"`  // 压缩的记录数
+	Aggregated int `json:"aggregated"` // 汇总后精简的记录数
+	Days       int `json:"days"`       // 汇总的天数
+}
+
+// ApplyRetention 按保留策略压缩和汇总记录，完整记录按天追加到 archiveDir 下的 decisions_YYYYMMDD.jsonl.gz
+func (l *DecisionLogger) ApplyRetention(policy RetentionPolicy, archiveDir string, now time.Time) (*RetentionResult, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	result := &RetentionResult{}
+	compactBefore, aggregateBefore := policy.cutoffs(now)
+
+	if !compactBefore.IsZero() {
+		archive := &decisionArchive{dir: archiveDir}
+		compacted, err := l.store.Compact(compactBefore, archive.write)
+		if closeErr := archive.close(); err == nil {
+			err = closeErr
+		}
+		result.Compacted = compacted
+		if err != nil {
+			return result, fmt.Errorf("压缩决策记录失败: %w", err)
+		}
+	}
+
+	if !aggregateBefore.IsZero() {
+		// 只需要读取上次汇总之后的记录（汇总按整天进行，已汇总的记录都在最后一个汇总日之前）
+		var start time.Time
+		existing, err := l.store.DailyStats()
+		if err != nil {
+			return result, fmt.Errorf("汇总决策记录失败: %w", err)
+		}
+		if len(existing) > 0 {
+			start = existing[len(existing)-1].LastTime.Add(time.Nanosecond)
+		}
+
+		byDate := make(map[string]*DailyStats)
+		var stats []*DailyStats
+		err = l.store.Walk(start, aggregateBefore.Add(-time.Nanosecond), func(record *DecisionRecord) error {
+			if record.Aggregated {
+				return nil
+			}
+			date := record.Timestamp.Local().Format("2006-01-02")
+			day, ok := byDate[date]
+			if !ok {
+				day = &DailyStats{Date: date}
+				byDate[date] = day
+				stats = append(stats, day)
+			}
+			day.add(record)
+			return nil
+		})
+		if err != nil {
+			return result, fmt.Errorf("汇总决策记录失败: %w", err)
+		}
+		if len(stats) > 0 {
+			aggregated, err := l.store.Aggregate(aggregateBefore, stats)
+			if err != nil {
+				return result, fmt.Errorf("汇总决策记录失败: %w", err)
+			}
+			result.Aggregated = aggregated
+			result.Days = len(stats)
+		}
+	}
+	return result, nil
+}
+
+// GetDailyStats 获取已汇总的每日统计
+func (l *DecisionLogger) GetDailyStats() ([]*DailyStats, error) {
+	return l.store.DailyStats()
+}
+
+// StorageUsage 记录和 archiveDir 下gzip归档的存储占用
+func (l *DecisionLogger) StorageUsage(archiveDir string) (*StorageUsage, error) {
+	usage, err := l.store.Usage()
+	if err != nil {
+		return nil, err
+	}
+	files, err := os.ReadDir(archiveDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("读取归档目录失败: %w", err)
+	}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".jsonl.gz") {
+			continue
+		}
+		if info, err := file.Info(); err == nil {
+			usage.ArchiveFiles++
+			usage.ArchiveBytes += info.Size()
+		}
+	}
+	usage.TotalBytes = usage.RecordBytes + usage.ArchiveBytes
+	return usage, nil
+}
+
+// decisionArchive 按天追加写入的 gzip JSONL 归档（每次追加一个新的 gzip 成员，gzip 读取时会自动连接）
+type decisionArchive struct {
+	dir  string
+	day  string
+	file *os.File
+	gz   *gzip.Writer
+	enc  *json.Encoder
+}
+
+// write 写入一批记录并刷新到文件（存储在这之后才替换为压缩记录）
+func (a *decisionArchive) write(records []*DecisionRecord) error {
+	for _, record := range records {
+		day := record.Timestamp.Local().Format("20060102")
+		if day != a.day {
+			if err := a.close(); err != nil {
+				return err
+			}
+			if err := a.open(day); err != nil {
+				return err
+			}
+		}
+		if err := a.enc.Encode(record); err != nil {
+			return fmt.Errorf("写入归档失败: %w", err)
+		}
+	}
+	if a.file == nil {
+		return nil
+	}
+	if err := a.gz.Flush(); err != nil {
+		return fmt.Errorf("写入归档失败: %w", err)
+	}
+	return a.file.Sync()
+}
+
+func (a *decisionArchive) open(day string) error {
+	if err := os.MkdirAll(a.dir, 0755); err != nil {
+		return fmt.Errorf("创建归档目录失败: %w", err)
+	}
+	file, err := os.OpenFile(filepath.Join(a.dir, fmt.Sprintf("decisions_%s.jsonl.gz", day)), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("打开归档文件失败: %w", err)
+	}
+	a.day, a.file = day, file
+	a.gz = gzip.NewWriter(file)
+	a.enc = json.NewEncoder(a.gz)
+	return nil
+}
+
+// ArchiveReader 按天读取 gzip 归档中的完整记录（用于找回已压缩记录的提示词）
+// 记录按时间正序查询时每天的归档只读取一次
+type ArchiveReader struct {
+	dir     string
+	day     string
+	records map[string]*DecisionRecord // 周期编号+秒级时间 -> 完整记录
+}
+
+// NewArchiveReader 读取 dir 下归档的读取器（dir 为空时找不到任何记录）
+func NewArchiveReader(dir string) *ArchiveReader {
+	return &ArchiveReader{dir: dir}
+}
+
+// Find 查找已压缩记录在归档中的完整记录
+func (r *ArchiveReader) Find(record *DecisionRecord) (*DecisionRecord, bool) {
+	if r.dir == "" {
+		return nil, false
+	}
+	day := record.Timestamp.Local().Format("20060102")
+	if day != r.day {
+		records, err := readArchiveDay(filepath.Join(r.dir, fmt.Sprintf("decisions_%s.jsonl.gz", day)))
+		if err != nil && !os.IsNotExist(err) {
+			log.Printf("⚠️  读取决策归档 %s 失败: %v", day, err)
+		}
+		r.day, r.records = day, records
+	}
+	full, ok := r.records[archiveKey(record)]
+	return full, ok
+}
+
+func archiveKey(record *DecisionRecord) string {
+	return fmt.Sprintf("%d_%d", record.CycleNumber, record.Timestamp.Unix())
+}
+
+// readArchiveDay 读取一天的归档（多个 gzip 成员自动连接）
+func readArchiveDay(path string) (map[string]*DecisionRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(bufio.NewReader(file))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	records := make(map[string]*DecisionRecord)
+	dec := json.NewDecoder(gz)
+	for {
+		var record DecisionRecord
+		if err := dec.Decode(&record); err == io.EOF {
+			return records, nil
+		} else if err != nil {
+			return records, err
+		}
+		records[archiveKey(&record)] = &record
+	}
+}
+
+func (a *decisionArchive) close() error {
+	if a.file == nil {
+		return nil
+	}
+	err := a.gz.Close()
+	if closeErr := a.file.Close(); err == nil {
+		err = closeErr
+	}
+	a.day, a.file, a.gz, a.enc = "", nil, nil, nil
+	return err
+}
@@ -0,0 +1,286 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 交易状态
+const (
+	TradeOpen   = "open"
+	TradeClosed = "closed"
+)
+
+// 平仓原因
+const (
+	ExitAIClose     = "ai_close"    // AI决策平仓
+	ExitStopLoss    = "stop_loss"   // 止损单成交
+	ExitTakeProfit  = "take_profit" // 止盈单成交
+	ExitLiquidation = "liquidation" // 强平
+	ExitManual      = "manual"      // 手动平仓（MCP等手动决策，或在交易所直接平仓）
+)
+
+// Trade 一笔完整的交易（开仓到平仓）
+type Trade struct {
+	ID           int64     `json:"id"`
+	Symbol       string    `json:"symbol"`
+	Side         string    `json:"side"`   // long/short
+	Status       string    `json:"status"` // open/closed
+	Quantity     float64   `json:"quantity"`
+	Leverage     int       `json:"leverage"`
+	EntryPrice   float64   `json:"entry_price"` // 开仓成交均价（查询不到成交时为下单时的市场价）
+	EntryTime    time.Time `json:"entry_time"`
+	EntryOrderID int64     `json:"entry_order_id"`
+	ExitPrice    float64   `json:"exit_price"` // 平仓成交均价
+	ExitTime     time.Time `json:"exit_time"`
+	ExitOrderID  int64     `json:"exit_order_id"`
+	ExitReason   string    `json:"exit_reason"` // ai_close/stop_loss/take_profit/liquidation/manual
+	StopLoss     float64   `json:"stop_loss"`
+	TakeProfit   float64   `json:"take_profit"`
+	Fees         float64   `json:"fees"`         // 开平仓手续费合计（USDT，正数为支出）
+	Funding      float64   `json:"funding"`      // 持仓期间资金费净额（USDT，正数为收入）
+	RealizedPnL  float64   `json:"realized_pnl"` // 价差盈亏（不含手续费和资金费）
+	NetPnL       float64   `json:"net_pnl"`      // 净盈亏 = 价差盈亏 - 手续费 + 资金费
+	OpenSource   string    `json:"open_source"`  // 开仓来源：ai / 手动决策来源 / detected（未经本系统开仓的持仓）
+	OpenCycle    int       `json:"open_cycle"`   // 开仓决策所在周期（0 表示没有对应的决策记录）
+	CloseCycle   int       `json:"close_cycle"`  // 平仓决策所在周期（止损/止盈/强平为 0）
+}
+
+// MarginUsed 开仓占用保证金
+func (t *Trade) MarginUsed() float64 {
+	if t.Leverage <= 0 {
+		return t.Quantity * t.EntryPrice
+	}
+	return t.Quantity * t.EntryPrice / float64(t.Leverage)
+}
+
+// Close 以成交均价平仓并计算盈亏
+func (t *Trade) Close(exitPrice float64, exitTime time.Time, reason string) {
+	t.Status = TradeClosed
+	t.ExitPrice = exitPrice
+	t.ExitTime = exitTime
+	t.ExitReason = reason
+	if t.Side == "long" {
+		t.RealizedPnL = t.Quantity * (exitPrice - t.EntryPrice)
+	} else {
+		t.RealizedPnL = t.Quantity * (t.EntryPrice - exitPrice)
+	}
+	t.NetPnL = t.RealizedPnL - t.Fees + t.Funding
+}
+
+// TradeLedger 交易账本：每笔交易一条记录，是所有交易表现统计的数据来源
+type TradeLedger interface {
+	// OpenTrade 记录新开仓（写入后填充 ID）
+	OpenTrade(trade *Trade) error
+	// UpdateTrade 更新交易（平仓、补充成交信息等）
+	UpdateTrade(trade *Trade) error
+	// LinkDecision 关联交易与决策周期（open=true 为开仓决策，否则为平仓决策）
+	LinkDecision(tradeID int64, cycle int, open bool) error
+	// OpenTrades 所有未平仓交易
+	OpenTrades() ([]*Trade, error)
+	// ClosedTrades since 之后平仓的交易（按平仓时间正序），limit>0 时只返回最近 limit 笔
+	ClosedTrades(since time.Time, limit int) ([]*Trade, error)
+	// Count 交易总数
+	Count() (int, error)
+}
+
+// FileTradeLedger JSON文件交易账本（未使用数据库存储决策日志时使用），全部交易保存在一个文件中
+type FileTradeLedger struct {
+	mu     sync.Mutex
+	path   string
+	trades []*Trade
+	nextID int64
+}
+
+// NewFileTradeLedger 创建JSON文件交易账本
+func NewFileTradeLedger(path string) (*FileTradeLedger, error) {
+	l := &FileTradeLedger{path: path, nextID: 1}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return l, nil
+		}
+		return nil, fmt.Errorf("读取交易账本失败: %w", err)
+	}
+	if err := json.Unmarshal(data, &l.trades); err != nil {
+		return nil, fmt.Errorf("解析交易账本失败: %w", err)
+	}
+	for _, trade := range l.trades {
+		if trade.ID >= l.nextID {
+			l.nextID = trade.ID + 1
+		}
+	}
+	return l, nil
+}
+
+// OpenTrade 记录新开仓
+func (l *FileTradeLedger) OpenTrade(trade *Trade) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	trade.ID = l.nextID
+	l.nextID++
+	stored := *trade
+	l.trades = append(l.trades, &stored)
+	return l.save()
+}
+
+// UpdateTrade 更新交易
+func (l *FileTradeLedger) UpdateTrade(trade *Trade) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, stored := range l.trades {
+		if stored.ID == trade.ID {
+			updated := *trade
+			l.trades[i] = &updated
+			return l.save()
+		}
+	}
+	return fmt.Errorf("交易 #%d 不存在", trade.ID)
+}
+
+// LinkDecision 关联交易与决策周期
+func (l *FileTradeLedger) LinkDecision(tradeID int64, cycle int, open bool) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, trade := range l.trades {
+		if trade.ID == tradeID {
+			if open {
+				trade.OpenCycle = cycle
+			} else {
+				trade.CloseCycle = cycle
+			}
+			return l.save()
+		}
+	}
+	return fmt.Errorf("交易 #%d 不存在", tradeID)
+}
+
+// OpenTrades 所有未平仓交易
+func (l *FileTradeLedger) OpenTrades() ([]*Trade, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var trades []*Trade
+	for _, trade := range l.trades {
+		if trade.Status == TradeOpen {
+			copied := *trade
+			trades = append(trades, &copied)
+		}
+	}
+	return trades, nil
+}
+
+// ClosedTrades since 之后平仓的交易（按平仓时间正序）
+func (l *FileTradeLedger) ClosedTrades(since time.Time, limit int) ([]*Trade, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var trades []*Trade
+	for _, trade := range l.trades {
+		if trade.Status == TradeClosed && !trade.ExitTime.Before(since) {
+			copied := *trade
+			trades = append(trades, &copied)
+		}
+	}
+	sort.SliceStable(trades, func(i, j int) bool { return trades[i].ExitTime.Before(trades[j].ExitTime) })
+	if limit > 0 && len(trades) > limit {
+		trades = trades[len(trades)-limit:]
+	}
+	return trades, nil
+}
+
+// Count 交易总数
+func (l *FileTradeLedger) Count() (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.trades), nil
+}
+
+func (l *FileTradeLedger) save() error {
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return fmt.Errorf("创建交易账本目录失败: %w", err)
+	}
+	data, err := json.MarshalIndent(l.trades, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化交易账本失败: %w", err)
+	}
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("写入交易账本失败: %w", err)
+	}
+	return os.Rename(tmp, l.path)
+}
+
+// TradesFromDecisionLogs 从决策日志中按开平仓动作配对重建交易（用于账本启用前的历史数据）
+// 决策日志中只有下单时的市场价，没有手续费和资金费
+func TradesFromDecisionLogs(records []*DecisionRecord) []*Trade {
+	builder := NewTradeBuilder()
+	for _, record := range records {
+		builder.Add(record)
+	}
+	return builder.Trades()
+}
+
+// TradeBuilder 按时间顺序逐条读入决策记录，增量配对开平仓动作（大量历史记录不需要一次性载入内存）
+type TradeBuilder struct {
+	open   map[string]*Trade // symbol_side -> 未平仓交易
+	trades []*Trade
+}
+
+// NewTradeBuilder 创建交易配对器
+func NewTradeBuilder() *TradeBuilder {
+	return &TradeBuilder{open: make(map[string]*Trade)}
+}
+
+// Add 读入一条决策记录（需按时间正序调用）
+func (b *TradeBuilder) Add(record *DecisionRecord) {
+	for _, action := range record.Decisions {
+		if !action.Success {
+			continue
+		}
+		var side string
+		switch action.Action {
+		case "open_long", "close_long":
+			side = "long"
+		case "open_short", "close_short":
+			side = "short"
+		default:
+			continue
+		}
+		posKey := action.Symbol + "_" + side
+
+		switch action.Action {
+		case "open_long", "open_short":
+			b.open[posKey] = &Trade{
+				Symbol:       action.Symbol,
+				Side:         side,
+				Status:       TradeOpen,
+				Quantity:     action.Quantity,
+				Leverage:     action.Leverage,
+				EntryPrice:   action.Price,
+				EntryTime:    action.Timestamp,
+				EntryOrderID: action.OrderID,
+				OpenSource:   "ai",
+				OpenCycle:    record.CycleNumber,
+			}
+		case "close_long", "close_short":
+			trade, ok := b.open[posKey]
+			if !ok {
+				continue
+			}
+			delete(b.open, posKey)
+			trade.ExitOrderID = action.OrderID
+			trade.CloseCycle = record.CycleNumber
+			trade.Close(action.Price, action.Timestamp, ExitAIClose)
+			b.trades = append(b.trades, trade)
+		}
+	}
+}
+
+// Trades 已配对完成（已平仓）的交易
+func (b *TradeBuilder) Trades() []*Trade {
+	return b.trades
+}
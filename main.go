@@ -9,12 +9,15 @@ import (
 	"nofx/config"
 	"nofx/manager"
 	"nofx/market"
+	"nofx/mcp"
 	"nofx/pool"
+	"nofx/trader"
 	"os"
 	"os/signal"
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 )
 
 // LeverageConfig 杠杆配置
@@ -38,6 +41,26 @@ type ConfigFile struct {
 	Leverage           LeverageConfig `json:"leverage"`
 	JWTSecret          string         `json:"jwt_secret"`
 	DataKLineTime      string         `json:"data_k_line_time"`
+	MCPAllowTrading    bool           `json:"mcp_allow_trading"`
+	AIRequestsPerMin   int            `json:"ai_requests_per_minute"`
+	AIMaxInFlight      int            `json:"ai_max_in_flight"`
+	AIRecordDir        string         `json:"ai_record_dir"`
+	AIStreaming        *bool          `json:"ai_streaming"`                   // 未配置时保持数据库中的值（默认开启）
+	AIStreamIdleSecs   int            `json:"ai_stream_idle_timeout_seconds"`
+	MarketStorePath    *string        `json:"market_store_path"` // 未配置时保持数据库中的值（默认 market_data/market.db，为空不启用）
+	MaxSlippagePct     *float64       `json:"max_slippage_pct"`  // 未配置时保持数据库中的值（默认 1.0，0 表示不检查）
+
+	SignalWeights map[string]float64 `json:"signal_source_weights"` // 币种池信号源权重，未列出的信号源不启用
+	SignalFile    *string            `json:"signal_file"`           // static 信号源的自选币种文件
+
+	UniverseEnabled *bool           `json:"universe_enabled"` // 是否为所有交易员启用自动可交易池
+	UniverseFilters json.RawMessage `json:"universe_filters"` // 自动可交易池默认筛选条件，未列出的字段使用内置默认值
+
+	DecisionLogStorage string `json:"decision_log_storage"` // 决策日志存储：sqlite（默认）或 file
+
+	DecisionFullRetentionDays    *int    `json:"decision_full_retention_days"`    // 决策记录保留完整内容的天数（0 不压缩）
+	DecisionCompactRetentionDays *int    `json:"decision_compact_retention_days"` // 决策记录保留天数，之后汇总为每日统计（0 不汇总）
+	DecisionArchiveDir           *string `json:"decision_archive_dir"`            // 压缩前完整决策记录的gzip归档目录
 }
 
 // syncConfigToDatabase 从config.json读取配置并同步到数据库
@@ -73,6 +96,8 @@ func syncConfigToDatabase(database *config.Database) error {
 		"max_daily_loss":        fmt.Sprintf("%.1f", configFile.MaxDailyLoss),
 		"max_drawdown":          fmt.Sprintf("%.1f", configFile.MaxDrawdown),
 		"stop_trading_minutes":  strconv.Itoa(configFile.StopTradingMinutes),
+		"mcp_allow_trading":     fmt.Sprintf("%t", configFile.MCPAllowTrading),
+		"ai_record_dir":         configFile.AIRecordDir,
 	}
 
 	// 同步default_coins（转换为JSON字符串存储）
@@ -91,6 +116,66 @@ func syncConfigToDatabase(database *config.Database) error {
 		configs["altcoin_leverage"] = strconv.Itoa(configFile.Leverage.AltcoinLeverage)
 	}
 
+	// 同步AI请求限流配置
+	if configFile.AIRequestsPerMin > 0 {
+		configs["ai_requests_per_minute"] = strconv.Itoa(configFile.AIRequestsPerMin)
+	}
+	if configFile.AIMaxInFlight > 0 {
+		configs["ai_max_in_flight"] = strconv.Itoa(configFile.AIMaxInFlight)
+	}
+
+	// 同步AI流式输出配置
+	if configFile.AIStreaming != nil {
+		configs["ai_streaming"] = fmt.Sprintf("%t", *configFile.AIStreaming)
+	}
+	if configFile.AIStreamIdleSecs > 0 {
+		configs["ai_stream_idle_timeout_seconds"] = strconv.Itoa(configFile.AIStreamIdleSecs)
+	}
+
+	// 同步行情存储路径
+	if configFile.MarketStorePath != nil {
+		configs["market_store_path"] = *configFile.MarketStorePath
+	}
+
+	// 同步开仓滑点上限
+	if configFile.MaxSlippagePct != nil {
+		configs["max_slippage_pct"] = strconv.FormatFloat(*configFile.MaxSlippagePct, 'f', -1, 64)
+	}
+
+	// 同步币种池信号源配置
+	if len(configFile.SignalWeights) > 0 {
+		if weightsJSON, err := json.Marshal(configFile.SignalWeights); err == nil {
+			configs["signal_source_weights"] = string(weightsJSON)
+		}
+	}
+	if configFile.SignalFile != nil {
+		configs["signal_file"] = *configFile.SignalFile
+	}
+
+	// 同步自动可交易池配置
+	if configFile.UniverseEnabled != nil {
+		configs["universe_enabled"] = strconv.FormatBool(*configFile.UniverseEnabled)
+	}
+	if len(configFile.UniverseFilters) > 0 {
+		configs["universe_filters"] = string(configFile.UniverseFilters)
+	}
+
+	// 同步决策日志存储方式
+	if configFile.DecisionLogStorage != "" {
+		configs["decision_log_storage"] = configFile.DecisionLogStorage
+	}
+
+	// 同步决策日志保留策略
+	if configFile.DecisionFullRetentionDays != nil {
+		configs["decision_full_retention_days"] = strconv.Itoa(*configFile.DecisionFullRetentionDays)
+	}
+	if configFile.DecisionCompactRetentionDays != nil {
+		configs["decision_compact_retention_days"] = strconv.Itoa(*configFile.DecisionCompactRetentionDays)
+	}
+	if configFile.DecisionArchiveDir != nil {
+		configs["decision_archive_dir"] = *configFile.DecisionArchiveDir
+	}
+
 	// 如果JWT密钥不为空，也同步
 	if configFile.JWTSecret != "" {
 		configs["jwt_secret"] = configFile.JWTSecret
@@ -145,6 +230,30 @@ func loadBetaCodesToDatabase(database *config.Database) error {
 }
 
 func main() {
+	// MCP stdio 模式: nofx mcp [dbPath]
+	if len(os.Args) > 1 && os.Args[1] == "mcp" {
+		runMCPStdio(os.Args[2:])
+		return
+	}
+
+	// 导入旧版JSON决策日志: nofx import-decision-logs [dbPath] [logsDir]
+	if len(os.Args) > 1 && os.Args[1] == "import-decision-logs" {
+		runImportDecisionLogs(os.Args[2:])
+		return
+	}
+
+	// 导出数据集: nofx export -trader <id> -dataset <name> [-format csv|jsonl|parquet]
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		runExport(os.Args[2:])
+		return
+	}
+
+	// 离线回放历史决策: nofx replay -trader <id> -recordings <dir>
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		runReplay(os.Args[2:])
+		return
+	}
+
 	fmt.Println("╔════════════════════════════════════════════════════════════╗")
 	fmt.Println("║    🤖 AI多模型交易系统 - 支持 DeepSeek & Qwen            ║")
 	fmt.Println("╚════════════════════════════════════════════════════════════╝")
@@ -211,15 +320,15 @@ func main() {
 	if defaultCoinsJSON != "" {
 		// 尝试从JSON解析
 		if err := json.Unmarshal([]byte(defaultCoinsJSON), &defaultCoins); err != nil {
-			log.Printf("⚠️  解析default_coins配置失败: %v，使用硬编码默认值", err)
-			defaultCoins = []string{"BTCUSDT", "ETHUSDT", "SOLUSDT", "BNBUSDT", "XRPUSDT", "DOGEUSDT", "ADAUSDT", "HYPEUSDT"}
+			log.Printf("⚠️  解析default_coins配置失败: %v，使用内置默认值", err)
+			defaultCoins = pool.DefaultCoins()
 		} else {
 			log.Printf("✓ 从数据库加载默认币种列表（共%d个）: %v", len(defaultCoins), defaultCoins)
 		}
 	} else {
 		// 如果数据库中没有配置，使用硬编码默认值
-		defaultCoins = []string{"BTCUSDT", "ETHUSDT", "SOLUSDT", "BNBUSDT", "XRPUSDT", "DOGEUSDT", "ADAUSDT", "HYPEUSDT"}
-		log.Printf("⚠️  数据库中未配置default_coins，使用硬编码默认值")
+		defaultCoins = pool.DefaultCoins()
+		log.Printf("⚠️  数据库中未配置default_coins，使用内置默认值")
 	}
 
 	pool.SetDefaultCoins(defaultCoins)
@@ -242,6 +351,69 @@ func main() {
 		log.Printf("✓ 已配置OI Top API")
 	}
 
+	// 币种池信号源权重与自选币种文件
+	if v, _ := database.GetSystemConfig("signal_source_weights"); v != "" {
+		var weights map[string]float64
+		if err := json.Unmarshal([]byte(v), &weights); err != nil {
+			log.Printf("⚠️  解析signal_source_weights配置失败: %v，使用默认权重", err)
+		} else {
+			pool.SetSourceWeights(weights)
+		}
+	}
+	if v, _ := database.GetSystemConfig("signal_file"); v != "" {
+		pool.SetSignalFile(v)
+		log.Printf("✓ 已配置自选币种文件: %s", v)
+	}
+
+	// 自动可交易池（交易员可通过 universe_filters 覆盖）
+	universeEnabledStr, _ := database.GetSystemConfig("universe_enabled")
+	universeFiltersJSON, _ := database.GetSystemConfig("universe_filters")
+	universeFilters, err := pool.ParseUniverseFilters(universeFiltersJSON)
+	if err != nil {
+		log.Printf("⚠️  %v，使用默认筛选条件", err)
+	}
+	pool.SetUniverse(universeEnabledStr == "true", universeFilters)
+
+	// 设置AI请求限流（同一provider+API Key的所有交易员共享）
+	aiRPM := mcp.DefaultRequestsPerMinute
+	if v, _ := database.GetSystemConfig("ai_requests_per_minute"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			aiRPM = n
+		}
+	}
+	aiMaxInFlight := mcp.DefaultMaxInFlight
+	if v, _ := database.GetSystemConfig("ai_max_in_flight"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			aiMaxInFlight = n
+		}
+	}
+	mcp.SetDefaultLimits(aiRPM, aiMaxInFlight)
+
+	// AI流式输出（实时思维链，可中止卡住的请求）
+	aiStreaming := true
+	if v, _ := database.GetSystemConfig("ai_streaming"); v != "" {
+		aiStreaming = v == "true"
+	}
+	streamIdleTimeout := mcp.DefaultStreamIdleTimeout
+	if v, _ := database.GetSystemConfig("ai_stream_idle_timeout_seconds"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			streamIdleTimeout = time.Duration(n) * time.Second
+		}
+	}
+	mcp.SetStreaming(aiStreaming, streamIdleTimeout)
+
+	// AI响应录制（用于 nofx replay 离线回放）
+	if recordDir, _ := database.GetSystemConfig("ai_record_dir"); recordDir != "" {
+		mcp.SetRecordDir(recordDir)
+	}
+
+	// 开仓滑点检查
+	if v, _ := database.GetSystemConfig("max_slippage_pct"); v != "" {
+		if pct, err := strconv.ParseFloat(v, 64); err == nil {
+			trader.SetMaxSlippagePct(pct)
+		}
+	}
+
 	// 创建TraderManager
 	traderManager := manager.NewTraderManager()
 
@@ -251,6 +423,9 @@ func main() {
 		log.Fatalf("❌ 加载交易员失败: %v", err)
 	}
 
+	// 定期压缩、汇总过期的决策日志
+	traderManager.StartRetentionScheduler()
+
 	// 获取数据库中的所有交易员配置（用于显示，使用default用户）
 	traders, err := database.GetTraders("default")
 	if err != nil {
@@ -303,6 +478,18 @@ func main() {
 		}
 	}()
 
+	// 本地行情存储：重启或断线后只回补缺失的K线，并支持更长的回看
+	if storePath, _ := database.GetSystemConfig("market_store_path"); storePath != "" {
+		store, err := market.OpenStore(storePath)
+		if err != nil {
+			log.Printf("⚠️  %v，K线仅保存在内存中", err)
+		} else {
+			market.SetStore(store)
+			defer store.Close()
+			log.Printf("✓ 本地行情存储: %s", storePath)
+		}
+	}
+
 	// 启动流行情数据 - 默认使用所有交易员设置的币种 如果没有设置币种 则优先使用系统默认
 	go market.NewWSMonitor(150).Start(database.GetCustomCoins())
 	//go market.NewWSMonitor(150).Start([]string{}) //这里是一个使用方式 传入空的话 则使用market市场的所有币种
@@ -0,0 +1,55 @@
+package manager
+
+import (
+	"log"
+	"time"
+)
+
+// retentionInterval 决策日志保留策略的执行间隔
+const retentionInterval = 6 * time.Hour
+
+// StartRetentionScheduler 后台定期按保留策略压缩、汇总所有交易员的决策日志（启动后先延迟几分钟，避开交易员初始化）
+func (tm *TraderManager) StartRetentionScheduler() {
+	go func() {
+		time.Sleep(5 * time.Minute)
+		ticker := time.NewTicker(retentionInterval)
+		defer ticker.Stop()
+		for {
+			tm.ApplyRetention()
+			<-ticker.C
+		}
+	}()
+	log.Printf("✓ 决策日志保留策略已启用（每%v执行一次）", retentionInterval)
+}
+
+// ApplyRetention 对内存中的所有交易员执行一次决策日志保留策略
+func (tm *TraderManager) ApplyRetention() {
+	if tm.database == nil {
+		return
+	}
+
+	changed := false
+	for id, at := range tm.GetAllTraders() {
+		policy, err := tm.database.RetentionPolicy(id)
+		if err != nil {
+			log.Printf("⚠️  [%s] 获取决策日志保留策略失败: %v", at.GetName(), err)
+			continue
+		}
+		result, err := at.GetDecisionLogger().ApplyRetention(policy, tm.database.DecisionArchiveDir(id), time.Now())
+		if err != nil {
+			log.Printf("⚠️  [%s] 执行决策日志保留策略失败: %v", at.GetName(), err)
+		}
+		if result == nil || result.Compacted+result.Aggregated == 0 {
+			continue
+		}
+		changed = true
+		log.Printf("🗜️  [%s] 决策日志已压缩 %d 条，汇总 %d 天（精简 %d 条）", at.GetName(), result.Compacted, result.Days, result.Aggregated)
+	}
+
+	// 压缩和精简后的空间需要 VACUUM 才会还给文件系统
+	if storage, _ := tm.database.GetSystemConfig("decision_log_storage"); changed && storage != "file" {
+		if err := tm.database.Vacuum(); err != nil {
+			log.Printf("⚠️  回收数据库空间失败: %v", err)
+		}
+	}
+}
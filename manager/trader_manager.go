@@ -6,7 +6,12 @@ import (
 	"fmt"
 	"log"
 	"nofx/config"
+	"nofx/logger"
+	"nofx/market"
+	"nofx/pool"
 	"nofx/trader"
+	"os"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
@@ -26,6 +31,8 @@ type TraderManager struct {
 	traders         map[string]*trader.AutoTrader // key: trader ID
 	competitionCache *CompetitionCache
 	mu              sync.RWMutex
+
+	database *config.Database // 用于恢复和持久化币种冷却期
 }
 
 // NewTraderManager 创建trader管理器
@@ -42,6 +49,7 @@ func NewTraderManager() *TraderManager {
 func (tm *TraderManager) LoadTradersFromDatabase(database *config.Database) error {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
+	tm.database = database
 
 	// 获取所有用户
 	userIDs, err := database.GetAllUsers()
@@ -200,8 +208,9 @@ func (tm *TraderManager) addTraderFromDB(traderCfg *config.TraderRecord, aiModel
 		}
 	}
 
-	// 如果没有指定交易币种，使用默认币种
-	if len(tradingCoins) == 0 {
+	// 如果没有指定交易币种，使用默认币种（启用自动可交易池时由可交易池提供候选币种）
+	universe := parseUniverse(traderCfg)
+	if len(tradingCoins) == 0 && universe == nil {
 		tradingCoins = defaultCoins
 	}
 
@@ -211,6 +220,11 @@ func (tm *TraderManager) addTraderFromDB(traderCfg *config.TraderRecord, aiModel
 		effectiveCoinPoolURL = coinPoolURL
 		log.Printf("✓ 交易员 %s 启用 COIN POOL 信号源: %s", traderCfg.Name, coinPoolURL)
 	}
+	var effectiveOITopURL string
+	if traderCfg.UseOITop && oiTopURL != "" {
+		effectiveOITopURL = oiTopURL
+		log.Printf("✓ 交易员 %s 启用 OI TOP 信号源: %s", traderCfg.Name, oiTopURL)
+	}
 
 	// 构建AutoTraderConfig
 	traderConfig := trader.AutoTraderConfig{
@@ -223,6 +237,7 @@ func (tm *TraderManager) addTraderFromDB(traderCfg *config.TraderRecord, aiModel
 		HyperliquidPrivateKey: "",
 		HyperliquidTestnet:    exchangeCfg.Testnet,
 		CoinPoolAPIURL:        effectiveCoinPoolURL,
+		OITopAPIURL:           effectiveOITopURL,
 		UseQwen:               aiModelCfg.Provider == "qwen",
 		DeepSeekKey:           "",
 		QwenKey:               "",
@@ -239,6 +254,15 @@ func (tm *TraderManager) addTraderFromDB(traderCfg *config.TraderRecord, aiModel
 		DefaultCoins:          defaultCoins,
 		TradingCoins:          tradingCoins,
 		SystemPromptTemplate:  traderCfg.SystemPromptTemplate, // 系统提示词模板
+		DecisionPipeline:      traderCfg.DecisionPipeline,     // 决策流程
+		Timeframes:            parseTimeframes(traderCfg),     // 额外K线周期
+		Universe:              universe,                       // 自动可交易池筛选条件
+		SymbolBlacklist:       trader.ParseSymbolList(traderCfg.SymbolBlacklist),
+		SymbolWhitelist:       trader.ParseSymbolList(traderCfg.SymbolWhitelist),
+		SymbolCooldowns:       tm.loadSymbolCooldowns(traderCfg.ID),
+		StopLossCooldown:      time.Duration(traderCfg.StopLossCooldown) * time.Minute,
+		DecisionStore:         tm.decisionStore(traderCfg.ID),
+		TradeLedger:           tm.tradeLedger(traderCfg.ID),
 	}
 
 	// 根据交易所类型设置API密钥
@@ -266,6 +290,7 @@ func (tm *TraderManager) addTraderFromDB(traderCfg *config.TraderRecord, aiModel
 	if err != nil {
 		return fmt.Errorf("创建trader失败: %w", err)
 	}
+	tm.persistSymbolCooldowns(at, traderCfg.ID)
 
 	// 设置自定义prompt（如果有）
 	if traderCfg.CustomPrompt != "" {
@@ -307,8 +332,9 @@ func (tm *TraderManager) AddTraderFromDB(traderCfg *config.TraderRecord, aiModel
 		}
 	}
 
-	// 如果没有指定交易币种，使用默认币种
-	if len(tradingCoins) == 0 {
+	// 如果没有指定交易币种，使用默认币种（启用自动可交易池时由可交易池提供候选币种）
+	universe := parseUniverse(traderCfg)
+	if len(tradingCoins) == 0 && universe == nil {
 		tradingCoins = defaultCoins
 	}
 
@@ -318,6 +344,11 @@ func (tm *TraderManager) AddTraderFromDB(traderCfg *config.TraderRecord, aiModel
 		effectiveCoinPoolURL = coinPoolURL
 		log.Printf("✓ 交易员 %s 启用 COIN POOL 信号源: %s", traderCfg.Name, coinPoolURL)
 	}
+	var effectiveOITopURL string
+	if traderCfg.UseOITop && oiTopURL != "" {
+		effectiveOITopURL = oiTopURL
+		log.Printf("✓ 交易员 %s 启用 OI TOP 信号源: %s", traderCfg.Name, oiTopURL)
+	}
 
 	// 构建AutoTraderConfig
 	traderConfig := trader.AutoTraderConfig{
@@ -330,6 +361,7 @@ func (tm *TraderManager) AddTraderFromDB(traderCfg *config.TraderRecord, aiModel
 		HyperliquidPrivateKey: "",
 		HyperliquidTestnet:    exchangeCfg.Testnet,
 		CoinPoolAPIURL:        effectiveCoinPoolURL,
+		OITopAPIURL:           effectiveOITopURL,
 		UseQwen:               aiModelCfg.Provider == "qwen",
 		DeepSeekKey:           "",
 		QwenKey:               "",
@@ -345,6 +377,15 @@ func (tm *TraderManager) AddTraderFromDB(traderCfg *config.TraderRecord, aiModel
 		IsCrossMargin:         traderCfg.IsCrossMargin,
 		DefaultCoins:          defaultCoins,
 		TradingCoins:          tradingCoins,
+		DecisionPipeline:      traderCfg.DecisionPipeline, // 决策流程
+		Timeframes:            parseTimeframes(traderCfg), // 额外K线周期
+		Universe:              universe,                   // 自动可交易池筛选条件
+		SymbolBlacklist:       trader.ParseSymbolList(traderCfg.SymbolBlacklist),
+		SymbolWhitelist:       trader.ParseSymbolList(traderCfg.SymbolWhitelist),
+		SymbolCooldowns:       tm.loadSymbolCooldowns(traderCfg.ID),
+		StopLossCooldown:      time.Duration(traderCfg.StopLossCooldown) * time.Minute,
+		DecisionStore:         tm.decisionStore(traderCfg.ID),
+		TradeLedger:           tm.tradeLedger(traderCfg.ID),
 	}
 
 	// 根据交易所类型设置API密钥
@@ -372,6 +413,7 @@ func (tm *TraderManager) AddTraderFromDB(traderCfg *config.TraderRecord, aiModel
 	if err != nil {
 		return fmt.Errorf("创建trader失败: %w", err)
 	}
+	tm.persistSymbolCooldowns(at, traderCfg.ID)
 
 	// 设置自定义prompt（如果有）
 	if traderCfg.CustomPrompt != "" {
@@ -708,10 +750,100 @@ func containsUserPrefix(traderID string) bool {
 	return false
 }
 
+// parseTimeframes 解析交易员配置的额外K线周期（格式错误时忽略并记录日志）
+func parseTimeframes(traderCfg *config.TraderRecord) []market.Timeframe {
+	if traderCfg.Timeframes == "" {
+		return nil
+	}
+	timeframes, err := market.ParseTimeframes(traderCfg.Timeframes)
+	if err != nil {
+		log.Printf("⚠️  交易员 %s 的K线周期配置无效，已忽略: %v", traderCfg.Name, err)
+		return nil
+	}
+	return timeframes
+}
+
+// parseUniverse 解析交易员的自动可交易池筛选条件（未启用时返回 nil，格式错误时沿用全局配置并记录日志）
+func parseUniverse(traderCfg *config.TraderRecord) *pool.UniverseFilters {
+	universe, err := pool.UniverseFor(traderCfg.UniverseFilters)
+	if err != nil {
+		log.Printf("⚠️  交易员 %s 的可交易池配置无效，已使用全局配置: %v", traderCfg.Name, err)
+		universe, _ = pool.UniverseFor("")
+	}
+	return universe
+}
+
+// loadSymbolCooldowns 从数据库恢复交易员未到期的币种冷却期
+func (tm *TraderManager) loadSymbolCooldowns(traderID string) []trader.SymbolCooldown {
+	if tm.database == nil {
+		return nil
+	}
+	records, err := tm.database.GetSymbolCooldowns(traderID)
+	if err != nil {
+		log.Printf("⚠️  加载交易员 %s 的币种冷却期失败: %v", traderID, err)
+		return nil
+	}
+	cooldowns := make([]trader.SymbolCooldown, 0, len(records))
+	for _, record := range records {
+		cooldowns = append(cooldowns, trader.SymbolCooldown{Symbol: record.Symbol, Until: record.Until, Reason: record.Reason})
+	}
+	return cooldowns
+}
+
+// decisionStore 交易员的决策日志存储：默认写入数据库，decision_log_storage=file 时返回 nil（沿用JSON文件）
+// 首次使用数据库存储时自动导入 decision_logs/<trader_id> 目录下已有的JSON记录
+func (tm *TraderManager) decisionStore(traderID string) logger.DecisionStore {
+	if tm.database == nil {
+		return nil
+	}
+	if storage, _ := tm.database.GetSystemConfig("decision_log_storage"); storage == "file" {
+		return nil
+	}
+
+	store := tm.database.DecisionStore(traderID)
+	logDir := filepath.Join("decision_logs", traderID)
+	if count, err := store.Count(); err == nil && count == 0 {
+		if _, err := os.Stat(logDir); err == nil {
+			imported, err := store.ImportDir(logDir)
+			if err != nil {
+				log.Printf("⚠️  导入交易员 %s 的历史决策日志失败: %v", traderID, err)
+			} else if imported > 0 {
+				log.Printf("✓ 已导入交易员 %s 的 %d 条历史决策日志 (%s)", traderID, imported, logDir)
+			}
+		}
+	}
+	return store
+}
+
+// tradeLedger 交易员的交易账本：决策日志存入数据库时交易账本也存入数据库，否则返回nil（使用JSON文件账本）
+func (tm *TraderManager) tradeLedger(traderID string) logger.TradeLedger {
+	if tm.database == nil {
+		return nil
+	}
+	if storage, _ := tm.database.GetSystemConfig("decision_log_storage"); storage == "file" {
+		return nil
+	}
+	return tm.database.TradeLedger(traderID)
+}
+
+// persistSymbolCooldowns 交易员新增的冷却期（如止损后自动冷却）写回数据库，重启后仍然生效
+func (tm *TraderManager) persistSymbolCooldowns(at *trader.AutoTrader, traderID string) {
+	database := tm.database
+	if database == nil {
+		return
+	}
+	at.SymbolRules().SetCooldownHook(func(cooldown trader.SymbolCooldown) {
+		if err := database.SetSymbolCooldown(traderID, cooldown.Symbol, cooldown.Until, cooldown.Reason); err != nil {
+			log.Printf("⚠️  保存交易员 %s 的币种冷却期失败: %v", traderID, err)
+		}
+	})
+}
+
 // LoadUserTraders 为特定用户加载交易员到内存
 func (tm *TraderManager) LoadUserTraders(database *config.Database, userID string) error {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
+	tm.database = database
 
 	// 获取指定用户的所有交易员
 	traders, err := database.GetTraders(userID)
@@ -856,8 +988,9 @@ func (tm *TraderManager) loadSingleTrader(traderCfg *config.TraderRecord, aiMode
 		}
 	}
 
-	// 如果没有指定交易币种，使用默认币种
-	if len(tradingCoins) == 0 {
+	// 如果没有指定交易币种，使用默认币种（启用自动可交易池时由可交易池提供候选币种）
+	universe := parseUniverse(traderCfg)
+	if len(tradingCoins) == 0 && universe == nil {
 		tradingCoins = defaultCoins
 	}
 
@@ -867,6 +1000,11 @@ func (tm *TraderManager) loadSingleTrader(traderCfg *config.TraderRecord, aiMode
 		effectiveCoinPoolURL = coinPoolURL
 		log.Printf("✓ 交易员 %s 启用 COIN POOL 信号源: %s", traderCfg.Name, coinPoolURL)
 	}
+	var effectiveOITopURL string
+	if traderCfg.UseOITop && oiTopURL != "" {
+		effectiveOITopURL = oiTopURL
+		log.Printf("✓ 交易员 %s 启用 OI TOP 信号源: %s", traderCfg.Name, oiTopURL)
+	}
 
 	// 构建AutoTraderConfig
 	traderConfig := trader.AutoTraderConfig{
@@ -879,6 +1017,7 @@ func (tm *TraderManager) loadSingleTrader(traderCfg *config.TraderRecord, aiMode
 		AltcoinLeverage:      traderCfg.AltcoinLeverage,
 		ScanInterval:         time.Duration(traderCfg.ScanIntervalMinutes) * time.Minute,
 		CoinPoolAPIURL:       effectiveCoinPoolURL,
+		OITopAPIURL:          effectiveOITopURL,
 		CustomAPIURL:         aiModelCfg.CustomAPIURL,    // 自定义API URL
 		CustomModelName:      aiModelCfg.CustomModelName, // 自定义模型名称
 		UseQwen:              aiModelCfg.Provider == "qwen",
@@ -889,6 +1028,15 @@ func (tm *TraderManager) loadSingleTrader(traderCfg *config.TraderRecord, aiMode
 		DefaultCoins:         defaultCoins,
 		TradingCoins:         tradingCoins,
 		SystemPromptTemplate: traderCfg.SystemPromptTemplate, // 系统提示词模板
+		DecisionPipeline:     traderCfg.DecisionPipeline,     // 决策流程
+		Timeframes:           parseTimeframes(traderCfg),     // 额外K线周期
+		Universe:             universe,                       // 自动可交易池筛选条件
+		SymbolBlacklist:      trader.ParseSymbolList(traderCfg.SymbolBlacklist),
+		SymbolWhitelist:      trader.ParseSymbolList(traderCfg.SymbolWhitelist),
+		SymbolCooldowns:      tm.loadSymbolCooldowns(traderCfg.ID),
+		StopLossCooldown:     time.Duration(traderCfg.StopLossCooldown) * time.Minute,
+		DecisionStore:        tm.decisionStore(traderCfg.ID),
+		TradeLedger:          tm.tradeLedger(traderCfg.ID),
 	}
 
 	// 根据交易所类型设置API密钥
@@ -916,6 +1064,7 @@ func (tm *TraderManager) loadSingleTrader(traderCfg *config.TraderRecord, aiMode
 	if err != nil {
 		return fmt.Errorf("创建trader失败: %w", err)
 	}
+	tm.persistSymbolCooldowns(at, traderCfg.ID)
 
 	// 设置自定义prompt（如果有）
 	if traderCfg.CustomPrompt != "" {
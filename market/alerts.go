@@ -0,0 +1,468 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"nofx/market/indicator"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 警报类型
+const (
+	AlertVolumeSpike    = "volume_spike"    // 成交量突增
+	AlertPriceMove      = "price_move"      // 15分钟内价格快速变动
+	AlertFundingExtreme = "funding_extreme" // 资金费率极端
+	AlertOIJump         = "oi_jump"         // 持仓量突变
+)
+
+const (
+	markPriceStream     = "!markPrice@arr" // 全市场标记价格与资金费率流（每3秒推送）
+	alertCooldown       = 15 * time.Minute // 同一交易对同类警报的最小间隔
+	alertHistorySize    = 200              // 保留的最近警报数量
+	alertSubscriberBuf  = 100              // 每个订阅者的警报缓冲，消费过慢时丢弃
+	alertScoreHalfLife  = 30 * time.Minute // 警报评分半衰期
+	alertMaxMagnitude   = 3.0              // 单条警报计分时超出阈值的倍数上限
+	oiPollInterval      = 5 * time.Minute  // 持仓量轮询间隔（币安没有持仓量推送）
+	featureInterval     = "3m"             // 计算特征和检测警报使用的K线周期
+	volumeSpikeLookback = 20               // 成交量突增的对比K线数量
+)
+
+// alertWeights 各类警报的基础评分
+var alertWeights = map[string]float64{
+	AlertVolumeSpike:    10,
+	AlertPriceMove:      15,
+	AlertFundingExtreme: 5,
+	AlertOIJump:         10,
+}
+
+// SymbolScore 交易对警报评分
+type SymbolScore struct {
+	Symbol        string    `json:"symbol"`
+	Score         float64   `json:"score"`
+	AlertCount    int       `json:"alert_count"`
+	LastAlertTime time.Time `json:"last_alert_time"`
+}
+
+// startAlertEngine 启动警报分发、资金费率订阅和持仓量轮询
+func (m *WSMonitor) startAlertEngine() {
+	go m.dispatchAlerts()
+
+	ch := m.combinedClient.AddSubscriber(markPriceStream, 10)
+	go m.handleMarkPriceData(ch)
+	if err := m.combinedClient.subscribeStreams([]string{markPriceStream}); err != nil {
+		log.Printf("⚠️  订阅资金费率流失败: %v", err)
+	}
+
+	go m.pollOpenInterest()
+}
+
+// dispatchAlerts 把 alertsChan 中的警报广播给所有订阅者
+func (m *WSMonitor) dispatchAlerts() {
+	for alert := range m.alertsChan {
+		m.alertMu.Lock()
+		for ch := range m.alertSubs {
+			select {
+			case ch <- alert:
+			default:
+			}
+		}
+		m.alertMu.Unlock()
+	}
+}
+
+// SubscribeAlerts 订阅实时警报，返回警报通道和取消订阅函数
+func (m *WSMonitor) SubscribeAlerts() (<-chan Alert, func()) {
+	ch := make(chan Alert, alertSubscriberBuf)
+	m.alertMu.Lock()
+	m.alertSubs[ch] = struct{}{}
+	m.alertMu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			m.alertMu.Lock()
+			delete(m.alertSubs, ch)
+			m.alertMu.Unlock()
+		})
+	}
+}
+
+// RecentAlerts 最近的警报（按时间升序），symbol 为空时返回所有交易对
+func (m *WSMonitor) RecentAlerts(symbol string, limit int) []Alert {
+	symbol = strings.ToUpper(symbol)
+	m.alertMu.Lock()
+	defer m.alertMu.Unlock()
+
+	var alerts []Alert
+	for _, alert := range m.recentAlerts {
+		if symbol == "" || alert.Symbol == symbol {
+			alerts = append(alerts, alert)
+		}
+	}
+	if limit > 0 && len(alerts) > limit {
+		alerts = alerts[len(alerts)-limit:]
+	}
+	return alerts
+}
+
+// publishAlert 更新交易对评分并发布警报（同类警报冷却期内忽略）
+func (m *WSMonitor) publishAlert(alert Alert) {
+	now := time.Now()
+	alert.Timestamp = now
+
+	m.alertMu.Lock()
+	key := alert.Symbol + "|" + alert.Type
+	if last, ok := m.lastAlerts[key]; ok && now.Sub(last) < alertCooldown {
+		m.alertMu.Unlock()
+		return
+	}
+	m.lastAlerts[key] = now
+
+	stats := m.symbolStatsLocked(alert.Symbol)
+	stats.Score = decayedScore(stats, now) + alertScore(alert)
+	stats.AlertCount++
+	stats.LastAlertTime = now
+	if alert.Type == AlertVolumeSpike {
+		stats.VolumeSpikeCount++
+	}
+
+	m.recentAlerts = append(m.recentAlerts, alert)
+	if len(m.recentAlerts) > alertHistorySize {
+		m.recentAlerts = m.recentAlerts[len(m.recentAlerts)-alertHistorySize:]
+	}
+
+	log.Printf("🚨 %s", alert.Message)
+	if !m.alertsClosed {
+		select {
+		case m.alertsChan <- alert:
+		default:
+			log.Printf("⚠️  警报通道已满，丢弃: %s", alert.Message)
+		}
+	}
+	m.alertMu.Unlock()
+}
+
+// symbolStatsLocked 获取（或创建）交易对统计，调用方需持有 alertMu
+func (m *WSMonitor) symbolStatsLocked(symbol string) *SymbolStats {
+	if value, ok := m.symbolStats.Load(symbol); ok {
+		return value.(*SymbolStats)
+	}
+	stats := &SymbolStats{}
+	m.symbolStats.Store(symbol, stats)
+	return stats
+}
+
+// alertScore 单条警报的评分：基础分 × 超出阈值的倍数（上限 alertMaxMagnitude）
+func alertScore(alert Alert) float64 {
+	magnitude := 1.0
+	if alert.Threshold != 0 {
+		magnitude = math.Min(math.Abs(alert.Value/alert.Threshold), alertMaxMagnitude)
+	}
+	return alertWeights[alert.Type] * magnitude
+}
+
+// decayedScore 按半衰期衰减后的评分
+func decayedScore(stats *SymbolStats, now time.Time) float64 {
+	if stats.LastAlertTime.IsZero() {
+		return stats.Score
+	}
+	elapsed := now.Sub(stats.LastAlertTime)
+	return stats.Score * math.Pow(0.5, float64(elapsed)/float64(alertScoreHalfLife))
+}
+
+// RankedSymbols 按当前（衰减后）评分降序返回评分不低于 minScore 的交易对，limit<=0 表示不限制
+func (m *WSMonitor) RankedSymbols(minScore float64, limit int) []SymbolScore {
+	now := time.Now()
+	m.alertMu.Lock()
+	var ranked []SymbolScore
+	m.symbolStats.Range(func(key, value interface{}) bool {
+		stats := value.(*SymbolStats)
+		if score := decayedScore(stats, now); score >= minScore && score > 0 {
+			ranked = append(ranked, SymbolScore{
+				Symbol:        key.(string),
+				Score:         score,
+				AlertCount:    stats.AlertCount,
+				LastAlertTime: stats.LastAlertTime,
+			})
+		}
+		return true
+	})
+	m.alertMu.Unlock()
+
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].Symbol < ranked[j].Symbol
+	})
+	if limit > 0 && len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked
+}
+
+// HotSymbols 近期警报活跃的交易对：评分不低于 MinScoreThreshold 且在 NoAlertTimeout 内有过警报
+func (m *WSMonitor) HotSymbols(limit int) []SymbolScore {
+	cutoff := time.Now().Add(-config.CleanupConfig.NoAlertTimeout)
+	var hot []SymbolScore
+	for _, s := range m.RankedSymbols(config.CleanupConfig.MinScoreThreshold, 0) {
+		if s.LastAlertTime.After(cutoff) {
+			hot = append(hot, s)
+		}
+	}
+	if limit > 0 && len(hot) > limit {
+		hot = hot[:limit]
+	}
+	return hot
+}
+
+// GetFeatures 获取交易对最近一次计算的特征（基于3分钟K线）
+func (m *WSMonitor) GetFeatures(symbol string) (*SymbolFeatures, bool) {
+	value, ok := m.featuresMap.Load(strings.ToUpper(symbol))
+	if !ok {
+		return nil, false
+	}
+	return value.(*SymbolFeatures), true
+}
+
+// checkKlineAlerts 根据3分钟K线检测价格快速变动；K线收盘时更新特征并检测成交量突增
+func (m *WSMonitor) checkKlineAlerts(symbol string, closed bool) {
+	s, ok := m.series(symbol, featureInterval)
+	if !ok {
+		return
+	}
+	thresholds := config.AlertThresholds
+
+	// 每次推送都会检测价格变动，只复制需要的K线
+	klines := s.snapshot(6)
+	n := len(klines)
+
+	// 15分钟（5根3分钟K线）价格变动
+	if n > 5 {
+		ref := klines[n-5].Open
+		if ref > 0 {
+			change := (klines[n-1].Close - ref) / ref
+			if math.Abs(change) >= thresholds.PriceChange15Min {
+				m.publishAlert(Alert{
+					Type:      AlertPriceMove,
+					Symbol:    symbol,
+					Value:     change,
+					Threshold: thresholds.PriceChange15Min,
+					Message:   fmt.Sprintf("%s 15分钟价格变动 %+.2f%%", symbol, change*100),
+				})
+			}
+		}
+	}
+
+	if !closed {
+		return
+	}
+
+	features := calculateFeatures(symbol, s.snapshot(0))
+	if features == nil {
+		return
+	}
+	m.featuresMap.Store(symbol, features)
+
+	m.alertMu.Lock()
+	m.symbolStatsLocked(symbol).LastActiveTime = time.Now()
+	m.alertMu.Unlock()
+
+	if features.VolumeRatio20 >= thresholds.VolumeSpike {
+		m.publishAlert(Alert{
+			Type:      AlertVolumeSpike,
+			Symbol:    symbol,
+			Value:     features.VolumeRatio20,
+			Threshold: thresholds.VolumeSpike,
+			Message:   fmt.Sprintf("%s 成交量突增: 最新3分钟成交量为前%d根均值的 %.1f 倍", symbol, volumeSpikeLookback, features.VolumeRatio20),
+		})
+	}
+}
+
+// calculateFeatures 根据K线（最后一根为刚收盘的K线）计算特征
+func calculateFeatures(symbol string, klines []Kline) *SymbolFeatures {
+	n := len(klines)
+	if n < volumeSpikeLookback+1 {
+		return nil
+	}
+	last := klines[n-1]
+	f := &SymbolFeatures{
+		Symbol:    symbol,
+		Timestamp: time.UnixMilli(last.CloseTime),
+		Price:     last.Close,
+		Volume:    last.Volume,
+	}
+	f.PriceChange15Min = priceChange(klines, 5)
+	f.PriceChange1H = priceChange(klines, 20)
+	f.PriceChange4H = priceChange(klines, 80)
+
+	// 成交量：最新一根与之前K线的均值对比
+	avg5 := averageVolume(klines[n-6 : n-1])
+	avg20 := averageVolume(klines[n-volumeSpikeLookback-1 : n-1])
+	if avg5 > 0 {
+		f.VolumeRatio5 = last.Volume / avg5
+	}
+	if avg20 > 0 {
+		f.VolumeRatio20 = last.Volume / avg20
+		f.VolumeTrend = avg5 / avg20
+	}
+
+	rsi := indicator.NewRSI(14)
+	sma5, sma10, sma20 := indicator.NewSMA(5), indicator.NewSMA(10), indicator.NewSMA(20)
+	returns := indicator.NewSMA(20)
+	for i, k := range klines {
+		rsi.Add(k.Close)
+		sma5.Add(k.Close)
+		sma10.Add(k.Close)
+		sma20.Add(k.Close)
+		if i > 0 && klines[i-1].Close > 0 {
+			returns.Add(k.Close/klines[i-1].Close - 1)
+		}
+	}
+	if rsi.Ready() {
+		f.RSI14 = rsi.Value()
+	}
+	f.SMA5, f.SMA10, f.SMA20 = sma5.Value(), sma10.Value(), sma20.Value()
+	if returns.Ready() {
+		f.Volatility20 = returns.StdDev()
+	}
+
+	high, low := last.High, last.Low
+	for _, k := range klines[n-20:] {
+		high = math.Max(high, k.High)
+		low = math.Min(low, k.Low)
+	}
+	if low > 0 {
+		f.HighLowRatio = high / low
+	}
+	if high > low {
+		f.PositionInRange = (last.Close - low) / (high - low)
+	}
+	return f
+}
+
+// priceChange 最近 candles 根K线的价格变化（比例），K线不足时返回0
+func priceChange(klines []Kline, candles int) float64 {
+	n := len(klines)
+	if n <= candles || klines[n-candles-1].Close <= 0 {
+		return 0
+	}
+	ref := klines[n-candles-1].Close
+	return (klines[n-1].Close - ref) / ref
+}
+
+// averageVolume 平均成交量
+func averageVolume(klines []Kline) float64 {
+	if len(klines) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, k := range klines {
+		sum += k.Volume
+	}
+	return sum / float64(len(klines))
+}
+
+// handleMarkPriceData 处理全市场资金费率推送，检测资金费率极端
+func (m *WSMonitor) handleMarkPriceData(ch <-chan []byte) {
+	threshold := config.AlertThresholds.FundingExtreme
+	for data := range ch {
+		var updates []struct {
+			EventTime       int64  `json:"E"`
+			Symbol          string `json:"s"`
+			MarkPrice       string `json:"p"`
+			IndexPrice      string `json:"i"`
+			FundingRate     string `json:"r"`
+			NextFundingTime int64  `json:"T"`
+		}
+		if err := json.Unmarshal(data, &updates); err != nil {
+			log.Printf("解析资金费率数据失败: %v", err)
+			continue
+		}
+		for _, u := range updates {
+			rate, err := parseFloat(u.FundingRate)
+			if err != nil {
+				continue
+			}
+			// 实时推送保持资金费率缓存新鲜，交易员读取时无需请求REST
+			index := PremiumIndex{LastFundingRate: rate, NextFundingTime: u.NextFundingTime, Time: u.EventTime}
+			index.MarkPrice, _ = parseFloat(u.MarkPrice)
+			index.IndexPrice, _ = parseFloat(u.IndexPrice)
+			binanceMetadata.updatePremium(u.Symbol, index)
+
+			if !m.isMonitored(u.Symbol) || math.Abs(rate) < threshold {
+				continue
+			}
+			m.publishAlert(Alert{
+				Type:      AlertFundingExtreme,
+				Symbol:    u.Symbol,
+				Value:     rate,
+				Threshold: threshold,
+				Message:   fmt.Sprintf("%s 资金费率极端: %.4f%%", u.Symbol, rate*100),
+			})
+		}
+	}
+}
+
+// isMonitored 是否为监控中的交易对（已加载3分钟K线）
+func (m *WSMonitor) isMonitored(symbol string) bool {
+	_, ok := m.series(symbol, featureInterval)
+	return ok
+}
+
+// pollOpenInterest 定期轮询监控交易对的持仓量，检测持仓量突变
+func (m *WSMonitor) pollOpenInterest() {
+	ticker := time.NewTicker(oiPollInterval)
+	defer ticker.Stop()
+
+	lastOI := make(map[string]float64)
+	var mu sync.Mutex
+
+	for {
+		var wg sync.WaitGroup
+		semaphore := make(chan struct{}, 5) // 限制并发数
+		for _, symbol := range m.symbols {
+			wg.Add(1)
+			semaphore <- struct{}{}
+			go func(s string) {
+				defer wg.Done()
+				defer func() { <-semaphore }()
+
+				oi, t, err := binanceMetadata.fetchOpenInterest(s) // 同时刷新共享缓存
+				if err != nil || oi <= 0 {
+					return
+				}
+				if store := GetStore(); store != nil {
+					store.SaveOpenInterest(s, t, oi)
+				}
+
+				mu.Lock()
+				prev := lastOI[s]
+				lastOI[s] = oi
+				mu.Unlock()
+
+				if prev <= 0 {
+					return
+				}
+				threshold := config.AlertThresholds.OIJump
+				if change := (oi - prev) / prev; math.Abs(change) >= threshold {
+					m.publishAlert(Alert{
+						Type:      AlertOIJump,
+						Symbol:    s,
+						Value:     change,
+						Threshold: threshold,
+						Message:   fmt.Sprintf("%s 持仓量%v内变化 %+.2f%%", s, oiPollInterval, change*100),
+					})
+				}
+			}(symbol)
+		}
+		wg.Wait()
+
+		<-ticker.C
+	}
+}
@@ -11,23 +11,32 @@ import (
 )
 
 const (
-	baseURL = "https://fapi.binance.com"
+	binanceBaseURL = "https://fapi.binance.com"
+	asterBaseURL   = "https://fapi.asterdex.com" // 与币安合约接口兼容
 )
 
 type APIClient struct {
-	client *http.Client
+	client  *http.Client
+	baseURL string
 }
 
+// NewAPIClient 创建币安合约行情客户端
 func NewAPIClient() *APIClient {
+	return newAPIClient(binanceBaseURL)
+}
+
+// newAPIClient 创建指定地址的行情客户端（接口与币安合约兼容的交易所共用）
+func newAPIClient(baseURL string) *APIClient {
 	return &APIClient{
 		client: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		baseURL: baseURL,
 	}
 }
 
 func (c *APIClient) GetExchangeInfo() (*ExchangeInfo, error) {
-	url := fmt.Sprintf("%s/fapi/v1/exchangeInfo", baseURL)
+	url := fmt.Sprintf("%s/fapi/v1/exchangeInfo", c.baseURL)
 	resp, err := c.client.Get(url)
 	if err != nil {
 		return nil, err
@@ -48,7 +57,55 @@ func (c *APIClient) GetExchangeInfo() (*ExchangeInfo, error) {
 }
 
 func (c *APIClient) GetKlines(symbol, interval string, limit int) ([]Kline, error) {
-	url := fmt.Sprintf("%s/fapi/v1/klines", baseURL)
+	return c.fetchKlines(symbol, interval, limit, 0, 0)
+}
+
+// GetKlinesRange 获取 [startTime, endTime] 区间内开盘的K线（毫秒），超过单次上限时自动分页
+func (c *APIClient) GetKlinesRange(symbol, interval string, startTime, endTime int64) ([]Kline, error) {
+	var all []Kline
+	for startTime <= endTime {
+		klines, err := c.fetchKlines(symbol, interval, maxKlineLimit, startTime, endTime)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, klines...)
+		if len(klines) < maxKlineLimit {
+			break
+		}
+		startTime = klines[len(klines)-1].OpenTime + 1
+	}
+	return all, nil
+}
+
+// GetRecentKlines 获取最近 limit 根K线，超过单次上限时按时间向前分页
+func (c *APIClient) GetRecentKlines(symbol, interval string, limit int) ([]Kline, error) {
+	if limit <= maxKlineLimit {
+		return c.GetKlines(symbol, interval, limit)
+	}
+
+	var all []Kline
+	var endTime int64
+	for len(all) < limit {
+		batch := limit - len(all)
+		if batch > maxKlineLimit {
+			batch = maxKlineLimit
+		}
+		klines, err := c.fetchKlines(symbol, interval, batch, 0, endTime)
+		if err != nil {
+			return nil, err
+		}
+		all = append(klines, all...)
+		if len(klines) < batch {
+			break // 已到最早的K线
+		}
+		endTime = klines[0].OpenTime - 1
+	}
+	return all, nil
+}
+
+// fetchKlines 请求K线接口，startTime/endTime 为0时不传
+func (c *APIClient) fetchKlines(symbol, interval string, limit int, startTime, endTime int64) ([]Kline, error) {
+	url := fmt.Sprintf("%s/fapi/v1/klines", c.baseURL)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return nil, err
@@ -58,6 +115,12 @@ func (c *APIClient) GetKlines(symbol, interval string, limit int) ([]Kline, erro
 	q.Add("symbol", symbol)
 	q.Add("interval", interval)
 	q.Add("limit", strconv.Itoa(limit))
+	if startTime > 0 {
+		q.Add("startTime", strconv.FormatInt(startTime, 10))
+	}
+	if endTime > 0 {
+		q.Add("endTime", strconv.FormatInt(endTime, 10))
+	}
 	req.URL.RawQuery = q.Encode()
 
 	resp, err := c.client.Do(req)
@@ -114,7 +177,7 @@ func parseKline(kr KlineResponse) (Kline, error) {
 }
 
 func (c *APIClient) GetCurrentPrice(symbol string) (float64, error) {
-	url := fmt.Sprintf("%s/fapi/v1/ticker/price", baseURL)
+	url := fmt.Sprintf("%s/fapi/v1/ticker/price", c.baseURL)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return 0, err
@@ -148,3 +211,127 @@ func (c *APIClient) GetCurrentPrice(symbol string) (float64, error) {
 
 	return price, nil
 }
+
+// GetTickers24h 获取所有交易对的24小时行情统计
+func (c *APIClient) GetTickers24h() ([]Ticker24hr, error) {
+	var tickers []Ticker24hr
+	if err := c.getJSON("/fapi/v1/ticker/24hr", "", &tickers); err != nil {
+		return nil, err
+	}
+	return tickers, nil
+}
+
+// GetBookTickers 获取所有交易对的最优挂单价格
+func (c *APIClient) GetBookTickers() ([]BookTicker, error) {
+	var tickers []BookTicker
+	if err := c.getJSON("/fapi/v1/ticker/bookTicker", "", &tickers); err != nil {
+		return nil, err
+	}
+	return tickers, nil
+}
+
+// GetOpenInterest 获取当前持仓量及其时间（毫秒）
+func (c *APIClient) GetOpenInterest(symbol string) (float64, int64, error) {
+	var result struct {
+		OpenInterest string `json:"openInterest"`
+		Symbol       string `json:"symbol"`
+		Time         int64  `json:"time"`
+	}
+	if err := c.getJSON("/fapi/v1/openInterest", symbol, &result); err != nil {
+		return 0, 0, err
+	}
+
+	oi, err := strconv.ParseFloat(result.OpenInterest, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("解析持仓量失败: %w", err)
+	}
+	if result.Time == 0 {
+		result.Time = time.Now().UnixMilli()
+	}
+	return oi, result.Time, nil
+}
+
+// PremiumIndex 标记价格与资金费率
+type PremiumIndex struct {
+	MarkPrice       float64
+	IndexPrice      float64
+	LastFundingRate float64
+	NextFundingTime int64
+	Time            int64
+}
+
+// premiumIndexResponse premiumIndex 接口返回的单个交易对数据
+type premiumIndexResponse struct {
+	Symbol          string `json:"symbol"`
+	MarkPrice       string `json:"markPrice"`
+	IndexPrice      string `json:"indexPrice"`
+	LastFundingRate string `json:"lastFundingRate"`
+	NextFundingTime int64  `json:"nextFundingTime"`
+	InterestRate    string `json:"interestRate"`
+	Time            int64  `json:"time"`
+}
+
+func (r premiumIndexResponse) parse() PremiumIndex {
+	index := PremiumIndex{
+		NextFundingTime: r.NextFundingTime,
+		Time:            r.Time,
+	}
+	index.MarkPrice, _ = strconv.ParseFloat(r.MarkPrice, 64)
+	index.IndexPrice, _ = strconv.ParseFloat(r.IndexPrice, 64)
+	index.LastFundingRate, _ = strconv.ParseFloat(r.LastFundingRate, 64)
+	if index.Time == 0 {
+		index.Time = time.Now().UnixMilli()
+	}
+	return index
+}
+
+// GetPremiumIndex 获取标记价格与资金费率
+func (c *APIClient) GetPremiumIndex(symbol string) (*PremiumIndex, error) {
+	var result premiumIndexResponse
+	if err := c.getJSON("/fapi/v1/premiumIndex", symbol, &result); err != nil {
+		return nil, err
+	}
+	index := result.parse()
+	return &index, nil
+}
+
+// GetPremiumIndexAll 一次获取所有交易对的标记价格与资金费率
+func (c *APIClient) GetPremiumIndexAll() (map[string]PremiumIndex, error) {
+	var results []premiumIndexResponse
+	if err := c.getJSON("/fapi/v1/premiumIndex", "", &results); err != nil {
+		return nil, err
+	}
+	indexes := make(map[string]PremiumIndex, len(results))
+	for _, r := range results {
+		indexes[r.Symbol] = r.parse()
+	}
+	return indexes, nil
+}
+
+// getJSON 请求行情接口并解析JSON（symbol 为空时请求全市场数据）
+func (c *APIClient) getJSON(path, symbol string, out interface{}) error {
+	req, err := http.NewRequest("GET", c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	if symbol != "" {
+		q := req.URL.Query()
+		q.Add("symbol", symbol)
+		req.URL.RawQuery = q.Encode()
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("请求%s失败 (status %d): %s", path, resp.StatusCode, string(body))
+	}
+	return json.Unmarshal(body, out)
+}
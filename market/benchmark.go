@@ -0,0 +1,51 @@
+package market
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// historicalPrices 已收盘分钟K线的收盘价缓存（symbol@分钟 -> 价格），历史价格不会再变化
+var (
+	historicalPrices   = make(map[string]float64)
+	historicalPricesMu sync.Mutex
+)
+
+// maxHistoricalPrices 缓存上限，超过后清空重建
+const maxHistoricalPrices = 4096
+
+// PriceAt symbol 在 t 时刻的价格（t 所在1分钟K线的收盘价，t 为当前时间时即最新价）
+func PriceAt(symbol string, t time.Time) (float64, error) {
+	minute := t.Truncate(time.Minute)
+	key := fmt.Sprintf("%s@%d", symbol, minute.Unix())
+	closed := time.Since(minute) > 2*time.Minute
+
+	if closed {
+		historicalPricesMu.Lock()
+		price, ok := historicalPrices[key]
+		historicalPricesMu.Unlock()
+		if ok {
+			return price, nil
+		}
+	}
+
+	klines, err := NewAPIClient().fetchKlines(symbol, "1m", 1, 0, t.UnixMilli())
+	if err != nil {
+		return 0, fmt.Errorf("获取%s历史价格失败: %w", symbol, err)
+	}
+	if len(klines) == 0 {
+		return 0, fmt.Errorf("%s在%s没有K线数据", symbol, t.Format("2006-01-02 15:04"))
+	}
+	price := klines[len(klines)-1].Close
+
+	if closed {
+		historicalPricesMu.Lock()
+		if len(historicalPrices) >= maxHistoricalPrices {
+			historicalPrices = make(map[string]float64)
+		}
+		historicalPrices[key] = price
+		historicalPricesMu.Unlock()
+	}
+	return price, nil
+}
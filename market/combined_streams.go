@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -11,21 +12,56 @@ import (
 	"github.com/gorilla/websocket"
 )
 
+const (
+	connStaleTimeout   = time.Minute      // 连接上所有流都没有消息超过该时间视为假死，主动重连
+	klineStaleAfter    = 2 * time.Minute  // K线流超过该时间没有推送视为过期
+	realtimeStaleAfter = 30 * time.Second // 深度/标记价格流超过该时间没有推送视为过期
+	watchdogInterval   = 15 * time.Second // 假死检测间隔
+	lagSmoothing       = 0.05             // 推送延迟指数平均的平滑系数
+)
+
 type CombinedStreamsClient struct {
 	conn        *websocket.Conn
 	mu          sync.RWMutex
 	subscribers map[string]chan []byte
+	streams     map[string]time.Time // 已订阅的流 -> 订阅时间（重连后全部恢复）
 	reconnect   bool
 	done        chan struct{}
 	batchSize   int // 每批订阅的流数量
+	onReconnect func()
+
+	statsMu        sync.Mutex
+	lastMessage    map[string]time.Time // 每个流最近一次收到消息的时间
+	lastAnyMessage time.Time
+	connectedAt    time.Time
+	connected      bool
+	reconnects     int
+	lastReconnect  time.Time
+	messages       int64
+	avgLagMs       float64 // 交易所事件时间到本地接收的平均延迟（毫秒）
+}
+
+// StreamHealth 组合流连接健康状况
+type StreamHealth struct {
+	Connected      bool      `json:"connected"`
+	ConnectedSince time.Time `json:"connected_since"`
+	Reconnects     int       `json:"reconnects"`
+	LastReconnect  time.Time `json:"last_reconnect,omitempty"`
+	Streams        int       `json:"streams"`
+	StaleStreams   []string  `json:"stale_streams"`
+	LastMessage    time.Time `json:"last_message"`
+	Messages       int64     `json:"messages"`
+	AvgLagMs       float64   `json:"avg_lag_ms"`
 }
 
 func NewCombinedStreamsClient(batchSize int) *CombinedStreamsClient {
 	return &CombinedStreamsClient{
 		subscribers: make(map[string]chan []byte),
+		streams:     make(map[string]time.Time),
 		reconnect:   true,
 		done:        make(chan struct{}),
 		batchSize:   batchSize,
+		lastMessage: make(map[string]time.Time),
 	}
 }
 
@@ -44,8 +80,19 @@ func (c *CombinedStreamsClient) Connect() error {
 	c.conn = conn
 	c.mu.Unlock()
 
+	now := time.Now()
+	c.statsMu.Lock()
+	first := c.connectedAt.IsZero()
+	c.connected = true
+	c.connectedAt = now
+	c.lastAnyMessage = now
+	c.statsMu.Unlock()
+
 	log.Println("组合流WebSocket连接成功")
 	go c.readMessages()
+	if first {
+		go c.watchdog()
+	}
 
 	return nil
 }
@@ -91,16 +138,31 @@ func (c *CombinedStreamsClient) splitIntoBatches(symbols []string, batchSize int
 	return batches
 }
 
-// subscribeStreams 订阅多个流
+// subscribeStreams 订阅多个流（记录订阅，重连后自动恢复）
 func (c *CombinedStreamsClient) subscribeStreams(streams []string) error {
+	c.mu.Lock()
+	now := time.Now()
+	for _, stream := range streams {
+		if _, ok := c.streams[stream]; !ok {
+			c.streams[stream] = now
+		}
+	}
+	c.mu.Unlock()
+
+	return c.sendSubscribe(streams)
+}
+
+// sendSubscribe 发送订阅请求
+func (c *CombinedStreamsClient) sendSubscribe(streams []string) error {
 	subscribeMsg := map[string]interface{}{
 		"method": "SUBSCRIBE",
 		"params": streams,
 		"id":     time.Now().UnixNano(),
 	}
 
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	// 写操作需要独占连接（gorilla/websocket 不支持并发写）
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
 	if c.conn == nil {
 		return fmt.Errorf("WebSocket未连接")
@@ -110,6 +172,69 @@ func (c *CombinedStreamsClient) subscribeStreams(streams []string) error {
 	return c.conn.WriteJSON(subscribeMsg)
 }
 
+// unsubscribeStreams 取消订阅多个流，并关闭对应的订阅者通道
+func (c *CombinedStreamsClient) unsubscribeStreams(streams []string) error {
+	c.removeStreams(streams)
+
+	unsubscribeMsg := map[string]interface{}{
+		"method": "UNSUBSCRIBE",
+		"params": streams,
+		"id":     time.Now().UnixNano(),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		return nil // 未连接时重连也不会恢复已移除的流
+	}
+	log.Printf("取消订阅流: %v", streams)
+	return c.conn.WriteJSON(unsubscribeMsg)
+}
+
+// removeStreams 移除流的订阅记录和订阅者（关闭通道，处理协程随之退出）
+func (c *CombinedStreamsClient) removeStreams(streams []string) {
+	c.mu.Lock()
+	for _, stream := range streams {
+		delete(c.streams, stream)
+		if ch, ok := c.subscribers[stream]; ok {
+			close(ch)
+			delete(c.subscribers, stream)
+		}
+	}
+	c.mu.Unlock()
+
+	c.statsMu.Lock()
+	for _, stream := range streams {
+		delete(c.lastMessage, stream)
+	}
+	c.statsMu.Unlock()
+}
+
+// resubscribeAll 重连后分批恢复所有已订阅的流
+func (c *CombinedStreamsClient) resubscribeAll() {
+	c.mu.Lock()
+	streams := make([]string, 0, len(c.streams))
+	now := time.Now()
+	for stream := range c.streams {
+		streams = append(streams, stream)
+		c.streams[stream] = now // 重新计算过期时间
+	}
+	c.mu.Unlock()
+
+	batches := c.splitIntoBatches(streams, c.batchSize)
+	for i, batch := range batches {
+		if err := c.sendSubscribe(batch); err != nil {
+			log.Printf("⚠️  重连后恢复订阅失败（第 %d 批）: %v", i+1, err)
+			return
+		}
+		if i < len(batches)-1 {
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+	log.Printf("✓ 重连后已恢复 %d 个订阅流", len(streams))
+}
+
 func (c *CombinedStreamsClient) readMessages() {
 	for {
 		select {
@@ -128,6 +253,9 @@ func (c *CombinedStreamsClient) readMessages() {
 			_, message, err := conn.ReadMessage()
 			if err != nil {
 				log.Printf("读取组合流消息失败: %v", err)
+				c.statsMu.Lock()
+				c.connected = false
+				c.statsMu.Unlock()
 				c.handleReconnect()
 				return
 			}
@@ -148,11 +276,14 @@ func (c *CombinedStreamsClient) handleCombinedMessage(message []byte) {
 		return
 	}
 
-	c.mu.RLock()
-	ch, exists := c.subscribers[combinedMsg.Stream]
-	c.mu.RUnlock()
+	if combinedMsg.Stream != "" {
+		c.recordMessage(combinedMsg.Stream, combinedMsg.Data)
+	}
 
-	if exists {
+	// 持有读锁发送（非阻塞），避免与 removeStreams/Close 关闭通道并发
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if ch, exists := c.subscribers[combinedMsg.Stream]; exists {
 		select {
 		case ch <- combinedMsg.Data:
 		default:
@@ -169,6 +300,13 @@ func (c *CombinedStreamsClient) AddSubscriber(stream string, bufferSize int) <-c
 	return ch
 }
 
+// SetOnReconnect 设置重连成功后的回调（用于回补断线期间的数据）
+func (c *CombinedStreamsClient) SetOnReconnect(fn func()) {
+	c.mu.Lock()
+	c.onReconnect = fn
+	c.mu.Unlock()
+}
+
 func (c *CombinedStreamsClient) handleReconnect() {
 	if !c.reconnect {
 		return
@@ -180,6 +318,21 @@ func (c *CombinedStreamsClient) handleReconnect() {
 	if err := c.Connect(); err != nil {
 		log.Printf("组合流重新连接失败: %v", err)
 		go c.handleReconnect()
+		return
+	}
+
+	c.statsMu.Lock()
+	c.reconnects++
+	c.lastReconnect = time.Now()
+	c.statsMu.Unlock()
+
+	c.resubscribeAll()
+
+	c.mu.RLock()
+	onReconnect := c.onReconnect
+	c.mu.RUnlock()
+	if onReconnect != nil {
+		go onReconnect()
 	}
 }
 
@@ -200,3 +353,133 @@ func (c *CombinedStreamsClient) Close() {
 		delete(c.subscribers, stream)
 	}
 }
+
+// recordMessage 记录流的最近消息时间和推送延迟
+func (c *CombinedStreamsClient) recordMessage(stream string, data json.RawMessage) {
+	now := time.Now()
+	var event struct {
+		EventTime int64 `json:"E"`
+	}
+	// 数组格式的全市场流（如 !markPrice@arr）不计算延迟
+	if len(data) == 0 || data[0] != '{' || json.Unmarshal(data, &event) != nil {
+		event.EventTime = 0
+	}
+
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	c.lastMessage[stream] = now
+	c.lastAnyMessage = now
+	c.messages++
+	if event.EventTime > 0 {
+		lag := float64(now.UnixMilli() - event.EventTime)
+		if c.avgLagMs == 0 {
+			c.avgLagMs = lag
+		} else {
+			c.avgLagMs += lagSmoothing * (lag - c.avgLagMs)
+		}
+	}
+}
+
+// staleAfter 流的过期阈值（0 表示不检测，例如强平流可能长时间没有推送）
+func staleAfter(stream string) time.Duration {
+	switch {
+	case strings.Contains(stream, "@kline_"):
+		return klineStaleAfter
+	case strings.Contains(stream, "@depth"), strings.HasPrefix(stream, "!markPrice"):
+		return realtimeStaleAfter
+	default:
+		return 0
+	}
+}
+
+// StreamStale 流是否已过期（已订阅但超过阈值没有推送）；未订阅的流视为过期
+func (c *CombinedStreamsClient) StreamStale(stream string) bool {
+	c.mu.RLock()
+	subscribedAt, ok := c.streams[stream]
+	c.mu.RUnlock()
+	if !ok {
+		return true
+	}
+	return c.streamStale(stream, subscribedAt, time.Now())
+}
+
+// streamStale 判断流是否过期（新订阅的流从订阅时间开始计算）
+func (c *CombinedStreamsClient) streamStale(stream string, subscribedAt, now time.Time) bool {
+	threshold := staleAfter(stream)
+	if threshold == 0 {
+		return false
+	}
+	c.statsMu.Lock()
+	last := c.lastMessage[stream]
+	connected := c.connected
+	c.statsMu.Unlock()
+	if !connected {
+		return true
+	}
+	if subscribedAt.After(last) {
+		last = subscribedAt
+	}
+	return now.Sub(last) > threshold
+}
+
+// watchdog 检测连接假死（已连接但所有流都没有消息），关闭连接触发重连
+func (c *CombinedStreamsClient) watchdog() {
+	ticker := time.NewTicker(watchdogInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+
+		c.mu.RLock()
+		conn := c.conn
+		hasStreams := len(c.streams) > 0
+		c.mu.RUnlock()
+
+		c.statsMu.Lock()
+		silent := time.Since(c.lastAnyMessage)
+		connected := c.connected
+		c.statsMu.Unlock()
+
+		if conn != nil && connected && hasStreams && silent > connStaleTimeout {
+			log.Printf("⚠️  组合流已 %v 没有收到消息，主动断开重连", silent.Round(time.Second))
+			conn.Close() // readMessages 读取失败后进入重连流程
+		}
+	}
+}
+
+// Health 连接健康状况
+func (c *CombinedStreamsClient) Health() StreamHealth {
+	c.mu.RLock()
+	streams := make(map[string]time.Time, len(c.streams))
+	for stream, subscribedAt := range c.streams {
+		streams[stream] = subscribedAt
+	}
+	c.mu.RUnlock()
+
+	now := time.Now()
+	stale := []string{}
+	for stream, subscribedAt := range streams {
+		if c.streamStale(stream, subscribedAt, now) {
+			stale = append(stale, stream)
+		}
+	}
+	sort.Strings(stale)
+
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	return StreamHealth{
+		Connected:      c.connected,
+		ConnectedSince: c.connectedAt,
+		Reconnects:     c.reconnects,
+		LastReconnect:  c.lastReconnect,
+		Streams:        len(streams),
+		StaleStreams:   stale,
+		LastMessage:    c.lastAnyMessage,
+		Messages:       c.messages,
+		AvgLagMs:       c.avgLagMs,
+	}
+}
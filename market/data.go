@@ -1,29 +1,50 @@
 package market
 
 import (
-	"encoding/json"
 	"fmt"
-	"io"
-	"math"
-	"net/http"
+	"log"
 	"strconv"
 	"strings"
+
+	"nofx/market/indicator"
 )
 
 // Get 获取指定代币的市场数据
 func Get(symbol string) (*Data, error) {
+	return GetWithTimeframes(symbol, nil)
+}
+
+// GetWithTimeframes 获取指定代币的市场数据，并附带额外周期的数据
+// 3m/4h 基础数据始终包含；额外周期首次请求时会自动回补历史并订阅实时K线
+func GetWithTimeframes(symbol string, timeframes []Timeframe) (*Data, error) {
+	return GetWithOptions(symbol, Options{Timeframes: timeframes})
+}
+
+// Options 获取市场数据的可选项
+type Options struct {
+	Provider   Provider         // 行情来源（为空时使用币安），应与交易员所在交易所一致
+	Timeframes []Timeframe      // 额外周期（3m/4h 始终包含）
+	Indicators []indicator.Spec // 额外技术指标，对 3m、4h 及所有额外周期分别计算
+}
+
+// GetWithOptions 获取指定代币的市场数据，附带额外周期和额外技术指标
+func GetWithOptions(symbol string, opts Options) (*Data, error) {
 	var klines3m, klines4h []Kline
 	var err error
+	provider := opts.Provider
+	if provider == nil {
+		provider = Binance
+	}
 	// 标准化symbol
 	symbol = Normalize(symbol)
 	// 获取3分钟K线数据 (最近10个)
-	klines3m, err = WSMonitorCli.GetCurrentKlines(symbol, "3m") // 多获取一些用于计算
+	klines3m, err = provider.GetKlines(symbol, "3m", defaultKlineLimit) // 多获取一些用于计算
 	if err != nil {
 		return nil, fmt.Errorf("获取3分钟K线失败: %v", err)
 	}
 
 	// 获取4小时K线数据 (最近10个)
-	klines4h, err = WSMonitorCli.GetCurrentKlines(symbol, "4h") // 多获取用于计算指标
+	klines4h, err = provider.GetKlines(symbol, "4h", defaultKlineLimit) // 多获取用于计算指标
 	if err != nil {
 		return nil, fmt.Errorf("获取4小时K线失败: %v", err)
 	}
@@ -54,14 +75,30 @@ func Get(symbol string) (*Data, error) {
 	}
 
 	// 获取OI数据
-	oiData, err := getOpenInterestData(symbol)
+	oiData, err := provider.GetOpenInterest(symbol)
 	if err != nil {
 		// OI失败不影响整体,使用默认值
 		oiData = &OIData{Latest: 0, Average: 0}
 	}
 
 	// 获取Funding Rate
-	fundingRate, _ := getFundingRate(symbol)
+	fundingRate, _ := provider.GetFundingRate(symbol)
+
+	// 订单簿深度（失败不影响整体）
+	var depthData *DepthData
+	if book, err := provider.GetOrderBook(symbol); err != nil {
+		log.Printf("⚠️  获取 %s 订单簿失败: %v", symbol, err)
+	} else {
+		depthData = AnalyzeDepth(book)
+	}
+
+	// 衍生品数据（仅部分行情来源支持，失败不影响整体）
+	var derivativesData *DerivativesData
+	if dp, ok := provider.(DerivativesProvider); ok {
+		if derivativesData, err = dp.GetDerivatives(symbol); err != nil {
+			log.Printf("⚠️  获取 %s 衍生品数据失败: %v", symbol, err)
+		}
+	}
 
 	// 计算日内系列数据
 	intradayData := calculateIntradaySeries(klines3m)
@@ -69,6 +106,30 @@ func Get(symbol string) (*Data, error) {
 	// 计算长期数据
 	longerTermData := calculateLongerTermData(klines4h)
 
+	// 额外周期（单个周期失败不影响整体）
+	var timeframeData map[string]*TimeframeData
+	var indicatorData map[string][]*indicator.Series
+	if len(opts.Indicators) > 0 {
+		indicatorData = map[string][]*indicator.Series{
+			"3m": calculateIndicators(symbol, "3m", klines3m, opts.Indicators),
+			"4h": calculateIndicators(symbol, "4h", klines4h, opts.Indicators),
+		}
+	}
+	for _, tf := range opts.Timeframes {
+		klines, err := provider.GetKlines(symbol, tf.Interval, tf.Lookback)
+		if err != nil {
+			log.Printf("⚠️  获取 %s %s K线失败: %v", symbol, tf.Interval, err)
+			continue
+		}
+		if timeframeData == nil {
+			timeframeData = make(map[string]*TimeframeData)
+		}
+		timeframeData[tf.Interval] = calculateTimeframeData(tf.Interval, klines)
+		if indicatorData != nil {
+			indicatorData[tf.Interval] = calculateIndicators(symbol, tf.Interval, klines, opts.Indicators)
+		}
+	}
+
 	return &Data{
 		Symbol:            symbol,
 		CurrentPrice:      currentPrice,
@@ -81,29 +142,23 @@ func Get(symbol string) (*Data, error) {
 		FundingRate:       fundingRate,
 		IntradaySeries:    intradayData,
 		LongerTermContext: longerTermData,
+		Timeframes:        timeframeData,
+		Indicators:        indicatorData,
+		Depth:             depthData,
+		Derivatives:       derivativesData,
 	}, nil
 }
 
 // calculateEMA 计算EMA
 func calculateEMA(klines []Kline, period int) float64 {
-	if len(klines) < period {
-		return 0
-	}
-
-	// 计算SMA作为初始EMA
-	sum := 0.0
-	for i := 0; i < period; i++ {
-		sum += klines[i].Close
+	ema := indicator.NewEMA(period)
+	for _, k := range klines {
+		ema.Add(k.Close)
 	}
-	ema := sum / float64(period)
-
-	// 计算EMA
-	multiplier := 2.0 / float64(period+1)
-	for i := period; i < len(klines); i++ {
-		ema = (klines[i].Close-ema)*multiplier + ema
+	if !ema.Ready() {
+		return 0
 	}
-
-	return ema
+	return ema.Value()
 }
 
 // calculateMACD 计算MACD
@@ -112,131 +167,72 @@ func calculateMACD(klines []Kline) float64 {
 		return 0
 	}
 
-	// 计算12期和26期EMA
-	ema12 := calculateEMA(klines, 12)
-	ema26 := calculateEMA(klines, 26)
-
 	// MACD = EMA12 - EMA26
-	return ema12 - ema26
+	return calculateEMA(klines, 12) - calculateEMA(klines, 26)
 }
 
-// calculateRSI 计算RSI
+// calculateRSI 计算RSI（Wilder平滑）
 func calculateRSI(klines []Kline, period int) float64 {
-	if len(klines) <= period {
-		return 0
+	rsi := indicator.NewRSI(period)
+	for _, k := range klines {
+		rsi.Add(k.Close)
 	}
-
-	gains := 0.0
-	losses := 0.0
-
-	// 计算初始平均涨跌幅
-	for i := 1; i <= period; i++ {
-		change := klines[i].Close - klines[i-1].Close
-		if change > 0 {
-			gains += change
-		} else {
-			losses += -change
-		}
-	}
-
-	avgGain := gains / float64(period)
-	avgLoss := losses / float64(period)
-
-	// 使用Wilder平滑方法计算后续RSI
-	for i := period + 1; i < len(klines); i++ {
-		change := klines[i].Close - klines[i-1].Close
-		if change > 0 {
-			avgGain = (avgGain*float64(period-1) + change) / float64(period)
-			avgLoss = (avgLoss * float64(period-1)) / float64(period)
-		} else {
-			avgGain = (avgGain * float64(period-1)) / float64(period)
-			avgLoss = (avgLoss*float64(period-1) + (-change)) / float64(period)
-		}
-	}
-
-	if avgLoss == 0 {
-		return 100
+	if !rsi.Ready() {
+		return 0
 	}
-
-	rs := avgGain / avgLoss
-	rsi := 100 - (100 / (1 + rs))
-
-	return rsi
+	return rsi.Value()
 }
 
 // calculateATR 计算ATR
 func calculateATR(klines []Kline, period int) float64 {
-	if len(klines) <= period {
-		return 0
+	atr := indicator.NewATR(period)
+	for _, bar := range toBars(klines) {
+		atr.Update(bar)
 	}
-
-	trs := make([]float64, len(klines))
-	for i := 1; i < len(klines); i++ {
-		high := klines[i].High
-		low := klines[i].Low
-		prevClose := klines[i-1].Close
-
-		tr1 := high - low
-		tr2 := math.Abs(high - prevClose)
-		tr3 := math.Abs(low - prevClose)
-
-		trs[i] = math.Max(tr1, math.Max(tr2, tr3))
-	}
-
-	// 计算初始ATR
-	sum := 0.0
-	for i := 1; i <= period; i++ {
-		sum += trs[i]
-	}
-	atr := sum / float64(period)
-
-	// Wilder平滑
-	for i := period + 1; i < len(klines); i++ {
-		atr = (atr*float64(period-1) + trs[i]) / float64(period)
+	if !atr.Ready() {
+		return 0
 	}
-
-	return atr
+	return atr.Value()
 }
 
-// calculateIntradaySeries 计算日内系列数据
+// calculateIntradaySeries 计算日内系列数据（所有指标单次遍历增量计算）
 func calculateIntradaySeries(klines []Kline) *IntradayData {
 	data := &IntradayData{
-		MidPrices:   make([]float64, 0, 10),
-		EMA20Values: make([]float64, 0, 10),
-		MACDValues:  make([]float64, 0, 10),
-		RSI7Values:  make([]float64, 0, 10),
-		RSI14Values: make([]float64, 0, 10),
-	}
-
-	// 获取最近10个数据点
-	start := len(klines) - 10
-	if start < 0 {
-		start = 0
-	}
-
-	for i := start; i < len(klines); i++ {
-		data.MidPrices = append(data.MidPrices, klines[i].Close)
-
-		// 计算每个点的EMA20
-		if i >= 19 {
-			ema20 := calculateEMA(klines[:i+1], 20)
-			data.EMA20Values = append(data.EMA20Values, ema20)
+		MidPrices:   make([]float64, 0, seriesPoints),
+		EMA20Values: make([]float64, 0, seriesPoints),
+		MACDValues:  make([]float64, 0, seriesPoints),
+		RSI7Values:  make([]float64, 0, seriesPoints),
+		RSI14Values: make([]float64, 0, seriesPoints),
+	}
+
+	ema20 := indicator.NewEMA(20)
+	macd := indicator.NewMACD(12, 26, 9)
+	rsi7 := indicator.NewRSI(7)
+	rsi14 := indicator.NewRSI(14)
+
+	// 只输出最近10个数据点
+	start := len(klines) - seriesPoints
+	for i, bar := range toBars(klines) {
+		ema20.Update(bar)
+		macd.Update(bar)
+		rsi7.Update(bar)
+		rsi14.Update(bar)
+		if i < start {
+			continue
 		}
 
-		// 计算每个点的MACD
-		if i >= 25 {
-			macd := calculateMACD(klines[:i+1])
-			data.MACDValues = append(data.MACDValues, macd)
+		data.MidPrices = append(data.MidPrices, bar.Close)
+		if ema20.Ready() {
+			data.EMA20Values = append(data.EMA20Values, ema20.Value())
 		}
-
-		// 计算每个点的RSI
-		if i >= 7 {
-			rsi7 := calculateRSI(klines[:i+1], 7)
-			data.RSI7Values = append(data.RSI7Values, rsi7)
+		if macd.LineReady() {
+			data.MACDValues = append(data.MACDValues, macd.Line())
 		}
-		if i >= 14 {
-			rsi14 := calculateRSI(klines[:i+1], 14)
-			data.RSI14Values = append(data.RSI14Values, rsi14)
+		if rsi7.Ready() {
+			data.RSI7Values = append(data.RSI7Values, rsi7.Value())
+		}
+		if rsi14.Ready() {
+			data.RSI14Values = append(data.RSI14Values, rsi14.Value())
 		}
 	}
 
@@ -246,113 +242,59 @@ func calculateIntradaySeries(klines []Kline) *IntradayData {
 // calculateLongerTermData 计算长期数据
 func calculateLongerTermData(klines []Kline) *LongerTermData {
 	data := &LongerTermData{
-		MACDValues:  make([]float64, 0, 10),
-		RSI14Values: make([]float64, 0, 10),
-	}
-
-	// 计算EMA
-	data.EMA20 = calculateEMA(klines, 20)
-	data.EMA50 = calculateEMA(klines, 50)
-
-	// 计算ATR
-	data.ATR3 = calculateATR(klines, 3)
-	data.ATR14 = calculateATR(klines, 14)
-
-	// 计算成交量
-	if len(klines) > 0 {
-		data.CurrentVolume = klines[len(klines)-1].Volume
-		// 计算平均成交量
-		sum := 0.0
-		for _, k := range klines {
-			sum += k.Volume
+		MACDValues:  make([]float64, 0, seriesPoints),
+		RSI14Values: make([]float64, 0, seriesPoints),
+	}
+
+	ema20 := indicator.NewEMA(20)
+	ema50 := indicator.NewEMA(50)
+	atr3 := indicator.NewATR(3)
+	atr14 := indicator.NewATR(14)
+	macd := indicator.NewMACD(12, 26, 9)
+	rsi14 := indicator.NewRSI(14)
+
+	start := len(klines) - seriesPoints
+	volumeSum := 0.0
+	for i, bar := range toBars(klines) {
+		ema20.Update(bar)
+		ema50.Update(bar)
+		atr3.Update(bar)
+		atr14.Update(bar)
+		macd.Update(bar)
+		rsi14.Update(bar)
+		volumeSum += bar.Volume
+		if i < start {
+			continue
 		}
-		data.AverageVolume = sum / float64(len(klines))
-	}
 
-	// 计算MACD和RSI序列
-	start := len(klines) - 10
-	if start < 0 {
-		start = 0
-	}
-
-	for i := start; i < len(klines); i++ {
-		if i >= 25 {
-			macd := calculateMACD(klines[:i+1])
-			data.MACDValues = append(data.MACDValues, macd)
+		if macd.LineReady() {
+			data.MACDValues = append(data.MACDValues, macd.Line())
 		}
-		if i >= 14 {
-			rsi14 := calculateRSI(klines[:i+1], 14)
-			data.RSI14Values = append(data.RSI14Values, rsi14)
+		if rsi14.Ready() {
+			data.RSI14Values = append(data.RSI14Values, rsi14.Value())
 		}
 	}
 
-	return data
-}
-
-// getOpenInterestData 获取OI数据
-func getOpenInterestData(symbol string) (*OIData, error) {
-	url := fmt.Sprintf("https://fapi.binance.com/fapi/v1/openInterest?symbol=%s", symbol)
-
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	var result struct {
-		OpenInterest string `json:"openInterest"`
-		Symbol       string `json:"symbol"`
-		Time         int64  `json:"time"`
-	}
+	data.EMA20 = readyValue(ema20.Ready(), ema20.Value())
+	data.EMA50 = readyValue(ema50.Ready(), ema50.Value())
+	data.ATR3 = readyValue(atr3.Ready(), atr3.Value())
+	data.ATR14 = readyValue(atr14.Ready(), atr14.Value())
 
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, err
+	// 计算成交量
+	if len(klines) > 0 {
+		data.CurrentVolume = klines[len(klines)-1].Volume
+		data.AverageVolume = volumeSum / float64(len(klines))
 	}
 
-	oi, _ := strconv.ParseFloat(result.OpenInterest, 64)
-
-	return &OIData{
-		Latest:  oi,
-		Average: oi * 0.999, // 近似平均值
-	}, nil
+	return data
 }
 
-// getFundingRate 获取资金费率
-func getFundingRate(symbol string) (float64, error) {
-	url := fmt.Sprintf("https://fapi.binance.com/fapi/v1/premiumIndex?symbol=%s", symbol)
-
-	resp, err := http.Get(url)
-	if err != nil {
-		return 0, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return 0, err
-	}
-
-	var result struct {
-		Symbol          string `json:"symbol"`
-		MarkPrice       string `json:"markPrice"`
-		IndexPrice      string `json:"indexPrice"`
-		LastFundingRate string `json:"lastFundingRate"`
-		NextFundingTime int64  `json:"nextFundingTime"`
-		InterestRate    string `json:"interestRate"`
-		Time            int64  `json:"time"`
-	}
-
-	if err := json.Unmarshal(body, &result); err != nil {
-		return 0, err
+// readyValue 指标未就绪时返回0
+func readyValue(ready bool, value float64) float64 {
+	if !ready {
+		return 0
 	}
-
-	rate, _ := strconv.ParseFloat(result.LastFundingRate, 64)
-	return rate, nil
+	return value
 }
 
 // Format 格式化输出市场数据
@@ -372,6 +314,14 @@ func Format(data *Data) string {
 
 	sb.WriteString(fmt.Sprintf("Funding Rate: %.2e\n\n", data.FundingRate))
 
+	if data.Depth != nil {
+		formatDepth(&sb, data.Depth)
+	}
+
+	if data.Derivatives != nil {
+		formatDerivatives(&sb, data.Derivatives)
+	}
+
 	if data.IntradaySeries != nil {
 		sb.WriteString("Intraday series (3‑minute intervals, oldest → latest):\n\n")
 
@@ -417,6 +367,14 @@ func Format(data *Data) string {
 		}
 	}
 
+	if len(data.Timeframes) > 0 {
+		formatTimeframes(&sb, data.Timeframes)
+	}
+
+	if len(data.Indicators) > 0 {
+		formatIndicators(&sb, data.Indicators)
+	}
+
 	return sb.String()
 }
 
@@ -0,0 +1,334 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	liquidationStream    = "!forceOrder@arr" // 全市场强平订单流（每个交易对每秒最多推送一条）
+	liquidationRetention = 4 * time.Hour     // 强平记录保留时长（最大统计窗口）
+
+	futuresDataPeriod   = "5m"        // 合约数据接口的统计周期
+	futuresDataLimit    = 48          // 拉取的数据点数量（48 × 5m = 4小时）
+	derivativesCacheTTL = time.Minute // 合约数据缓存时间（接口按5分钟更新）
+)
+
+// liquidationWindows 强平统计的滚动窗口
+var liquidationWindows = []time.Duration{15 * time.Minute, time.Hour, 4 * time.Hour}
+
+// LiquidationStats 滚动窗口内的强平统计（名义价值单位 USDT）
+type LiquidationStats struct {
+	Window        string
+	LongCount     int     // 多头被强平次数（强平卖单）
+	LongNotional  float64 // 多头被强平名义价值
+	ShortCount    int     // 空头被强平次数（强平买单）
+	ShortNotional float64 // 空头被强平名义价值
+}
+
+// DerivativesData 衍生品仓位与资金流数据
+type DerivativesData struct {
+	Liquidations            []LiquidationStats // 强平统计（需启动行情监控，否则为空）
+	LongShortRatio          float64            // 全市场账户多空比
+	LongShortRatioChange4h  float64            // 全市场账户多空比4小时变化
+	TopTraderLongShortRatio float64            // 大户账户多空比
+	TakerBuySellRatio1h     float64            // 1小时主动买入/卖出成交量比
+	TakerBuySellRatio4h     float64            // 4小时主动买入/卖出成交量比
+	OpenInterestValue       float64            // 持仓价值（USDT）
+	OIChange1hPct           float64            // 持仓量1小时变化（%）
+	OIChange4hPct           float64            // 持仓量4小时变化（%）
+}
+
+// DerivativesProvider 提供衍生品数据的行情来源（目前仅币安）
+type DerivativesProvider interface {
+	GetDerivatives(symbol string) (*DerivativesData, error)
+}
+
+// liquidationEvent 单笔强平订单
+type liquidationEvent struct {
+	time     int64 // 毫秒
+	long     bool  // true = 多头被强平
+	notional float64
+}
+
+// liquidationTracker 按交易对保存最近的强平订单
+type liquidationTracker struct {
+	mu     sync.Mutex
+	events map[string][]liquidationEvent
+}
+
+func newLiquidationTracker() *liquidationTracker {
+	return &liquidationTracker{events: make(map[string][]liquidationEvent)}
+}
+
+// add 记录强平订单并清理过期记录
+func (t *liquidationTracker) add(symbol string, event liquidationEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	cutoff := time.Now().Add(-liquidationRetention).UnixMilli()
+	events := t.events[symbol]
+	i := 0
+	for i < len(events) && events[i].time < cutoff {
+		i++
+	}
+	t.events[symbol] = append(events[i:], event)
+}
+
+// stats 统计各滚动窗口的强平情况
+func (t *liquidationTracker) stats(symbol string) []LiquidationStats {
+	t.mu.Lock()
+	events := append([]liquidationEvent(nil), t.events[symbol]...)
+	t.mu.Unlock()
+
+	now := time.Now()
+	stats := make([]LiquidationStats, len(liquidationWindows))
+	for i, window := range liquidationWindows {
+		stats[i].Window = formatWindow(window)
+		cutoff := now.Add(-window).UnixMilli()
+		for _, e := range events {
+			if e.time < cutoff {
+				continue
+			}
+			if e.long {
+				stats[i].LongCount++
+				stats[i].LongNotional += e.notional
+			} else {
+				stats[i].ShortCount++
+				stats[i].ShortNotional += e.notional
+			}
+		}
+	}
+	return stats
+}
+
+// formatWindow 格式化统计窗口，例如 15m、1h、4h
+func formatWindow(d time.Duration) string {
+	if d >= time.Hour && d%time.Hour == 0 {
+		return fmt.Sprintf("%dh", int(d/time.Hour))
+	}
+	return fmt.Sprintf("%dm", int(d/time.Minute))
+}
+
+// subscribeLiquidations 订阅全市场强平订单流
+func (m *WSMonitor) subscribeLiquidations() error {
+	ch := m.combinedClient.AddSubscriber(liquidationStream, 1000)
+	go m.handleLiquidationData(ch)
+	return m.combinedClient.subscribeStreams([]string{liquidationStream})
+}
+
+// handleLiquidationData 处理强平订单推送
+func (m *WSMonitor) handleLiquidationData(ch <-chan []byte) {
+	for data := range ch {
+		var event struct {
+			Order struct {
+				Symbol    string `json:"s"`
+				Side      string `json:"S"`
+				AvgPrice  string `json:"ap"`
+				FilledQty string `json:"z"`
+				TradeTime int64  `json:"T"`
+			} `json:"o"`
+		}
+		if err := json.Unmarshal(data, &event); err != nil {
+			log.Printf("解析强平数据失败: %v", err)
+			continue
+		}
+		price, _ := strconv.ParseFloat(event.Order.AvgPrice, 64)
+		qty, _ := strconv.ParseFloat(event.Order.FilledQty, 64)
+		if price <= 0 || qty <= 0 {
+			continue
+		}
+		m.liquidations.add(event.Order.Symbol, liquidationEvent{
+			time:     event.Order.TradeTime,
+			long:     event.Order.Side == "SELL",
+			notional: price * qty,
+		})
+	}
+}
+
+// LiquidationStats 获取交易对最近的强平统计
+func (m *WSMonitor) LiquidationStats(symbol string) []LiquidationStats {
+	return m.liquidations.stats(strings.ToUpper(symbol))
+}
+
+// ratioPoint 多空比数据点
+type ratioPoint struct {
+	LongShortRatio string `json:"longShortRatio"`
+	Timestamp      int64  `json:"timestamp"`
+}
+
+// takerVolumePoint 主动买卖量数据点
+type takerVolumePoint struct {
+	BuyVol    string `json:"buyVol"`
+	SellVol   string `json:"sellVol"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// openInterestHistPoint 持仓量历史数据点
+type openInterestHistPoint struct {
+	SumOpenInterest      string `json:"sumOpenInterest"`
+	SumOpenInterestValue string `json:"sumOpenInterestValue"`
+	Timestamp            int64  `json:"timestamp"`
+}
+
+// getFuturesData 请求 /futures/data 统计接口（按时间升序返回）
+func (c *APIClient) getFuturesData(endpoint, symbol string, out interface{}) error {
+	path := fmt.Sprintf("/futures/data/%s?period=%s&limit=%d", endpoint, futuresDataPeriod, futuresDataLimit)
+	return c.getJSON(path, symbol, out)
+}
+
+// derivativesCacheEntry 合约数据缓存
+type derivativesCacheEntry struct {
+	data    DerivativesData
+	fetched time.Time
+}
+
+// derivativesCache 合约数据缓存: SYMBOL -> *derivativesCacheEntry
+var derivativesCache sync.Map
+
+// GetDerivatives 获取多空比、主动买卖量、持仓量变化（缓存1分钟）和实时强平统计
+func (p *binanceProvider) GetDerivatives(symbol string) (*DerivativesData, error) {
+	symbol = strings.ToUpper(symbol)
+
+	var data DerivativesData
+	if value, ok := derivativesCache.Load(symbol); ok && time.Since(value.(*derivativesCacheEntry).fetched) < derivativesCacheTTL {
+		data = value.(*derivativesCacheEntry).data
+	} else {
+		fetched, err := fetchDerivatives(NewAPIClient(), symbol)
+		if err != nil {
+			return nil, err
+		}
+		data = *fetched
+		derivativesCache.Store(symbol, &derivativesCacheEntry{data: data, fetched: time.Now()})
+	}
+
+	if WSMonitorCli != nil {
+		data.Liquidations = WSMonitorCli.LiquidationStats(symbol)
+	}
+	return &data, nil
+}
+
+// fetchDerivatives 请求合约数据接口，单个接口失败时对应字段为0，全部失败才返回错误
+func fetchDerivatives(client *APIClient, symbol string) (*DerivativesData, error) {
+	data := &DerivativesData{}
+	var errs []string
+
+	var global []ratioPoint
+	if err := client.getFuturesData("globalLongShortAccountRatio", symbol, &global); err != nil {
+		errs = append(errs, err.Error())
+	} else if n := len(global); n > 0 {
+		data.LongShortRatio = floatValue(global[n-1].LongShortRatio)
+		data.LongShortRatioChange4h = data.LongShortRatio - floatValue(global[0].LongShortRatio)
+	}
+
+	var top []ratioPoint
+	if err := client.getFuturesData("topLongShortAccountRatio", symbol, &top); err != nil {
+		errs = append(errs, err.Error())
+	} else if n := len(top); n > 0 {
+		data.TopTraderLongShortRatio = floatValue(top[n-1].LongShortRatio)
+	}
+
+	var taker []takerVolumePoint
+	if err := client.getFuturesData("takerlongshortRatio", symbol, &taker); err != nil {
+		errs = append(errs, err.Error())
+	} else {
+		data.TakerBuySellRatio1h = takerRatio(taker, time.Hour)
+		data.TakerBuySellRatio4h = takerRatio(taker, 4*time.Hour)
+	}
+
+	var oiHist []openInterestHistPoint
+	if err := client.getFuturesData("openInterestHist", symbol, &oiHist); err != nil {
+		errs = append(errs, err.Error())
+	} else if n := len(oiHist); n > 0 {
+		latest := floatValue(oiHist[n-1].SumOpenInterest)
+		data.OpenInterestValue = floatValue(oiHist[n-1].SumOpenInterestValue)
+		data.OIChange1hPct = percentChange(oiHistAt(oiHist, time.Hour), latest)
+		data.OIChange4hPct = percentChange(floatValue(oiHist[0].SumOpenInterest), latest)
+
+		// 历史持仓量写入本地存储，使持仓量均值在重启后也能覆盖完整24小时
+		if store := GetStore(); store != nil {
+			for _, point := range oiHist {
+				store.SaveOpenInterest(symbol, point.Timestamp, floatValue(point.SumOpenInterest))
+			}
+		}
+	}
+
+	if len(errs) == 4 {
+		return nil, fmt.Errorf("获取%s合约数据失败: %s", symbol, strings.Join(errs, "; "))
+	}
+	return data, nil
+}
+
+// takerRatio 最近 window 内主动买入量与主动卖出量之比
+func takerRatio(points []takerVolumePoint, window time.Duration) float64 {
+	if len(points) == 0 {
+		return 0
+	}
+	cutoff := points[len(points)-1].Timestamp - window.Milliseconds()
+	buy, sell := 0.0, 0.0
+	for _, p := range points {
+		if p.Timestamp <= cutoff {
+			continue
+		}
+		buy += floatValue(p.BuyVol)
+		sell += floatValue(p.SellVol)
+	}
+	if sell == 0 {
+		return 0
+	}
+	return buy / sell
+}
+
+// oiHistAt 距最新数据点 ago 之前的持仓量（数据不足时使用最早的数据点）
+func oiHistAt(points []openInterestHistPoint, ago time.Duration) float64 {
+	target := points[len(points)-1].Timestamp - ago.Milliseconds()
+	for i := len(points) - 1; i >= 0; i-- {
+		if points[i].Timestamp <= target {
+			return floatValue(points[i].SumOpenInterest)
+		}
+	}
+	return floatValue(points[0].SumOpenInterest)
+}
+
+// percentChange 从 from 到 to 的变化百分比
+func percentChange(from, to float64) float64 {
+	if from == 0 {
+		return 0
+	}
+	return (to - from) / from * 100
+}
+
+// floatValue 解析数字字符串，失败返回0
+func floatValue(s string) float64 {
+	v, _ := parseFloat(s)
+	return v
+}
+
+// formatDerivatives 格式化衍生品数据
+func formatDerivatives(sb *strings.Builder, d *DerivativesData) {
+	sb.WriteString("Derivatives positioning and flow:\n\n")
+	if d.LongShortRatio > 0 {
+		sb.WriteString(fmt.Sprintf("Global account long/short ratio: %.3f (4h change: %+.3f)\n\n", d.LongShortRatio, d.LongShortRatioChange4h))
+	}
+	if d.TopTraderLongShortRatio > 0 {
+		sb.WriteString(fmt.Sprintf("Top trader account long/short ratio: %.3f\n\n", d.TopTraderLongShortRatio))
+	}
+	if d.TakerBuySellRatio1h > 0 || d.TakerBuySellRatio4h > 0 {
+		sb.WriteString(fmt.Sprintf("Taker buy/sell volume ratio: 1h %.3f, 4h %.3f\n\n", d.TakerBuySellRatio1h, d.TakerBuySellRatio4h))
+	}
+	if d.OpenInterestValue > 0 {
+		sb.WriteString(fmt.Sprintf("Open interest value: $%.0f (1h change: %+.2f%%, 4h change: %+.2f%%)\n\n", d.OpenInterestValue, d.OIChange1hPct, d.OIChange4hPct))
+	}
+	if len(d.Liquidations) > 0 {
+		parts := make([]string, len(d.Liquidations))
+		for i, l := range d.Liquidations {
+			parts[i] = fmt.Sprintf("%s longs $%.0f (%d) / shorts $%.0f (%d)", l.Window, l.LongNotional, l.LongCount, l.ShortNotional, l.ShortCount)
+		}
+		sb.WriteString(fmt.Sprintf("Forced liquidations: %s\n\n", strings.Join(parts, "; ")))
+	}
+}
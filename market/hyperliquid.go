@@ -0,0 +1,251 @@
+package market
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	hyperliquidMainnetURL = "https://api.hyperliquid.xyz"
+	hyperliquidTestnetURL = "https://api.hyperliquid-testnet.xyz"
+
+	hyperliquidMaxCandles   = 5000             // candleSnapshot 最多返回的K线数量
+	hyperliquidAssetCtxsTTL = 15 * time.Second // 资金费率/持仓量缓存时间（一次请求包含所有币种）
+)
+
+// hyperliquidIntervals Hyperliquid 支持的K线周期（不支持 6h）
+var hyperliquidIntervals = map[string]bool{
+	"1m": true, "3m": true, "5m": true, "15m": true, "30m": true,
+	"1h": true, "2h": true, "4h": true, "8h": true, "12h": true,
+	"1d": true, "3d": true, "1w": true, "1M": true,
+}
+
+// hyperliquidProvider Hyperliquid行情（info API）
+type hyperliquidProvider struct {
+	name    string
+	infoURL string
+	client  *http.Client
+
+	mu          sync.Mutex
+	assetCtxs   map[string]hyperliquidAssetCtx // coin -> 资产上下文
+	ctxsFetched time.Time
+}
+
+// hyperliquidAssetCtx 永续合约资产上下文
+type hyperliquidAssetCtx struct {
+	Funding      string `json:"funding"`      // 当前资金费率（每小时结算）
+	OpenInterest string `json:"openInterest"` // 持仓量（以币为单位）
+	MarkPx       string `json:"markPx"`
+}
+
+func newHyperliquidProvider(name, baseURL string) *hyperliquidProvider {
+	return &hyperliquidProvider{
+		name:    name,
+		infoURL: baseURL + "/info",
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (p *hyperliquidProvider) Name() string { return p.name }
+
+// postInfo 请求 info 接口
+func (p *hyperliquidProvider) postInfo(request interface{}, out interface{}) error {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Post(p.infoURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Hyperliquid info 请求失败 (status %d): %s", resp.StatusCode, string(body))
+	}
+	return json.Unmarshal(body, out)
+}
+
+// GetKlines 通过 candleSnapshot 获取最近 lookback 根K线
+func (p *hyperliquidProvider) GetKlines(symbol, interval string, lookback int) ([]Kline, error) {
+	if !hyperliquidIntervals[interval] {
+		return nil, fmt.Errorf("Hyperliquid 不支持的K线周期: %s", interval)
+	}
+	if lookback <= 0 {
+		lookback = defaultKlineLimit
+	}
+	if lookback > hyperliquidMaxCandles {
+		lookback = hyperliquidMaxCandles
+	}
+
+	coin := HyperliquidCoin(symbol)
+	now := time.Now().UnixMilli()
+	start := now - int64(lookback)*intervalDurations[interval].Milliseconds()
+
+	var candles []struct {
+		OpenTime  int64  `json:"t"`
+		CloseTime int64  `json:"T"`
+		Open      string `json:"o"`
+		High      string `json:"h"`
+		Low       string `json:"l"`
+		Close     string `json:"c"`
+		Volume    string `json:"v"`
+		Trades    int    `json:"n"`
+	}
+	request := map[string]interface{}{
+		"type": "candleSnapshot",
+		"req": map[string]interface{}{
+			"coin":      coin,
+			"interval":  interval,
+			"startTime": start,
+			"endTime":   now,
+		},
+	}
+	if err := p.postInfo(request, &candles); err != nil {
+		return nil, fmt.Errorf("获取Hyperliquid %s %s K线失败: %w", coin, interval, err)
+	}
+	if len(candles) == 0 {
+		return nil, fmt.Errorf("Hyperliquid %s 没有%v K线数据", coin, interval)
+	}
+
+	klines := make([]Kline, 0, len(candles))
+	for _, c := range candles {
+		k := Kline{
+			OpenTime:  c.OpenTime,
+			CloseTime: c.CloseTime,
+			Trades:    c.Trades,
+		}
+		k.Open, _ = strconv.ParseFloat(c.Open, 64)
+		k.High, _ = strconv.ParseFloat(c.High, 64)
+		k.Low, _ = strconv.ParseFloat(c.Low, 64)
+		k.Close, _ = strconv.ParseFloat(c.Close, 64)
+		k.Volume, _ = strconv.ParseFloat(c.Volume, 64)
+		k.QuoteVolume = k.Volume * k.Close // 近似成交额
+		klines = append(klines, k)
+	}
+	if len(klines) > lookback {
+		klines = klines[len(klines)-lookback:]
+	}
+	return klines, nil
+}
+
+// assetCtx 获取币种的资产上下文（metaAndAssetCtxs 一次返回所有币种，短时间缓存）
+func (p *hyperliquidProvider) assetCtx(symbol string) (hyperliquidAssetCtx, error) {
+	coin := HyperliquidCoin(symbol)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.assetCtxs == nil || time.Since(p.ctxsFetched) > hyperliquidAssetCtxsTTL {
+		var response []json.RawMessage
+		if err := p.postInfo(map[string]string{"type": "metaAndAssetCtxs"}, &response); err != nil {
+			return hyperliquidAssetCtx{}, fmt.Errorf("获取Hyperliquid资产信息失败: %w", err)
+		}
+		if len(response) < 2 {
+			return hyperliquidAssetCtx{}, fmt.Errorf("Hyperliquid资产信息格式错误")
+		}
+		var meta struct {
+			Universe []struct {
+				Name string `json:"name"`
+			} `json:"universe"`
+		}
+		var ctxs []hyperliquidAssetCtx
+		if err := json.Unmarshal(response[0], &meta); err != nil {
+			return hyperliquidAssetCtx{}, fmt.Errorf("解析Hyperliquid meta失败: %w", err)
+		}
+		if err := json.Unmarshal(response[1], &ctxs); err != nil {
+			return hyperliquidAssetCtx{}, fmt.Errorf("解析Hyperliquid资产上下文失败: %w", err)
+		}
+
+		assetCtxs := make(map[string]hyperliquidAssetCtx, len(ctxs))
+		for i, asset := range meta.Universe {
+			if i < len(ctxs) {
+				assetCtxs[asset.Name] = ctxs[i]
+			}
+		}
+		p.assetCtxs = assetCtxs
+		p.ctxsFetched = time.Now()
+	}
+
+	ctx, ok := p.assetCtxs[coin]
+	if !ok {
+		return hyperliquidAssetCtx{}, fmt.Errorf("Hyperliquid 没有币种 %s", coin)
+	}
+	return ctx, nil
+}
+
+// GetOpenInterest 获取持仓量（Hyperliquid 不提供历史，平均值等于最新值）
+func (p *hyperliquidProvider) GetOpenInterest(symbol string) (*OIData, error) {
+	ctx, err := p.assetCtx(symbol)
+	if err != nil {
+		return nil, err
+	}
+	oi, err := strconv.ParseFloat(ctx.OpenInterest, 64)
+	if err != nil {
+		return nil, fmt.Errorf("解析Hyperliquid持仓量失败: %w", err)
+	}
+	return &OIData{Latest: oi, Average: oi}, nil
+}
+
+// hyperliquidFundingPeriods Hyperliquid 每小时结算资金费率，换算成8小时费率需要乘的倍数
+const hyperliquidFundingPeriods = 8
+
+// GetFundingRate 获取资金费率（Hyperliquid 每小时结算，这里换算为8小时费率，与币安等交易所可比）
+func (p *hyperliquidProvider) GetFundingRate(symbol string) (float64, error) {
+	ctx, err := p.assetCtx(symbol)
+	if err != nil {
+		return 0, err
+	}
+	rate, err := strconv.ParseFloat(ctx.Funding, 64)
+	if err != nil {
+		return 0, fmt.Errorf("解析Hyperliquid资金费率失败: %w", err)
+	}
+	return rate * hyperliquidFundingPeriods, nil
+}
+
+// GetOrderBook 通过 l2Book 获取订单簿（每侧最多20档）
+func (p *hyperliquidProvider) GetOrderBook(symbol string) (*OrderBook, error) {
+	coin := HyperliquidCoin(symbol)
+	var result struct {
+		Time   int64 `json:"time"`
+		Levels [][]struct {
+			Px string `json:"px"`
+			Sz string `json:"sz"`
+		} `json:"levels"`
+	}
+	if err := p.postInfo(map[string]string{"type": "l2Book", "coin": coin}, &result); err != nil {
+		return nil, fmt.Errorf("获取Hyperliquid %s 订单簿失败: %w", coin, err)
+	}
+	if len(result.Levels) < 2 {
+		return nil, fmt.Errorf("Hyperliquid %s 订单簿格式错误", coin)
+	}
+
+	sides := make([][]OrderBookLevel, 2)
+	for i := 0; i < 2; i++ {
+		for _, l := range result.Levels[i] {
+			price, err1 := strconv.ParseFloat(l.Px, 64)
+			size, err2 := strconv.ParseFloat(l.Sz, 64)
+			if err1 != nil || err2 != nil || size <= 0 {
+				continue
+			}
+			sides[i] = append(sides[i], OrderBookLevel{Price: price, Quantity: size})
+		}
+	}
+	return &OrderBook{
+		Symbol:     strings.ToUpper(symbol),
+		Bids:       sides[0],
+		Asks:       sides[1],
+		UpdateTime: result.Time,
+	}, nil
+}
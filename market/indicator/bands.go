@@ -0,0 +1,164 @@
+package indicator
+
+// BollingerBands 布林带（中轨SMA ± mult 倍总体标准差）
+type BollingerBands struct {
+	sma  *SMA
+	mult float64
+}
+
+// NewBollingerBands 创建布林带
+func NewBollingerBands(period int, mult float64) *BollingerBands {
+	return &BollingerBands{sma: NewSMA(period), mult: mult}
+}
+
+func (b *BollingerBands) Update(bar Bar) { b.sma.Add(bar.Close) }
+func (b *BollingerBands) Ready() bool    { return b.sma.Ready() }
+
+func (b *BollingerBands) Values() []float64 {
+	middle := b.sma.Value()
+	width := b.mult * b.sma.StdDev()
+	return []float64{middle + width, middle, middle - width}
+}
+
+// Keltner 肯特纳通道（中轨EMA ± mult 倍ATR）
+type Keltner struct {
+	ema  *EMA
+	atr  *ATR
+	mult float64
+}
+
+// NewKeltner 创建肯特纳通道
+func NewKeltner(period int, mult float64, atrPeriod int) *Keltner {
+	return &Keltner{ema: NewEMA(period), atr: NewATR(atrPeriod), mult: mult}
+}
+
+func (k *Keltner) Update(bar Bar) {
+	k.ema.Add(bar.Close)
+	k.atr.Update(bar)
+}
+
+func (k *Keltner) Ready() bool { return k.ema.Ready() && k.atr.Ready() }
+
+func (k *Keltner) Values() []float64 {
+	middle := k.ema.Value()
+	width := k.mult * k.atr.Value()
+	return []float64{middle + width, middle, middle - width}
+}
+
+// SuperTrend 超级趋势（输出趋势线和方向：1 多头 / -1 空头）
+type SuperTrend struct {
+	atr       *ATR
+	mult      float64
+	started   bool
+	upper     float64
+	lower     float64
+	direction float64
+	prevClose float64
+}
+
+// NewSuperTrend 创建超级趋势
+func NewSuperTrend(period int, mult float64) *SuperTrend {
+	return &SuperTrend{atr: NewATR(period), mult: mult}
+}
+
+func (s *SuperTrend) Update(bar Bar) {
+	s.atr.Update(bar)
+	if !s.atr.Ready() {
+		s.prevClose = bar.Close
+		return
+	}
+
+	hl2 := (bar.High + bar.Low) / 2
+	basicUpper := hl2 + s.mult*s.atr.Value()
+	basicLower := hl2 - s.mult*s.atr.Value()
+
+	if !s.started {
+		s.started = true
+		s.upper, s.lower, s.direction = basicUpper, basicLower, 1
+		s.prevClose = bar.Close
+		return
+	}
+
+	// 上轨只降不升、下轨只升不降，除非前收盘价已突破
+	if basicUpper < s.upper || s.prevClose > s.upper {
+		s.upper = basicUpper
+	}
+	if basicLower > s.lower || s.prevClose < s.lower {
+		s.lower = basicLower
+	}
+
+	switch {
+	case s.direction > 0 && bar.Close < s.lower:
+		s.direction = -1
+	case s.direction < 0 && bar.Close > s.upper:
+		s.direction = 1
+	}
+	s.prevClose = bar.Close
+}
+
+func (s *SuperTrend) Ready() bool { return s.started }
+
+func (s *SuperTrend) Values() []float64 {
+	if s.direction > 0 {
+		return []float64{s.lower, s.direction}
+	}
+	return []float64{s.upper, s.direction}
+}
+
+func init() {
+	Register(&Definition{
+		Name:        "bb",
+		Description: "布林带（SMA ± mult 倍标准差）",
+		Params:      []Param{{Name: "period", Default: 20}, {Name: "mult", Default: 2}},
+		Outputs:     []string{"upper", "middle", "lower"},
+		New: func(p []float64) (Indicator, error) {
+			period, err := intParam("period", p[0])
+			if err != nil {
+				return nil, err
+			}
+			mult, err := positiveParam("mult", p[1])
+			if err != nil {
+				return nil, err
+			}
+			return NewBollingerBands(period, mult), nil
+		},
+	})
+	Register(&Definition{
+		Name:        "keltner",
+		Description: "肯特纳通道（EMA ± mult 倍ATR）",
+		Params:      []Param{{Name: "period", Default: 20}, {Name: "mult", Default: 2}, {Name: "atr_period", Default: 10}},
+		Outputs:     []string{"upper", "middle", "lower"},
+		New: func(p []float64) (Indicator, error) {
+			period, err := intParam("period", p[0])
+			if err != nil {
+				return nil, err
+			}
+			mult, err := positiveParam("mult", p[1])
+			if err != nil {
+				return nil, err
+			}
+			atrPeriod, err := intParam("atr_period", p[2])
+			if err != nil {
+				return nil, err
+			}
+			return NewKeltner(period, mult, atrPeriod), nil
+		},
+	})
+	Register(&Definition{
+		Name:        "supertrend",
+		Description: "超级趋势（direction: 1 多头 / -1 空头）",
+		Params:      []Param{{Name: "period", Default: 10}, {Name: "mult", Default: 3}},
+		Outputs:     []string{"value", "direction"},
+		New: func(p []float64) (Indicator, error) {
+			period, err := intParam("period", p[0])
+			if err != nil {
+				return nil, err
+			}
+			mult, err := positiveParam("mult", p[1])
+			if err != nil {
+				return nil, err
+			}
+			return NewSuperTrend(period, mult), nil
+		},
+	})
+}
@@ -0,0 +1,387 @@
+package indicator
+
+import "math"
+
+// window 定长滑动窗口，维护和与平方和
+type window struct {
+	vals  []float64
+	next  int
+	count int
+	sum   float64
+	sumSq float64
+}
+
+func newWindow(size int) *window {
+	return &window{vals: make([]float64, size)}
+}
+
+// push 加入一个值（窗口满时挤出最旧的值）
+func (w *window) push(v float64) {
+	if w.count == len(w.vals) {
+		old := w.vals[w.next]
+		w.sum -= old
+		w.sumSq -= old * old
+	} else {
+		w.count++
+	}
+	w.vals[w.next] = v
+	w.next = (w.next + 1) % len(w.vals)
+	w.sum += v
+	w.sumSq += v * v
+}
+
+func (w *window) full() bool    { return w.count == len(w.vals) }
+func (w *window) mean() float64 { return w.sum / float64(w.count) }
+
+// stddev 总体标准差
+func (w *window) stddev() float64 {
+	m := w.mean()
+	variance := w.sumSq/float64(w.count) - m*m
+	if variance < 0 {
+		variance = 0 // 浮点误差
+	}
+	return math.Sqrt(variance)
+}
+
+// SMA 简单移动平均
+type SMA struct {
+	w *window
+}
+
+// NewSMA 创建简单移动平均
+func NewSMA(period int) *SMA { return &SMA{w: newWindow(period)} }
+
+func (s *SMA) Add(v float64)     { s.w.push(v) }
+func (s *SMA) Update(bar Bar)    { s.Add(bar.Close) }
+func (s *SMA) Ready() bool       { return s.w.full() }
+func (s *SMA) Value() float64    { return s.w.mean() }
+func (s *SMA) Values() []float64 { return []float64{s.Value()} }
+func (s *SMA) StdDev() float64   { return s.w.stddev() }
+
+// EMA 指数移动平均（前 period 个值的SMA作为初始值）
+type EMA struct {
+	period     int
+	multiplier float64
+	count      int
+	seedSum    float64
+	value      float64
+}
+
+// NewEMA 创建指数移动平均
+func NewEMA(period int) *EMA {
+	return &EMA{period: period, multiplier: 2.0 / float64(period+1)}
+}
+
+// Add 输入一个值
+func (e *EMA) Add(v float64) {
+	e.count++
+	if e.count < e.period {
+		e.seedSum += v
+		return
+	}
+	if e.count == e.period {
+		e.seedSum += v
+		e.value = e.seedSum / float64(e.period)
+		return
+	}
+	e.value = (v-e.value)*e.multiplier + e.value
+}
+
+func (e *EMA) Update(bar Bar)    { e.Add(bar.Close) }
+func (e *EMA) Ready() bool       { return e.count >= e.period }
+func (e *EMA) Value() float64    { return e.value }
+func (e *EMA) Values() []float64 { return []float64{e.value} }
+
+// Wilder Wilder平滑（前 period 个值的均值作为初始值）
+type Wilder struct {
+	period  int
+	count   int
+	seedSum float64
+	value   float64
+}
+
+// NewWilder 创建Wilder平滑
+func NewWilder(period int) *Wilder { return &Wilder{period: period} }
+
+// Add 输入一个值
+func (w *Wilder) Add(v float64) {
+	w.count++
+	if w.count <= w.period {
+		w.seedSum += v
+		if w.count == w.period {
+			w.value = w.seedSum / float64(w.period)
+		}
+		return
+	}
+	w.value = (w.value*float64(w.period-1) + v) / float64(w.period)
+}
+
+func (w *Wilder) Ready() bool    { return w.count >= w.period }
+func (w *Wilder) Value() float64 { return w.value }
+
+// RSI 相对强弱指数（Wilder平滑）
+type RSI struct {
+	gain, loss *Wilder
+	prevClose  float64
+	count      int
+}
+
+// NewRSI 创建RSI
+func NewRSI(period int) *RSI {
+	return &RSI{gain: NewWilder(period), loss: NewWilder(period)}
+}
+
+// Add 输入一个收盘价
+func (r *RSI) Add(close float64) {
+	r.count++
+	if r.count > 1 {
+		change := close - r.prevClose
+		r.gain.Add(math.Max(change, 0))
+		r.loss.Add(math.Max(-change, 0))
+	}
+	r.prevClose = close
+}
+
+func (r *RSI) Update(bar Bar) { r.Add(bar.Close) }
+func (r *RSI) Ready() bool    { return r.gain.Ready() }
+
+// Value 当前RSI（0-100）
+func (r *RSI) Value() float64 {
+	if r.loss.Value() == 0 {
+		return 100
+	}
+	rs := r.gain.Value() / r.loss.Value()
+	return 100 - (100 / (1 + rs))
+}
+
+func (r *RSI) Values() []float64 { return []float64{r.Value()} }
+
+// MACD 指数平滑异同移动平均（macd、signal、hist）
+type MACD struct {
+	fast, slow, signal *EMA
+}
+
+// NewMACD 创建MACD
+func NewMACD(fast, slow, signal int) *MACD {
+	return &MACD{fast: NewEMA(fast), slow: NewEMA(slow), signal: NewEMA(signal)}
+}
+
+func (m *MACD) Update(bar Bar) {
+	m.fast.Add(bar.Close)
+	m.slow.Add(bar.Close)
+	if m.LineReady() {
+		m.signal.Add(m.Line())
+	}
+}
+
+// LineReady MACD线是否可用（信号线可能尚未就绪）
+func (m *MACD) LineReady() bool { return m.fast.Ready() && m.slow.Ready() }
+
+// Line MACD线（快线EMA - 慢线EMA）
+func (m *MACD) Line() float64 { return m.fast.Value() - m.slow.Value() }
+
+func (m *MACD) Ready() bool { return m.LineReady() && m.signal.Ready() }
+
+func (m *MACD) Values() []float64 {
+	line := m.Line()
+	return []float64{line, m.signal.Value(), line - m.signal.Value()}
+}
+
+// ATR 平均真实波幅（Wilder平滑，首根K线没有前收盘价不计入）
+type ATR struct {
+	avg       *Wilder
+	prevClose float64
+	count     int
+}
+
+// NewATR 创建ATR
+func NewATR(period int) *ATR { return &ATR{avg: NewWilder(period)} }
+
+func (a *ATR) Update(bar Bar) {
+	a.count++
+	if a.count > 1 {
+		a.avg.Add(trueRange(bar, a.prevClose))
+	}
+	a.prevClose = bar.Close
+}
+
+func (a *ATR) Ready() bool       { return a.avg.Ready() }
+func (a *ATR) Value() float64    { return a.avg.Value() }
+func (a *ATR) Values() []float64 { return []float64{a.avg.Value()} }
+
+// trueRange 真实波幅
+func trueRange(bar Bar, prevClose float64) float64 {
+	return math.Max(bar.High-bar.Low, math.Max(math.Abs(bar.High-prevClose), math.Abs(bar.Low-prevClose)))
+}
+
+// OBV 能量潮
+type OBV struct {
+	value     float64
+	prevClose float64
+	count     int
+}
+
+func (o *OBV) Update(bar Bar) {
+	o.count++
+	if o.count > 1 {
+		switch {
+		case bar.Close > o.prevClose:
+			o.value += bar.Volume
+		case bar.Close < o.prevClose:
+			o.value -= bar.Volume
+		}
+	}
+	o.prevClose = bar.Close
+}
+
+func (o *OBV) Ready() bool       { return o.count > 0 }
+func (o *OBV) Values() []float64 { return []float64{o.value} }
+
+// VWAP 成交量加权平均价
+// period=0 时按UTC自然日累计（每日重置），否则为最近 period 根K线的滚动VWAP
+type VWAP struct {
+	pv, vol *window // 滚动模式
+	day     int64
+	cumPV   float64
+	cumVol  float64
+}
+
+// NewVWAP 创建VWAP
+func NewVWAP(period int) *VWAP {
+	v := &VWAP{day: -1}
+	if period > 0 {
+		v.pv, v.vol = newWindow(period), newWindow(period)
+	}
+	return v
+}
+
+func (v *VWAP) Update(bar Bar) {
+	typical := (bar.High + bar.Low + bar.Close) / 3
+	if v.pv != nil {
+		v.pv.push(typical * bar.Volume)
+		v.vol.push(bar.Volume)
+		return
+	}
+	if day := bar.Time / 86400000; day != v.day {
+		v.day, v.cumPV, v.cumVol = day, 0, 0
+	}
+	v.cumPV += typical * bar.Volume
+	v.cumVol += bar.Volume
+}
+
+func (v *VWAP) sums() (float64, float64) {
+	if v.pv != nil {
+		return v.pv.sum, v.vol.sum
+	}
+	return v.cumPV, v.cumVol
+}
+
+func (v *VWAP) Ready() bool {
+	_, vol := v.sums()
+	return vol > 0
+}
+
+func (v *VWAP) Values() []float64 {
+	pv, vol := v.sums()
+	return []float64{pv / vol}
+}
+
+func init() {
+	Register(&Definition{
+		Name:        "sma",
+		Description: "简单移动平均",
+		Params:      []Param{{Name: "period", Default: 20}},
+		Outputs:     []string{"value"},
+		New: func(p []float64) (Indicator, error) {
+			period, err := intParam("period", p[0])
+			if err != nil {
+				return nil, err
+			}
+			return NewSMA(period), nil
+		},
+	})
+	Register(&Definition{
+		Name:        "ema",
+		Description: "指数移动平均",
+		Params:      []Param{{Name: "period", Default: 20}},
+		Outputs:     []string{"value"},
+		New: func(p []float64) (Indicator, error) {
+			period, err := intParam("period", p[0])
+			if err != nil {
+				return nil, err
+			}
+			return NewEMA(period), nil
+		},
+	})
+	Register(&Definition{
+		Name:        "rsi",
+		Description: "相对强弱指数（Wilder平滑）",
+		Params:      []Param{{Name: "period", Default: 14}},
+		Outputs:     []string{"value"},
+		New: func(p []float64) (Indicator, error) {
+			period, err := intParam("period", p[0])
+			if err != nil {
+				return nil, err
+			}
+			return NewRSI(period), nil
+		},
+	})
+	Register(&Definition{
+		Name:        "macd",
+		Description: "MACD（快线、慢线、信号线）",
+		Params:      []Param{{Name: "fast", Default: 12}, {Name: "slow", Default: 26}, {Name: "signal", Default: 9}},
+		Outputs:     []string{"macd", "signal", "hist"},
+		New: func(p []float64) (Indicator, error) {
+			fast, err := intParam("fast", p[0])
+			if err != nil {
+				return nil, err
+			}
+			slow, err := intParam("slow", p[1])
+			if err != nil {
+				return nil, err
+			}
+			signal, err := intParam("signal", p[2])
+			if err != nil {
+				return nil, err
+			}
+			return NewMACD(fast, slow, signal), nil
+		},
+	})
+	Register(&Definition{
+		Name:        "atr",
+		Description: "平均真实波幅（Wilder平滑）",
+		Params:      []Param{{Name: "period", Default: 14}},
+		Outputs:     []string{"value"},
+		New: func(p []float64) (Indicator, error) {
+			period, err := intParam("period", p[0])
+			if err != nil {
+				return nil, err
+			}
+			return NewATR(period), nil
+		},
+	})
+	Register(&Definition{
+		Name:        "obv",
+		Description: "能量潮（On-Balance Volume）",
+		Outputs:     []string{"value"},
+		New: func(p []float64) (Indicator, error) {
+			return &OBV{}, nil
+		},
+	})
+	Register(&Definition{
+		Name:        "vwap",
+		Description: "成交量加权平均价（period=0 按UTC日重置，否则为滚动窗口）",
+		Params:      []Param{{Name: "period", Default: 0}},
+		Outputs:     []string{"value"},
+		New: func(p []float64) (Indicator, error) {
+			if p[0] == 0 {
+				return NewVWAP(0), nil
+			}
+			period, err := intParam("period", p[0])
+			if err != nil {
+				return nil, err
+			}
+			return NewVWAP(period), nil
+		},
+	})
+}
@@ -0,0 +1,250 @@
+// Package indicator 增量计算的技术指标库
+//
+// 每个指标逐根K线更新（Update 为 O(1) 或摊还 O(1)），并按名称注册，
+// 提示词模板可以通过 "@indicators: bb(20,2), adx(14)" 声明需要的指标和参数
+package indicator
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Bar 一根K线
+type Bar struct {
+	Time   int64 // 开盘时间（毫秒）
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	Volume float64
+}
+
+// Indicator 增量指标
+type Indicator interface {
+	// Update 输入下一根K线
+	Update(bar Bar)
+	// Ready 是否已有足够数据输出有效值
+	Ready() bool
+	// Values 当前值，与 Definition.Outputs 一一对应
+	Values() []float64
+}
+
+// Param 指标参数
+type Param struct {
+	Name    string  `json:"name"`
+	Default float64 `json:"default"`
+}
+
+// Definition 指标定义
+type Definition struct {
+	Name        string                                    `json:"name"`        // 注册名称（小写），如 "bb"
+	Description string                                    `json:"description"` // 说明
+	Params      []Param                                   `json:"params"`      // 参数（按位置传入，可省略尾部使用默认值）
+	Outputs     []string                                  `json:"outputs"`     // 输出名称，如 ["upper","middle","lower"]
+	New         func(params []float64) (Indicator, error) `json:"-"`
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]*Definition)
+)
+
+// Register 注册指标（重复注册会覆盖）
+func Register(def *Definition) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[strings.ToLower(def.Name)] = def
+}
+
+// Lookup 按名称查找指标定义
+func Lookup(name string) (*Definition, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	def, ok := registry[strings.ToLower(name)]
+	return def, ok
+}
+
+// List 所有已注册的指标（按名称排序）
+func List() []*Definition {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	defs := make([]*Definition, 0, len(registry))
+	for _, def := range registry {
+		defs = append(defs, def)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs
+}
+
+// Spec 指标及其参数，如 bb(20,2)
+type Spec struct {
+	Name   string    `json:"name"`
+	Params []float64 `json:"params"` // 已补全默认值
+}
+
+// String 以 "bb(20,2)" 形式输出
+func (s Spec) String() string {
+	if len(s.Params) == 0 {
+		return s.Name
+	}
+	parts := make([]string, len(s.Params))
+	for i, p := range s.Params {
+		parts[i] = strconv.FormatFloat(p, 'f', -1, 64)
+	}
+	return s.Name + "(" + strings.Join(parts, ",") + ")"
+}
+
+// New 创建该指标的实例
+func (s Spec) New() (Indicator, error) {
+	def, ok := Lookup(s.Name)
+	if !ok {
+		return nil, fmt.Errorf("未知指标: %s", s.Name)
+	}
+	return def.New(s.Params)
+}
+
+// ParseSpec 解析单个指标声明，如 "bb(20,2)"、"obv"；省略的参数使用默认值
+func ParseSpec(text string) (Spec, error) {
+	text = strings.TrimSpace(text)
+	name, args := text, ""
+	if open := strings.Index(text, "("); open >= 0 {
+		if !strings.HasSuffix(text, ")") {
+			return Spec{}, fmt.Errorf("指标声明缺少右括号: %s", text)
+		}
+		name, args = strings.TrimSpace(text[:open]), text[open+1:len(text)-1]
+	}
+	name = strings.ToLower(name)
+
+	def, ok := Lookup(name)
+	if !ok {
+		return Spec{}, fmt.Errorf("未知指标: %s", name)
+	}
+
+	var params []float64
+	if strings.TrimSpace(args) != "" {
+		for _, arg := range strings.Split(args, ",") {
+			v, err := strconv.ParseFloat(strings.TrimSpace(arg), 64)
+			if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+				return Spec{}, fmt.Errorf("指标 %s 的参数无效: %s", name, arg)
+			}
+			params = append(params, v)
+		}
+	}
+	if len(params) > len(def.Params) {
+		return Spec{}, fmt.Errorf("指标 %s 最多 %d 个参数", name, len(def.Params))
+	}
+	for i := len(params); i < len(def.Params); i++ {
+		params = append(params, def.Params[i].Default)
+	}
+
+	spec := Spec{Name: name, Params: params}
+	// 提前创建一次实例以校验参数
+	if _, err := def.New(params); err != nil {
+		return Spec{}, fmt.Errorf("指标 %s 参数错误: %w", spec, err)
+	}
+	return spec, nil
+}
+
+// ParseSpecs 解析逗号分隔的多个指标声明（括号内的逗号不作为分隔符），重复声明会去重
+func ParseSpecs(text string) ([]Spec, error) {
+	var specs []Spec
+	seen := make(map[string]bool)
+	depth, start := 0, 0
+	flush := func(end int) error {
+		part := strings.TrimSpace(text[start:end])
+		if part == "" {
+			return nil
+		}
+		spec, err := ParseSpec(part)
+		if err != nil {
+			return err
+		}
+		if !seen[spec.String()] {
+			seen[spec.String()] = true
+			specs = append(specs, spec)
+		}
+		return nil
+	}
+
+	for i, ch := range text {
+		switch ch {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				if err := flush(i); err != nil {
+					return nil, err
+				}
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("指标声明括号不匹配: %s", text)
+	}
+	if err := flush(len(text)); err != nil {
+		return nil, err
+	}
+	return specs, nil
+}
+
+// Series 指标在最近若干根K线上的输出
+type Series struct {
+	Spec    Spec        `json:"spec"`
+	Outputs []string    `json:"outputs"`
+	Values  [][]float64 `json:"values"` // Values[i] 为第 i 个输出的序列（旧 → 新）
+}
+
+// Compute 对整段K线运行指标，返回最近 points 个有效值
+func Compute(spec Spec, bars []Bar, points int) (*Series, error) {
+	def, ok := Lookup(spec.Name)
+	if !ok {
+		return nil, fmt.Errorf("未知指标: %s", spec.Name)
+	}
+	ind, err := def.New(spec.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	series := &Series{
+		Spec:    spec,
+		Outputs: def.Outputs,
+		Values:  make([][]float64, len(def.Outputs)),
+	}
+	start := len(bars) - points
+	for i, bar := range bars {
+		ind.Update(bar)
+		if i < start || !ind.Ready() {
+			continue
+		}
+		for j, v := range ind.Values() {
+			series.Values[j] = append(series.Values[j], v)
+		}
+	}
+	return series, nil
+}
+
+// MaxPeriod 周期类参数的上限（与单次K线回看上限一致，更长的周期永远不会就绪，且会按周期分配缓冲区）
+const MaxPeriod = 1500
+
+// intParam 把参数转为 1~MaxPeriod 的整数周期
+func intParam(name string, v float64) (int, error) {
+	if v <= 0 || v > MaxPeriod || v != math.Trunc(v) {
+		return 0, fmt.Errorf("%s 必须是1~%d的整数: %v", name, MaxPeriod, v)
+	}
+	return int(v), nil
+}
+
+// positiveParam 校验参数为正数
+func positiveParam(name string, v float64) (float64, error) {
+	if v <= 0 {
+		return 0, fmt.Errorf("%s 必须大于0: %v", name, v)
+	}
+	return v, nil
+}
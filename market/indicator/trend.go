@@ -0,0 +1,188 @@
+package indicator
+
+import "math"
+
+// ADX 平均趋向指数（输出 adx、+DI、-DI）
+type ADX struct {
+	tr, plusDM, minusDM *Wilder
+	adx                 *Wilder
+	prev                Bar
+	count               int
+}
+
+// NewADX 创建ADX/DMI
+func NewADX(period int) *ADX {
+	return &ADX{
+		tr:      NewWilder(period),
+		plusDM:  NewWilder(period),
+		minusDM: NewWilder(period),
+		adx:     NewWilder(period),
+	}
+}
+
+func (a *ADX) Update(bar Bar) {
+	a.count++
+	if a.count > 1 {
+		up := bar.High - a.prev.High
+		down := a.prev.Low - bar.Low
+		plus, minus := 0.0, 0.0
+		if up > down && up > 0 {
+			plus = up
+		}
+		if down > up && down > 0 {
+			minus = down
+		}
+		a.tr.Add(trueRange(bar, a.prev.Close))
+		a.plusDM.Add(plus)
+		a.minusDM.Add(minus)
+
+		if a.tr.Ready() {
+			plusDI, minusDI := a.di()
+			dx := 0.0
+			if sum := plusDI + minusDI; sum > 0 {
+				dx = 100 * math.Abs(plusDI-minusDI) / sum
+			}
+			a.adx.Add(dx)
+		}
+	}
+	a.prev = bar
+}
+
+// di 当前 +DI / -DI
+func (a *ADX) di() (float64, float64) {
+	if a.tr.Value() == 0 {
+		return 0, 0
+	}
+	return 100 * a.plusDM.Value() / a.tr.Value(), 100 * a.minusDM.Value() / a.tr.Value()
+}
+
+func (a *ADX) Ready() bool { return a.adx.Ready() }
+
+func (a *ADX) Values() []float64 {
+	plusDI, minusDI := a.di()
+	return []float64{a.adx.Value(), plusDI, minusDI}
+}
+
+// extremaWindow 单调队列，O(1) 摊还维护滑动窗口内的最大/最小值
+type extremaWindow struct {
+	size  int
+	index int
+	maxQ  []indexedValue
+	minQ  []indexedValue
+}
+
+type indexedValue struct {
+	index int
+	value float64
+}
+
+func newExtremaWindow(size int) *extremaWindow {
+	return &extremaWindow{size: size}
+}
+
+func (w *extremaWindow) push(v float64) {
+	w.index++
+	for len(w.maxQ) > 0 && w.maxQ[len(w.maxQ)-1].value <= v {
+		w.maxQ = w.maxQ[:len(w.maxQ)-1]
+	}
+	w.maxQ = append(w.maxQ, indexedValue{w.index, v})
+	for len(w.minQ) > 0 && w.minQ[len(w.minQ)-1].value >= v {
+		w.minQ = w.minQ[:len(w.minQ)-1]
+	}
+	w.minQ = append(w.minQ, indexedValue{w.index, v})
+
+	oldest := w.index - w.size
+	for w.maxQ[0].index <= oldest {
+		w.maxQ = w.maxQ[1:]
+	}
+	for w.minQ[0].index <= oldest {
+		w.minQ = w.minQ[1:]
+	}
+}
+
+func (w *extremaWindow) full() bool   { return w.index >= w.size }
+func (w *extremaWindow) max() float64 { return w.maxQ[0].value }
+func (w *extremaWindow) min() float64 { return w.minQ[0].value }
+
+// StochRSI 随机RSI（输出 %K、%D，范围0-100）
+type StochRSI struct {
+	rsi    *RSI
+	window *extremaWindow
+	k, d   *SMA
+}
+
+// NewStochRSI 创建随机RSI
+func NewStochRSI(rsiPeriod, stochPeriod, kPeriod, dPeriod int) *StochRSI {
+	return &StochRSI{
+		rsi:    NewRSI(rsiPeriod),
+		window: newExtremaWindow(stochPeriod),
+		k:      NewSMA(kPeriod),
+		d:      NewSMA(dPeriod),
+	}
+}
+
+func (s *StochRSI) Update(bar Bar) {
+	s.rsi.Add(bar.Close)
+	if !s.rsi.Ready() {
+		return
+	}
+	rsi := s.rsi.Value()
+	s.window.push(rsi)
+	if !s.window.full() {
+		return
+	}
+
+	stoch := 0.0
+	if span := s.window.max() - s.window.min(); span > 0 {
+		stoch = 100 * (rsi - s.window.min()) / span
+	}
+	s.k.Add(stoch)
+	if s.k.Ready() {
+		s.d.Add(s.k.Value())
+	}
+}
+
+func (s *StochRSI) Ready() bool { return s.d.Ready() }
+
+func (s *StochRSI) Values() []float64 {
+	return []float64{s.k.Value(), s.d.Value()}
+}
+
+func init() {
+	Register(&Definition{
+		Name:        "adx",
+		Description: "平均趋向指数 ADX/DMI（Wilder平滑）",
+		Params:      []Param{{Name: "period", Default: 14}},
+		Outputs:     []string{"adx", "plus_di", "minus_di"},
+		New: func(p []float64) (Indicator, error) {
+			period, err := intParam("period", p[0])
+			if err != nil {
+				return nil, err
+			}
+			return NewADX(period), nil
+		},
+	})
+	Register(&Definition{
+		Name:        "stochrsi",
+		Description: "随机RSI（%K、%D）",
+		Params: []Param{
+			{Name: "rsi_period", Default: 14},
+			{Name: "stoch_period", Default: 14},
+			{Name: "k", Default: 3},
+			{Name: "d", Default: 3},
+		},
+		Outputs: []string{"k", "d"},
+		New: func(p []float64) (Indicator, error) {
+			periods := make([]int, len(p))
+			names := []string{"rsi_period", "stoch_period", "k", "d"}
+			for i := range p {
+				n, err := intParam(names[i], p[i])
+				if err != nil {
+					return nil, err
+				}
+				periods[i] = n
+			}
+			return NewStochRSI(periods[0], periods[1], periods[2], periods[3]), nil
+		},
+	})
+}
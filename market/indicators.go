@@ -0,0 +1,74 @@
+package market
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"nofx/market/indicator"
+)
+
+// toBars 把K线转为指标输入
+func toBars(klines []Kline) []indicator.Bar {
+	bars := make([]indicator.Bar, len(klines))
+	for i, k := range klines {
+		bars[i] = indicator.Bar{
+			Time:   k.OpenTime,
+			Open:   k.Open,
+			High:   k.High,
+			Low:    k.Low,
+			Close:  k.Close,
+			Volume: k.Volume,
+		}
+	}
+	return bars
+}
+
+// calculateIndicators 对一段K线计算声明的指标（每个指标单次遍历）
+func calculateIndicators(symbol, interval string, klines []Kline, specs []indicator.Spec) []*indicator.Series {
+	if len(specs) == 0 || len(klines) == 0 {
+		return nil
+	}
+	bars := toBars(klines)
+	result := make([]*indicator.Series, 0, len(specs))
+	for _, spec := range specs {
+		series, err := indicator.Compute(spec, bars, seriesPoints)
+		if err != nil {
+			log.Printf("⚠️  计算 %s %s 指标 %s 失败: %v", symbol, interval, spec, err)
+			continue
+		}
+		result = append(result, series)
+	}
+	return result
+}
+
+// formatIndicators 输出额外技术指标（按周期从短到长）
+func formatIndicators(sb *strings.Builder, indicators map[string][]*indicator.Series) {
+	intervals := make([]string, 0, len(indicators))
+	for interval := range indicators {
+		intervals = append(intervals, interval)
+	}
+	sort.Slice(intervals, func(i, j int) bool {
+		return intervalDurations[intervals[i]] < intervalDurations[intervals[j]]
+	})
+
+	for _, interval := range intervals {
+		list := indicators[interval]
+		if len(list) == 0 {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("Technical indicators (%s candles, oldest → latest):\n\n", interval))
+		for _, series := range list {
+			if len(series.Values) == 0 || len(series.Values[0]) == 0 {
+				sb.WriteString(fmt.Sprintf("%s: insufficient data\n\n", series.Spec))
+				continue
+			}
+			parts := make([]string, len(series.Outputs))
+			for i, name := range series.Outputs {
+				parts[i] = fmt.Sprintf("%s %s", name, formatFloatSlice(series.Values[i]))
+			}
+			sb.WriteString(fmt.Sprintf("%s: %s\n\n", series.Spec, strings.Join(parts, ", ")))
+		}
+	}
+}
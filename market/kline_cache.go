@@ -0,0 +1,189 @@
+package market
+
+import (
+	"strings"
+	"sync"
+)
+
+// klineCloseBuffer 每个收盘订阅者的事件缓冲，消费过慢时丢弃
+const klineCloseBuffer = 256
+
+// KlineEvent K线收盘事件
+type KlineEvent struct {
+	Symbol   string `json:"symbol"`
+	Interval string `json:"interval"`
+	Kline    Kline  `json:"kline"`
+}
+
+// klineSeries 单个交易对单个周期的K线环形缓冲
+// WebSocket 更新和读取通过读写锁互斥，读取时返回副本，调用方可以自由持有和修改
+type klineSeries struct {
+	mu   sync.RWMutex
+	buf  []Kline // 环形存储，容量即保留的K线数量
+	head int     // 最早一根K线的位置
+	size int
+}
+
+func newKlineSeries(limit int) *klineSeries {
+	return &klineSeries{buf: make([]Kline, limit)}
+}
+
+// at 第 i 根K线（0 为最早），调用方需持有锁
+func (s *klineSeries) at(i int) *Kline {
+	return &s.buf[(s.head+i)%len(s.buf)]
+}
+
+// resize 调整容量（只保留最近的K线），调用方需持有写锁
+func (s *klineSeries) resize(limit int) {
+	if limit == len(s.buf) {
+		return
+	}
+	n := s.size
+	if n > limit {
+		n = limit
+	}
+	buf := make([]Kline, limit)
+	for i := 0; i < n; i++ {
+		buf[i] = *s.at(s.size - n + i)
+	}
+	s.buf, s.head, s.size = buf, 0, n
+}
+
+// grow 容量小于 limit 时扩容（只增不减）
+func (s *klineSeries) grow(limit int) {
+	s.mu.Lock()
+	if limit > len(s.buf) {
+		s.resize(limit)
+	}
+	s.mu.Unlock()
+}
+
+// replace 用 klines（按时间升序）替换缓存内容
+func (s *klineSeries) replace(klines []Kline, limit int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replaceLocked(klines, limit)
+}
+
+// replaceLocked 替换缓存内容，调用方需持有写锁
+func (s *klineSeries) replaceLocked(klines []Kline, limit int) {
+	if limit < len(s.buf) {
+		limit = len(s.buf)
+	}
+	if len(klines) > limit {
+		klines = klines[len(klines)-limit:]
+	}
+	s.buf = make([]Kline, limit)
+	copy(s.buf, klines)
+	s.head, s.size = 0, len(klines)
+}
+
+// merge 合并回补的K线（同一开盘时间以 klines 为准）
+// 读取和替换在同一次写锁内完成，避免丢失期间写入的实时K线
+func (s *klineSeries) merge(klines []Kline, limit int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replaceLocked(mergeKlines(s.snapshotLocked(0), klines), limit)
+}
+
+// update 写入一根实时K线：与最后一根开盘时间相同则覆盖，更新的K线追加（满时淘汰最早的），更早的忽略
+func (s *klineSeries) update(k Kline) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.size > 0 {
+		last := s.at(s.size - 1)
+		if last.OpenTime == k.OpenTime {
+			*last = k
+			return
+		}
+		if k.OpenTime < last.OpenTime {
+			return
+		}
+	}
+	if s.size < len(s.buf) {
+		*s.at(s.size) = k
+		s.size++
+		return
+	}
+	s.buf[s.head] = k
+	s.head = (s.head + 1) % len(s.buf)
+}
+
+// snapshot 最近 lookback 根K线的副本（lookback<=0 返回全部）
+func (s *klineSeries) snapshot(lookback int) []Kline {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.snapshotLocked(lookback)
+}
+
+// snapshotLocked 最近 lookback 根K线的副本，调用方需持有锁
+func (s *klineSeries) snapshotLocked(lookback int) []Kline {
+	n := s.size
+	if lookback > 0 && lookback < n {
+		n = lookback
+	}
+	klines := make([]Kline, n)
+	for i := 0; i < n; i++ {
+		klines[i] = *s.at(s.size - n + i)
+	}
+	return klines
+}
+
+// len 缓存的K线数量
+func (s *klineSeries) len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.size
+}
+
+// series 获取交易对指定周期的K线缓存
+func (m *WSMonitor) series(symbol, interval string) (*klineSeries, bool) {
+	value, ok := m.klineData.Load(klineKey(symbol, interval))
+	if !ok {
+		return nil, false
+	}
+	return value.(*klineSeries), true
+}
+
+// seriesOrCreate 获取（不存在时创建）交易对指定周期的K线缓存
+func (m *WSMonitor) seriesOrCreate(symbol, interval string) *klineSeries {
+	if s, ok := m.series(symbol, interval); ok {
+		return s
+	}
+	value, _ := m.klineData.LoadOrStore(klineKey(symbol, interval), newKlineSeries(m.klineLimit(interval)))
+	return value.(*klineSeries)
+}
+
+// SubscribeKlineClose 订阅K线收盘事件（interval 为空时订阅所有周期），返回事件通道和取消订阅函数
+func (m *WSMonitor) SubscribeKlineClose(interval string) (<-chan KlineEvent, func()) {
+	ch := make(chan KlineEvent, klineCloseBuffer)
+	m.closeMu.Lock()
+	m.closeSubs[ch] = interval
+	m.closeMu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			m.closeMu.Lock()
+			delete(m.closeSubs, ch)
+			m.closeMu.Unlock()
+		})
+	}
+}
+
+// publishKlineClose 通知订阅者K线收盘
+func (m *WSMonitor) publishKlineClose(symbol, interval string, k Kline) {
+	event := KlineEvent{Symbol: strings.ToUpper(symbol), Interval: interval, Kline: k}
+	m.closeMu.Lock()
+	defer m.closeMu.Unlock()
+	for ch, filter := range m.closeSubs {
+		if filter != "" && filter != interval {
+			continue
+		}
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package market
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	metadataTimeout     = 10 * time.Second // 元数据请求超时
+	premiumIndexTTL     = 3 * time.Minute  // 资金费率/标记价格缓存有效期（需长于刷新间隔，刷新失败时仍可使用）
+	premiumIndexRefresh = time.Minute      // 批量刷新全市场资金费率的间隔
+	openInterestTTL     = time.Minute      // 持仓量缓存有效期
+)
+
+// metadataCache 合约元数据（资金费率、标记价格、持仓量）的共享缓存
+// 所有交易员共用：资金费率通过 premiumIndex 批量接口一次获取全部交易对并定时刷新，
+// 持仓量按交易对缓存；缓存过期时并发的相同请求只会发出一次
+type metadataCache struct {
+	client *APIClient
+	group  singleflight.Group
+
+	mu      sync.RWMutex
+	premium map[string]premiumEntry // symbol -> 资金费率/标记价格
+	missing map[string]time.Time    // 批量接口中没有的交易对 -> 发现时间（premiumIndexTTL 内不再为其刷新）
+	oi      map[string]oiEntry      // symbol -> 持仓量
+
+	refreshOnce sync.Once
+}
+
+type premiumEntry struct {
+	index   PremiumIndex
+	fetched time.Time
+}
+
+type oiEntry struct {
+	value   float64
+	time    int64 // 交易所返回的采样时间（毫秒）
+	fetched time.Time
+}
+
+var (
+	binanceMetadata = newMetadataCache(binanceBaseURL)
+	asterMetadata   = newMetadataCache(asterBaseURL)
+)
+
+func newMetadataCache(baseURL string) *metadataCache {
+	client := newAPIClient(baseURL)
+	client.client.Timeout = metadataTimeout
+	return &metadataCache{
+		client:  client,
+		premium: make(map[string]premiumEntry),
+		missing: make(map[string]time.Time),
+		oi:      make(map[string]oiEntry),
+	}
+}
+
+// premiumIndex 获取交易对的资金费率和标记价格（缓存过期时批量刷新全市场）
+func (c *metadataCache) premiumIndex(symbol string) (*PremiumIndex, error) {
+	symbol = strings.ToUpper(symbol)
+	c.refreshOnce.Do(func() { go c.refreshLoop() })
+
+	if index, ok := c.cachedPremium(symbol); ok {
+		return index, nil
+	}
+	if c.knownMissing(symbol) {
+		return nil, fmt.Errorf("没有 %s 的资金费率", symbol)
+	}
+	if err := c.refreshPremium(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	entry, ok := c.premium[symbol]
+	if !ok {
+		c.missing[symbol] = time.Now()
+	}
+	c.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("没有 %s 的资金费率", symbol)
+	}
+	index := entry.index
+	return &index, nil
+}
+
+// cachedPremium 未过期的资金费率缓存
+func (c *metadataCache) cachedPremium(symbol string) (*PremiumIndex, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entry, ok := c.premium[symbol]
+	if !ok || time.Since(entry.fetched) > premiumIndexTTL {
+		return nil, false
+	}
+	index := entry.index
+	return &index, true
+}
+
+// knownMissing 交易对最近一次批量刷新时不存在（未知交易对不会反复触发全市场刷新）
+func (c *metadataCache) knownMissing(symbol string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	since, ok := c.missing[symbol]
+	return ok && time.Since(since) <= premiumIndexTTL
+}
+
+// refreshPremium 批量获取全市场资金费率（并发调用合并为一次请求）
+func (c *metadataCache) refreshPremium() error {
+	_, err, _ := c.group.Do("premiumIndex", func() (interface{}, error) {
+		indexes, err := c.client.GetPremiumIndexAll()
+		if err != nil {
+			return nil, fmt.Errorf("批量获取资金费率失败: %w", err)
+		}
+		now := time.Now()
+		c.mu.Lock()
+		for symbol, index := range indexes {
+			c.premium[symbol] = premiumEntry{index: index, fetched: now}
+			delete(c.missing, symbol)
+		}
+		c.mu.Unlock()
+		return nil, nil
+	})
+	return err
+}
+
+// allPremium 所有未过期的资金费率（全部过期时先批量刷新）
+func (c *metadataCache) allPremium() (map[string]PremiumIndex, error) {
+	c.refreshOnce.Do(func() { go c.refreshLoop() })
+
+	if indexes := c.freshPremium(); len(indexes) > 0 {
+		return indexes, nil
+	}
+	if err := c.refreshPremium(); err != nil {
+		return nil, err
+	}
+	return c.freshPremium(), nil
+}
+
+// freshPremium 未过期的资金费率副本
+func (c *metadataCache) freshPremium() map[string]PremiumIndex {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	indexes := make(map[string]PremiumIndex, len(c.premium))
+	for symbol, entry := range c.premium {
+		if time.Since(entry.fetched) <= premiumIndexTTL {
+			indexes[symbol] = entry.index
+		}
+	}
+	return indexes
+}
+
+// GetFundingRates 币安所有交易对的资金费率与标记价格（共享缓存）
+func GetFundingRates() (map[string]PremiumIndex, error) {
+	return binanceMetadata.allPremium()
+}
+
+// refreshLoop 定时刷新全市场资金费率（首次使用时启动）
+func (c *metadataCache) refreshLoop() {
+	ticker := time.NewTicker(premiumIndexRefresh)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := c.refreshPremium(); err != nil {
+			log.Printf("⚠️  %v", err)
+		}
+	}
+}
+
+// updatePremium 写入实时推送的资金费率（全市场标记价格流）
+func (c *metadataCache) updatePremium(symbol string, index PremiumIndex) {
+	c.mu.Lock()
+	symbol = strings.ToUpper(symbol)
+	c.premium[symbol] = premiumEntry{index: index, fetched: time.Now()}
+	delete(c.missing, symbol)
+	c.mu.Unlock()
+}
+
+// openInterest 获取交易对持仓量及其采样时间（毫秒），缓存 openInterestTTL
+func (c *metadataCache) openInterest(symbol string) (float64, int64, error) {
+	symbol = strings.ToUpper(symbol)
+	c.mu.RLock()
+	entry, ok := c.oi[symbol]
+	c.mu.RUnlock()
+	if ok && time.Since(entry.fetched) <= openInterestTTL {
+		return entry.value, entry.time, nil
+	}
+	return c.fetchOpenInterest(symbol)
+}
+
+// GetOpenInterest 币安交易对的当前持仓量（共享缓存）
+func GetOpenInterest(symbol string) (float64, error) {
+	oi, _, err := binanceMetadata.openInterest(symbol)
+	return oi, err
+}
+
+// fetchOpenInterest 请求最新持仓量并写入缓存（并发的相同请求合并为一次）
+func (c *metadataCache) fetchOpenInterest(symbol string) (float64, int64, error) {
+	symbol = strings.ToUpper(symbol)
+	v, err, _ := c.group.Do("oi:"+symbol, func() (interface{}, error) {
+		oi, t, err := c.client.GetOpenInterest(symbol)
+		if err != nil {
+			return nil, err
+		}
+		entry := oiEntry{value: oi, time: t, fetched: time.Now()}
+		c.mu.Lock()
+		c.oi[symbol] = entry
+		c.mu.Unlock()
+		return entry, nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	entry := v.(oiEntry)
+	return entry.value, entry.time, nil
+}
@@ -15,13 +15,29 @@ type WSMonitor struct {
 	symbols        []string
 	featuresMap    sync.Map
 	alertsChan     chan Alert
-	klineDataMap3m sync.Map // 存储每个交易对的K线历史数据
-	klineDataMap4h sync.Map // 存储每个交易对的K线历史数据
+	klineData      sync.Map // K线缓存: "SYMBOL@interval" -> *klineSeries
+	klineLimits    sync.Map // 每个周期保留的K线数量: interval -> int
+	backfilled     sync.Map // 已回补的历史长度: "SYMBOL@interval" -> int
+	subscribed     sync.Map // 已订阅的实时流: "SYMBOL@interval"（K线）或 "SYMBOL@depth"（深度） -> bool
+	orderBooks     sync.Map // 实时部分深度: symbol -> *OrderBook
+	depthAccess    sync.Map // 深度最近一次被读取的时间: symbol -> time.Time（闲置的深度流会被取消订阅）
+	liquidations   *liquidationTracker
 	tickerDataMap  sync.Map // 存储每个交易对的ticker数据
 	batchSize      int
 	filterSymbols  sync.Map // 使用sync.Map来存储需要监控的币种和其状态
 	symbolStats    sync.Map // 存储币种统计信息
 	FilterSymbol   []string //经过筛选的币种
+
+	alertMu      sync.Mutex              // 保护警报订阅者、最近警报、冷却时间和 SymbolStats
+	alertSubs    map[chan Alert]struct{} // 警报订阅者
+	recentAlerts []Alert                 // 最近的警报（按时间升序）
+	lastAlerts   map[string]time.Time    // 同类警报最近触发时间: "SYMBOL|type" -> time
+	alertsClosed bool
+
+	closeMu   sync.Mutex                 // 保护K线收盘订阅者
+	closeSubs map[chan KlineEvent]string // K线收盘订阅者 -> 订阅的周期（空为全部）
+
+	staleRefreshed sync.Map // 实时流过期时最近一次REST刷新: "SYMBOL@interval" -> time.Time
 }
 type SymbolStats struct {
 	LastActiveTime   time.Time
@@ -32,13 +48,20 @@ type SymbolStats struct {
 }
 
 var WSMonitorCli *WSMonitor
-var subKlineTime = []string{"3m", "4h"} // 管理订阅流的K线周期
+var subKlineTime = []string{"3m", "4h"} // 启动时为所有交易对订阅的基础K线周期（其他周期按需订阅）
+
+// staleRefreshInterval 实时流过期时REST刷新的最小间隔
+const staleRefreshInterval = 30 * time.Second
 
 func NewWSMonitor(batchSize int) *WSMonitor {
 	WSMonitorCli = &WSMonitor{
 		wsClient:       NewWSClient(),
 		combinedClient: NewCombinedStreamsClient(batchSize),
 		alertsChan:     make(chan Alert, 1000),
+		liquidations:   newLiquidationTracker(),
+		alertSubs:      make(map[chan Alert]struct{}),
+		lastAlerts:     make(map[string]time.Time),
+		closeSubs:      make(map[chan KlineEvent]string),
 		batchSize:      batchSize,
 	}
 	return WSMonitorCli
@@ -89,25 +112,18 @@ func (m *WSMonitor) initializeHistoricalData() error {
 			defer wg.Done()
 			defer func() { <-semaphore }()
 
-			// 获取历史K线数据
-			klines, err := apiClient.GetKlines(s, "3m", 100)
-			if err != nil {
-				log.Printf("获取 %s 历史数据失败: %v", s, err)
-				return
-			}
-			if len(klines) > 0 {
-				m.klineDataMap3m.Store(s, klines)
-				log.Printf("已加载 %s 的历史K线数据-3m: %d 条", s, len(klines))
-			}
-			// 获取历史K线数据
-			klines4h, err := apiClient.GetKlines(s, "4h", 100)
-			if err != nil {
-				log.Printf("获取 %s 历史数据失败: %v", s, err)
-				return
-			}
-			if len(klines4h) > 0 {
-				m.klineDataMap4h.Store(s, klines4h)
-				log.Printf("已加载 %s 的历史K线数据-4h: %d 条", s, len(klines4h))
+			for _, st := range subKlineTime {
+				// 获取历史K线数据（启用本地存储时只回补缺失的区间）
+				klines, err := loadKlines(apiClient, s, st, defaultKlineLimit)
+				if err != nil {
+					log.Printf("获取 %s 历史数据失败: %v", s, err)
+					return
+				}
+				if len(klines) > 0 {
+					m.seriesOrCreate(s, st).replace(klines, m.klineLimit(st))
+					m.backfilled.Store(klineKey(s, st), defaultKlineLimit)
+					log.Printf("已加载 %s 的历史K线数据-%s: %d 条", s, st, len(klines))
+				}
 			}
 		}(symbol)
 	}
@@ -125,6 +141,9 @@ func (m *WSMonitor) Start(coins []string) {
 		return
 	}
 
+	// 断线重连后回补断线期间缺失的K线
+	m.combinedClient.SetOnReconnect(m.backfillAfterReconnect)
+
 	err = m.combinedClient.Connect()
 	if err != nil {
 		log.Fatalf("❌ 批量订阅流: %v", err)
@@ -136,6 +155,9 @@ func (m *WSMonitor) Start(coins []string) {
 		log.Fatalf("❌ 订阅币种交易对: %v", err)
 		return
 	}
+	// 启动警报引擎
+	m.startAlertEngine()
+	m.startDepthJanitor()
 }
 
 // subscribeSymbol 注册监听
@@ -153,6 +175,7 @@ func (m *WSMonitor) subscribeAll() error {
 	log.Println("开始订阅所有交易对...")
 	for _, symbol := range m.symbols {
 		for _, st := range subKlineTime {
+			m.subscribed.Store(klineKey(symbol, st), true)
 			m.subscribeSymbol(symbol, st)
 		}
 	}
@@ -163,6 +186,9 @@ func (m *WSMonitor) subscribeAll() error {
 			return err
 		}
 	}
+	if err := m.subscribeLiquidations(); err != nil {
+		log.Printf("⚠️  订阅强平订单流失败: %v", err)
+	}
 	log.Println("所有交易对订阅完成")
 	return nil
 }
@@ -178,16 +204,99 @@ func (m *WSMonitor) handleKlineData(symbol string, ch <-chan []byte, _time strin
 	}
 }
 
-func (m *WSMonitor) getKlineDataMap(_time string) *sync.Map {
-	var klineDataMap *sync.Map
-	if _time == "3m" {
-		klineDataMap = &m.klineDataMap3m
-	} else if _time == "4h" {
-		klineDataMap = &m.klineDataMap4h
-	} else {
-		klineDataMap = &sync.Map{}
+// klineKey K线缓存/订阅的键
+func klineKey(symbol, interval string) string {
+	return strings.ToUpper(symbol) + "@" + interval
+}
+
+// loadKlines 获取最近 lookback 根K线：启用本地存储时读取存储并回补缺口，否则直接请求REST
+func loadKlines(apiClient *APIClient, symbol, interval string, lookback int) ([]Kline, error) {
+	if store := GetStore(); store != nil {
+		return store.Backfill(apiClient, symbol, interval, lookback)
+	}
+	if lookback > maxKlineLimit {
+		lookback = maxKlineLimit
+	}
+	return apiClient.GetKlines(symbol, interval, lookback)
+}
+
+// maxLookback 单个周期允许的最大回看长度
+func maxLookback() int {
+	if GetStore() != nil {
+		return maxStoreLookback
+	}
+	return maxKlineLimit
+}
+
+// backfillAfterReconnect 重连后为所有已订阅的交易对和周期回补断线期间的K线
+func (m *WSMonitor) backfillAfterReconnect() {
+	apiClient := NewAPIClient()
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, 5) // 限制并发数
+	count := 0
+
+	m.subscribed.Range(func(key, _ interface{}) bool {
+		symbol, interval, ok := strings.Cut(key.(string), "@")
+		if !ok || !IsValidInterval(interval) { // 跳过深度流等非K线订阅
+			return true
+		}
+		count++
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			limit := m.klineLimit(interval)
+			klines, err := loadKlines(apiClient, symbol, interval, limit)
+			if err != nil {
+				log.Printf("⚠️  重连后回补 %s %s K线失败: %v", symbol, interval, err)
+				return
+			}
+			m.seriesOrCreate(symbol, interval).merge(klines, limit)
+		}()
+		return true
+	})
+
+	wg.Wait()
+	log.Printf("✓ 重连后已回补 %d 个K线流", count)
+}
+
+// klineLimit 指定周期保留的K线数量
+func (m *WSMonitor) klineLimit(interval string) int {
+	if value, ok := m.klineLimits.Load(interval); ok {
+		return value.(int)
+	}
+	return defaultKlineLimit
+}
+
+// raiseKlineLimit 提高指定周期保留的K线数量（只增不减）
+func (m *WSMonitor) raiseKlineLimit(interval string, limit int) {
+	for {
+		current, loaded := m.klineLimits.LoadOrStore(interval, limit)
+		if !loaded || current.(int) >= limit {
+			return
+		}
+		if m.klineLimits.CompareAndSwap(interval, current, limit) {
+			return
+		}
 	}
-	return klineDataMap
+}
+
+// ensureSubscribed 确保已订阅指定交易对和周期的实时K线
+func (m *WSMonitor) ensureSubscribed(symbol, interval string) error {
+	key := klineKey(symbol, interval)
+	if _, loaded := m.subscribed.LoadOrStore(key, true); loaded {
+		return nil
+	}
+
+	streams := m.subscribeSymbol(symbol, interval)
+	if err := m.combinedClient.subscribeStreams(streams); err != nil {
+		m.subscribed.Delete(key)
+		return fmt.Errorf("动态订阅%v K线失败: %w", interval, err)
+	}
+	log.Printf("动态订阅流: %v", streams)
+	return nil
 }
 func (m *WSMonitor) processKlineUpdate(symbol string, wsData KlineWSData, _time string) {
 	// 转换WebSocket数据为Kline结构
@@ -205,56 +314,102 @@ func (m *WSMonitor) processKlineUpdate(symbol string, wsData KlineWSData, _time
 	kline.QuoteVolume, _ = parseFloat(wsData.Kline.QuoteVolume)
 	kline.TakerBuyBaseVolume, _ = parseFloat(wsData.Kline.TakerBuyBaseVolume)
 	kline.TakerBuyQuoteVolume, _ = parseFloat(wsData.Kline.TakerBuyQuoteVolume)
-	// 更新K线数据
-	var klineDataMap = m.getKlineDataMap(_time)
-	value, exists := klineDataMap.Load(symbol)
-	var klines []Kline
-	if exists {
-		klines = value.([]Kline)
-
-		// 检查是否是新的K线
-		if len(klines) > 0 && klines[len(klines)-1].OpenTime == kline.OpenTime {
-			// 更新当前K线
-			klines[len(klines)-1] = kline
-		} else {
-			// 添加新K线
-			klines = append(klines, kline)
-
-			// 保持数据长度
-			if len(klines) > 100 {
-				klines = klines[1:]
-			}
-		}
-	} else {
-		klines = []Kline{kline}
+	// 已收盘的K线写入本地存储
+	if store := GetStore(); store != nil && wsData.Kline.IsFinal {
+		store.SaveClosedKline(symbol, _time, kline)
 	}
+	// 更新K线数据（同一根K线覆盖，新K线追加）
+	m.seriesOrCreate(symbol, _time).update(kline)
 
-	klineDataMap.Store(symbol, klines)
+	if wsData.Kline.IsFinal {
+		m.publishKlineClose(symbol, _time, kline)
+	}
+	if _time == featureInterval {
+		m.checkKlineAlerts(symbol, wsData.Kline.IsFinal)
+	}
 }
 
 func (m *WSMonitor) GetCurrentKlines(symbol string, _time string) ([]Kline, error) {
-	// 对每一个进来的symbol检测是否存在内类 是否的话就订阅它
-	value, exists := m.getKlineDataMap(_time).Load(symbol)
-	if !exists {
-		// 如果Ws数据未初始化完成时,单独使用api获取 - 兼容性代码 (防止在未初始化完成是,已经有交易员运行)
-		apiClient := NewAPIClient()
-		klines, err := apiClient.GetKlines(symbol, _time, 100)
-		m.getKlineDataMap(_time).Store(strings.ToUpper(symbol), klines) //动态缓存进缓存
-		subStr := m.subscribeSymbol(symbol, _time)
-		subErr := m.combinedClient.subscribeStreams(subStr)
-		log.Printf("动态订阅流: %v", subStr)
-		if subErr != nil {
-			return nil, fmt.Errorf("动态订阅%v分钟K线失败: %v", _time, subErr)
-		}
-		if err != nil {
-			return nil, fmt.Errorf("获取%v分钟K线失败: %v", _time, err)
+	return m.GetKlines(symbol, _time, defaultKlineLimit)
+}
+
+// GetKlines 获取指定周期最近 lookback 根K线
+// 缓存中没有（或回看长度不足）时从本地存储/REST回补历史，并动态订阅该周期的实时K线
+// 启用本地存储时回看长度上限为 maxStoreLookback，否则为 maxKlineLimit
+func (m *WSMonitor) GetKlines(symbol, interval string, lookback int) ([]Kline, error) {
+	symbol = strings.ToUpper(symbol)
+	if !IsValidInterval(interval) {
+		return nil, fmt.Errorf("不支持的K线周期: %s", interval)
+	}
+	if lookback <= 0 {
+		lookback = defaultKlineLimit
+	}
+	if limit := maxLookback(); lookback > limit {
+		lookback = limit
+	}
+	m.raiseKlineLimit(interval, lookback)
+
+	key := klineKey(symbol, interval)
+	if s, exists := m.series(symbol, interval); exists {
+		s.grow(m.klineLimit(interval))
+		fetched, _ := m.backfilled.Load(key)
+		// 已回补过足够长度（新上市币种K线本身就不足时也不再重复请求）
+		if n, _ := fetched.(int); s.len() >= lookback || n >= lookback {
+			m.refreshIfStale(symbol, interval, s)
+			return s.snapshot(lookback), nil
 		}
-		return klines, fmt.Errorf("symbol不存在")
 	}
-	return value.([]Kline), nil
+
+	// 回补历史K线（WS数据未初始化完成、新周期或需要更长回看时）
+	klines, err := loadKlines(NewAPIClient(), symbol, interval, lookback)
+	if err != nil {
+		return nil, fmt.Errorf("获取%v K线失败: %w", interval, err)
+	}
+	if len(klines) == 0 {
+		return nil, fmt.Errorf("%s 没有%v K线数据", symbol, interval)
+	}
+	m.seriesOrCreate(symbol, interval).replace(klines, m.klineLimit(interval))
+	m.backfilled.Store(key, lookback)
+
+	if err := m.ensureSubscribed(symbol, interval); err != nil {
+		// 订阅失败不影响本次返回，下次请求时重试
+		log.Printf("⚠️  %v", err)
+	}
+	return klines, nil
+}
+
+// refreshIfStale 实时K线流长时间没有推送时从本地存储/REST刷新缓存（按 staleRefreshInterval 限频）
+func (m *WSMonitor) refreshIfStale(symbol, interval string, s *klineSeries) {
+	stream := fmt.Sprintf("%s@kline_%s", strings.ToLower(symbol), interval)
+	if !m.combinedClient.StreamStale(stream) {
+		return
+	}
+	key := klineKey(symbol, interval)
+	now := time.Now()
+	if last, ok := m.staleRefreshed.Load(key); ok && now.Sub(last.(time.Time)) < staleRefreshInterval {
+		return
+	}
+	m.staleRefreshed.Store(key, now)
+
+	limit := m.klineLimit(interval)
+	klines, err := loadKlines(NewAPIClient(), symbol, interval, limit)
+	if err != nil {
+		log.Printf("⚠️  %s 实时流已过期且REST刷新失败: %v", stream, err)
+		return
+	}
+	s.merge(klines, limit)
+	log.Printf("🔄 %s 实时流已过期，已通过REST刷新K线", stream)
+}
+
+// Health 实时行情WebSocket健康状况
+func (m *WSMonitor) Health() StreamHealth {
+	return m.combinedClient.Health()
 }
 
 func (m *WSMonitor) Close() {
 	m.wsClient.Close()
+	m.alertMu.Lock()
+	m.alertsClosed = true
 	close(m.alertsChan)
+	m.alertMu.Unlock()
 }
@@ -0,0 +1,385 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	depthStreamSuffix  = "@depth20@500ms" // 部分深度流（前20档，500ms推送一次）
+	depthSnapshotLimit = 500              // REST 深度快照档位
+	orderBookMaxAge    = 10 * time.Second // WebSocket 深度超过该时间未更新视为过期
+	orderBookCacheTTL  = 5 * time.Second  // REST 深度快照缓存时间
+	wallMinMultiple    = 5.0              // 单档名义价值达到同侧中位数的倍数视为挂单墙
+	maxWallsPerSide    = 3                // 每侧最多输出的挂单墙数量
+	depthBandNarrowPct = 0.5              // 近端深度区间（%）
+	depthBandWidePct   = 1.0              // 远端深度区间（%）
+	depthIdleTTL       = 10 * time.Minute // 深度流超过该时间没有被读取则取消订阅
+	depthSweepInterval = time.Minute      // 闲置深度流的检查间隔
+)
+
+// OrderBookLevel 一档挂单
+type OrderBookLevel struct {
+	Price    float64 `json:"price"`
+	Quantity float64 `json:"quantity"`
+}
+
+// OrderBook 订单簿快照（Bids 价格降序，Asks 价格升序）
+type OrderBook struct {
+	Symbol     string           `json:"symbol"`
+	Bids       []OrderBookLevel `json:"bids"`
+	Asks       []OrderBookLevel `json:"asks"`
+	UpdateTime int64            `json:"update_time"` // 毫秒
+}
+
+// DepthWall 大额挂单（挂单墙）
+type DepthWall struct {
+	Price       float64 `json:"price"`
+	Notional    float64 `json:"notional"`     // 名义价值（USDT）
+	DistancePct float64 `json:"distance_pct"` // 距中间价的百分比（买单为负）
+}
+
+// DepthData 订单簿流动性指标
+type DepthData struct {
+	BestBid     float64
+	BestAsk     float64
+	MidPrice    float64
+	SpreadBps   float64 // 买卖价差（基点）
+	BidDepth05  float64 // 中间价下方0.5%内买单名义价值（USDT）
+	AskDepth05  float64 // 中间价上方0.5%内卖单名义价值（USDT）
+	BidDepth1   float64 // 中间价下方1%内买单名义价值（USDT）
+	AskDepth1   float64 // 中间价上方1%内卖单名义价值（USDT）
+	Imbalance   float64 // 1%内买卖挂单不平衡度: (买-卖)/(买+卖)，范围 -1 ~ 1
+	CoveragePct float64 // 订单簿覆盖的价格范围（两侧较小者，%），小于1时深度数据可能偏小
+	BidWalls    []DepthWall
+	AskWalls    []DepthWall
+}
+
+// AnalyzeDepth 根据订单簿计算流动性指标
+func AnalyzeDepth(book *OrderBook) *DepthData {
+	if book == nil || len(book.Bids) == 0 || len(book.Asks) == 0 {
+		return nil
+	}
+	d := &DepthData{
+		BestBid: book.Bids[0].Price,
+		BestAsk: book.Asks[0].Price,
+	}
+	d.MidPrice = (d.BestBid + d.BestAsk) / 2
+	if d.MidPrice <= 0 {
+		return nil
+	}
+	d.SpreadBps = (d.BestAsk - d.BestBid) / d.MidPrice * 10000
+
+	d.BidDepth05 = depthWithin(book.Bids, d.MidPrice, depthBandNarrowPct)
+	d.AskDepth05 = depthWithin(book.Asks, d.MidPrice, depthBandNarrowPct)
+	d.BidDepth1 = depthWithin(book.Bids, d.MidPrice, depthBandWidePct)
+	d.AskDepth1 = depthWithin(book.Asks, d.MidPrice, depthBandWidePct)
+	if total := d.BidDepth1 + d.AskDepth1; total > 0 {
+		d.Imbalance = (d.BidDepth1 - d.AskDepth1) / total
+	}
+
+	bidCoverage := (d.MidPrice - book.Bids[len(book.Bids)-1].Price) / d.MidPrice * 100
+	askCoverage := (book.Asks[len(book.Asks)-1].Price - d.MidPrice) / d.MidPrice * 100
+	d.CoveragePct = math.Min(bidCoverage, askCoverage)
+
+	d.BidWalls = findWalls(book.Bids, d.MidPrice)
+	d.AskWalls = findWalls(book.Asks, d.MidPrice)
+	return d
+}
+
+// depthWithin 计算距中间价 pct% 以内的挂单名义价值
+func depthWithin(levels []OrderBookLevel, mid, pct float64) float64 {
+	total := 0.0
+	for _, l := range levels {
+		if math.Abs(l.Price-mid)/mid*100 > pct {
+			break
+		}
+		total += l.Price * l.Quantity
+	}
+	return total
+}
+
+// findWalls 找出名义价值远高于同侧中位数的挂单（按名义价值降序）
+func findWalls(levels []OrderBookLevel, mid float64) []DepthWall {
+	if len(levels) < 3 {
+		return nil
+	}
+	notionals := make([]float64, len(levels))
+	for i, l := range levels {
+		notionals[i] = l.Price * l.Quantity
+	}
+	sorted := append([]float64(nil), notionals...)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+	if median <= 0 {
+		return nil
+	}
+
+	var walls []DepthWall
+	for i, l := range levels {
+		if notionals[i] >= median*wallMinMultiple {
+			walls = append(walls, DepthWall{
+				Price:       l.Price,
+				Notional:    notionals[i],
+				DistancePct: (l.Price - mid) / mid * 100,
+			})
+		}
+	}
+	sort.Slice(walls, func(i, j int) bool { return walls[i].Notional > walls[j].Notional })
+	if len(walls) > maxWallsPerSide {
+		walls = walls[:maxWallsPerSide]
+	}
+	return walls
+}
+
+// SlippageEstimate 市价单滑点估算
+type SlippageEstimate struct {
+	AvgPrice    float64 `json:"avg_price"`    // 预计成交均价
+	SlippagePct float64 `json:"slippage_pct"` // 相对中间价的滑点（%，总为正）
+	Filled      bool    `json:"filled"`       // 订单簿深度是否足以完全成交
+}
+
+// EstimateSlippage 估算以市价买入（buy=true）或卖出名义价值 notional（USDT）的滑点
+func (b *OrderBook) EstimateSlippage(buy bool, notional float64) (*SlippageEstimate, error) {
+	if len(b.Bids) == 0 || len(b.Asks) == 0 {
+		return nil, fmt.Errorf("%s 订单簿为空", b.Symbol)
+	}
+	mid := (b.Bids[0].Price + b.Asks[0].Price) / 2
+	levels := b.Bids
+	if buy {
+		levels = b.Asks
+	}
+
+	remaining := notional
+	cost, qty := 0.0, 0.0
+	for _, l := range levels {
+		levelNotional := l.Price * l.Quantity
+		take := math.Min(remaining, levelNotional)
+		cost += take
+		qty += take / l.Price
+		remaining -= take
+		if remaining <= 0 {
+			break
+		}
+	}
+	if qty == 0 {
+		return nil, fmt.Errorf("%s 订单簿没有可成交的挂单", b.Symbol)
+	}
+
+	avg := cost / qty
+	return &SlippageEstimate{
+		AvgPrice:    avg,
+		SlippagePct: math.Abs(avg-mid) / mid * 100,
+		Filled:      remaining <= 0,
+	}, nil
+}
+
+// EstimateSlippage 用行情来源的订单簿估算市价单滑点
+// 币安实时深度只有前20档，不足以完全成交时改用更深的REST快照重新估算
+func EstimateSlippage(provider Provider, symbol string, buy bool, notional float64) (*SlippageEstimate, error) {
+	if provider == nil {
+		provider = Binance
+	}
+	symbol = Normalize(symbol)
+	book, err := provider.GetOrderBook(symbol)
+	if err != nil {
+		return nil, err
+	}
+	estimate, err := book.EstimateSlippage(buy, notional)
+	if err != nil || estimate.Filled || provider != Binance {
+		return estimate, err
+	}
+
+	deeper, err := getDepthSnapshot(NewAPIClient(), symbol)
+	if err != nil {
+		return estimate, nil
+	}
+	return deeper.EstimateSlippage(buy, notional)
+}
+
+// formatDepth 格式化订单簿流动性指标
+func formatDepth(sb *strings.Builder, d *DepthData) {
+	sb.WriteString("Order book liquidity:\n\n")
+	sb.WriteString(fmt.Sprintf("Best bid: %.4f, Best ask: %.4f, Spread: %.2f bps\n\n", d.BestBid, d.BestAsk, d.SpreadBps))
+	sb.WriteString(fmt.Sprintf("Depth within ±%.1f%%: bids $%.0f vs asks $%.0f\n\n", depthBandNarrowPct, d.BidDepth05, d.AskDepth05))
+	sb.WriteString(fmt.Sprintf("Depth within ±%.1f%%: bids $%.0f vs asks $%.0f\n\n", depthBandWidePct, d.BidDepth1, d.AskDepth1))
+	sb.WriteString(fmt.Sprintf("Bid/ask imbalance (±%.1f%%, -1 = all asks, +1 = all bids): %+.3f\n\n", depthBandWidePct, d.Imbalance))
+	if d.CoveragePct < depthBandWidePct {
+		sb.WriteString(fmt.Sprintf("(Order book snapshot only covers ±%.2f%% around mid price; deeper liquidity is not included)\n\n", d.CoveragePct))
+	}
+	if len(d.BidWalls) > 0 {
+		sb.WriteString(fmt.Sprintf("Large bid walls: %s\n\n", formatWalls(d.BidWalls)))
+	}
+	if len(d.AskWalls) > 0 {
+		sb.WriteString(fmt.Sprintf("Large ask walls: %s\n\n", formatWalls(d.AskWalls)))
+	}
+}
+
+// formatWalls 格式化挂单墙，例如 "$1200000 @ 64000.0000 (-0.35%)"
+func formatWalls(walls []DepthWall) string {
+	parts := make([]string, len(walls))
+	for i, w := range walls {
+		parts[i] = fmt.Sprintf("$%.0f @ %.4f (%+.2f%%)", w.Notional, w.Price, w.DistancePct)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// parseDepthLevels 解析 [["price","qty"], ...] 格式的挂单
+func parseDepthLevels(raw [][]string) []OrderBookLevel {
+	levels := make([]OrderBookLevel, 0, len(raw))
+	for _, entry := range raw {
+		if len(entry) < 2 {
+			continue
+		}
+		price, err1 := strconv.ParseFloat(entry[0], 64)
+		qty, err2 := strconv.ParseFloat(entry[1], 64)
+		if err1 != nil || err2 != nil || qty <= 0 {
+			continue
+		}
+		levels = append(levels, OrderBookLevel{Price: price, Quantity: qty})
+	}
+	return levels
+}
+
+// GetDepth 获取订单簿快照
+func (c *APIClient) GetDepth(symbol string, limit int) (*OrderBook, error) {
+	var result struct {
+		EventTime int64      `json:"E"`
+		Bids      [][]string `json:"bids"`
+		Asks      [][]string `json:"asks"`
+	}
+	if err := c.getJSON(fmt.Sprintf("/fapi/v1/depth?limit=%d", limit), symbol, &result); err != nil {
+		return nil, err
+	}
+	book := &OrderBook{
+		Symbol:     strings.ToUpper(symbol),
+		Bids:       parseDepthLevels(result.Bids),
+		Asks:       parseDepthLevels(result.Asks),
+		UpdateTime: result.EventTime,
+	}
+	if book.UpdateTime == 0 {
+		book.UpdateTime = time.Now().UnixMilli()
+	}
+	return book, nil
+}
+
+// depthStream 交易对的部分深度流名称
+func depthStream(symbol string) string {
+	return strings.ToLower(symbol) + depthStreamSuffix
+}
+
+// ensureDepthSubscribed 确保已订阅交易对的部分深度流
+func (m *WSMonitor) ensureDepthSubscribed(symbol string) error {
+	key := strings.ToUpper(symbol) + "@depth"
+	if _, loaded := m.subscribed.LoadOrStore(key, true); loaded {
+		return nil
+	}
+
+	stream := depthStream(symbol)
+	ch := m.combinedClient.AddSubscriber(stream, 10)
+	go m.handleDepthData(strings.ToUpper(symbol), ch)
+	if err := m.combinedClient.subscribeStreams([]string{stream}); err != nil {
+		m.combinedClient.removeStreams([]string{stream})
+		m.subscribed.Delete(key)
+		return fmt.Errorf("订阅%s深度失败: %w", symbol, err)
+	}
+	return nil
+}
+
+// startDepthJanitor 定期取消订阅闲置的深度流（交易对离开候选列表后不再被读取）
+func (m *WSMonitor) startDepthJanitor() {
+	go func() {
+		ticker := time.NewTicker(depthSweepInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.combinedClient.done:
+				return
+			case now := <-ticker.C:
+				m.unsubscribeIdleDepth(now)
+			}
+		}
+	}()
+}
+
+// unsubscribeIdleDepth 取消订阅超过 depthIdleTTL 没有被读取的深度流
+func (m *WSMonitor) unsubscribeIdleDepth(now time.Time) {
+	m.depthAccess.Range(func(key, value interface{}) bool {
+		symbol := key.(string)
+		if now.Sub(value.(time.Time)) < depthIdleTTL {
+			return true
+		}
+		m.depthAccess.Delete(symbol)
+		if err := m.combinedClient.unsubscribeStreams([]string{depthStream(symbol)}); err != nil {
+			log.Printf("⚠️  取消订阅%s深度失败: %v", symbol, err)
+		}
+		m.orderBooks.Delete(symbol)
+		m.subscribed.Delete(symbol + "@depth")
+		return true
+	})
+}
+
+// handleDepthData 处理部分深度推送（每次推送为完整的前20档快照）
+func (m *WSMonitor) handleDepthData(symbol string, ch <-chan []byte) {
+	for data := range ch {
+		var update struct {
+			EventTime int64      `json:"E"`
+			Bids      [][]string `json:"b"`
+			Asks      [][]string `json:"a"`
+		}
+		if err := json.Unmarshal(data, &update); err != nil {
+			log.Printf("解析深度数据失败: %v", err)
+			continue
+		}
+		m.orderBooks.Store(symbol, &OrderBook{
+			Symbol:     symbol,
+			Bids:       parseDepthLevels(update.Bids),
+			Asks:       parseDepthLevels(update.Asks),
+			UpdateTime: update.EventTime,
+		})
+	}
+}
+
+// GetOrderBook 获取订单簿：优先使用实时深度流，没有（或已过期）时使用REST快照并订阅深度流
+func (m *WSMonitor) GetOrderBook(symbol string) (*OrderBook, error) {
+	symbol = strings.ToUpper(symbol)
+	m.depthAccess.Store(symbol, time.Now())
+	if value, ok := m.orderBooks.Load(symbol); ok {
+		book := value.(*OrderBook)
+		if time.Since(time.UnixMilli(book.UpdateTime)) < orderBookMaxAge {
+			return book, nil
+		}
+	}
+
+	if err := m.ensureDepthSubscribed(symbol); err != nil {
+		log.Printf("⚠️  %v", err)
+	}
+	return getDepthSnapshot(NewAPIClient(), symbol)
+}
+
+// depthSnapshotCache REST 深度快照缓存: "baseURL|SYMBOL" -> *OrderBook
+var depthSnapshotCache sync.Map
+
+// getDepthSnapshot 获取（短时间缓存的）REST深度快照
+func getDepthSnapshot(client *APIClient, symbol string) (*OrderBook, error) {
+	key := client.baseURL + "|" + strings.ToUpper(symbol)
+	if value, ok := depthSnapshotCache.Load(key); ok {
+		book := value.(*OrderBook)
+		if time.Since(time.UnixMilli(book.UpdateTime)) < orderBookCacheTTL {
+			return book, nil
+		}
+	}
+	book, err := client.GetDepth(symbol, depthSnapshotLimit)
+	if err != nil {
+		return nil, fmt.Errorf("获取%s深度失败: %w", symbol, err)
+	}
+	depthSnapshotCache.Store(key, book)
+	return book, nil
+}
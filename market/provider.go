@@ -0,0 +1,163 @@
+package market
+
+import (
+	"fmt"
+	"time"
+)
+
+// Provider 行情数据来源
+// 交易员应使用其所在交易所的行情（不同交易所的价格、资金费率和持仓量并不相同）
+type Provider interface {
+	// Name 来源名称，如 "binance"、"hyperliquid"、"aster"
+	Name() string
+	// GetKlines 获取最近 lookback 根K线（按时间升序，最后一根可能尚未收盘）
+	GetKlines(symbol, interval string, lookback int) ([]Kline, error)
+	// GetOpenInterest 获取持仓量（以币为单位）
+	GetOpenInterest(symbol string) (*OIData, error)
+	// GetFundingRate 获取当前资金费率（统一为8小时费率，结算周期不同的交易所需要换算）
+	GetFundingRate(symbol string) (float64, error)
+	// GetOrderBook 获取订单簿快照
+	GetOrderBook(symbol string) (*OrderBook, error)
+}
+
+var (
+	// Binance 币安合约行情（WebSocket实时缓存 + REST回补，默认来源）
+	Binance Provider = &binanceProvider{}
+	// Aster Aster合约行情（接口与币安合约兼容）
+	Aster Provider = &asterProvider{client: newAPIClient(asterBaseURL), meta: asterMetadata}
+	// Hyperliquid Hyperliquid主网行情
+	Hyperliquid Provider = newHyperliquidProvider("hyperliquid", hyperliquidMainnetURL)
+	// HyperliquidTestnet Hyperliquid测试网行情
+	HyperliquidTestnet Provider = newHyperliquidProvider("hyperliquid-testnet", hyperliquidTestnetURL)
+)
+
+// ProviderFor 返回与交易所对应的行情来源，未知交易所使用币安
+func ProviderFor(exchange string, testnet bool) Provider {
+	switch exchange {
+	case "hyperliquid":
+		if testnet {
+			return HyperliquidTestnet
+		}
+		return Hyperliquid
+	case "aster":
+		return Aster
+	default:
+		return Binance
+	}
+}
+
+// binanceProvider 币安合约行情
+type binanceProvider struct{}
+
+func (p *binanceProvider) Name() string { return "binance" }
+
+// GetKlines 优先使用WebSocket实时缓存，行情监控未启动时直接请求REST
+func (p *binanceProvider) GetKlines(symbol, interval string, lookback int) ([]Kline, error) {
+	if WSMonitorCli != nil {
+		return WSMonitorCli.GetKlines(symbol, interval, lookback)
+	}
+	klines, err := loadKlines(NewAPIClient(), symbol, interval, lookback)
+	if err != nil {
+		return nil, err
+	}
+	if len(klines) == 0 {
+		return nil, fmt.Errorf("%s 没有%v K线数据", symbol, interval)
+	}
+	return klines, nil
+}
+
+// GetOpenInterest 获取持仓量（共享缓存），启用本地存储时记录采样并用最近24小时的采样计算平均值
+func (p *binanceProvider) GetOpenInterest(symbol string) (*OIData, error) {
+	oi, t, err := binanceMetadata.openInterest(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	average := oi * 0.999 // 近似平均值
+	if store := GetStore(); store != nil {
+		if avg, ok := averageOpenInterest(store, symbol, t, oi); ok {
+			average = avg
+		}
+		store.SaveOpenInterest(symbol, t, oi)
+	}
+
+	return &OIData{
+		Latest:  oi,
+		Average: average,
+	}, nil
+}
+
+// averageOpenInterest 用本地存储中最近24小时的持仓量采样（含本次）计算平均值，样本不足时返回 false
+func averageOpenInterest(store *Store, symbol string, now int64, latest float64) (float64, bool) {
+	points, err := store.LoadOpenInterest(symbol, now-24*time.Hour.Milliseconds(), now-1)
+	if err != nil || len(points) == 0 {
+		return 0, false
+	}
+	sum := latest
+	for _, p := range points {
+		sum += p.OpenInterest
+	}
+	return sum / float64(len(points)+1), true
+}
+
+// GetFundingRate 获取资金费率（8小时，共享缓存），启用本地存储时记录采样
+func (p *binanceProvider) GetFundingRate(symbol string) (float64, error) {
+	index, err := binanceMetadata.premiumIndex(symbol)
+	if err != nil {
+		return 0, err
+	}
+	if store := GetStore(); store != nil {
+		store.SaveFundingRate(symbol, index.Time, index.LastFundingRate, index.MarkPrice)
+	}
+	return index.LastFundingRate, nil
+}
+
+// GetOrderBook 实时部分深度流（首次请求时订阅），行情监控未启动时使用REST快照
+func (p *binanceProvider) GetOrderBook(symbol string) (*OrderBook, error) {
+	if WSMonitorCli != nil {
+		return WSMonitorCli.GetOrderBook(symbol)
+	}
+	return getDepthSnapshot(NewAPIClient(), symbol)
+}
+
+// asterProvider Aster合约行情（REST，接口与币安合约兼容）
+type asterProvider struct {
+	client *APIClient
+	meta   *metadataCache // 资金费率/持仓量共享缓存
+}
+
+func (p *asterProvider) Name() string { return "aster" }
+
+func (p *asterProvider) GetKlines(symbol, interval string, lookback int) ([]Kline, error) {
+	if !IsValidInterval(interval) {
+		return nil, fmt.Errorf("不支持的K线周期: %s", interval)
+	}
+	klines, err := p.client.GetRecentKlines(symbol, interval, lookback)
+	if err != nil {
+		return nil, fmt.Errorf("获取Aster %s K线失败: %w", interval, err)
+	}
+	if len(klines) == 0 {
+		return nil, fmt.Errorf("Aster %s 没有%v K线数据", symbol, interval)
+	}
+	return klines, nil
+}
+
+func (p *asterProvider) GetOpenInterest(symbol string) (*OIData, error) {
+	oi, _, err := p.meta.openInterest(symbol)
+	if err != nil {
+		return nil, fmt.Errorf("获取Aster持仓量失败: %w", err)
+	}
+	return &OIData{Latest: oi, Average: oi}, nil
+}
+
+func (p *asterProvider) GetFundingRate(symbol string) (float64, error) {
+	index, err := p.meta.premiumIndex(symbol)
+	if err != nil {
+		return 0, fmt.Errorf("获取Aster资金费率失败: %w", err)
+	}
+	return index.LastFundingRate, nil
+}
+
+func (p *asterProvider) GetOrderBook(symbol string) (*OrderBook, error) {
+	return getDepthSnapshot(p.client, symbol)
+}
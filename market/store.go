@@ -0,0 +1,446 @@
+package market
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const (
+	maxStoreLookback   = 5000            // 启用本地存储时单个周期允许的最大回看长度
+	storeWriteBuffer   = 4096            // 异步写入队列长度
+	storeFlushInterval = 1 * time.Second // 异步写入批量提交间隔
+	storeFlushBatch    = 500             // 单次事务最多写入的条数
+)
+
+// Store 本地行情时序存储（SQLite），按 symbol + interval 保存已收盘K线，以及持仓量和资金费率
+//
+// WebSocket 只推送实时数据，重启或断线期间的K线由 Backfill 检测缺口后通过REST补齐
+type Store struct {
+	db     *sql.DB
+	writes chan storeWrite
+	done   chan struct{}
+	wg     sync.WaitGroup
+
+	listedFrom sync.Map // 交易所没有更早K线的位置（新上市币种）: "SYMBOL@interval" -> openTime
+}
+
+// storeWrite 一条待写入的语句
+type storeWrite struct {
+	query string
+	args  []interface{}
+}
+
+// TimeRange 时间区间（毫秒，闭区间）
+type TimeRange struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+// OpenInterestPoint 持仓量采样
+type OpenInterestPoint struct {
+	Time         int64   `json:"time"`
+	OpenInterest float64 `json:"open_interest"`
+}
+
+// FundingRatePoint 资金费率采样
+type FundingRatePoint struct {
+	Time        int64   `json:"time"`
+	FundingRate float64 `json:"funding_rate"`
+	MarkPrice   float64 `json:"mark_price"`
+}
+
+var defaultStore atomic.Pointer[Store]
+
+// SetStore 设置全局行情存储（nil 表示不使用本地存储）
+func SetStore(s *Store) {
+	defaultStore.Store(s)
+}
+
+// GetStore 获取全局行情存储，未启用时返回 nil
+func GetStore() *Store {
+	return defaultStore.Load()
+}
+
+// OpenStore 打开（或创建）行情存储
+func OpenStore(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("创建行情存储目录失败: %w", err)
+	}
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, fmt.Errorf("打开行情存储失败: %w", err)
+	}
+	// SQLite 单写者，串行化连接避免 database is locked
+	db.SetMaxOpenConns(1)
+
+	s := &Store{
+		db:     db,
+		writes: make(chan storeWrite, storeWriteBuffer),
+		done:   make(chan struct{}),
+	}
+	if err := s.createTables(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("创建行情存储表失败: %w", err)
+	}
+
+	s.wg.Add(1)
+	go s.writeLoop()
+	return s, nil
+}
+
+// createTables 创建数据表
+func (s *Store) createTables() error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS klines (
+			symbol TEXT NOT NULL,
+			interval TEXT NOT NULL,
+			open_time INTEGER NOT NULL,
+			close_time INTEGER NOT NULL,
+			open REAL NOT NULL,
+			high REAL NOT NULL,
+			low REAL NOT NULL,
+			close REAL NOT NULL,
+			volume REAL NOT NULL,
+			quote_volume REAL NOT NULL DEFAULT 0,
+			trades INTEGER NOT NULL DEFAULT 0,
+			taker_buy_base_volume REAL NOT NULL DEFAULT 0,
+			taker_buy_quote_volume REAL NOT NULL DEFAULT 0,
+			PRIMARY KEY (symbol, interval, open_time)
+		) WITHOUT ROWID`,
+
+		`CREATE TABLE IF NOT EXISTS open_interest (
+			symbol TEXT NOT NULL,
+			time INTEGER NOT NULL,
+			open_interest REAL NOT NULL,
+			PRIMARY KEY (symbol, time)
+		) WITHOUT ROWID`,
+
+		`CREATE TABLE IF NOT EXISTS funding_rates (
+			symbol TEXT NOT NULL,
+			time INTEGER NOT NULL,
+			funding_rate REAL NOT NULL,
+			mark_price REAL NOT NULL DEFAULT 0,
+			PRIMARY KEY (symbol, time)
+		) WITHOUT ROWID`,
+	}
+	for _, query := range queries {
+		if _, err := s.db.Exec(query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close 写完队列中的数据后关闭存储
+func (s *Store) Close() error {
+	close(s.done)
+	s.wg.Wait()
+	return s.db.Close()
+}
+
+// enqueue 异步写入（队列满时丢弃，K线缺口会在下次回补时补齐）
+func (s *Store) enqueue(query string, args ...interface{}) {
+	select {
+	case s.writes <- storeWrite{query: query, args: args}:
+	default:
+		log.Printf("⚠️  行情存储写入队列已满，丢弃一条数据")
+	}
+}
+
+// writeLoop 批量提交异步写入
+func (s *Store) writeLoop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(storeFlushInterval)
+	defer ticker.Stop()
+
+	var batch []storeWrite
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := s.execBatch(batch); err != nil {
+			log.Printf("⚠️  行情存储写入失败: %v", err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case w := <-s.writes:
+			batch = append(batch, w)
+			if len(batch) >= storeFlushBatch {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-s.done:
+			for {
+				select {
+				case w := <-s.writes:
+					batch = append(batch, w)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// execBatch 在一个事务中执行多条写入
+func (s *Store) execBatch(batch []storeWrite) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, w := range batch {
+		if _, err := tx.Exec(w.query, w.args...); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+const upsertKlineSQL = `INSERT OR REPLACE INTO klines (
+	symbol, interval, open_time, close_time, open, high, low, close,
+	volume, quote_volume, trades, taker_buy_base_volume, taker_buy_quote_volume
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+func klineArgs(symbol, interval string, k Kline) []interface{} {
+	return []interface{}{
+		symbol, interval, k.OpenTime, k.CloseTime, k.Open, k.High, k.Low, k.Close,
+		k.Volume, k.QuoteVolume, k.Trades, k.TakerBuyBaseVolume, k.TakerBuyQuoteVolume,
+	}
+}
+
+// SaveKlines 同步写入K线（只保存已收盘的K线）
+func (s *Store) SaveKlines(symbol, interval string, klines []Kline) error {
+	symbol = strings.ToUpper(symbol)
+	now := time.Now().UnixMilli()
+	batch := make([]storeWrite, 0, len(klines))
+	for _, k := range klines {
+		if k.CloseTime >= now {
+			continue
+		}
+		batch = append(batch, storeWrite{query: upsertKlineSQL, args: klineArgs(symbol, interval, k)})
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+	if err := s.execBatch(batch); err != nil {
+		return fmt.Errorf("保存%s %s K线失败: %w", symbol, interval, err)
+	}
+	return nil
+}
+
+// SaveClosedKline 异步写入一根已收盘的K线（WebSocket 推送使用）
+func (s *Store) SaveClosedKline(symbol, interval string, k Kline) {
+	s.enqueue(upsertKlineSQL, klineArgs(strings.ToUpper(symbol), interval, k)...)
+}
+
+// LoadKlines 读取 [start, end] 区间内开盘的K线（按时间升序）
+func (s *Store) LoadKlines(symbol, interval string, start, end int64) ([]Kline, error) {
+	rows, err := s.db.Query(`
+		SELECT open_time, close_time, open, high, low, close, volume,
+		       quote_volume, trades, taker_buy_base_volume, taker_buy_quote_volume
+		FROM klines
+		WHERE symbol = ? AND interval = ? AND open_time >= ? AND open_time <= ?
+		ORDER BY open_time
+	`, strings.ToUpper(symbol), interval, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("读取%s %s K线失败: %w", symbol, interval, err)
+	}
+	defer rows.Close()
+
+	var klines []Kline
+	for rows.Next() {
+		var k Kline
+		if err := rows.Scan(&k.OpenTime, &k.CloseTime, &k.Open, &k.High, &k.Low, &k.Close, &k.Volume,
+			&k.QuoteVolume, &k.Trades, &k.TakerBuyBaseVolume, &k.TakerBuyQuoteVolume); err != nil {
+			return nil, err
+		}
+		klines = append(klines, k)
+	}
+	return klines, rows.Err()
+}
+
+// FindGaps 检测 [start, end] 区间内缺失的K线区间
+// 区间开头早于最早一根已存K线的部分也视为缺口；最后一根之后到 end 的部分由调用方按需处理
+func (s *Store) FindGaps(symbol, interval string, start, end int64) ([]TimeRange, error) {
+	step, ok := intervalDurations[interval]
+	if !ok {
+		return nil, fmt.Errorf("不支持的K线周期: %s", interval)
+	}
+	klines, err := s.LoadKlines(symbol, interval, start, end)
+	if err != nil {
+		return nil, err
+	}
+	return findGaps(klines, step.Milliseconds(), start), nil
+}
+
+// findGaps 根据相邻K线的开盘时间找出缺口
+// 阈值取 1.5 个周期，兼容月线长度不固定的情况
+func findGaps(klines []Kline, step, start int64) []TimeRange {
+	var gaps []TimeRange
+	if len(klines) == 0 {
+		return gaps
+	}
+	tolerance := step * 3 / 2
+	if klines[0].OpenTime-start >= tolerance {
+		gaps = append(gaps, TimeRange{Start: start, End: klines[0].OpenTime - 1})
+	}
+	for i := 1; i < len(klines); i++ {
+		if klines[i].OpenTime-klines[i-1].OpenTime >= tolerance {
+			gaps = append(gaps, TimeRange{Start: klines[i-1].OpenTime + 1, End: klines[i].OpenTime - 1})
+		}
+	}
+	return gaps
+}
+
+// Backfill 返回最近 lookback 根K线：优先读取本地存储，缺失的区间通过REST补齐并写回存储
+// 返回结果包含尚未收盘的当前K线（不落盘）
+func (s *Store) Backfill(client *APIClient, symbol, interval string, lookback int) ([]Kline, error) {
+	symbol = strings.ToUpper(symbol)
+	stepDuration, ok := intervalDurations[interval]
+	if !ok {
+		return nil, fmt.Errorf("不支持的K线周期: %s", interval)
+	}
+	step := stepDuration.Milliseconds()
+	now := time.Now().UnixMilli()
+	start := now - int64(lookback)*step
+
+	stored, err := s.LoadKlines(symbol, interval, start, now)
+	if err != nil {
+		return nil, err
+	}
+
+	// 本地没有数据：直接从交易所拉取最近 lookback 根
+	if len(stored) == 0 {
+		klines, err := client.GetRecentKlines(symbol, interval, lookback)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.SaveKlines(symbol, interval, klines); err != nil {
+			log.Printf("⚠️  %v", err)
+		}
+		return klines, nil
+	}
+
+	gaps := findGaps(stored, step, start)
+	key := klineKey(symbol, interval)
+	if listed, ok := s.listedFrom.Load(key); ok && len(gaps) > 0 && gaps[0].Start == start && gaps[0].End < listed.(int64) {
+		gaps = gaps[1:] // 新上市币种，更早的K线本就不存在
+	}
+	// 最后一根已存K线之后（含当前未收盘K线）总是重新获取
+	gaps = append(gaps, TimeRange{Start: stored[len(stored)-1].OpenTime + 1, End: now})
+
+	var fetched []Kline
+	for i, gap := range gaps {
+		klines, err := client.GetKlinesRange(symbol, interval, gap.Start, gap.End)
+		if err != nil {
+			return nil, fmt.Errorf("回补%s %s K线缺口失败: %w", symbol, interval, err)
+		}
+		if i == 0 && gap.Start == start && len(klines) == 0 {
+			s.listedFrom.Store(key, stored[0].OpenTime)
+		}
+		fetched = append(fetched, klines...)
+	}
+	if len(gaps) > 1 {
+		log.Printf("🔄 回补 %s %s K线缺口 %d 处，共 %d 根", symbol, interval, len(gaps)-1, len(fetched))
+	}
+	if err := s.SaveKlines(symbol, interval, fetched); err != nil {
+		log.Printf("⚠️  %v", err)
+	}
+
+	klines := mergeKlines(stored, fetched)
+	if len(klines) > lookback {
+		klines = klines[len(klines)-lookback:]
+	}
+	return klines, nil
+}
+
+// mergeKlines 按开盘时间合并两组K线（后者覆盖前者），结果升序
+func mergeKlines(base, updates []Kline) []Kline {
+	byTime := make(map[int64]Kline, len(base)+len(updates))
+	for _, k := range base {
+		byTime[k.OpenTime] = k
+	}
+	for _, k := range updates {
+		byTime[k.OpenTime] = k
+	}
+	merged := make([]Kline, 0, len(byTime))
+	for _, k := range byTime {
+		merged = append(merged, k)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].OpenTime < merged[j].OpenTime })
+	return merged
+}
+
+// SaveOpenInterest 异步记录持仓量采样
+func (s *Store) SaveOpenInterest(symbol string, t int64, openInterest float64) {
+	s.enqueue(`INSERT OR REPLACE INTO open_interest (symbol, time, open_interest) VALUES (?, ?, ?)`,
+		strings.ToUpper(symbol), t, openInterest)
+}
+
+// LoadOpenInterest 读取 [start, end] 区间内的持仓量采样
+func (s *Store) LoadOpenInterest(symbol string, start, end int64) ([]OpenInterestPoint, error) {
+	rows, err := s.db.Query(`
+		SELECT time, open_interest FROM open_interest
+		WHERE symbol = ? AND time >= ? AND time <= ?
+		ORDER BY time
+	`, strings.ToUpper(symbol), start, end)
+	if err != nil {
+		return nil, fmt.Errorf("读取%s持仓量失败: %w", symbol, err)
+	}
+	defer rows.Close()
+
+	var points []OpenInterestPoint
+	for rows.Next() {
+		var p OpenInterestPoint
+		if err := rows.Scan(&p.Time, &p.OpenInterest); err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
+// SaveFundingRate 异步记录资金费率采样
+func (s *Store) SaveFundingRate(symbol string, t int64, rate, markPrice float64) {
+	s.enqueue(`INSERT OR REPLACE INTO funding_rates (symbol, time, funding_rate, mark_price) VALUES (?, ?, ?, ?)`,
+		strings.ToUpper(symbol), t, rate, markPrice)
+}
+
+// LoadFundingRates 读取 [start, end] 区间内的资金费率采样
+func (s *Store) LoadFundingRates(symbol string, start, end int64) ([]FundingRatePoint, error) {
+	rows, err := s.db.Query(`
+		SELECT time, funding_rate, mark_price FROM funding_rates
+		WHERE symbol = ? AND time >= ? AND time <= ?
+		ORDER BY time
+	`, strings.ToUpper(symbol), start, end)
+	if err != nil {
+		return nil, fmt.Errorf("读取%s资金费率失败: %w", symbol, err)
+	}
+	defer rows.Close()
+
+	var points []FundingRatePoint
+	for rows.Next() {
+		var p FundingRatePoint
+		if err := rows.Scan(&p.Time, &p.FundingRate, &p.MarkPrice); err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
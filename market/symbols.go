@@ -0,0 +1,77 @@
+package market
+
+import "strings"
+
+// hyperliquidKilo Hyperliquid 以 "k" 前缀表示1000倍计价单位（kPEPE），对应币安的 1000PEPEUSDT
+const hyperliquidKilo = "1000"
+
+// SymbolRegistry 交易所的交易对注册表
+// 候选币种使用标准交易对（币安格式，如 1000PEPEUSDT），下单和查询行情前需转换为交易所的交易对（如 Hyperliquid 的 kPEPE）；
+// 不在注册表中的交易对视为该交易所不可交易
+type SymbolRegistry struct {
+	exchange    string
+	toVenue     map[string]string // 标准交易对 -> 交易所交易对
+	toCanonical map[string]string // 交易所交易对 -> 标准交易对
+}
+
+// NewSymbolRegistry 根据 标准交易对 -> 交易所交易对 的映射创建注册表
+func NewSymbolRegistry(exchange string, mapping map[string]string) *SymbolRegistry {
+	r := &SymbolRegistry{
+		exchange:    exchange,
+		toVenue:     make(map[string]string, len(mapping)),
+		toCanonical: make(map[string]string, len(mapping)),
+	}
+	for canonical, venue := range mapping {
+		canonical = strings.ToUpper(canonical)
+		r.toVenue[canonical] = venue
+		r.toCanonical[venue] = canonical
+	}
+	return r
+}
+
+// Exchange 注册表所属交易所
+func (r *SymbolRegistry) Exchange() string { return r.exchange }
+
+// Len 可交易的交易对数量
+func (r *SymbolRegistry) Len() int { return len(r.toVenue) }
+
+// VenueSymbol 标准交易对在交易所的名称
+func (r *SymbolRegistry) VenueSymbol(symbol string) (string, bool) {
+	venue, ok := r.toVenue[strings.ToUpper(symbol)]
+	return venue, ok
+}
+
+// Canonical 交易所交易对对应的标准交易对
+func (r *SymbolRegistry) Canonical(venue string) (string, bool) {
+	canonical, ok := r.toCanonical[venue]
+	return canonical, ok
+}
+
+// Tradable 标准交易对在交易所是否可交易
+func (r *SymbolRegistry) Tradable(symbol string) bool {
+	_, ok := r.toVenue[strings.ToUpper(symbol)]
+	return ok
+}
+
+// HyperliquidCoin 标准交易对转换为Hyperliquid币种名（没有注册表时的默认规则）
+// 例如 "BTCUSDT" -> "BTC"，"1000PEPEUSDT" -> "kPEPE"
+func HyperliquidCoin(symbol string) string {
+	base := strings.TrimSuffix(strings.ToUpper(symbol), "USDT")
+	if rest := strings.TrimPrefix(base, hyperliquidKilo); rest != base && rest != "" && !startsWithDigit(rest) {
+		return "k" + rest
+	}
+	return base
+}
+
+// HyperliquidCanonical Hyperliquid币种名转换为标准交易对
+// 例如 "BTC" -> "BTCUSDT"，"kPEPE" -> "1000PEPEUSDT"
+func HyperliquidCanonical(coin string) string {
+	if len(coin) > 1 && coin[0] == 'k' && coin[1] >= 'A' && coin[1] <= 'Z' {
+		return hyperliquidKilo + coin[1:] + "USDT"
+	}
+	return strings.ToUpper(coin) + "USDT"
+}
+
+func startsWithDigit(s string) bool {
+	return s != "" && s[0] >= '0' && s[0] <= '9'
+}
@@ -0,0 +1,209 @@
+package market
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"nofx/market/indicator"
+)
+
+const (
+	defaultKlineLimit = 100  // 默认每个周期保留的K线数量
+	maxKlineLimit     = 1500 // Binance 单次最多返回1500根K线
+	seriesPoints      = 10   // 输出给AI的序列长度
+)
+
+// intervalDurations 支持的K线周期（Binance 合约）
+var intervalDurations = map[string]time.Duration{
+	"1m":  time.Minute,
+	"3m":  3 * time.Minute,
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"30m": 30 * time.Minute,
+	"1h":  time.Hour,
+	"2h":  2 * time.Hour,
+	"4h":  4 * time.Hour,
+	"6h":  6 * time.Hour,
+	"8h":  8 * time.Hour,
+	"12h": 12 * time.Hour,
+	"1d":  24 * time.Hour,
+	"3d":  3 * 24 * time.Hour,
+	"1w":  7 * 24 * time.Hour,
+	"1M":  30 * 24 * time.Hour,
+}
+
+// Timeframe 一个需要的K线周期及回看长度
+type Timeframe struct {
+	Interval string `json:"interval"` // K线周期，如 "15m"、"1h"
+	Lookback int    `json:"lookback"` // 回看K线数量（用于计算指标）
+}
+
+// String 以 "15m:100" 形式输出
+func (tf Timeframe) String() string {
+	return fmt.Sprintf("%s:%d", tf.Interval, tf.Lookback)
+}
+
+// IsValidInterval 是否为支持的K线周期
+func IsValidInterval(interval string) bool {
+	_, ok := intervalDurations[interval]
+	return ok
+}
+
+// ParseTimeframes 解析周期配置，格式: "15m:100,1h:60,1d"（省略回看长度时使用默认值100）
+// 同一周期出现多次时取最大的回看长度，结果按周期从短到长排序
+// 回看长度上限为 maxStoreLookback；未启用本地存储时获取K线会截断到 maxKlineLimit
+func ParseTimeframes(spec string) ([]Timeframe, error) {
+	byInterval := make(map[string]int)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		interval, lookbackStr, hasLookback := strings.Cut(part, ":")
+		interval = strings.TrimSpace(interval)
+		if !IsValidInterval(interval) {
+			return nil, fmt.Errorf("不支持的K线周期: %s", interval)
+		}
+
+		lookback := defaultKlineLimit
+		if hasLookback {
+			n, err := strconv.Atoi(strings.TrimSpace(lookbackStr))
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("无效的回看长度: %s", part)
+			}
+			if n > maxStoreLookback {
+				return nil, fmt.Errorf("回看长度不能超过%d: %s", maxStoreLookback, part)
+			}
+			lookback = n
+		}
+
+		if lookback > byInterval[interval] {
+			byInterval[interval] = lookback
+		}
+	}
+
+	timeframes := make([]Timeframe, 0, len(byInterval))
+	for interval, lookback := range byInterval {
+		timeframes = append(timeframes, Timeframe{Interval: interval, Lookback: lookback})
+	}
+	sortTimeframes(timeframes)
+	return timeframes, nil
+}
+
+// FormatTimeframes 把周期列表输出为配置字符串（ParseTimeframes 的逆操作）
+func FormatTimeframes(timeframes []Timeframe) string {
+	parts := make([]string, len(timeframes))
+	for i, tf := range timeframes {
+		parts[i] = tf.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// sortTimeframes 按周期从短到长排序
+func sortTimeframes(timeframes []Timeframe) {
+	sort.Slice(timeframes, func(i, j int) bool {
+		return intervalDurations[timeframes[i].Interval] < intervalDurations[timeframes[j].Interval]
+	})
+}
+
+// calculateTimeframeData 根据K线计算某个周期的指标与序列
+func calculateTimeframeData(interval string, klines []Kline) *TimeframeData {
+	data := &TimeframeData{
+		Interval: interval,
+		Candles:  len(klines),
+	}
+	if len(klines) == 0 {
+		return data
+	}
+
+	last := klines[len(klines)-1]
+	first := klines[0]
+	data.CurrentPrice = last.Close
+	if first.Open > 0 {
+		data.PriceChange = (last.Close - first.Open) / first.Open * 100
+	}
+
+	ema20 := indicator.NewEMA(20)
+	ema50 := indicator.NewEMA(50)
+	macd := indicator.NewMACD(12, 26, 9)
+	rsi7 := indicator.NewRSI(7)
+	rsi14 := indicator.NewRSI(14)
+	atr14 := indicator.NewATR(14)
+
+	start := len(klines) - seriesPoints
+	volumeSum := 0.0
+	for i, bar := range toBars(klines) {
+		ema20.Update(bar)
+		ema50.Update(bar)
+		macd.Update(bar)
+		rsi7.Update(bar)
+		rsi14.Update(bar)
+		atr14.Update(bar)
+		volumeSum += bar.Volume
+		if i < start {
+			continue
+		}
+
+		data.ClosePrices = append(data.ClosePrices, bar.Close)
+		if ema20.Ready() {
+			data.EMA20Values = append(data.EMA20Values, ema20.Value())
+		}
+		if macd.LineReady() {
+			data.MACDValues = append(data.MACDValues, macd.Line())
+		}
+		if rsi14.Ready() {
+			data.RSI14Values = append(data.RSI14Values, rsi14.Value())
+		}
+	}
+
+	data.EMA20 = readyValue(ema20.Ready(), ema20.Value())
+	data.EMA50 = readyValue(ema50.Ready(), ema50.Value())
+	data.MACD = readyValue(macd.LineReady(), macd.Line())
+	data.RSI7 = readyValue(rsi7.Ready(), rsi7.Value())
+	data.RSI14 = readyValue(rsi14.Ready(), rsi14.Value())
+	data.ATR14 = readyValue(atr14.Ready(), atr14.Value())
+	data.CurrentVolume = last.Volume
+	data.AverageVolume = volumeSum / float64(len(klines))
+
+	return data
+}
+
+// formatTimeframes 输出额外周期的数据（按周期从短到长）
+func formatTimeframes(sb *strings.Builder, timeframes map[string]*TimeframeData) {
+	intervals := make([]string, 0, len(timeframes))
+	for interval := range timeframes {
+		intervals = append(intervals, interval)
+	}
+	sort.Slice(intervals, func(i, j int) bool {
+		return intervalDurations[intervals[i]] < intervalDurations[intervals[j]]
+	})
+
+	for _, interval := range intervals {
+		tf := timeframes[interval]
+		sb.WriteString(fmt.Sprintf("Additional timeframe (%s candles, %d candles lookback, oldest → latest):\n\n", tf.Interval, tf.Candles))
+
+		sb.WriteString(fmt.Sprintf("Price change over lookback: %+.2f%%\n\n", tf.PriceChange))
+
+		sb.WriteString(fmt.Sprintf("20‑Period EMA: %.3f vs. 50‑Period EMA: %.3f, MACD: %.3f, RSI (7): %.3f, RSI (14): %.3f, 14‑Period ATR: %.3f\n\n",
+			tf.EMA20, tf.EMA50, tf.MACD, tf.RSI7, tf.RSI14, tf.ATR14))
+
+		sb.WriteString(fmt.Sprintf("Current Volume: %.3f vs. Average Volume: %.3f\n\n", tf.CurrentVolume, tf.AverageVolume))
+
+		if len(tf.ClosePrices) > 0 {
+			sb.WriteString(fmt.Sprintf("Close prices: %s\n\n", formatFloatSlice(tf.ClosePrices)))
+		}
+		if len(tf.EMA20Values) > 0 {
+			sb.WriteString(fmt.Sprintf("EMA indicators (20‑period): %s\n\n", formatFloatSlice(tf.EMA20Values)))
+		}
+		if len(tf.MACDValues) > 0 {
+			sb.WriteString(fmt.Sprintf("MACD indicators: %s\n\n", formatFloatSlice(tf.MACDValues)))
+		}
+		if len(tf.RSI14Values) > 0 {
+			sb.WriteString(fmt.Sprintf("RSI indicators (14‑Period): %s\n\n", formatFloatSlice(tf.RSI14Values)))
+		}
+	}
+}
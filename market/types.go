@@ -1,6 +1,10 @@
 package market
 
-import "time"
+import (
+	"time"
+
+	"nofx/market/indicator"
+)
 
 // Data 市场数据结构
 type Data struct {
@@ -15,6 +19,10 @@ type Data struct {
 	FundingRate       float64
 	IntradaySeries    *IntradayData
 	LongerTermContext *LongerTermData
+	Timeframes        map[string]*TimeframeData      // 交易员/模板额外声明的周期（key 为周期，如 "15m"）
+	Indicators        map[string][]*indicator.Series // 模板声明的额外技术指标（key 为周期）
+	Depth             *DepthData                     // 订单簿流动性（获取失败时为 nil）
+	Derivatives       *DerivativesData               // 强平、多空比、主动买卖量（行情来源不支持时为 nil）
 }
 
 // OIData Open Interest数据
@@ -44,6 +52,26 @@ type LongerTermData struct {
 	RSI14Values   []float64
 }
 
+// TimeframeData 额外周期的数据
+type TimeframeData struct {
+	Interval      string
+	Candles       int // 参与计算的K线数量
+	CurrentPrice  float64
+	PriceChange   float64 // 回看区间内的价格变化百分比
+	EMA20         float64
+	EMA50         float64
+	MACD          float64
+	RSI7          float64
+	RSI14         float64
+	ATR14         float64
+	CurrentVolume float64
+	AverageVolume float64
+	ClosePrices   []float64
+	EMA20Values   []float64
+	MACDValues    []float64
+	RSI14Values   []float64
+}
+
 // Binance API 响应结构
 type ExchangeInfo struct {
 	Symbols []SymbolInfo `json:"symbols"`
@@ -57,6 +85,7 @@ type SymbolInfo struct {
 	ContractType      string `json:"contractType"`
 	PricePrecision    int    `json:"pricePrecision"`
 	QuantityPrecision int    `json:"quantityPrecision"`
+	OnboardDate       int64  `json:"onboardDate"` // 上线时间（毫秒）
 }
 
 type Kline struct {
@@ -86,6 +115,16 @@ type Ticker24hr struct {
 	PriceChangePercent string `json:"priceChangePercent"`
 	Volume             string `json:"volume"`
 	QuoteVolume        string `json:"quoteVolume"`
+	LastPrice          string `json:"lastPrice"`
+}
+
+// BookTicker 最优挂单（买一/卖一）
+type BookTicker struct {
+	Symbol   string `json:"symbol"`
+	BidPrice string `json:"bidPrice"`
+	BidQty   string `json:"bidQty"`
+	AskPrice string `json:"askPrice"`
+	AskQty   string `json:"askQty"`
 }
 
 // 特征数据结构
@@ -131,6 +170,8 @@ type AlertThresholds struct {
 	VolumeTrend      float64 `json:"volume_trend"`
 	RSIOverbought    float64 `json:"rsi_overbought"`
 	RSIOversold      float64 `json:"rsi_oversold"`
+	FundingExtreme   float64 `json:"funding_extreme"` // 资金费率绝对值（每个结算周期）
+	OIJump           float64 `json:"oi_jump"`         // 持仓量在一个轮询间隔内的变化比例
 }
 type CleanupConfig struct {
 	InactiveTimeout   time.Duration `json:"inactive_timeout"`    // 不活跃超时时间
@@ -146,6 +187,8 @@ var config = Config{
 		VolumeTrend:      2.0,
 		RSIOverbought:    70,
 		RSIOversold:      30,
+		FundingExtreme:   0.001,
+		OIJump:           0.05,
 	},
 	CleanupConfig: CleanupConfig{
 		InactiveTimeout:   30 * time.Minute,
@@ -2,11 +2,14 @@ package mcp
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -27,16 +30,18 @@ type Client struct {
 	BaseURL    string
 	Model      string
 	Timeout    time.Duration
-	UseFullURL bool // 是否使用完整URL（不添加/chat/completions）
+	UseFullURL bool   // 是否使用完整URL（不添加/chat/completions）
+	RecordDir  string // 录制目录（非空时把每次请求/响应写入磁盘，用于回放）
 }
 
 func New() *Client {
 	// 默认配置
 	return &Client{
-		Provider: ProviderDeepSeek,
-		BaseURL:  "https://api.deepseek.com/v1",
-		Model:    "deepseek-chat",
-		Timeout:  120 * time.Second, // 增加到120秒，因为AI需要分析大量数据
+		Provider:  ProviderDeepSeek,
+		BaseURL:   "https://api.deepseek.com/v1",
+		Model:     "deepseek-chat",
+		Timeout:   120 * time.Second, // 增加到120秒，因为AI需要分析大量数据
+		RecordDir: getDefaultRecordDir(),
 	}
 }
 
@@ -116,12 +121,34 @@ func (client *Client) SetClient(Client Client) {
 	client = &Client
 }
 
+// CallStats 单次AI调用的统计信息
+type CallStats struct {
+	QueueWait time.Duration `json:"queue_wait"` // 在限流器中排队等待的总时长（含429退避）
+	RetryWait time.Duration `json:"retry_wait"` // 网络错误重试前的等待时长
+	Attempts  int           `json:"attempts"`   // 实际请求次数
+}
+
 // CallWithMessages 使用 system + user prompt 调用AI API（推荐）
 func (client *Client) CallWithMessages(systemPrompt, userPrompt string) (string, error) {
+	result, _, err := client.CallWithMessagesStats(systemPrompt, userPrompt)
+	return result, err
+}
+
+// CallWithMessagesStats 与 CallWithMessages 相同，额外返回排队/重试统计
+// 所有请求都经过共享限流器（按 provider + API Key 区分）
+func (client *Client) CallWithMessagesStats(systemPrompt, userPrompt string) (string, *CallStats, error) {
+	return client.call(context.Background(), systemPrompt, userPrompt, nil)
+}
+
+// call 带重试的AI调用；onDelta 非空时使用流式请求，ctx 取消时立即中止
+func (client *Client) call(ctx context.Context, systemPrompt, userPrompt string, onDelta StreamHandler) (string, *CallStats, error) {
+	stats := &CallStats{}
 	if client.APIKey == "" {
-		return "", fmt.Errorf("AI API密钥未设置，请先调用 SetDeepSeekAPIKey() 或 SetQwenAPIKey()")
+		return "", stats, fmt.Errorf("AI API密钥未设置，请先调用 SetDeepSeekAPIKey() 或 SetQwenAPIKey()")
 	}
 
+	limiter := limiterFor(client.Provider, client.APIKey)
+
 	// 重试配置
 	maxRetries := 3
 	var lastErr error
@@ -131,33 +158,69 @@ func (client *Client) CallWithMessages(systemPrompt, userPrompt string) (string,
 			fmt.Printf("⚠️  AI API调用失败，正在重试 (%d/%d)...\n", attempt, maxRetries)
 		}
 
-		result, err := client.callOnce(systemPrompt, userPrompt)
+		wait, release, err := limiter.Acquire(ctx)
+		stats.QueueWait += wait
+		if err != nil {
+			return "", stats, fmt.Errorf("AI请求已中止: %w", err)
+		}
+		if wait >= time.Second {
+			log.Printf("⏳ [MCP] AI请求排队等待 %v", wait.Round(time.Millisecond))
+		}
+		stats.Attempts++
+		var result string
+		if onDelta != nil {
+			if attempt > 1 {
+				onDelta(StreamDelta{Restart: true}) // 重试会从头输出，通知订阅方丢弃之前的片段
+			}
+			result, err = client.callStream(ctx, systemPrompt, userPrompt, onDelta)
+		} else {
+			result, err = client.callOnce(ctx, systemPrompt, userPrompt)
+		}
+		release()
+
 		if err == nil {
 			if attempt > 1 {
 				fmt.Printf("✓ AI API重试成功\n")
 			}
-			return result, nil
+			if client.RecordDir != "" {
+				client.saveRecording(systemPrompt, userPrompt, result)
+			}
+			return result, stats, nil
 		}
 
 		lastErr = err
-		// 如果不是网络错误，不重试
+		// 如果不是可重试的错误，不重试
 		if !isRetryableError(err) {
-			return "", err
+			return "", stats, err
+		}
+
+		if attempt >= maxRetries {
+			break
+		}
+
+		// 429：让共享限流器整体退避，下一次 Acquire 会自动等待
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests {
+			limiter.Penalize(backoffDuration(attempt, apiErr))
+			continue
 		}
 
 		// 重试前等待
-		if attempt < maxRetries {
-			waitTime := time.Duration(attempt) * 2 * time.Second
-			fmt.Printf("⏳ 等待%v后重试...\n", waitTime)
-			time.Sleep(waitTime)
+		waitTime := backoffDuration(attempt, apiErr)
+		fmt.Printf("⏳ 等待%v后重试...\n", waitTime)
+		stats.RetryWait += waitTime
+		select {
+		case <-time.After(waitTime):
+		case <-ctx.Done():
+			return "", stats, fmt.Errorf("AI请求已中止: %w", ctx.Err())
 		}
 	}
 
-	return "", fmt.Errorf("重试%d次后仍然失败: %w", maxRetries, lastErr)
+	return "", stats, fmt.Errorf("重试%d次后仍然失败: %w", maxRetries, lastErr)
 }
 
 // callOnce 单次调用AI API（内部使用）
-func (client *Client) callOnce(systemPrompt, userPrompt string) (string, error) {
+func (client *Client) callOnce(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
 	// 打印当前 AI 配置
 	log.Printf("📡 [MCP] AI 请求配置:")
 	log.Printf("   Provider: %s", client.Provider)
@@ -168,6 +231,38 @@ func (client *Client) callOnce(systemPrompt, userPrompt string) (string, error)
 		log.Printf("   API Key: %s...%s", client.APIKey[:4], client.APIKey[len(client.APIKey)-4:])
 	}
 
+	req, err := client.newChatRequest(ctx, systemPrompt, userPrompt, false)
+	if err != nil {
+		return "", err
+	}
+
+	// 发送请求
+	httpClient := &http.Client{Timeout: client.Timeout}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 读取响应
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", &APIError{
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+
+	return parseChatResponse(body)
+}
+
+// newChatRequest 构建 chat/completions 请求
+func (client *Client) newChatRequest(ctx context.Context, systemPrompt, userPrompt string, stream bool) (*http.Request, error) {
 	// 构建 messages 数组
 	messages := []map[string]string{}
 
@@ -192,13 +287,16 @@ func (client *Client) callOnce(systemPrompt, userPrompt string) (string, error)
 		"temperature": 0.5, // 降低temperature以提高JSON格式稳定性
 		"max_tokens":  2000,
 	}
+	if stream {
+		requestBody["stream"] = true
+	}
 
 	// 注意：response_format 参数仅 OpenAI 支持，DeepSeek/Qwen 不支持
 	// 我们通过强化 prompt 和后处理来确保 JSON 格式正确
 
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
-		return "", fmt.Errorf("序列化请求失败: %w", err)
+		return nil, fmt.Errorf("序列化请求失败: %w", err)
 	}
 
 	// 创建HTTP请求
@@ -212,12 +310,15 @@ func (client *Client) callOnce(systemPrompt, userPrompt string) (string, error)
 	}
 	log.Printf("📡 [MCP] 请求 URL: %s", url)
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", fmt.Errorf("创建请求失败: %w", err)
+		return nil, fmt.Errorf("创建请求失败: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
 
 	// 根据不同的Provider设置认证方式
 	switch client.Provider {
@@ -231,25 +332,11 @@ func (client *Client) callOnce(systemPrompt, userPrompt string) (string, error)
 		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", client.APIKey))
 	}
 
-	// 发送请求
-	httpClient := &http.Client{Timeout: client.Timeout}
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("发送请求失败: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// 读取响应
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("读取响应失败: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API返回错误 (status %d): %s", resp.StatusCode, string(body))
-	}
+	return req, nil
+}
 
-	// 解析响应
+// parseChatResponse 解析非流式的 chat/completions 响应
+func parseChatResponse(body []byte) (string, error) {
 	var result struct {
 		Choices []struct {
 			Message struct {
@@ -269,8 +356,71 @@ func (client *Client) callOnce(systemPrompt, userPrompt string) (string, error)
 	return result.Choices[0].Message.Content, nil
 }
 
+// APIError AI API返回的非200错误
+type APIError struct {
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration // 服务端通过 Retry-After 头建议的等待时长（0表示未提供）
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API返回错误 (status %d): %s", e.StatusCode, e.Body)
+}
+
+// maxRetryAfter Retry-After 的上限，避免异常值让交易周期长时间卡住
+const maxRetryAfter = 2 * time.Minute
+
+// parseRetryAfter 解析 Retry-After 头（秒数或HTTP日期）
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// backoffDuration 计算重试等待时长
+// 优先使用服务端的 Retry-After；429 未提供时指数退避（5s、10s、20s...）；其他错误线性退避
+func backoffDuration(attempt int, apiErr *APIError) time.Duration {
+	var wait time.Duration
+	switch {
+	case apiErr != nil && apiErr.RetryAfter > 0:
+		wait = apiErr.RetryAfter
+	case apiErr != nil && apiErr.StatusCode == http.StatusTooManyRequests:
+		wait = time.Duration(5*(1<<(attempt-1))) * time.Second
+	default:
+		wait = time.Duration(attempt) * 2 * time.Second
+	}
+	if wait > maxRetryAfter {
+		wait = maxRetryAfter
+	}
+	return wait
+}
+
 // isRetryableError 判断错误是否可重试
 func isRetryableError(err error) bool {
+	// 调用方主动中止的请求不重试；流式响应卡住则重新请求
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, ErrStreamIdle) {
+		return true
+	}
+
+	// 429限流和5xx服务端错误可以重试
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+	}
+
 	errStr := err.Error()
 	// 网络错误、超时、EOF等可以重试
 	retryableErrors := []string{
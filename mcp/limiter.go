@@ -0,0 +1,171 @@
+package mcp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"sync"
+	"time"
+)
+
+// 默认限流配置（所有使用同一 provider + API Key 的交易员共享）
+const (
+	DefaultRequestsPerMinute = 20 // 每分钟最多请求数
+	DefaultMaxInFlight       = 2  // 同时进行中的最大请求数
+)
+
+// Limiter 共享的AI请求限流器
+// 同一个 provider + API Key 的所有调用共用一个实例，同时限制RPM和并发数，
+// 并在收到429时让所有调用方一起退避
+type Limiter struct {
+	key string
+
+	mu            sync.Mutex
+	changed       chan struct{} // 并发槽位释放或限流参数变化时关闭并替换，唤醒所有等待者
+	rpm           int           // 每分钟最多请求数（<=0 表示不限制）
+	maxInFlight   int           // 最大并发数（<=0 表示不限制）
+	inFlight      int           // 当前进行中的请求数
+	recent        []time.Time   // 最近一分钟内的请求时间（滑动窗口）
+	cooldownUntil time.Time     // 429退避截止时间
+}
+
+var (
+	limitersMu         sync.Mutex
+	limiters           = make(map[string]*Limiter)
+	defaultRPM         = DefaultRequestsPerMinute
+	defaultMaxInFlight = DefaultMaxInFlight
+)
+
+// SetDefaultLimits 设置全局限流参数（对已创建的限流器同样生效）
+func SetDefaultLimits(requestsPerMinute, maxInFlight int) {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+
+	defaultRPM = requestsPerMinute
+	defaultMaxInFlight = maxInFlight
+	for _, l := range limiters {
+		l.setLimits(requestsPerMinute, maxInFlight)
+	}
+	log.Printf("🔧 [MCP] AI请求限流: %d 次/分钟, 最大并发 %d", requestsPerMinute, maxInFlight)
+}
+
+// limiterFor 获取（或创建）指定 provider + API Key 的共享限流器
+func limiterFor(provider Provider, apiKey string) *Limiter {
+	sum := sha256.Sum256([]byte(apiKey))
+	key := string(provider) + ":" + hex.EncodeToString(sum[:8])
+
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+
+	if l, ok := limiters[key]; ok {
+		return l
+	}
+	l := &Limiter{
+		key:         key,
+		rpm:         defaultRPM,
+		maxInFlight: defaultMaxInFlight,
+		changed:     make(chan struct{}),
+	}
+	limiters[key] = l
+	return l
+}
+
+// setLimits 更新限流参数
+func (l *Limiter) setLimits(requestsPerMinute, maxInFlight int) {
+	l.mu.Lock()
+	l.rpm = requestsPerMinute
+	l.maxInFlight = maxInFlight
+	l.notifyLocked()
+	l.mu.Unlock()
+}
+
+// notifyLocked 唤醒所有等待并发槽位的调用方（需持有锁）
+func (l *Limiter) notifyLocked() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// Acquire 阻塞直到获得请求配额，返回排队等待时长和释放函数
+// ctx 取消时立即返回错误（不占用配额，释放函数为空操作）
+func (l *Limiter) Acquire(ctx context.Context) (time.Duration, func(), error) {
+	start := time.Now()
+
+	// 1. 并发槽位
+	l.mu.Lock()
+	for l.maxInFlight > 0 && l.inFlight >= l.maxInFlight {
+		changed := l.changed
+		l.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return time.Since(start), func() {}, ctx.Err()
+		}
+		l.mu.Lock()
+	}
+	l.inFlight++
+	l.mu.Unlock()
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			l.mu.Lock()
+			l.inFlight--
+			l.notifyLocked()
+			l.mu.Unlock()
+		})
+	}
+
+	// 2. 速率配额（含429退避）
+	for {
+		l.mu.Lock()
+		wait := l.reserve(time.Now())
+		l.mu.Unlock()
+		if wait <= 0 {
+			break
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			release()
+			return time.Since(start), func() {}, ctx.Err()
+		}
+	}
+	return time.Since(start), release, nil
+}
+
+// reserve 尝试占用一个请求配额，成功返回0，否则返回还需等待的时长（需持有锁）
+func (l *Limiter) reserve(now time.Time) time.Duration {
+	if now.Before(l.cooldownUntil) {
+		return l.cooldownUntil.Sub(now)
+	}
+
+	// 清理滑动窗口外的记录
+	cutoff := now.Add(-time.Minute)
+	i := 0
+	for i < len(l.recent) && !l.recent[i].After(cutoff) {
+		i++
+	}
+	l.recent = l.recent[i:]
+
+	if l.rpm > 0 && len(l.recent) >= l.rpm {
+		return l.recent[0].Add(time.Minute).Sub(now)
+	}
+
+	l.recent = append(l.recent, now)
+	return 0
+}
+
+// Penalize 收到429后暂停该限流器下的所有请求
+func (l *Limiter) Penalize(retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	until := time.Now().Add(retryAfter)
+	if until.After(l.cooldownUntil) {
+		l.cooldownUntil = until
+		log.Printf("⏸  [MCP] 触发限流(429)，%s 暂停请求 %v", l.key, retryAfter)
+	}
+}
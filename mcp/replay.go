@@ -0,0 +1,194 @@
+package mcp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AIClient AI调用接口（真实网络客户端与回放客户端都实现该接口）
+type AIClient interface {
+	CallWithMessages(systemPrompt, userPrompt string) (string, error)
+	CallWithMessagesStats(systemPrompt, userPrompt string) (string, *CallStats, error)
+}
+
+var (
+	_ AIClient = (*Client)(nil)
+	_ AIClient = (*ReplayClient)(nil)
+)
+
+// Recording 一条录制的AI请求/响应
+type Recording struct {
+	Key          string    `json:"key"`
+	Provider     Provider  `json:"provider"`
+	Model        string    `json:"model"`
+	SystemPrompt string    `json:"system_prompt"`
+	UserPrompt   string    `json:"user_prompt"`
+	Response     string    `json:"response"`
+	RecordedAt   time.Time `json:"recorded_at"`
+}
+
+var (
+	dirMu            sync.RWMutex
+	defaultRecordDir string
+)
+
+// SetRecordDir 设置全局录制目录（为空则关闭录制），对之后创建的客户端生效
+func SetRecordDir(dir string) {
+	dirMu.Lock()
+	defaultRecordDir = dir
+	dirMu.Unlock()
+	if dir != "" {
+		log.Printf("📼 [MCP] 已开启AI响应录制: %s", dir)
+	}
+}
+
+// getDefaultRecordDir 获取全局录制目录
+func getDefaultRecordDir() string {
+	dirMu.RLock()
+	defer dirMu.RUnlock()
+	return defaultRecordDir
+}
+
+// RecordingKey 计算录制键：sha256(model + system prompt + user prompt)
+func RecordingKey(model, systemPrompt, userPrompt string) string {
+	h := sha256.New()
+	h.Write([]byte(model))
+	h.Write([]byte{0})
+	h.Write([]byte(systemPrompt))
+	h.Write([]byte{0})
+	h.Write([]byte(userPrompt))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// promptKey 不含模型的键（回放时未指定模型使用）
+func promptKey(systemPrompt, userPrompt string) string {
+	return RecordingKey("", systemPrompt, userPrompt)
+}
+
+// SetRecordDir 设置该客户端的录制目录（为空则关闭录制）
+func (client *Client) SetRecordDir(dir string) {
+	client.RecordDir = dir
+}
+
+// saveRecording 将一次成功的请求/响应写入录制目录
+func (client *Client) saveRecording(systemPrompt, userPrompt, response string) {
+	if err := os.MkdirAll(client.RecordDir, 0755); err != nil {
+		log.Printf("⚠️  [MCP] 创建录制目录失败: %v", err)
+		return
+	}
+
+	rec := Recording{
+		Key:          RecordingKey(client.Model, systemPrompt, userPrompt),
+		Provider:     client.Provider,
+		Model:        client.Model,
+		SystemPrompt: systemPrompt,
+		UserPrompt:   userPrompt,
+		Response:     response,
+		RecordedAt:   time.Now(),
+	}
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		log.Printf("⚠️  [MCP] 序列化录制数据失败: %v", err)
+		return
+	}
+
+	path := filepath.Join(client.RecordDir, rec.Key+".json")
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		log.Printf("⚠️  [MCP] 写入录制文件失败: %v", err)
+		return
+	}
+	log.Printf("📼 [MCP] 已录制AI响应: %s", rec.Key[:12])
+}
+
+// ReplayClient 回放客户端：从录制目录返回历史响应，不访问网络
+// 用于离线、确定性地重跑过去的决策周期
+type ReplayClient struct {
+	Dir   string
+	Model string // 为空时只按提示词匹配（忽略录制时的模型）
+
+	byKey    map[string]*Recording
+	byPrompt map[string]*Recording
+}
+
+// NewReplayClient 加载录制目录并创建回放客户端
+func NewReplayClient(dir, model string) (*ReplayClient, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取录制目录失败: %w", err)
+	}
+
+	rc := &ReplayClient{
+		Dir:      dir,
+		Model:    model,
+		byKey:    make(map[string]*Recording),
+		byPrompt: make(map[string]*Recording),
+	}
+
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			continue
+		}
+		var rec Recording
+		if err := json.Unmarshal(data, &rec); err != nil {
+			continue
+		}
+
+		// 始终按内容重新计算键，避免手工修改文件后键不一致
+		r := rec
+		r.Key = RecordingKey(rec.Model, rec.SystemPrompt, rec.UserPrompt)
+		rc.byKey[r.Key] = &r
+		pk := promptKey(rec.SystemPrompt, rec.UserPrompt)
+		if existing, ok := rc.byPrompt[pk]; !ok || rec.RecordedAt.After(existing.RecordedAt) {
+			rc.byPrompt[pk] = &r
+		}
+	}
+
+	log.Printf("📼 [MCP] 回放模式: 从 %s 加载 %d 条录制", dir, len(rc.byKey))
+	return rc, nil
+}
+
+// Len 已加载的录制条数
+func (rc *ReplayClient) Len() int {
+	return len(rc.byKey)
+}
+
+// Lookup 查找录制的响应
+func (rc *ReplayClient) Lookup(systemPrompt, userPrompt string) (*Recording, bool) {
+	if rc.Model != "" {
+		rec, ok := rc.byKey[RecordingKey(rc.Model, systemPrompt, userPrompt)]
+		return rec, ok
+	}
+	rec, ok := rc.byPrompt[promptKey(systemPrompt, userPrompt)]
+	return rec, ok
+}
+
+// CallWithMessages 返回录制的响应
+func (rc *ReplayClient) CallWithMessages(systemPrompt, userPrompt string) (string, error) {
+	result, _, err := rc.CallWithMessagesStats(systemPrompt, userPrompt)
+	return result, err
+}
+
+// CallWithMessagesStats 返回录制的响应（统计信息中不含排队时间）
+func (rc *ReplayClient) CallWithMessagesStats(systemPrompt, userPrompt string) (string, *CallStats, error) {
+	stats := &CallStats{Attempts: 1}
+	rec, ok := rc.Lookup(systemPrompt, userPrompt)
+	if !ok {
+		return "", stats, fmt.Errorf("回放未命中: 录制目录 %s 中没有匹配的请求 (key: %s)",
+			rc.Dir, RecordingKey(rc.Model, systemPrompt, userPrompt)[:12])
+	}
+	log.Printf("📼 [MCP] 回放AI响应: %s (录制于 %s)", rec.Key[:12], rec.RecordedAt.Format("2006-01-02 15:04:05"))
+	return rec.Response, stats, nil
+}
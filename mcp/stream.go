@@ -0,0 +1,203 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultStreamIdleTimeout 流式响应的默认空闲超时：超过该时长没有收到任何数据即中止并重试
+const DefaultStreamIdleTimeout = 90 * time.Second
+
+// ErrStreamIdle 流式响应长时间没有数据（服务端卡住）
+var ErrStreamIdle = errors.New("流式响应长时间无数据")
+
+// StreamDelta 流式响应中的一段增量
+type StreamDelta struct {
+	Content   string `json:"content,omitempty"`   // 正文增量
+	Reasoning string `json:"reasoning,omitempty"` // 推理过程增量（如 deepseek-reasoner 的 reasoning_content）
+	Restart   bool   `json:"restart,omitempty"`   // 请求重试，之前收到的增量作废
+}
+
+// StreamHandler 接收流式增量的回调（在请求所在的goroutine中同步调用）
+type StreamHandler func(delta StreamDelta)
+
+// StreamingClient 支持流式输出和中止的AI客户端
+type StreamingClient interface {
+	AIClient
+	// CallWithMessagesStream 流式调用AI，onDelta 为 nil 时退化为普通请求；ctx 取消即中止请求
+	CallWithMessagesStream(ctx context.Context, systemPrompt, userPrompt string, onDelta StreamHandler) (string, *CallStats, error)
+}
+
+var _ StreamingClient = (*Client)(nil)
+
+var (
+	streamMu          sync.RWMutex
+	streamingEnabled  = true
+	streamIdleTimeout = DefaultStreamIdleTimeout
+)
+
+// SetStreaming 设置是否使用流式请求及空闲超时（idleTimeout<=0 使用默认值）
+func SetStreaming(enabled bool, idleTimeout time.Duration) {
+	if idleTimeout <= 0 {
+		idleTimeout = DefaultStreamIdleTimeout
+	}
+	streamMu.Lock()
+	streamingEnabled = enabled
+	streamIdleTimeout = idleTimeout
+	streamMu.Unlock()
+	log.Printf("🔧 [MCP] AI流式输出: %t (空闲超时 %v)", enabled, idleTimeout)
+}
+
+// StreamingEnabled 是否使用流式请求
+func StreamingEnabled() bool {
+	streamMu.RLock()
+	defer streamMu.RUnlock()
+	return streamingEnabled
+}
+
+// getStreamIdleTimeout 获取流式响应空闲超时
+func getStreamIdleTimeout() time.Duration {
+	streamMu.RLock()
+	defer streamMu.RUnlock()
+	return streamIdleTimeout
+}
+
+// CallWithMessagesStream 流式调用AI API，每收到一段增量就回调 onDelta
+// 与 CallWithMessagesStats 共用限流与重试逻辑，返回完整的正文
+func (client *Client) CallWithMessagesStream(ctx context.Context, systemPrompt, userPrompt string, onDelta StreamHandler) (string, *CallStats, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return client.call(ctx, systemPrompt, userPrompt, onDelta)
+}
+
+// callStream 单次流式调用（内部使用）
+// 不设置整体超时（推理模型可能持续输出数分钟），改为空闲超时：长时间无数据则中止
+func (client *Client) callStream(ctx context.Context, systemPrompt, userPrompt string, onDelta StreamHandler) (string, error) {
+	log.Printf("📡 [MCP] AI 流式请求: %s / %s", client.Provider, client.Model)
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	idleTimeout := getStreamIdleTimeout()
+	var idle int32
+	timer := time.AfterFunc(idleTimeout, func() {
+		atomic.StoreInt32(&idle, 1)
+		cancel()
+	})
+	defer timer.Stop()
+
+	// wrapErr 区分调用方中止、空闲超时与普通网络错误
+	wrapErr := func(prefix string, err error) error {
+		if ctx.Err() != nil {
+			return fmt.Errorf("AI请求已中止: %w", ctx.Err())
+		}
+		if atomic.LoadInt32(&idle) == 1 {
+			return fmt.Errorf("%s: 超过%v %w", prefix, idleTimeout, ErrStreamIdle)
+		}
+		return fmt.Errorf("%s: %w", prefix, err)
+	}
+
+	req, err := client.newChatRequest(streamCtx, systemPrompt, userPrompt, true)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", wrapErr("发送请求失败", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", &APIError{
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+
+	// 部分兼容接口会忽略 stream 参数，直接返回完整JSON
+	if !strings.Contains(resp.Header.Get("Content-Type"), "text/event-stream") {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return "", wrapErr("读取响应失败", err)
+		}
+		content, err := parseChatResponse(body)
+		if err != nil {
+			return "", err
+		}
+		onDelta(StreamDelta{Content: content})
+		return content, nil
+	}
+
+	var content strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		timer.Reset(idleTimeout)
+
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue // 空行、注释（": keep-alive"）及 event/id 字段
+		}
+		payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if payload == "[DONE]" {
+			break
+		}
+
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content          string `json:"content"`
+					ReasoningContent string `json:"reasoning_content"`
+				} `json:"delta"`
+			} `json:"choices"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(payload), &chunk); err != nil {
+			log.Printf("⚠️  [MCP] 跳过无法解析的流式数据: %v", err)
+			continue
+		}
+		if chunk.Error != nil {
+			return "", fmt.Errorf("流式响应返回错误: %s", chunk.Error.Message)
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+
+		delta := StreamDelta{
+			Content:   chunk.Choices[0].Delta.Content,
+			Reasoning: chunk.Choices[0].Delta.ReasoningContent,
+		}
+		if delta.Content == "" && delta.Reasoning == "" {
+			continue
+		}
+		content.WriteString(delta.Content)
+		onDelta(delta)
+	}
+	if err := scanner.Err(); err != nil {
+		return "", wrapErr("读取流式响应失败", err)
+	}
+	if ctx.Err() != nil || atomic.LoadInt32(&idle) == 1 {
+		return "", wrapErr("读取流式响应失败", io.ErrUnexpectedEOF)
+	}
+
+	if content.Len() == 0 {
+		return "", fmt.Errorf("API返回空响应")
+	}
+	return content.String(), nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"log"
+	"nofx/config"
+	"nofx/manager"
+	"nofx/market"
+	"nofx/mcpserver"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// runMCPStdio 以MCP stdio模式运行（供Claude Desktop等MCP客户端以子进程方式启动）
+// stdout 专用于协议消息，所有日志与打印输出都被重定向到 stderr
+func runMCPStdio(args []string) {
+	protocolOut := os.Stdout
+	os.Stdout = os.Stderr
+	log.SetOutput(os.Stderr)
+
+	dbPath := "config.db"
+	if len(args) > 0 {
+		dbPath = args[0]
+	}
+
+	log.Printf("📋 [MCP] 初始化配置数据库: %s", dbPath)
+	database, err := config.NewDatabase(dbPath)
+	if err != nil {
+		log.Fatalf("❌ 初始化数据库失败: %v", err)
+	}
+	defer database.Close()
+
+	// 加载交易员（仅用于查询与手动操作，不会启动AI决策循环）
+	traderManager := manager.NewTraderManager()
+	if err := traderManager.LoadTradersFromDatabase(database); err != nil {
+		log.Fatalf("❌ 加载交易员失败: %v", err)
+	}
+
+	// 行情数据（get_market_data 依赖）
+	go market.NewWSMonitor(150).Start(database.GetCustomCoins())
+
+	allowTradingStr, _ := database.GetSystemConfig("mcp_allow_trading")
+	server := mcpserver.New(traderManager, database, allowTradingStr == "true")
+	log.Printf("🔌 [MCP] stdio服务端已启动（交易工具: %t）", server.AllowTrading())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		cancel()
+		os.Stdin.Close()
+	}()
+
+	if err := server.ServeStdio(ctx, os.Stdin, protocolOut); err != nil && err != context.Canceled {
+		log.Printf("❌ [MCP] stdio服务端错误: %v", err)
+	}
+	log.Printf("👋 [MCP] stdio服务端已退出")
+}
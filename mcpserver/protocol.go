@@ -0,0 +1,83 @@
+package mcpserver
+
+import "encoding/json"
+
+// ProtocolVersion 默认的MCP协议版本
+const ProtocolVersion = "2024-11-05"
+
+// supportedProtocolVersions 可协商的协议版本（客户端请求其中之一时原样回显）
+var supportedProtocolVersions = map[string]bool{
+	"2024-11-05": true,
+	"2025-03-26": true,
+	"2025-06-18": true,
+}
+
+// JSON-RPC 2.0 错误码
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+
+	codeNotInitialized = -32002 // 会话未完成 initialize 握手
+)
+
+// Request JSON-RPC请求（ID为空表示通知，不需要响应）
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// isNotification 是否为通知消息
+func (r *Request) isNotification() bool {
+	return len(r.ID) == 0 || string(r.ID) == "null"
+}
+
+// Response JSON-RPC响应
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+// RPCError JSON-RPC错误
+type RPCError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// Tool MCP工具描述
+type Tool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"inputSchema"`
+}
+
+// Content 工具返回的内容块（目前只使用text类型）
+type Content struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// ToolResult tools/call 的返回结果
+type ToolResult struct {
+	Content []Content `json:"content"`
+	IsError bool      `json:"isError,omitempty"`
+}
+
+// initializeParams initialize 请求参数
+type initializeParams struct {
+	ProtocolVersion string                 `json:"protocolVersion"`
+	ClientInfo      map[string]interface{} `json:"clientInfo"`
+}
+
+// callToolParams tools/call 请求参数
+type callToolParams struct {
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments"`
+}
@@ -0,0 +1,318 @@
+package mcpserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"nofx/auth"
+	"nofx/config"
+	"nofx/manager"
+	"nofx/trader"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// serverName / serverVersion initialize 时返回的服务端信息
+const (
+	serverName    = "nofx"
+	serverVersion = "1.0.0"
+)
+
+// Session 一个MCP客户端会话
+// UserID为空表示本地stdio会话，可访问所有已加载的交易员
+type Session struct {
+	ID     string
+	UserID string
+
+	mu          sync.Mutex // SSE 传输可能并发投递消息
+	initialized bool       // 是否已完成 initialize 握手
+}
+
+// setInitialized 标记会话已完成 initialize 握手
+func (sess *Session) setInitialized() {
+	sess.mu.Lock()
+	sess.initialized = true
+	sess.mu.Unlock()
+}
+
+// isInitialized 会话是否已完成 initialize 握手
+func (sess *Session) isInitialized() bool {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	return sess.initialized
+}
+
+// toolHandler 工具处理函数，返回值为字符串时原样输出，否则序列化为JSON
+type toolHandler func(sess *Session, args map[string]interface{}) (interface{}, error)
+
+// toolDef 已注册的工具
+type toolDef struct {
+	Tool
+	guarded bool // 是否为交易类工具（需要 mcp_allow_trading 开启）
+	handler toolHandler
+}
+
+// Server MCP服务端（与传输层无关，stdio和HTTP/SSE共用）
+type Server struct {
+	traderManager *manager.TraderManager
+	database      *config.Database
+	allowTrading  bool
+	tools         []*toolDef
+	toolIndex     map[string]*toolDef
+
+	sseSessions map[string]*sseSession // SSE会话表 (session_id -> 会话)
+	sseMu       sync.RWMutex
+}
+
+// New 创建MCP服务端
+// allowTrading 为 false 时不暴露任何下单/平仓工具
+func New(traderManager *manager.TraderManager, database *config.Database, allowTrading bool) *Server {
+	s := &Server{
+		traderManager: traderManager,
+		database:      database,
+		allowTrading:  allowTrading,
+		toolIndex:     make(map[string]*toolDef),
+		sseSessions:   make(map[string]*sseSession),
+	}
+	s.registerTools()
+	return s
+}
+
+// AllowTrading 是否开放了交易类工具
+func (s *Server) AllowTrading() bool {
+	return s.allowTrading
+}
+
+// register 注册工具
+func (s *Server) register(def *toolDef) {
+	s.tools = append(s.tools, def)
+	s.toolIndex[def.Name] = def
+}
+
+// Dispatch 处理一条原始JSON-RPC消息（支持批量），返回需要回写的响应
+// 全部为通知时返回nil
+func (s *Server) Dispatch(sess *Session, raw []byte) []byte {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return nil
+	}
+
+	// 批量请求
+	if raw[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(raw, &batch); err != nil {
+			return marshalResponse(errorResponse(nil, codeParseError, "解析JSON失败: "+err.Error()))
+		}
+		var responses []*Response
+		for _, item := range batch {
+			if resp := s.handleRaw(sess, item); resp != nil {
+				responses = append(responses, resp)
+			}
+		}
+		if len(responses) == 0 {
+			return nil
+		}
+		data, _ := json.Marshal(responses)
+		return data
+	}
+
+	if resp := s.handleRaw(sess, raw); resp != nil {
+		return marshalResponse(resp)
+	}
+	return nil
+}
+
+// handleRaw 解析并处理单条消息
+func (s *Server) handleRaw(sess *Session, raw []byte) *Response {
+	var req Request
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return errorResponse(nil, codeParseError, "解析JSON失败: "+err.Error())
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		// 客户端对服务端请求的响应（本服务端不发起请求）直接忽略
+		if req.Method == "" && !req.isNotification() {
+			return nil
+		}
+		return errorResponse(req.ID, codeInvalidRequest, "无效的JSON-RPC请求")
+	}
+
+	result, rpcErr := s.handleRequest(sess, &req)
+	if req.isNotification() {
+		return nil
+	}
+	if rpcErr != nil {
+		return &Response{JSONRPC: "2.0", ID: req.ID, Error: rpcErr}
+	}
+	return &Response{JSONRPC: "2.0", ID: req.ID, Result: result}
+}
+
+// handleRequest 按方法分发
+func (s *Server) handleRequest(sess *Session, req *Request) (interface{}, *RPCError) {
+	// 握手前只接受 initialize、ping 和通知
+	if req.Method != "initialize" && req.Method != "ping" && !strings.HasPrefix(req.Method, "notifications/") && !sess.isInitialized() {
+		return nil, &RPCError{Code: codeNotInitialized, Message: "会话尚未初始化，请先调用 initialize"}
+	}
+
+	switch req.Method {
+	case "initialize":
+		var params initializeParams
+		if len(req.Params) > 0 {
+			if err := json.Unmarshal(req.Params, &params); err != nil {
+				return nil, &RPCError{Code: codeInvalidParams, Message: "无效的initialize参数: " + err.Error()}
+			}
+		}
+		version := ProtocolVersion
+		if supportedProtocolVersions[params.ProtocolVersion] {
+			version = params.ProtocolVersion
+		}
+		sess.setInitialized()
+		log.Printf("🔌 MCP会话已初始化 [%s] 客户端: %v 协议: %s", sess.ID, params.ClientInfo["name"], version)
+		return map[string]interface{}{
+			"protocolVersion": version,
+			"capabilities": map[string]interface{}{
+				"tools": map[string]interface{}{"listChanged": false},
+			},
+			"serverInfo": map[string]interface{}{
+				"name":    serverName,
+				"version": serverVersion,
+			},
+			"instructions": s.instructions(),
+		}, nil
+
+	case "notifications/initialized", "notifications/cancelled":
+		return nil, nil
+
+	case "ping":
+		return map[string]interface{}{}, nil
+
+	case "tools/list":
+		tools := make([]Tool, 0, len(s.tools))
+		for _, def := range s.tools {
+			if def.guarded && !s.allowTrading {
+				continue
+			}
+			tools = append(tools, def.Tool)
+		}
+		return map[string]interface{}{"tools": tools}, nil
+
+	case "tools/call":
+		var params callToolParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &RPCError{Code: codeInvalidParams, Message: "无效的tools/call参数: " + err.Error()}
+		}
+		def, ok := s.toolIndex[params.Name]
+		if !ok || (def.guarded && !s.allowTrading) {
+			return nil, &RPCError{Code: codeInvalidParams, Message: fmt.Sprintf("未知的工具: %s", params.Name)}
+		}
+		if params.Arguments == nil {
+			params.Arguments = map[string]interface{}{}
+		}
+		return s.callTool(sess, def, params.Arguments), nil
+
+	default:
+		return nil, &RPCError{Code: codeMethodNotFound, Message: fmt.Sprintf("不支持的方法: %s", req.Method)}
+	}
+}
+
+// callTool 执行工具，工具内部错误以 isError 结果返回（而不是协议错误），便于模型自行纠正
+func (s *Server) callTool(sess *Session, def *toolDef, args map[string]interface{}) *ToolResult {
+	output, err := def.handler(sess, args)
+	if err != nil {
+		log.Printf("⚠️  MCP工具 %s 调用失败: %v", def.Name, err)
+		return &ToolResult{Content: []Content{{Type: "text", Text: err.Error()}}, IsError: true}
+	}
+
+	var text string
+	switch v := output.(type) {
+	case string:
+		text = v
+	default:
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return &ToolResult{Content: []Content{{Type: "text", Text: "序列化结果失败: " + err.Error()}}, IsError: true}
+		}
+		text = string(data)
+	}
+	return &ToolResult{Content: []Content{{Type: "text", Text: text}}}
+}
+
+// instructions 提供给客户端的使用说明
+func (s *Server) instructions() string {
+	var sb strings.Builder
+	sb.WriteString("nofx AI交易系统。可查询交易员状态、账户、持仓、决策日志、历史表现以及实时行情。")
+	sb.WriteString("大多数工具需要 trader_id，可先调用 list_traders 获取。")
+	if s.allowTrading {
+		sb.WriteString("交易类工具（open_position / close_position）会真实下单，必须传入 confirm=true 才会执行，否则仅返回预览。")
+	} else {
+		sb.WriteString("当前为只读模式，未开放交易类工具。")
+	}
+	return sb.String()
+}
+
+// accessibleTraderIDs 当前会话可访问的交易员ID（已排序）
+func (s *Server) accessibleTraderIDs(sess *Session) []string {
+	var ids []string
+	if sess.UserID == "" || s.database == nil || auth.IsAdminMode() {
+		ids = s.traderManager.GetTraderIDs()
+	} else {
+		// 确保用户的交易员已加载到内存中
+		if err := s.traderManager.LoadUserTraders(s.database, sess.UserID); err != nil {
+			log.Printf("⚠️ 加载用户 %s 的交易员失败: %v", sess.UserID, err)
+		}
+		records, err := s.database.GetTraders(sess.UserID)
+		if err != nil {
+			log.Printf("⚠️ 获取用户 %s 的交易员列表失败: %v", sess.UserID, err)
+			return nil
+		}
+		for _, record := range records {
+			ids = append(ids, record.ID)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// resolveTrader 根据参数获取交易员（未指定且只有一个可用交易员时自动选择）
+func (s *Server) resolveTrader(sess *Session, args map[string]interface{}) (*trader.AutoTrader, error) {
+	ids := s.accessibleTraderIDs(sess)
+	traderID := argString(args, "trader_id")
+
+	if traderID == "" {
+		if len(ids) != 1 {
+			return nil, fmt.Errorf("请指定trader_id（可用: %s）", strings.Join(ids, ", "))
+		}
+		traderID = ids[0]
+	}
+
+	allowed := false
+	for _, id := range ids {
+		if id == traderID {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, fmt.Errorf("trader ID '%s' 不存在或无权访问", traderID)
+	}
+
+	return s.traderManager.GetTrader(traderID)
+}
+
+// marshalResponse 序列化响应
+func marshalResponse(resp *Response) []byte {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		data, _ = json.Marshal(errorResponse(resp.ID, codeInternalError, "序列化响应失败: "+err.Error()))
+	}
+	return data
+}
+
+// errorResponse 构造错误响应
+func errorResponse(id json.RawMessage, code int, message string) *Response {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	return &Response{JSONRPC: "2.0", ID: id, Error: &RPCError{Code: code, Message: message}}
+}
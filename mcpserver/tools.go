@@ -0,0 +1,427 @@
+package mcpserver
+
+import (
+	"fmt"
+	"nofx/decision"
+	"nofx/market"
+	"nofx/market/indicator"
+	"strconv"
+	"strings"
+)
+
+// traderIDProperty 通用的trader_id参数描述
+var traderIDProperty = map[string]interface{}{
+	"type":        "string",
+	"description": "交易员ID（可通过 list_traders 获取；只有一个交易员时可省略）",
+}
+
+// objectSchema 构造JSON Schema对象
+func objectSchema(properties map[string]interface{}, required ...string) map[string]interface{} {
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// registerTools 注册所有工具
+func (s *Server) registerTools() {
+	// ========== 只读工具 ==========
+	s.register(&toolDef{
+		Tool: Tool{
+			Name:        "list_traders",
+			Description: "列出当前可访问的所有AI交易员及其运行状态",
+			InputSchema: objectSchema(map[string]interface{}{}),
+		},
+		handler: s.toolListTraders,
+	})
+
+	s.register(&toolDef{
+		Tool: Tool{
+			Name:        "get_trader_status",
+			Description: "获取交易员的运行状态（是否运行、运行时长、AI调用次数、扫描间隔等）",
+			InputSchema: objectSchema(map[string]interface{}{"trader_id": traderIDProperty}),
+		},
+		handler: func(sess *Session, args map[string]interface{}) (interface{}, error) {
+			at, err := s.resolveTrader(sess, args)
+			if err != nil {
+				return nil, err
+			}
+			return at.GetStatus(), nil
+		},
+	})
+
+	s.register(&toolDef{
+		Tool: Tool{
+			Name:        "get_account",
+			Description: "获取交易员的账户信息（净值、可用余额、总盈亏、保证金使用率）",
+			InputSchema: objectSchema(map[string]interface{}{"trader_id": traderIDProperty}),
+		},
+		handler: func(sess *Session, args map[string]interface{}) (interface{}, error) {
+			at, err := s.resolveTrader(sess, args)
+			if err != nil {
+				return nil, err
+			}
+			return at.GetAccountInfo()
+		},
+	})
+
+	s.register(&toolDef{
+		Tool: Tool{
+			Name:        "get_positions",
+			Description: "获取交易员当前持仓列表",
+			InputSchema: objectSchema(map[string]interface{}{"trader_id": traderIDProperty}),
+		},
+		handler: func(sess *Session, args map[string]interface{}) (interface{}, error) {
+			at, err := s.resolveTrader(sess, args)
+			if err != nil {
+				return nil, err
+			}
+			positions, err := at.GetPositions()
+			if err != nil {
+				return nil, err
+			}
+			if positions == nil {
+				positions = []map[string]interface{}{}
+			}
+			return positions, nil
+		},
+	})
+
+	s.register(&toolDef{
+		Tool: Tool{
+			Name:        "get_decision_history",
+			Description: "获取交易员最近的AI决策记录（最新的在前），包含思维链、决策和执行结果",
+			InputSchema: objectSchema(map[string]interface{}{
+				"trader_id": traderIDProperty,
+				"limit": map[string]interface{}{
+					"type":        "integer",
+					"description": "返回条数，默认10，最大100",
+				},
+				"include_prompts": map[string]interface{}{
+					"type":        "boolean",
+					"description": "是否包含完整的系统提示词和输入提示词（内容较长），默认false",
+				},
+			}),
+		},
+		handler: s.toolDecisionHistory,
+	})
+
+	s.register(&toolDef{
+		Tool: Tool{
+			Name:        "get_performance",
+			Description: "分析交易员的历史表现（胜率、盈亏比、年化夏普/索提诺/卡玛比率、最大回撤、多空及时段统计、各币种表现、最近交易）",
+			InputSchema: objectSchema(map[string]interface{}{
+				"trader_id": traderIDProperty,
+				"lookback_cycles": map[string]interface{}{
+					"type":        "integer",
+					"description": "分析最近多少个决策周期，默认100",
+				},
+			}),
+		},
+		handler: func(sess *Session, args map[string]interface{}) (interface{}, error) {
+			at, err := s.resolveTrader(sess, args)
+			if err != nil {
+				return nil, err
+			}
+			lookback := argInt(args, "lookback_cycles", 100)
+			if lookback <= 0 {
+				lookback = 100
+			}
+			return at.GetDecisionLogger().AnalyzePerformance(lookback)
+		},
+	})
+
+	s.register(&toolDef{
+		Tool: Tool{
+			Name:        "get_market_data",
+			Description: "获取币种的实时行情与技术指标（价格、EMA、MACD、RSI、持仓量、资金费率、3分钟与4小时序列），格式与AI决策时看到的一致",
+			InputSchema: objectSchema(map[string]interface{}{
+				"symbol": map[string]interface{}{
+					"type":        "string",
+					"description": "币种，如 BTC 或 BTCUSDT",
+				},
+				"timeframes": map[string]interface{}{
+					"type":        "string",
+					"description": "额外K线周期及回看长度，如 \"15m:100,1h:60\"（可选）",
+				},
+				"exchange": map[string]interface{}{
+					"type":        "string",
+					"enum":        []string{"binance", "hyperliquid", "aster"},
+					"description": "行情来源交易所（可选，默认 binance）",
+				},
+				"indicators": map[string]interface{}{
+					"type":        "string",
+					"description": "额外技术指标，如 \"bb(20,2),adx(14),supertrend\"（可选，支持 sma/ema/rsi/macd/atr/bb/keltner/supertrend/adx/stochrsi/obv/vwap）",
+				},
+			}, "symbol"),
+		},
+		handler: func(sess *Session, args map[string]interface{}) (interface{}, error) {
+			symbol := argString(args, "symbol")
+			if symbol == "" {
+				return nil, fmt.Errorf("symbol不能为空")
+			}
+			timeframes, err := market.ParseTimeframes(argString(args, "timeframes"))
+			if err != nil {
+				return nil, err
+			}
+			indicators, err := indicator.ParseSpecs(argString(args, "indicators"))
+			if err != nil {
+				return nil, err
+			}
+			provider := market.ProviderFor(argString(args, "exchange"), false)
+			if provider == market.Binance && market.WSMonitorCli == nil {
+				return nil, fmt.Errorf("行情监控未启动")
+			}
+			data, err := market.GetWithOptions(symbol, market.Options{
+				Provider:   provider,
+				Timeframes: timeframes,
+				Indicators: indicators,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("获取%s市场数据失败: %w", symbol, err)
+			}
+			return market.Format(data), nil
+		},
+	})
+
+	// ========== 交易工具（需开启 mcp_allow_trading）==========
+	s.register(&toolDef{
+		Tool: Tool{
+			Name:        "open_position",
+			Description: "手动开仓（真实下单）。与AI决策使用同一套风控校验（杠杆上限、仓位上限、风险回报比≥1:3）。必须传入 confirm=true 才会执行，否则仅返回预览",
+			InputSchema: objectSchema(map[string]interface{}{
+				"trader_id": traderIDProperty,
+				"symbol":    map[string]interface{}{"type": "string", "description": "币种，如 BTCUSDT"},
+				"side": map[string]interface{}{
+					"type": "string",
+					"enum": []string{"long", "short"},
+				},
+				"position_size_usd": map[string]interface{}{"type": "number", "description": "仓位价值（USDT）"},
+				"leverage":          map[string]interface{}{"type": "integer", "description": "杠杆倍数"},
+				"stop_loss":         map[string]interface{}{"type": "number", "description": "止损价"},
+				"take_profit":       map[string]interface{}{"type": "number", "description": "止盈价"},
+				"reasoning":         map[string]interface{}{"type": "string", "description": "开仓理由（记录到决策日志）"},
+				"confirm":           map[string]interface{}{"type": "boolean", "description": "确认执行，必须为true"},
+			}, "symbol", "side", "position_size_usd", "leverage", "stop_loss", "take_profit"),
+		},
+		guarded: true,
+		handler: s.toolOpenPosition,
+	})
+
+	s.register(&toolDef{
+		Tool: Tool{
+			Name:        "close_position",
+			Description: "手动平仓（真实下单）。必须传入 confirm=true 才会执行，否则仅返回预览",
+			InputSchema: objectSchema(map[string]interface{}{
+				"trader_id": traderIDProperty,
+				"symbol":    map[string]interface{}{"type": "string", "description": "币种，如 BTCUSDT"},
+				"side": map[string]interface{}{
+					"type": "string",
+					"enum": []string{"long", "short"},
+				},
+				"reasoning": map[string]interface{}{"type": "string", "description": "平仓理由（记录到决策日志）"},
+				"confirm":   map[string]interface{}{"type": "boolean", "description": "确认执行，必须为true"},
+			}, "symbol", "side"),
+		},
+		guarded: true,
+		handler: s.toolClosePosition,
+	})
+}
+
+// toolListTraders 列出可访问的交易员
+func (s *Server) toolListTraders(sess *Session, args map[string]interface{}) (interface{}, error) {
+	var result []map[string]interface{}
+	for _, id := range s.accessibleTraderIDs(sess) {
+		at, err := s.traderManager.GetTrader(id)
+		if err != nil {
+			continue
+		}
+		status := at.GetStatus()
+		result = append(result, map[string]interface{}{
+			"trader_id":       at.GetID(),
+			"trader_name":     at.GetName(),
+			"ai_model":        at.GetAIModel(),
+			"exchange":        at.GetExchange(),
+			"is_running":      status["is_running"],
+			"call_count":      status["call_count"],
+			"initial_balance": status["initial_balance"],
+			"prompt_template": at.GetSystemPromptTemplate(),
+		})
+	}
+	if result == nil {
+		result = []map[string]interface{}{}
+	}
+	return result, nil
+}
+
+// toolDecisionHistory 最近的决策记录（最新的在前）
+func (s *Server) toolDecisionHistory(sess *Session, args map[string]interface{}) (interface{}, error) {
+	at, err := s.resolveTrader(sess, args)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := argInt(args, "limit", 10)
+	if limit <= 0 {
+		limit = 10
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	includePrompts := argBool(args, "include_prompts")
+
+	records, err := at.GetDecisionLogger().GetLatestRecords(limit)
+	if err != nil {
+		return nil, fmt.Errorf("获取决策日志失败: %w", err)
+	}
+
+	// GetLatestRecords 返回从旧到新，这里反转为最新在前
+	result := make([]interface{}, 0, len(records))
+	for i := len(records) - 1; i >= 0; i-- {
+		record := *records[i]
+		if !includePrompts {
+			record.SystemPrompt = ""
+			record.InputPrompt = ""
+		}
+		result = append(result, record)
+	}
+	return result, nil
+}
+
+// toolOpenPosition 手动开仓
+func (s *Server) toolOpenPosition(sess *Session, args map[string]interface{}) (interface{}, error) {
+	at, err := s.resolveTrader(sess, args)
+	if err != nil {
+		return nil, err
+	}
+
+	side := strings.ToLower(argString(args, "side"))
+	if side != "long" && side != "short" {
+		return nil, fmt.Errorf("side必须为long或short")
+	}
+
+	d := &decision.Decision{
+		Symbol:          argString(args, "symbol"),
+		Action:          "open_" + side,
+		Leverage:        argInt(args, "leverage", 0),
+		PositionSizeUSD: argFloat(args, "position_size_usd"),
+		StopLoss:        argFloat(args, "stop_loss"),
+		TakeProfit:      argFloat(args, "take_profit"),
+		Reasoning:       argString(args, "reasoning"),
+	}
+	if d.Symbol == "" {
+		return nil, fmt.Errorf("symbol不能为空")
+	}
+
+	return s.executeGuarded(sess, at.GetID(), args, d)
+}
+
+// toolClosePosition 手动平仓
+func (s *Server) toolClosePosition(sess *Session, args map[string]interface{}) (interface{}, error) {
+	at, err := s.resolveTrader(sess, args)
+	if err != nil {
+		return nil, err
+	}
+
+	side := strings.ToLower(argString(args, "side"))
+	if side != "long" && side != "short" {
+		return nil, fmt.Errorf("side必须为long或short")
+	}
+
+	d := &decision.Decision{
+		Symbol:    argString(args, "symbol"),
+		Action:    "close_" + side,
+		Reasoning: argString(args, "reasoning"),
+	}
+	if d.Symbol == "" {
+		return nil, fmt.Errorf("symbol不能为空")
+	}
+
+	return s.executeGuarded(sess, at.GetID(), args, d)
+}
+
+// executeGuarded 交易类工具的统一出口：未确认时只返回预览
+func (s *Server) executeGuarded(sess *Session, traderID string, args map[string]interface{}, d *decision.Decision) (interface{}, error) {
+	if !argBool(args, "confirm") {
+		return map[string]interface{}{
+			"executed":  false,
+			"trader_id": traderID,
+			"preview":   d,
+			"message":   "未执行：这是一个真实下单操作，请确认参数无误后携带 confirm=true 再次调用",
+		}, nil
+	}
+
+	at, err := s.traderManager.GetTrader(traderID)
+	if err != nil {
+		return nil, err
+	}
+
+	source := "mcp"
+	if sess.UserID != "" {
+		source = "mcp:" + sess.UserID
+	}
+	action, err := at.ExecuteManualDecision(d, source)
+	if err != nil {
+		return nil, fmt.Errorf("执行失败: %w", err)
+	}
+
+	return map[string]interface{}{
+		"executed":  true,
+		"trader_id": traderID,
+		"action":    action,
+	}, nil
+}
+
+// argString 读取字符串参数
+func argString(args map[string]interface{}, key string) string {
+	switch v := args[key].(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
+
+// argFloat 读取数值参数（兼容字符串形式的数字）
+func argFloat(args map[string]interface{}, key string) float64 {
+	switch v := args[key].(type) {
+	case float64:
+		return v
+	case string:
+		f, _ := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f
+	default:
+		return 0
+	}
+}
+
+// argInt 读取整数参数，缺省时返回默认值
+func argInt(args map[string]interface{}, key string, defaultValue int) int {
+	switch v := args[key].(type) {
+	case float64:
+		return int(v)
+	case string:
+		if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+			return n
+		}
+	}
+	return defaultValue
+}
+
+// argBool 读取布尔参数（兼容 "true" 字符串）
+func argBool(args map[string]interface{}, key string) bool {
+	switch v := args[key].(type) {
+	case bool:
+		return v
+	case string:
+		return strings.EqualFold(strings.TrimSpace(v), "true")
+	default:
+		return false
+	}
+}
@@ -0,0 +1,167 @@
+package mcpserver
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxMessageSize 单条消息的最大长度
+const maxMessageSize = 4 * 1024 * 1024
+
+// sseKeepAliveInterval SSE保活间隔（防止代理断开空闲连接）
+const sseKeepAliveInterval = 30 * time.Second
+
+// ServeStdio 以stdio传输运行：每行一条JSON-RPC消息
+// 注意：out 必须是独占的，任何日志都不能写入其中
+func (s *Server) ServeStdio(ctx context.Context, in io.Reader, out io.Writer) error {
+	sess := &Session{ID: "stdio"}
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+
+	writer := bufio.NewWriter(out)
+	for scanner.Scan() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		resp := s.Dispatch(sess, scanner.Bytes())
+		if resp == nil {
+			continue
+		}
+		if _, err := writer.Write(append(resp, '\n')); err != nil {
+			return fmt.Errorf("写入响应失败: %w", err)
+		}
+		if err := writer.Flush(); err != nil {
+			return fmt.Errorf("写入响应失败: %w", err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取stdin失败: %w", err)
+	}
+	return nil
+}
+
+// sseSession 一个SSE长连接会话
+type sseSession struct {
+	*Session
+	out  chan []byte
+	done chan struct{}
+}
+
+// HandleSSE GET /mcp/sse：建立SSE连接，首先推送 endpoint 事件告知客户端消息投递地址
+func (s *Server) HandleSSE(c *gin.Context) {
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "当前连接不支持流式响应"})
+		return
+	}
+
+	sess := &sseSession{
+		Session: &Session{ID: uuid.New().String(), UserID: c.GetString("user_id")},
+		out:     make(chan []byte, 64),
+		done:    make(chan struct{}),
+	}
+	s.sseMu.Lock()
+	s.sseSessions[sess.ID] = sess
+	s.sseMu.Unlock()
+
+	defer func() {
+		s.sseMu.Lock()
+		delete(s.sseSessions, sess.ID)
+		s.sseMu.Unlock()
+		close(sess.done)
+		log.Printf("🔌 MCP SSE会话已断开 [%s]", sess.ID)
+	}()
+
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.Header().Set("Connection", "keep-alive")
+	c.Writer.Header().Set("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	endpoint := strings.TrimSuffix(c.Request.URL.Path, "/sse") + "/message?session_id=" + sess.ID
+	fmt.Fprintf(c.Writer, "event: endpoint\ndata: %s\n\n", endpoint)
+	flusher.Flush()
+	log.Printf("🔌 MCP SSE会话已建立 [%s] 用户: %s", sess.ID, sess.UserID)
+
+	keepAlive := time.NewTicker(sseKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case msg := <-sess.out:
+			fmt.Fprintf(c.Writer, "event: message\ndata: %s\n\n", msg)
+			flusher.Flush()
+		case <-keepAlive.C:
+			fmt.Fprint(c.Writer, ": ping\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// HandleSSEMessage POST /mcp/message?session_id=xxx：接收客户端消息，响应通过SSE推送
+func (s *Server) HandleSSEMessage(c *gin.Context) {
+	sessionID := c.Query("session_id")
+	s.sseMu.RLock()
+	sess, ok := s.sseSessions[sessionID]
+	s.sseMu.RUnlock()
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "会话不存在或已断开"})
+		return
+	}
+	if sess.UserID != c.GetString("user_id") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权访问该会话"})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxMessageSize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取请求失败"})
+		return
+	}
+
+	resp := s.Dispatch(sess.Session, body)
+	if resp != nil {
+		select {
+		case sess.out <- resp:
+		case <-sess.done:
+			c.JSON(http.StatusGone, gin.H{"error": "会话已断开"})
+			return
+		}
+	}
+
+	c.Status(http.StatusAccepted)
+}
+
+// HandleHTTP POST /mcp：无状态的HTTP传输，直接在响应体中返回JSON-RPC结果
+// 适用于不需要服务端推送的简单客户端
+func (s *Server) HandleHTTP(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxMessageSize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取请求失败"})
+		return
+	}
+
+	// 无状态传输没有跨请求的握手，每个请求都视为已初始化
+	sess := &Session{ID: "http", UserID: c.GetString("user_id"), initialized: true}
+	resp := s.Dispatch(sess, body)
+	if resp == nil {
+		c.Status(http.StatusAccepted)
+		return
+	}
+	c.Data(http.StatusOK, "application/json", resp)
+}
@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
-	"net/http"
+	"nofx/market"
 	"os"
 	"path/filepath"
 	"strings"
@@ -30,13 +30,11 @@ type CoinPoolConfig struct {
 	Timeout         time.Duration
 	CacheDir        string
 	UseDefaultCoins bool // 是否使用默认主流币种
-}
 
-var coinPoolConfig = CoinPoolConfig{
-	APIURL:          "",
-	Timeout:         30 * time.Second, // 增加到30秒
-	CacheDir:        "coin_pool_cache",
-	UseDefaultCoins: false, // 默认不使用
+	OITopAPIURL     string        // OI Top API
+	RefreshInterval time.Duration // 合并结果的刷新间隔
+
+	Universe *UniverseFilters // 可交易池筛选条件（nil 表示不启用 universe 信号源）
 }
 
 // CoinPoolCache 币种池缓存
@@ -68,19 +66,19 @@ type CoinPoolAPIResponse struct {
 	} `json:"data"`
 }
 
-// SetCoinPoolAPI 设置币种池API
+// SetCoinPoolAPI 设置全局币种池的AI500 API（交易员使用各自的币种池实例）
 func SetCoinPoolAPI(apiURL string) {
-	coinPoolConfig.APIURL = apiURL
+	defaultPool.update(func(cfg *CoinPoolConfig) { cfg.APIURL = apiURL })
 }
 
-// SetOITopAPI 设置OI Top API
+// SetOITopAPI 设置全局币种池的OI Top API
 func SetOITopAPI(apiURL string) {
-	oiTopConfig.APIURL = apiURL
+	defaultPool.update(func(cfg *CoinPoolConfig) { cfg.OITopAPIURL = apiURL })
 }
 
-// SetUseDefaultCoins 设置是否使用默认主流币种
+// SetUseDefaultCoins 设置全局币种池是否使用默认主流币种
 func SetUseDefaultCoins(useDefault bool) {
-	coinPoolConfig.UseDefaultCoins = useDefault
+	defaultPool.update(func(cfg *CoinPoolConfig) { cfg.UseDefaultCoins = useDefault })
 }
 
 // SetDefaultCoins 设置默认主流币种列表
@@ -91,49 +89,44 @@ func SetDefaultCoins(coins []string) {
 	}
 }
 
-// GetCoinPool 获取币种池列表（带重试和缓存机制）
+// DefaultCoins 默认主流币种列表副本
+func DefaultCoins() []string {
+	return append([]string(nil), defaultMainstreamCoins...)
+}
+
+// GetCoinPool 获取全局币种池的AI500币种列表
 func GetCoinPool() ([]CoinInfo, error) {
+	return defaultPool.GetCoinPool()
+}
+
+// GetCoinPool 获取AI500币种列表（带重试和缓存机制）
+func (p *Pool) GetCoinPool() ([]CoinInfo, error) {
+	cfg := p.config()
 	// 优先检查是否启用默认币种列表
-	if coinPoolConfig.UseDefaultCoins {
+	if cfg.UseDefaultCoins {
 		log.Printf("✓ 已启用默认主流币种列表")
 		return convertSymbolsToCoins(defaultMainstreamCoins), nil
 	}
 
 	// 检查API URL是否配置
-	if strings.TrimSpace(coinPoolConfig.APIURL) == "" {
+	if strings.TrimSpace(cfg.APIURL) == "" {
 		log.Printf("⚠️  未配置币种池API URL，使用默认主流币种列表")
 		return convertSymbolsToCoins(defaultMainstreamCoins), nil
 	}
 
-	maxRetries := 3
-	var lastErr error
-
 	// 尝试从API获取
-	for attempt := 1; attempt <= maxRetries; attempt++ {
-		if attempt > 1 {
-			log.Printf("⚠️  第%d次重试获取币种池（共%d次）...", attempt, maxRetries)
-			time.Sleep(2 * time.Second) // 重试前等待2秒
+	coins, lastErr := fetchWithRetry("币种池", func() ([]CoinInfo, error) { return fetchCoinPool(cfg) })
+	if lastErr == nil {
+		// 成功获取后保存到缓存
+		if err := saveCoinPoolCache(cfg.CacheDir, coins); err != nil {
+			log.Printf("⚠️  保存币种池缓存失败: %v", err)
 		}
-
-		coins, err := fetchCoinPool()
-		if err == nil {
-			if attempt > 1 {
-				log.Printf("✓ 第%d次重试成功", attempt)
-			}
-			// 成功获取后保存到缓存
-			if err := saveCoinPoolCache(coins); err != nil {
-				log.Printf("⚠️  保存币种池缓存失败: %v", err)
-			}
-			return coins, nil
-		}
-
-		lastErr = err
-		log.Printf("❌ 第%d次请求失败: %v", attempt, err)
+		return coins, nil
 	}
 
 	// API获取失败，尝试使用缓存
 	log.Printf("⚠️  API请求全部失败，尝试使用历史缓存数据...")
-	cachedCoins, err := loadCoinPoolCache()
+	cachedCoins, err := loadCoinPoolCache(cfg.CacheDir)
 	if err == nil {
 		log.Printf("✓ 使用历史缓存数据（共%d个币种）", len(cachedCoins))
 		return cachedCoins, nil
@@ -145,32 +138,12 @@ func GetCoinPool() ([]CoinInfo, error) {
 }
 
 // fetchCoinPool 实际执行币种池请求
-func fetchCoinPool() ([]CoinInfo, error) {
+func fetchCoinPool(cfg CoinPoolConfig) ([]CoinInfo, error) {
 	log.Printf("🔄 正在请求AI500币种池...")
 
-	client := &http.Client{
-		Timeout: coinPoolConfig.Timeout,
-	}
-
-	resp, err := client.Get(coinPoolConfig.APIURL)
-	if err != nil {
-		return nil, fmt.Errorf("请求币种池API失败: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("读取响应失败: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API返回错误 (status %d): %s", resp.StatusCode, string(body))
-	}
-
-	// 解析API响应
 	var response CoinPoolAPIResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, fmt.Errorf("JSON解析失败: %w", err)
+	if err := getJSON(cfg.APIURL, cfg.Timeout, &response); err != nil {
+		return nil, fmt.Errorf("请求币种池API失败: %w", err)
 	}
 
 	if !response.Success {
@@ -192,9 +165,9 @@ func fetchCoinPool() ([]CoinInfo, error) {
 }
 
 // saveCoinPoolCache 保存币种池到缓存文件
-func saveCoinPoolCache(coins []CoinInfo) error {
+func saveCoinPoolCache(cacheDir string, coins []CoinInfo) error {
 	// 确保缓存目录存在
-	if err := os.MkdirAll(coinPoolConfig.CacheDir, 0755); err != nil {
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
 		return fmt.Errorf("创建缓存目录失败: %w", err)
 	}
 
@@ -209,7 +182,7 @@ func saveCoinPoolCache(coins []CoinInfo) error {
 		return fmt.Errorf("序列化缓存数据失败: %w", err)
 	}
 
-	cachePath := filepath.Join(coinPoolConfig.CacheDir, "latest.json")
+	cachePath := filepath.Join(cacheDir, "latest.json")
 	if err := ioutil.WriteFile(cachePath, data, 0644); err != nil {
 		return fmt.Errorf("写入缓存文件失败: %w", err)
 	}
@@ -219,8 +192,8 @@ func saveCoinPoolCache(coins []CoinInfo) error {
 }
 
 // loadCoinPoolCache 从缓存文件加载币种池
-func loadCoinPoolCache() ([]CoinInfo, error) {
-	cachePath := filepath.Join(coinPoolConfig.CacheDir, "latest.json")
+func loadCoinPoolCache(cacheDir string) ([]CoinInfo, error) {
+	cachePath := filepath.Join(cacheDir, "latest.json")
 
 	// 检查文件是否存在
 	if _, err := os.Stat(cachePath); os.IsNotExist(err) {
@@ -250,9 +223,14 @@ func loadCoinPoolCache() ([]CoinInfo, error) {
 	return cache.Coins, nil
 }
 
-// GetAvailableCoins 获取可用的币种列表（过滤不可用的）
+// GetAvailableCoins 获取全局币种池可用的币种列表
 func GetAvailableCoins() ([]string, error) {
-	coins, err := GetCoinPool()
+	return defaultPool.GetAvailableCoins()
+}
+
+// GetAvailableCoins 获取可用的币种列表（过滤不可用的）
+func (p *Pool) GetAvailableCoins() ([]string, error) {
+	coins, err := p.GetCoinPool()
 	if err != nil {
 		return nil, err
 	}
@@ -273,9 +251,14 @@ func GetAvailableCoins() ([]string, error) {
 	return symbols, nil
 }
 
-// GetTopRatedCoins 获取评分最高的N个币种（按评分从大到小排序）
+// GetTopRatedCoins 获取全局币种池评分最高的N个币种
 func GetTopRatedCoins(limit int) ([]string, error) {
-	coins, err := GetCoinPool()
+	return defaultPool.GetTopRatedCoins(limit)
+}
+
+// GetTopRatedCoins 获取评分最高的N个币种（按评分从大到小排序）
+func (p *Pool) GetTopRatedCoins(limit int) ([]string, error) {
+	coins, err := p.GetCoinPool()
 	if err != nil {
 		return nil, err
 	}
@@ -408,53 +391,33 @@ type OITopCache struct {
 	SourceType string       `json:"source_type"`
 }
 
-var oiTopConfig = struct {
-	APIURL   string
-	Timeout  time.Duration
-	CacheDir string
-}{
-	APIURL:   "",
-	Timeout:  30 * time.Second,
-	CacheDir: "coin_pool_cache",
+// GetOITopPositions 获取全局币种池的持仓量增长Top20数据
+func GetOITopPositions() ([]OIPosition, error) {
+	return defaultPool.GetOITopPositions()
 }
 
 // GetOITopPositions 获取持仓量增长Top20数据（带重试和缓存）
-func GetOITopPositions() ([]OIPosition, error) {
+func (p *Pool) GetOITopPositions() ([]OIPosition, error) {
+	cfg := p.config()
 	// 检查API URL是否配置
-	if strings.TrimSpace(oiTopConfig.APIURL) == "" {
+	if strings.TrimSpace(cfg.OITopAPIURL) == "" {
 		log.Printf("⚠️  未配置OI Top API URL，跳过OI Top数据获取")
 		return []OIPosition{}, nil // 返回空列表，不是错误
 	}
 
-	maxRetries := 3
-	var lastErr error
-
 	// 尝试从API获取
-	for attempt := 1; attempt <= maxRetries; attempt++ {
-		if attempt > 1 {
-			log.Printf("⚠️  第%d次重试获取OI Top数据（共%d次）...", attempt, maxRetries)
-			time.Sleep(2 * time.Second)
-		}
-
-		positions, err := fetchOITop()
-		if err == nil {
-			if attempt > 1 {
-				log.Printf("✓ 第%d次重试成功", attempt)
-			}
-			// 成功获取后保存到缓存
-			if err := saveOITopCache(positions); err != nil {
-				log.Printf("⚠️  保存OI Top缓存失败: %v", err)
-			}
-			return positions, nil
+	positions, lastErr := fetchWithRetry("OI Top数据", func() ([]OIPosition, error) { return fetchOITop(cfg) })
+	if lastErr == nil {
+		// 成功获取后保存到缓存
+		if err := saveOITopCache(cfg.CacheDir, positions); err != nil {
+			log.Printf("⚠️  保存OI Top缓存失败: %v", err)
 		}
-
-		lastErr = err
-		log.Printf("❌ 第%d次请求OI Top失败: %v", attempt, err)
+		return positions, nil
 	}
 
 	// API获取失败，尝试使用缓存
 	log.Printf("⚠️  OI Top API请求全部失败，尝试使用历史缓存数据...")
-	cachedPositions, err := loadOITopCache()
+	cachedPositions, err := loadOITopCache(cfg.CacheDir)
 	if err == nil {
 		log.Printf("✓ 使用历史OI Top缓存数据（共%d个币种）", len(cachedPositions))
 		return cachedPositions, nil
@@ -466,32 +429,12 @@ func GetOITopPositions() ([]OIPosition, error) {
 }
 
 // fetchOITop 实际执行OI Top请求
-func fetchOITop() ([]OIPosition, error) {
+func fetchOITop(cfg CoinPoolConfig) ([]OIPosition, error) {
 	log.Printf("🔄 正在请求OI Top数据...")
 
-	client := &http.Client{
-		Timeout: oiTopConfig.Timeout,
-	}
-
-	resp, err := client.Get(oiTopConfig.APIURL)
-	if err != nil {
-		return nil, fmt.Errorf("请求OI Top API失败: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("读取OI Top响应失败: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("OI Top API返回错误 (status %d): %s", resp.StatusCode, string(body))
-	}
-
-	// 解析API响应
 	var response OITopAPIResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, fmt.Errorf("OI Top JSON解析失败: %w", err)
+	if err := getJSON(cfg.OITopAPIURL, cfg.Timeout, &response); err != nil {
+		return nil, fmt.Errorf("请求OI Top API失败: %w", err)
 	}
 
 	if !response.Success {
@@ -508,8 +451,8 @@ func fetchOITop() ([]OIPosition, error) {
 }
 
 // saveOITopCache 保存OI Top数据到缓存
-func saveOITopCache(positions []OIPosition) error {
-	if err := os.MkdirAll(oiTopConfig.CacheDir, 0755); err != nil {
+func saveOITopCache(cacheDir string, positions []OIPosition) error {
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
 		return fmt.Errorf("创建缓存目录失败: %w", err)
 	}
 
@@ -524,7 +467,7 @@ func saveOITopCache(positions []OIPosition) error {
 		return fmt.Errorf("序列化OI Top缓存数据失败: %w", err)
 	}
 
-	cachePath := filepath.Join(oiTopConfig.CacheDir, "oi_top_latest.json")
+	cachePath := filepath.Join(cacheDir, "oi_top_latest.json")
 	if err := ioutil.WriteFile(cachePath, data, 0644); err != nil {
 		return fmt.Errorf("写入OI Top缓存文件失败: %w", err)
 	}
@@ -534,8 +477,8 @@ func saveOITopCache(positions []OIPosition) error {
 }
 
 // loadOITopCache 从缓存加载OI Top数据
-func loadOITopCache() ([]OIPosition, error) {
-	cachePath := filepath.Join(oiTopConfig.CacheDir, "oi_top_latest.json")
+func loadOITopCache(cacheDir string) ([]OIPosition, error) {
+	cachePath := filepath.Join(cacheDir, "oi_top_latest.json")
 
 	if _, err := os.Stat(cachePath); os.IsNotExist(err) {
 		return nil, fmt.Errorf("OI Top缓存文件不存在")
@@ -563,9 +506,14 @@ func loadOITopCache() ([]OIPosition, error) {
 	return cache.Positions, nil
 }
 
-// GetOITopSymbols 获取OI Top的币种符号列表
+// GetOITopSymbols 获取全局币种池OI Top的币种符号列表
 func GetOITopSymbols() ([]string, error) {
-	positions, err := GetOITopPositions()
+	return defaultPool.GetOITopSymbols()
+}
+
+// GetOITopSymbols 获取OI Top的币种符号列表
+func (p *Pool) GetOITopSymbols() ([]string, error) {
+	positions, err := p.GetOITopPositions()
 	if err != nil {
 		return nil, err
 	}
@@ -579,67 +527,26 @@ func GetOITopSymbols() ([]string, error) {
 	return symbols, nil
 }
 
-// MergedCoinPool 合并的币种池（AI500 + OI Top）
+// alertCoinLimit 币种池中最多加入的行情警报币种数量
+const alertCoinLimit = 10
+
+// MergedCoinPool 按权重合并的币种池（所有启用的信号源）
 type MergedCoinPool struct {
-	AI500Coins    []CoinInfo          // AI500评分币种
-	OITopCoins    []OIPosition        // 持仓量增长Top20
-	AllSymbols    []string            // 所有不重复的币种符号
-	SymbolSources map[string][]string // 每个币种的来源（"ai500"/"oi_top"）
+	AllSymbols    []string            // 所有不重复的币种符号（按综合评分降序，同分按字母顺序）
+	SymbolSources map[string][]string // 每个币种的来源（"ai500"/"oi_top"/"market_alerts"/...）
+	SymbolScores  map[string]float64  // 每个币种的综合评分（Σ 信号源权重 × 信号强度）
+	SourceCounts  map[string]int      // 每个信号源贡献的币种数量
 }
 
-// GetMergedCoinPool 获取合并后的币种池（AI500 + OI Top，去重）
-func GetMergedCoinPool(ai500Limit int) (*MergedCoinPool, error) {
-	// 1. 获取AI500数据
-	ai500TopSymbols, err := GetTopRatedCoins(ai500Limit)
-	if err != nil {
-		log.Printf("⚠️  获取AI500数据失败: %v", err)
-		ai500TopSymbols = []string{} // 失败时用空列表
-	}
-
-	// 2. 获取OI Top数据
-	oiTopSymbols, err := GetOITopSymbols()
-	if err != nil {
-		log.Printf("⚠️  获取OI Top数据失败: %v", err)
-		oiTopSymbols = []string{} // 失败时用空列表
+// GetAlertCoins 获取近期行情警报活跃的币种（行情监控未启动时为空）
+func GetAlertCoins(limit int) []market.SymbolScore {
+	if market.WSMonitorCli == nil {
+		return nil
 	}
+	return market.WSMonitorCli.HotSymbols(limit)
+}
 
-	// 3. 合并并去重
-	symbolSet := make(map[string]bool)
-	symbolSources := make(map[string][]string)
-
-	// 添加AI500币种
-	for _, symbol := range ai500TopSymbols {
-		symbolSet[symbol] = true
-		symbolSources[symbol] = append(symbolSources[symbol], "ai500")
-	}
-
-	// 添加OI Top币种
-	for _, symbol := range oiTopSymbols {
-		if !symbolSet[symbol] {
-			symbolSet[symbol] = true
-		}
-		symbolSources[symbol] = append(symbolSources[symbol], "oi_top")
-	}
-
-	// 转换为数组
-	var allSymbols []string
-	for symbol := range symbolSet {
-		allSymbols = append(allSymbols, symbol)
-	}
-
-	// 获取完整数据
-	ai500Coins, _ := GetCoinPool()
-	oiTopPositions, _ := GetOITopPositions()
-
-	merged := &MergedCoinPool{
-		AI500Coins:    ai500Coins,
-		OITopCoins:    oiTopPositions,
-		AllSymbols:    allSymbols,
-		SymbolSources: symbolSources,
-	}
-
-	log.Printf("📊 币种池合并完成: AI500=%d, OI_Top=%d, 总计(去重)=%d",
-		len(ai500TopSymbols), len(oiTopSymbols), len(allSymbols))
-
-	return merged, nil
+// GetMergedCoinPool 获取全局币种池合并后的结果
+func GetMergedCoinPool(ai500Limit int) (*MergedCoinPool, error) {
+	return defaultPool.GetMergedCoinPool(ai500Limit)
 }
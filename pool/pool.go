@@ -0,0 +1,215 @@
+package pool
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCacheDir        = "coin_pool_cache"
+	defaultFetchTimeout    = 30 * time.Second
+	defaultRefreshInterval = 3 * time.Minute
+)
+
+// Pool 币种池实例
+// 每个交易员使用自己的实例（AI500/OI Top 地址来自所属用户的信号源配置），
+// 拥有独立的缓存目录、信号缓存和刷新周期；全局实例保留 config.json/system_config 的行为
+type Pool struct {
+	id     string
+	strict bool // 实例币种池：未配置地址的远程信号源直接不启用（全局币种池回退到默认币种）
+
+	mu  sync.RWMutex
+	cfg CoinPoolConfig
+
+	signals sync.Map // 本实例远程信号源的缓存: "name|limit" -> cachedSignals
+
+	mergedMu    sync.Mutex
+	merged      *MergedCoinPool
+	mergedLimit int
+	mergedAt    time.Time
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stopCh    chan struct{}
+}
+
+// defaultPool 全局币种池（由 main 根据 system_config 配置）
+var defaultPool = &Pool{
+	id: "default",
+	cfg: CoinPoolConfig{
+		Timeout:  defaultFetchTimeout,
+		CacheDir: defaultCacheDir,
+	},
+	stopCh: make(chan struct{}),
+}
+
+// Default 全局币种池
+func Default() *Pool {
+	return defaultPool
+}
+
+// New 创建币种池实例，id 通常为交易员ID
+// 未设置的缓存目录为 coin_pool_cache/<id>，刷新间隔默认3分钟
+func New(id string, cfg CoinPoolConfig) *Pool {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultFetchTimeout
+	}
+	if cfg.CacheDir == "" {
+		cfg.CacheDir = filepath.Join(defaultCacheDir, sanitizeDirName(id))
+	}
+	if cfg.RefreshInterval <= 0 {
+		cfg.RefreshInterval = defaultRefreshInterval
+	}
+	cfg.UseDefaultCoins = false
+	return &Pool{
+		id:     id,
+		strict: true,
+		cfg:    cfg,
+		stopCh: make(chan struct{}),
+	}
+}
+
+// sanitizeDirName 将实例ID转换为安全的目录名
+func sanitizeDirName(id string) string {
+	if id == "" {
+		return "default"
+	}
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '.', ' ':
+			return '_'
+		}
+		return r
+	}, id)
+}
+
+// config 当前配置副本
+func (p *Pool) config() CoinPoolConfig {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.cfg
+}
+
+// update 修改配置，并清空已缓存的信号和合并结果
+func (p *Pool) update(fn func(cfg *CoinPoolConfig)) {
+	p.mu.Lock()
+	fn(&p.cfg)
+	p.mu.Unlock()
+
+	p.signals.Range(func(key, _ interface{}) bool {
+		p.signals.Delete(key)
+		return true
+	})
+	p.mergedMu.Lock()
+	p.merged = nil
+	p.mergedMu.Unlock()
+}
+
+// boundSource 绑定到币种池实例的信号源（使用实例自己的地址和缓存）
+type boundSource interface {
+	SignalSource
+	// forPool 返回绑定到 p 的信号源，p 未配置该信号源时返回 nil
+	forPool(p *Pool) SignalSource
+}
+
+// sources 本实例启用的信号源及权重
+func (p *Pool) sources() ([]SignalSource, map[string]float64) {
+	enabled, weights := enabledSources()
+	sources := make([]SignalSource, 0, len(enabled))
+	for _, s := range enabled {
+		if b, ok := s.(boundSource); ok {
+			if s = b.forPool(p); s == nil {
+				continue
+			}
+		}
+		sources = append(sources, s)
+	}
+	return sources, weights
+}
+
+// cacheFor 信号源结果的缓存（绑定实例的信号源使用实例缓存，其余信号源所有实例共用）
+func (p *Pool) cacheFor(source SignalSource) (*sync.Map, time.Duration) {
+	if _, ok := source.(boundSource); ok {
+		ttl := p.config().RefreshInterval
+		if ttl <= 0 {
+			ttl = sourceCacheTTL
+		}
+		return &p.signals, ttl
+	}
+	return &sourceCache, sourceCacheTTL
+}
+
+// GetMergedCoinPool 获取合并后的币种池（所有启用的信号源按权重评分，去重）
+// ai500Limit 为AI500信号源取前N个评分最高的币种；实例币种池在刷新间隔内返回缓存结果，并在首次调用后定时后台刷新
+func (p *Pool) GetMergedCoinPool(ai500Limit int) (*MergedCoinPool, error) {
+	interval := p.config().RefreshInterval
+
+	p.mergedMu.Lock()
+	if p.merged != nil && p.mergedLimit == ai500Limit && time.Since(p.mergedAt) < interval {
+		merged := p.merged
+		p.mergedMu.Unlock()
+		return merged, nil
+	}
+	p.mergedMu.Unlock()
+
+	merged, err := p.refresh(ai500Limit)
+	if err != nil {
+		return nil, err
+	}
+	if interval > 0 {
+		p.startOnce.Do(func() { go p.refreshLoop(interval) })
+	}
+	return merged, nil
+}
+
+// refresh 重新合并所有信号源并更新缓存
+func (p *Pool) refresh(ai500Limit int) (*MergedCoinPool, error) {
+	merged := p.mergeSignals(map[string]int{sourceAI500: ai500Limit})
+	if len(merged.AllSymbols) == 0 {
+		// 没有启用任何有效信号源时使用默认主流币种
+		if len(defaultMainstreamCoins) == 0 {
+			return nil, fmt.Errorf("所有信号源均没有返回币种")
+		}
+		log.Printf("⚠️  [%s] 所有信号源均没有返回币种，使用默认主流币种列表", p.id)
+		for _, symbol := range defaultMainstreamCoins {
+			symbol = normalizeSymbol(symbol)
+			merged.AllSymbols = append(merged.AllSymbols, symbol)
+			merged.SymbolSources[symbol] = []string{"default"}
+		}
+	}
+
+	p.mergedMu.Lock()
+	p.merged, p.mergedLimit, p.mergedAt = merged, ai500Limit, time.Now()
+	p.mergedMu.Unlock()
+
+	log.Printf("📊 [%s] 币种池合并完成: %v, 总计(去重)=%d", p.id, merged.SourceCounts, len(merged.AllSymbols))
+	return merged, nil
+}
+
+// refreshLoop 按刷新间隔在后台更新合并结果（交易员决策时直接使用缓存）
+func (p *Pool) refreshLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stopCh:
+			return
+		case <-ticker.C:
+			p.mergedMu.Lock()
+			limit := p.mergedLimit
+			p.mergedMu.Unlock()
+			if _, err := p.refresh(limit); err != nil {
+				log.Printf("⚠️  [%s] 币种池后台刷新失败: %v", p.id, err)
+			}
+		}
+	}
+}
+
+// Stop 停止后台刷新
+func (p *Pool) Stop() {
+	p.stopOnce.Do(func() { close(p.stopCh) })
+}
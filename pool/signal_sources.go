@@ -0,0 +1,254 @@
+package pool
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"nofx/market"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 信号源标识（写入 CandidateCoin.Sources）
+const (
+	sourceAI500           = "ai500"
+	sourceOITop           = "oi_top"
+	sourceMarketAlerts    = "market_alerts"
+	sourceStatic          = "static"
+	sourceTopMovers       = "top_movers"
+	sourceFundingExtremes = "funding_extremes"
+	sourceUniverse        = "universe"
+)
+
+const (
+	topMoversVolumeRank   = 100       // 涨跌幅排名只在24小时成交额前N的交易对中进行
+	tradableSymbolsTTL    = time.Hour // 可交易交易对列表缓存时间
+	minFundingExtremeRate = 0.0003    // 资金费率绝对值低于该值不视为极端（0.03%）
+)
+
+// ai500Source AI500评分币种（地址来自所属币种池实例）
+type ai500Source struct {
+	pool *Pool
+}
+
+func (ai500Source) Name() string { return sourceAI500 }
+
+func (s ai500Source) forPool(p *Pool) SignalSource {
+	if p.strict && strings.TrimSpace(p.config().APIURL) == "" {
+		return nil
+	}
+	return ai500Source{pool: p}
+}
+
+func (s ai500Source) Fetch(limit int) ([]Signal, error) {
+	symbols, err := s.pool.GetTopRatedCoins(limit)
+	if err != nil {
+		return nil, err
+	}
+	return rankedSignals(symbols), nil
+}
+
+// oiTopSource 持仓量增长Top币种（地址来自所属币种池实例）
+type oiTopSource struct {
+	pool *Pool
+}
+
+func (oiTopSource) Name() string { return sourceOITop }
+
+func (s oiTopSource) forPool(p *Pool) SignalSource {
+	if p.strict && strings.TrimSpace(p.config().OITopAPIURL) == "" {
+		return nil
+	}
+	return oiTopSource{pool: p}
+}
+
+func (s oiTopSource) Fetch(limit int) ([]Signal, error) {
+	symbols, err := s.pool.GetOITopSymbols()
+	if err != nil {
+		return nil, err
+	}
+	if len(symbols) > limit {
+		symbols = symbols[:limit]
+	}
+	return rankedSignals(symbols), nil
+}
+
+// alertSource 近期行情警报活跃的币种（按警报评分）
+type alertSource struct{}
+
+func (alertSource) Name() string { return sourceMarketAlerts }
+
+func (alertSource) Fetch(limit int) ([]Signal, error) {
+	coins := GetAlertCoins(limit)
+	symbols := make([]string, 0, len(coins))
+	scores := make([]float64, 0, len(coins))
+	for _, coin := range coins {
+		symbols = append(symbols, coin.Symbol)
+		scores = append(scores, coin.Score)
+	}
+	return scaledSignals(symbols, scores), nil
+}
+
+// staticFileSource 本地文件中的自选币种
+// 文件为JSON字符串数组，或每行一个币种（# 开头为注释），按文件中的顺序排名
+type staticFileSource struct{}
+
+var signalFile string
+
+// SetSignalFile 设置自选币种文件路径（static 信号源）
+func SetSignalFile(path string) {
+	signalFile = strings.TrimSpace(path)
+}
+
+func (staticFileSource) Name() string { return sourceStatic }
+
+func (staticFileSource) Fetch(limit int) ([]Signal, error) {
+	if signalFile == "" {
+		return nil, fmt.Errorf("未配置自选币种文件")
+	}
+	data, err := os.ReadFile(signalFile)
+	if err != nil {
+		return nil, fmt.Errorf("读取自选币种文件失败: %w", err)
+	}
+
+	var symbols []string
+	if content := strings.TrimSpace(string(data)); strings.HasPrefix(content, "[") {
+		if err := json.Unmarshal([]byte(content), &symbols); err != nil {
+			return nil, fmt.Errorf("解析自选币种文件失败: %w", err)
+		}
+	} else {
+		for _, line := range strings.Split(content, "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" && !strings.HasPrefix(line, "#") {
+				symbols = append(symbols, line)
+			}
+		}
+	}
+	if len(symbols) > limit {
+		symbols = symbols[:limit]
+	}
+	return rankedSignals(symbols), nil
+}
+
+// topMoversSource 成交活跃的交易对中24小时涨跌幅最大的币种
+type topMoversSource struct{}
+
+func (topMoversSource) Name() string { return sourceTopMovers }
+
+func (topMoversSource) Fetch(limit int) ([]Signal, error) {
+	tradable, err := tradableSymbols()
+	if err != nil {
+		return nil, err
+	}
+	tickers, err := market.NewAPIClient().GetTickers24h()
+	if err != nil {
+		return nil, fmt.Errorf("获取24小时行情失败: %w", err)
+	}
+
+	type mover struct {
+		symbol      string
+		quoteVolume float64
+		change      float64
+	}
+	var movers []mover
+	for _, t := range tickers {
+		if !tradable[t.Symbol] {
+			continue
+		}
+		volume, _ := strconv.ParseFloat(t.QuoteVolume, 64)
+		change, _ := strconv.ParseFloat(t.PriceChangePercent, 64)
+		movers = append(movers, mover{symbol: t.Symbol, quoteVolume: volume, change: math.Abs(change)})
+	}
+
+	// 先按成交额筛出流动性足够的交易对，再按涨跌幅排名
+	sort.Slice(movers, func(i, j int) bool { return movers[i].quoteVolume > movers[j].quoteVolume })
+	if len(movers) > topMoversVolumeRank {
+		movers = movers[:topMoversVolumeRank]
+	}
+	sort.Slice(movers, func(i, j int) bool { return movers[i].change > movers[j].change })
+	if len(movers) > limit {
+		movers = movers[:limit]
+	}
+
+	symbols := make([]string, 0, len(movers))
+	changes := make([]float64, 0, len(movers))
+	for _, m := range movers {
+		symbols = append(symbols, m.symbol)
+		changes = append(changes, m.change)
+	}
+	return scaledSignals(symbols, changes), nil
+}
+
+// fundingExtremesSource 资金费率绝对值最大的币种（多空拥挤，可能反转）
+type fundingExtremesSource struct{}
+
+func (fundingExtremesSource) Name() string { return sourceFundingExtremes }
+
+func (fundingExtremesSource) Fetch(limit int) ([]Signal, error) {
+	tradable, err := tradableSymbols()
+	if err != nil {
+		return nil, err
+	}
+	rates, err := market.GetFundingRates()
+	if err != nil {
+		return nil, err
+	}
+
+	var symbols []string
+	for symbol, index := range rates {
+		if tradable[symbol] && math.Abs(index.LastFundingRate) >= minFundingExtremeRate {
+			symbols = append(symbols, symbol)
+		}
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		ri, rj := math.Abs(rates[symbols[i]].LastFundingRate), math.Abs(rates[symbols[j]].LastFundingRate)
+		if ri != rj {
+			return ri > rj
+		}
+		return symbols[i] < symbols[j]
+	})
+	if len(symbols) > limit {
+		symbols = symbols[:limit]
+	}
+
+	values := make([]float64, 0, len(symbols))
+	for _, symbol := range symbols {
+		values = append(values, math.Abs(rates[symbol].LastFundingRate))
+	}
+	return scaledSignals(symbols, values), nil
+}
+
+var (
+	tradableMu      sync.Mutex
+	tradableCache   map[string]bool
+	tradableFetched time.Time
+)
+
+// tradableSymbols 币安正在交易的USDT永续合约（来自 ExchangeInfo，缓存 tradableSymbolsTTL）
+func tradableSymbols() (map[string]bool, error) {
+	tradableMu.Lock()
+	defer tradableMu.Unlock()
+	if tradableCache != nil && time.Since(tradableFetched) < tradableSymbolsTTL {
+		return tradableCache, nil
+	}
+
+	info, err := market.NewAPIClient().GetExchangeInfo()
+	if err != nil {
+		return nil, fmt.Errorf("获取交易规则失败: %w", err)
+	}
+	symbols := make(map[string]bool, len(info.Symbols))
+	for _, s := range info.Symbols {
+		if s.Status == "TRADING" && s.ContractType == "PERPETUAL" && s.QuoteAsset == "USDT" {
+			symbols[s.Symbol] = true
+		}
+	}
+	if len(symbols) == 0 {
+		return nil, fmt.Errorf("交易规则中没有可交易的USDT永续合约")
+	}
+	tradableCache, tradableFetched = symbols, time.Now()
+	return symbols, nil
+}
@@ -0,0 +1,281 @@
+package pool
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	maxFetchRetries    = 3               // 远程信号源最大请求次数
+	fetchRetryDelay    = 2 * time.Second // 重试前等待时间
+	sourceCacheTTL     = time.Minute     // 信号源结果在内存中的缓存时间（多个交易员共用）
+	defaultSourceLimit = 20              // 信号源默认取前N个币种
+)
+
+// Signal 信号源给出的单个币种信号
+type Signal struct {
+	Symbol   string  `json:"symbol"`
+	Strength float64 `json:"strength"` // 源内归一化强度 (0, 1]，越大信号越强
+}
+
+// SignalSource 币种信号源
+// 实现者只负责获取并排序自己的信号，合并、加权、缓存由币种池统一处理
+type SignalSource interface {
+	// Name 来源标识，写入 CandidateCoin.Sources，例如 "ai500"、"oi_top"
+	Name() string
+	// Fetch 获取最多 limit 个币种信号（按强度降序）
+	Fetch(limit int) ([]Signal, error)
+}
+
+// defaultSourceWeights 默认启用的信号源及权重（未配置 signal_source_weights 时使用）
+var defaultSourceWeights = map[string]float64{
+	sourceAI500:        1.0,
+	sourceOITop:        1.0,
+	sourceMarketAlerts: 1.0,
+	sourceUniverse:     1.0,
+}
+
+var (
+	sourcesMu     sync.RWMutex
+	signalSources []SignalSource // 已注册的信号源（按注册顺序合并）
+	sourceWeights = defaultSourceWeights
+	sourceLimits  = map[string]int{sourceMarketAlerts: alertCoinLimit}
+
+	sourceCache sync.Map // 所有币种池实例共用的信号缓存: "name|limit" -> cachedSignals
+)
+
+type cachedSignals struct {
+	signals   []Signal
+	fetchedAt time.Time
+}
+
+func init() {
+	RegisterSignalSource(ai500Source{pool: defaultPool})
+	RegisterSignalSource(oiTopSource{pool: defaultPool})
+	RegisterSignalSource(alertSource{})
+	RegisterSignalSource(staticFileSource{})
+	RegisterSignalSource(topMoversSource{})
+	RegisterSignalSource(fundingExtremesSource{})
+	RegisterSignalSource(universeSource{})
+}
+
+// RegisterSignalSource 注册信号源（同名信号源会被替换），权重为0时不参与合并
+func RegisterSignalSource(source SignalSource) {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	for i, s := range signalSources {
+		if s.Name() == source.Name() {
+			signalSources[i] = source
+			return
+		}
+	}
+	signalSources = append(signalSources, source)
+}
+
+// SetSourceWeights 设置各信号源的权重（未列出或权重<=0的信号源不启用），空表示使用默认权重
+func SetSourceWeights(weights map[string]float64) {
+	if len(weights) == 0 {
+		weights = defaultSourceWeights
+	}
+	if _, ok := weights[sourceUniverse]; !ok {
+		// 可交易池是否启用由 universe_enabled/交易员配置决定，未列出权重时按1合并
+		withUniverse := make(map[string]float64, len(weights)+1)
+		for name, w := range weights {
+			withUniverse[name] = w
+		}
+		withUniverse[sourceUniverse] = 1.0
+		weights = withUniverse
+	}
+	sourcesMu.Lock()
+	sourceWeights = weights
+	sourcesMu.Unlock()
+	log.Printf("✓ 币种池信号源权重: %v", weights)
+}
+
+// SourceWeights 当前信号源权重
+func SourceWeights() map[string]float64 {
+	sourcesMu.RLock()
+	defer sourcesMu.RUnlock()
+	weights := make(map[string]float64, len(sourceWeights))
+	for name, w := range sourceWeights {
+		weights[name] = w
+	}
+	return weights
+}
+
+// enabledSources 权重大于0的信号源及其权重
+func enabledSources() ([]SignalSource, map[string]float64) {
+	sourcesMu.RLock()
+	defer sourcesMu.RUnlock()
+	var sources []SignalSource
+	for _, s := range signalSources {
+		if sourceWeights[s.Name()] > 0 {
+			sources = append(sources, s)
+		}
+	}
+	return sources, sourceWeights
+}
+
+// sourceLimit 信号源取前N个币种
+func sourceLimit(name string) int {
+	sourcesMu.RLock()
+	defer sourcesMu.RUnlock()
+	if n, ok := sourceLimits[name]; ok {
+		return n
+	}
+	return defaultSourceLimit
+}
+
+// fetchSignals 获取信号源的信号（在 cache 中缓存 ttl）
+func fetchSignals(cache *sync.Map, ttl time.Duration, source SignalSource, limit int) ([]Signal, error) {
+	key := fmt.Sprintf("%s|%d", source.Name(), limit)
+	if v, ok := cache.Load(key); ok {
+		cached := v.(cachedSignals)
+		if time.Since(cached.fetchedAt) < ttl {
+			return cached.signals, nil
+		}
+	}
+
+	signals, err := source.Fetch(limit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range signals {
+		signals[i].Symbol = normalizeSymbol(signals[i].Symbol)
+	}
+	cache.Store(key, cachedSignals{signals: signals, fetchedAt: time.Now()})
+	return signals, nil
+}
+
+// mergeSignals 按权重合并各信号源：币种评分 = Σ 信号源权重 × 信号强度
+// limits 覆盖指定信号源取前N个币种
+func (p *Pool) mergeSignals(limits map[string]int) *MergedCoinPool {
+	enabled, weights := p.sources()
+	var symbols []string
+	sources := make(map[string][]string)
+	scores := make(map[string]float64)
+	counts := make(map[string]int, len(enabled))
+
+	for _, source := range enabled {
+		name := source.Name()
+		limit, ok := limits[name]
+		if !ok {
+			limit = sourceLimit(name)
+		}
+		cache, ttl := p.cacheFor(source)
+		signals, err := fetchSignals(cache, ttl, source, limit)
+		if err != nil {
+			log.Printf("⚠️  获取信号源 %s 失败: %v", name, err)
+			continue
+		}
+		counts[name] = len(signals)
+		for _, signal := range signals {
+			if _, seen := scores[signal.Symbol]; !seen {
+				symbols = append(symbols, signal.Symbol)
+			}
+			if !containsString(sources[signal.Symbol], name) {
+				sources[signal.Symbol] = append(sources[signal.Symbol], name)
+			}
+			scores[signal.Symbol] += weights[name] * signal.Strength
+		}
+	}
+
+	// 评分高的币种排在前面，同分按字母顺序
+	sort.Slice(symbols, func(i, j int) bool {
+		si, sj := scores[symbols[i]], scores[symbols[j]]
+		if si != sj {
+			return si > sj
+		}
+		return symbols[i] < symbols[j]
+	})
+	return &MergedCoinPool{
+		AllSymbols:    symbols,
+		SymbolSources: sources,
+		SymbolScores:  scores,
+		SourceCounts:  counts,
+	}
+}
+
+// rankedSignals 按排名生成信号强度：第1名为1，之后线性递减
+func rankedSignals(symbols []string) []Signal {
+	signals := make([]Signal, 0, len(symbols))
+	for i, symbol := range symbols {
+		signals = append(signals, Signal{
+			Symbol:   symbol,
+			Strength: 1 - float64(i)/float64(len(symbols)),
+		})
+	}
+	return signals
+}
+
+// scaledSignals 按数值相对最大值生成信号强度（values 需与 symbols 一一对应且已按降序排列）
+func scaledSignals(symbols []string, values []float64) []Signal {
+	signals := make([]Signal, 0, len(symbols))
+	if len(values) == 0 || values[0] <= 0 {
+		return rankedSignals(symbols)
+	}
+	for i, symbol := range symbols {
+		signals = append(signals, Signal{Symbol: symbol, Strength: values[i] / values[0]})
+	}
+	return signals
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchWithRetry 请求远程数据，失败时最多重试到 maxFetchRetries 次
+func fetchWithRetry[T any](name string, fetch func() (T, error)) (T, error) {
+	var lastErr error
+	for attempt := 1; attempt <= maxFetchRetries; attempt++ {
+		if attempt > 1 {
+			log.Printf("⚠️  第%d次重试获取%s（共%d次）...", attempt, name, maxFetchRetries)
+			time.Sleep(fetchRetryDelay)
+		}
+
+		v, err := fetch()
+		if err == nil {
+			if attempt > 1 {
+				log.Printf("✓ 第%d次重试成功", attempt)
+			}
+			return v, nil
+		}
+		lastErr = err
+		log.Printf("❌ 第%d次请求%s失败: %v", attempt, name, err)
+	}
+	var zero T
+	return zero, lastErr
+}
+
+// getJSON 请求远程信号源接口并解析JSON
+func getJSON(url string, timeout time.Duration, out interface{}) error {
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("读取响应失败: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("API返回错误 (status %d): %s", resp.StatusCode, string(body))
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("JSON解析失败: %w", err)
+	}
+	return nil
+}
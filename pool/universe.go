@@ -0,0 +1,367 @@
+package pool
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"nofx/market"
+	"nofx/market/indicator"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	universeRefreshInterval = 15 * time.Minute // 可交易池后台重建间隔
+	universeCandidates      = 150              // 按成交额取前N个交易对计算持仓价值和ATR
+	universeConcurrency     = 5                // 计算持仓价值和ATR的并发请求数
+	universeATRInterval     = "1h"             // ATR使用的K线周期
+	universeATRPeriod       = 14
+)
+
+// UniverseFilters 可交易池筛选条件（0 表示不限制）
+type UniverseFilters struct {
+	MinQuoteVolume float64 `json:"min_quote_volume"` // 24小时成交额下限（USDT）
+	MinOIValue     float64 `json:"min_oi_value"`     // 持仓价值下限（USD）
+	MinATRPct      float64 `json:"min_atr_pct"`      // 1小时ATR占价格的百分比下限
+	MaxATRPct      float64 `json:"max_atr_pct"`      // 1小时ATR占价格的百分比上限
+	MinListingDays int     `json:"min_listing_days"` // 上线天数下限
+	MaxSpreadPct   float64 `json:"max_spread_pct"`   // 买一卖一价差占价格的百分比上限
+	Limit          int     `json:"limit"`            // 最多选出的币种数量
+}
+
+// defaultUniverseFilters 未配置 universe_filters 时的筛选条件
+var defaultUniverseFilters = UniverseFilters{
+	MinQuoteVolume: 50_000_000,
+	MinOIValue:     15_000_000,
+	MinATRPct:      0.3,
+	MaxATRPct:      5,
+	MinListingDays: 30,
+	MaxSpreadPct:   0.05,
+	Limit:          20,
+}
+
+var (
+	universeMu      sync.RWMutex
+	universeEnabled bool
+	universeFilters = defaultUniverseFilters
+)
+
+// ParseUniverseFilters 解析JSON格式的筛选条件，未出现的字段使用默认值
+func ParseUniverseFilters(raw string) (UniverseFilters, error) {
+	filters := defaultUniverseFilters
+	if strings.TrimSpace(raw) == "" {
+		return filters, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &filters); err != nil {
+		return defaultUniverseFilters, fmt.Errorf("解析可交易池筛选条件失败: %w", err)
+	}
+	return filters, nil
+}
+
+// SetUniverse 设置全局可交易池：是否默认启用及默认筛选条件（交易员可覆盖）
+func SetUniverse(enabled bool, filters UniverseFilters) {
+	universeMu.Lock()
+	universeEnabled, universeFilters = enabled, filters
+	universeMu.Unlock()
+
+	defaultPool.update(func(cfg *CoinPoolConfig) {
+		cfg.Universe = nil
+		if enabled {
+			f := filters
+			cfg.Universe = &f
+		}
+	})
+	if enabled {
+		log.Printf("✓ 已启用自动可交易池: %+v", filters)
+	}
+}
+
+// UniverseDefaults 当前默认筛选条件
+func UniverseDefaults() UniverseFilters {
+	universeMu.RLock()
+	defer universeMu.RUnlock()
+	return universeFilters
+}
+
+// UniverseFor 交易员的可交易池筛选条件，override 为交易员配置的JSON（覆盖默认条件中出现的字段）
+// override 为空时沿用全局开关；非空时默认启用，可用 "enabled": false 关闭；未启用时返回 nil
+func UniverseFor(override string) (*UniverseFilters, error) {
+	universeMu.RLock()
+	enabled, filters := universeEnabled, universeFilters
+	universeMu.RUnlock()
+
+	if strings.TrimSpace(override) != "" {
+		cfg := struct {
+			*UniverseFilters
+			Enabled *bool `json:"enabled"`
+		}{UniverseFilters: &filters}
+		if err := json.Unmarshal([]byte(override), &cfg); err != nil {
+			return nil, fmt.Errorf("解析交易员可交易池筛选条件失败: %w", err)
+		}
+		enabled = cfg.Enabled == nil || *cfg.Enabled
+	}
+	if !enabled {
+		return nil, nil
+	}
+	return &filters, nil
+}
+
+// UniverseSymbol 可交易池中交易对的流动性与波动率指标
+type UniverseSymbol struct {
+	Symbol      string  `json:"symbol"`
+	QuoteVolume float64 `json:"quote_volume"` // 24小时成交额（USDT）
+	OIValue     float64 `json:"oi_value"`     // 持仓价值（USD），未能获取时为0
+	ATRPct      float64 `json:"atr_pct"`      // 1小时ATR占价格的百分比，未能获取时为0
+	ListingDays int     `json:"listing_days"` // 上线天数
+	SpreadPct   float64 `json:"spread_pct"`   // 买一卖一价差占价格的百分比
+}
+
+// universeBuilder 定时扫描全部USDT永续合约，计算筛选所需的指标
+// 所有交易员共用同一份指标，各自按筛选条件选出候选币种
+type universeBuilder struct {
+	mu      sync.RWMutex
+	symbols []UniverseSymbol // 按24小时成交额降序
+	builtAt time.Time
+
+	buildMu   sync.Mutex
+	startOnce sync.Once
+}
+
+var universe = &universeBuilder{}
+
+// GetUniverse 按筛选条件从可交易池中选出交易对（按24小时成交额降序）
+func GetUniverse(filters UniverseFilters) ([]UniverseSymbol, error) {
+	symbols, err := universe.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	return selectUniverse(symbols, filters), nil
+}
+
+// snapshot 最近一次构建的指标（尚未构建或已过期时同步构建），首次调用后启动后台重建
+func (b *universeBuilder) snapshot() ([]UniverseSymbol, error) {
+	b.startOnce.Do(func() { go b.refreshLoop() })
+
+	b.mu.RLock()
+	symbols, builtAt := b.symbols, b.builtAt
+	b.mu.RUnlock()
+	if len(symbols) > 0 && time.Since(builtAt) < 2*universeRefreshInterval {
+		return symbols, nil
+	}
+	return b.rebuild(builtAt)
+}
+
+// rebuild 重新构建指标；并发调用时只构建一次（since 之后已有其他调用完成构建则直接返回）
+func (b *universeBuilder) rebuild(since time.Time) ([]UniverseSymbol, error) {
+	b.buildMu.Lock()
+	defer b.buildMu.Unlock()
+
+	b.mu.RLock()
+	if b.builtAt.After(since) && len(b.symbols) > 0 {
+		symbols := b.symbols
+		b.mu.RUnlock()
+		return symbols, nil
+	}
+	b.mu.RUnlock()
+
+	symbols, err := buildUniverse()
+	if err != nil {
+		return nil, err
+	}
+	b.mu.Lock()
+	b.symbols, b.builtAt = symbols, time.Now()
+	b.mu.Unlock()
+	return symbols, nil
+}
+
+// refreshLoop 后台定时重建
+func (b *universeBuilder) refreshLoop() {
+	ticker := time.NewTicker(universeRefreshInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		b.mu.RLock()
+		builtAt := b.builtAt
+		b.mu.RUnlock()
+		if _, err := b.rebuild(builtAt); err != nil {
+			log.Printf("⚠️  可交易池重建失败: %v", err)
+		}
+	}
+}
+
+// buildUniverse 从交易规则、24小时行情和最优挂单计算全部USDT永续合约的指标，
+// 成交额前 universeCandidates 的交易对再计算持仓价值和ATR
+func buildUniverse() ([]UniverseSymbol, error) {
+	client := market.NewAPIClient()
+	info, err := client.GetExchangeInfo()
+	if err != nil {
+		return nil, fmt.Errorf("获取交易规则失败: %w", err)
+	}
+	tickers, err := client.GetTickers24h()
+	if err != nil {
+		return nil, fmt.Errorf("获取24小时行情失败: %w", err)
+	}
+	books, err := client.GetBookTickers()
+	if err != nil {
+		return nil, fmt.Errorf("获取最优挂单失败: %w", err)
+	}
+
+	onboard := make(map[string]int64, len(info.Symbols))
+	for _, s := range info.Symbols {
+		if s.Status == "TRADING" && s.ContractType == "PERPETUAL" && s.QuoteAsset == "USDT" {
+			onboard[s.Symbol] = s.OnboardDate
+		}
+	}
+	spreads := make(map[string]float64, len(books))
+	for _, book := range books {
+		bid, _ := strconv.ParseFloat(book.BidPrice, 64)
+		ask, _ := strconv.ParseFloat(book.AskPrice, 64)
+		if bid > 0 && ask >= bid {
+			spreads[book.Symbol] = (ask - bid) / ((ask + bid) / 2) * 100
+		}
+	}
+
+	var symbols []UniverseSymbol
+	prices := make(map[string]float64)
+	now := time.Now()
+	for _, t := range tickers {
+		onboardDate, ok := onboard[t.Symbol]
+		if !ok {
+			continue
+		}
+		volume, _ := strconv.ParseFloat(t.QuoteVolume, 64)
+		price, _ := strconv.ParseFloat(t.LastPrice, 64)
+		spread, ok := spreads[t.Symbol]
+		if volume <= 0 || price <= 0 || !ok {
+			continue
+		}
+		listingDays := 0
+		if onboardDate > 0 {
+			listingDays = int(now.Sub(time.UnixMilli(onboardDate)).Hours() / 24)
+		}
+		prices[t.Symbol] = price
+		symbols = append(symbols, UniverseSymbol{
+			Symbol:      t.Symbol,
+			QuoteVolume: volume,
+			ListingDays: listingDays,
+			SpreadPct:   spread,
+		})
+	}
+	if len(symbols) == 0 {
+		return nil, fmt.Errorf("没有可交易的USDT永续合约")
+	}
+
+	sort.Slice(symbols, func(i, j int) bool { return symbols[i].QuoteVolume > symbols[j].QuoteVolume })
+	if len(symbols) > universeCandidates {
+		symbols = symbols[:universeCandidates]
+	}
+
+	// 持仓价值和ATR需要逐个交易对请求，限制并发
+	var wg sync.WaitGroup
+	var failed int
+	var failedMu sync.Mutex
+	sem := make(chan struct{}, universeConcurrency)
+	for i := range symbols {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(s *UniverseSymbol) {
+			defer func() { <-sem; wg.Done() }()
+			price := prices[s.Symbol]
+			oi, oiErr := market.GetOpenInterest(s.Symbol)
+			if oiErr == nil {
+				s.OIValue = oi * price
+			}
+			atr, atrErr := hourlyATR(client, s.Symbol)
+			if atrErr == nil {
+				s.ATRPct = atr / price * 100
+			}
+			if oiErr != nil || atrErr != nil {
+				failedMu.Lock()
+				failed++
+				failedMu.Unlock()
+			}
+		}(&symbols[i])
+	}
+	wg.Wait()
+
+	if failed > 0 {
+		log.Printf("⚠️  可交易池: %d 个交易对的持仓价值或ATR获取失败", failed)
+	}
+	log.Printf("🔄 可交易池已重建: %d 个USDT永续合约", len(symbols))
+	return symbols, nil
+}
+
+// hourlyATR 交易对1小时K线的ATR
+func hourlyATR(client *market.APIClient, symbol string) (float64, error) {
+	klines, err := client.GetKlines(symbol, universeATRInterval, universeATRPeriod*2)
+	if err != nil {
+		return 0, err
+	}
+	atr := indicator.NewATR(universeATRPeriod)
+	for _, k := range klines {
+		atr.Update(indicator.Bar{Time: k.OpenTime, Open: k.Open, High: k.High, Low: k.Low, Close: k.Close, Volume: k.Volume})
+	}
+	if !atr.Ready() {
+		return 0, fmt.Errorf("%s K线数量不足，无法计算ATR", symbol)
+	}
+	return atr.Value(), nil
+}
+
+// selectUniverse 按筛选条件过滤（保持成交额降序），取前 Limit 个
+func selectUniverse(symbols []UniverseSymbol, f UniverseFilters) []UniverseSymbol {
+	var selected []UniverseSymbol
+	for _, s := range symbols {
+		if s.QuoteVolume < f.MinQuoteVolume ||
+			(f.MinOIValue > 0 && s.OIValue < f.MinOIValue) ||
+			(f.MinATRPct > 0 && s.ATRPct < f.MinATRPct) ||
+			(f.MaxATRPct > 0 && (s.ATRPct <= 0 || s.ATRPct > f.MaxATRPct)) ||
+			s.ListingDays < f.MinListingDays ||
+			(f.MaxSpreadPct > 0 && s.SpreadPct > f.MaxSpreadPct) {
+			continue
+		}
+		selected = append(selected, s)
+		if f.Limit > 0 && len(selected) >= f.Limit {
+			break
+		}
+	}
+	return selected
+}
+
+// universeSource 可交易池信号源（筛选条件来自所属币种池实例）
+type universeSource struct {
+	filters *UniverseFilters
+}
+
+func (universeSource) Name() string { return sourceUniverse }
+
+func (universeSource) forPool(p *Pool) SignalSource {
+	filters := p.config().Universe
+	if filters == nil {
+		return nil
+	}
+	return universeSource{filters: filters}
+}
+
+func (s universeSource) Fetch(limit int) ([]Signal, error) {
+	filters := UniverseDefaults()
+	if s.filters != nil {
+		filters = *s.filters
+	}
+	if filters.Limit <= 0 {
+		filters.Limit = limit
+	}
+	selected, err := GetUniverse(filters)
+	if err != nil {
+		return nil, err
+	}
+
+	symbols := make([]string, 0, len(selected))
+	volumes := make([]float64, 0, len(selected))
+	for _, s := range selected {
+		symbols = append(symbols, s.Symbol)
+		volumes = append(volumes, s.QuoteVolume)
+	}
+	return scaledSignals(symbols, volumes), nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"nofx/config"
+	"nofx/decision"
+	"nofx/export"
+	"nofx/logger"
+	"nofx/mcp"
+	"os"
+	"reflect"
+)
+
+// runReplay 离线回放交易员的历史决策周期:
+// nofx replay -trader <id> -recordings <dir> [-model name] [-range 7d | -start 2025-01-01 -end 2025-02-01] [-db config.db]
+// 从决策日志读取每个周期记录的系统提示词和输入提示词，用录制的AI响应（ai_record_dir）重新解析决策，
+// 与当时记录的决策对比；只读取数据库和录制文件，不连接交易所和AI接口
+func runReplay(args []string) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	dbPath := fs.String("db", "config.db", "配置数据库路径")
+	traderID := fs.String("trader", "", "交易员ID（必填）")
+	recordDir := fs.String("recordings", "", "AI响应录制目录（必填，即运行时的 ai_record_dir）")
+	model := fs.String("model", "", "只匹配该模型的录制（为空时只按提示词匹配）")
+	rangeStr := fs.String("range", "", "时间范围: 24h、7d、4w、1y、all")
+	startStr := fs.String("start", "", "开始时间（RFC3339 或 2006-01-02），优先于 -range")
+	endStr := fs.String("end", "", "结束时间（RFC3339 或 2006-01-02），默认当前时间")
+	btcEthLeverage := fs.Int("btc-eth-leverage", 5, "BTC/ETH杠杆上限（决策验证用，与交易员配置一致）")
+	altcoinLeverage := fs.Int("altcoin-leverage", 5, "山寨币杠杆上限（决策验证用，与交易员配置一致）")
+	verbose := fs.Bool("v", false, "输出不一致周期的决策详情")
+	fs.Parse(args)
+
+	if *traderID == "" || *recordDir == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	start, end, _, err := export.ParseTimeRange(*rangeStr, *startStr, *endStr)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	replayClient, err := mcp.NewReplayClient(*recordDir, *model)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	database, err := config.NewDatabase(*dbPath)
+	if err != nil {
+		log.Fatalf("❌ 初始化数据库失败: %v", err)
+	}
+	defer database.Close()
+
+	var matched, mismatched, missed, skipped int
+	err = openDecisionLogger(database, *traderID).WalkRecords(start, end, func(record *logger.DecisionRecord) error {
+		label := fmt.Sprintf("周期 #%d (%s)", record.CycleNumber, record.Timestamp.Format("2006-01-02 15:04:05"))
+		switch {
+		case record.Pipeline != "":
+			skipped++
+			fmt.Printf("⏭️  %s 多阶段决策流程，暂不支持回放\n", label)
+			return nil
+		case record.SystemPrompt == "" || record.InputPrompt == "":
+			skipped++
+			fmt.Printf("⏭️  %s 没有完整提示词（已压缩或未调用AI）\n", label)
+			return nil
+		}
+		if _, ok := replayClient.Lookup(record.SystemPrompt, record.InputPrompt); !ok {
+			missed++
+			fmt.Printf("❓ %s 没有对应的录制\n", label)
+			return nil
+		}
+
+		replayed, err := decision.DecideFromPrompts(replayClient, record.SystemPrompt, record.InputPrompt,
+			record.AccountState.TotalBalance, *btcEthLeverage, *altcoinLeverage)
+		if err != nil {
+			mismatched++
+			fmt.Printf("❌ %s 回放失败: %v\n", label, err)
+			return nil
+		}
+		recorded, err := parseRecordedDecisions(record.DecisionJSON)
+		if err != nil {
+			mismatched++
+			fmt.Printf("❌ %s 解析记录的决策失败: %v\n", label, err)
+			return nil
+		}
+		if sameDecisions(recorded, replayed.Decisions) {
+			matched++
+			fmt.Printf("✅ %s 一致（%d 个决策）\n", label, len(recorded))
+			return nil
+		}
+
+		mismatched++
+		fmt.Printf("⚠️  %s 不一致: 记录 %d 个决策，回放 %d 个决策\n", label, len(recorded), len(replayed.Decisions))
+		if *verbose {
+			replayedJSON, _ := json.MarshalIndent(replayed.Decisions, "", "  ")
+			fmt.Printf("--- 记录\n%s\n+++ 回放\n%s\n", record.DecisionJSON, replayedJSON)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("❌ 读取决策日志失败: %v", err)
+	}
+	fmt.Printf("\n回放完成: 一致 %d，不一致 %d，未命中 %d，跳过 %d\n", matched, mismatched, missed, skipped)
+	if mismatched > 0 {
+		os.Exit(1)
+	}
+}
+
+// parseRecordedDecisions 解析决策记录中的决策JSON（没有决策时为空）
+func parseRecordedDecisions(data string) ([]decision.Decision, error) {
+	if data == "" {
+		return nil, nil
+	}
+	var decisions []decision.Decision
+	if err := json.Unmarshal([]byte(data), &decisions); err != nil {
+		return nil, err
+	}
+	return decisions, nil
+}
+
+// sameDecisions 两组决策是否相同（空和 nil 视为相同）
+func sameDecisions(a, b []decision.Decision) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
@@ -13,6 +13,7 @@ import (
 	"math/big"
 	"net/http"
 	"net/url"
+	"nofx/market"
 	"sort"
 	"strconv"
 	"strings"
@@ -36,6 +37,8 @@ type AsterTrader struct {
 	// 缓存交易对精度信息
 	symbolPrecision map[string]SymbolPrecision
 	mu              sync.RWMutex
+
+	symbols symbolRegistryCache // 可交易交易对
 }
 
 // SymbolPrecision 交易对精度信息
@@ -89,17 +92,41 @@ func (t *AsterTrader) getPrecision(symbol string) (SymbolPrecision, error) {
 	}
 	t.mu.RUnlock()
 
-	// 获取交易所信息
-	resp, err := t.client.Get(t.baseURL + "/fapi/v3/exchangeInfo")
+	registry, err := t.loadExchangeInfo()
 	if err != nil {
 		return SymbolPrecision{}, err
 	}
+	t.symbols.set(registry)
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if prec, ok := t.symbolPrecision[symbol]; ok {
+		return prec, nil
+	}
+
+	return SymbolPrecision{}, fmt.Errorf("未找到交易对 %s 的精度信息", symbol)
+}
+
+// SymbolRegistry Aster当前可交易的USDT永续合约（来自exchangeInfo）
+func (t *AsterTrader) SymbolRegistry() (*market.SymbolRegistry, error) {
+	return t.symbols.get(t.loadExchangeInfo)
+}
+
+// loadExchangeInfo 获取交易所信息，缓存所有交易对的精度并返回可交易交易对的注册表
+func (t *AsterTrader) loadExchangeInfo() (*market.SymbolRegistry, error) {
+	resp, err := t.client.Get(t.baseURL + "/fapi/v3/exchangeInfo")
+	if err != nil {
+		return nil, err
+	}
 	defer resp.Body.Close()
 
 	body, _ := io.ReadAll(resp.Body)
 	var info struct {
 		Symbols []struct {
 			Symbol            string                   `json:"symbol"`
+			Status            string                   `json:"status"`
+			ContractType      string                   `json:"contractType"`
+			QuoteAsset        string                   `json:"quoteAsset"`
 			PricePrecision    int                      `json:"pricePrecision"`
 			QuantityPrecision int                      `json:"quantityPrecision"`
 			Filters           []map[string]interface{} `json:"filters"`
@@ -107,10 +134,11 @@ func (t *AsterTrader) getPrecision(symbol string) (SymbolPrecision, error) {
 	}
 
 	if err := json.Unmarshal(body, &info); err != nil {
-		return SymbolPrecision{}, err
+		return nil, err
 	}
 
 	// 缓存所有交易对的精度
+	mapping := make(map[string]string, len(info.Symbols))
 	t.mu.Lock()
 	for _, s := range info.Symbols {
 		prec := SymbolPrecision{
@@ -134,14 +162,13 @@ func (t *AsterTrader) getPrecision(symbol string) (SymbolPrecision, error) {
 		}
 
 		t.symbolPrecision[s.Symbol] = prec
+		if s.Status == "TRADING" && s.ContractType == "PERPETUAL" && s.QuoteAsset == "USDT" {
+			mapping[s.Symbol] = s.Symbol
+		}
 	}
 	t.mu.Unlock()
 
-	if prec, ok := t.symbolPrecision[symbol]; ok {
-		return prec, nil
-	}
-
-	return SymbolPrecision{}, fmt.Errorf("未找到交易对 %s 的精度信息", symbol)
+	return market.NewSymbolRegistry("aster", mapping), nil
 }
 
 // roundToTickSize 将价格/数量四舍五入到tick size/step size的整数倍
@@ -981,6 +1008,83 @@ func (t *AsterTrader) CancelAllOrders(symbol string) error {
 	return err
 }
 
+// GetFills 获取成交明细（只统计以USDT计的手续费）
+func (t *AsterTrader) GetFills(symbol string, start, end time.Time) ([]Fill, error) {
+	params := map[string]interface{}{
+		"symbol":    symbol,
+		"startTime": start.UnixMilli(),
+		"endTime":   end.UnixMilli(),
+		"limit":     1000,
+	}
+	body, err := t.request("GET", "/fapi/v3/userTrades", params)
+	if err != nil {
+		return nil, fmt.Errorf("获取成交明细失败: %w", err)
+	}
+
+	var trades []struct {
+		Buyer           bool   `json:"buyer"`
+		Commission      string `json:"commission"`
+		CommissionAsset string `json:"commissionAsset"`
+		OrderID         int64  `json:"orderId"`
+		PositionSide    string `json:"positionSide"`
+		Price           string `json:"price"`
+		Qty             string `json:"qty"`
+		Time            int64  `json:"time"`
+	}
+	if err := json.Unmarshal(body, &trades); err != nil {
+		return nil, fmt.Errorf("解析成交明细失败: %w", err)
+	}
+
+	fills := make([]Fill, 0, len(trades))
+	for _, trade := range trades {
+		price, _ := strconv.ParseFloat(trade.Price, 64)
+		quantity, _ := strconv.ParseFloat(trade.Qty, 64)
+		fee := 0.0
+		if trade.CommissionAsset == "USDT" {
+			fee, _ = strconv.ParseFloat(trade.Commission, 64)
+		}
+		fills = append(fills, Fill{
+			OrderID:      trade.OrderID,
+			Buy:          trade.Buyer,
+			PositionSide: trade.PositionSide,
+			Price:        price,
+			Quantity:     quantity,
+			Fee:          fee,
+			Time:         time.UnixMilli(trade.Time),
+		})
+	}
+	return fills, nil
+}
+
+// GetFundingFees 获取资金费净额
+func (t *AsterTrader) GetFundingFees(symbol string, start, end time.Time) (float64, error) {
+	params := map[string]interface{}{
+		"symbol":     symbol,
+		"incomeType": "FUNDING_FEE",
+		"startTime":  start.UnixMilli(),
+		"endTime":    end.UnixMilli(),
+		"limit":      1000,
+	}
+	body, err := t.request("GET", "/fapi/v3/income", params)
+	if err != nil {
+		return 0, fmt.Errorf("获取资金费记录失败: %w", err)
+	}
+
+	var incomes []struct {
+		Income string `json:"income"`
+	}
+	if err := json.Unmarshal(body, &incomes); err != nil {
+		return 0, fmt.Errorf("解析资金费记录失败: %w", err)
+	}
+
+	total := 0.0
+	for _, income := range incomes {
+		amount, _ := strconv.ParseFloat(income.Income, 64)
+		total += amount
+	}
+	return total, nil
+}
+
 // FormatQuantity 格式化数量（实现Trader接口）
 func (t *AsterTrader) FormatQuantity(symbol string, quantity float64) (string, error) {
 	formatted, err := t.formatQuantity(symbol, quantity)
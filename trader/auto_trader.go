@@ -1,15 +1,18 @@
 package trader
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"nofx/decision"
 	"nofx/logger"
 	"nofx/market"
+	"nofx/market/indicator"
 	"nofx/mcp"
 	"nofx/pool"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -37,7 +40,8 @@ type AutoTraderConfig struct {
 	AsterSigner     string // Aster API钱包地址
 	AsterPrivateKey string // Aster API钱包私钥
 
-	CoinPoolAPIURL string
+	CoinPoolAPIURL string // AI500币种池API（为空不启用）
+	OITopAPIURL    string // OI Top API（为空不启用）
 
 	// AI配置
 	UseQwen     bool
@@ -73,6 +77,27 @@ type AutoTraderConfig struct {
 
 	// 系统提示词模板
 	SystemPromptTemplate string // 系统提示词模板名称（如 "default", "aggressive"）
+
+	// 决策流程（为空或 "single" 为单次调用，"multi_agent" 为分析师 → 风控经理 → 执行者）
+	DecisionPipeline string
+
+	// 额外K线周期（为空时使用提示词模板声明的周期）
+	Timeframes []market.Timeframe
+
+	// 自动可交易池筛选条件（nil 表示不启用，候选币种沿用默认币种/自定义币种）
+	Universe *pool.UniverseFilters
+
+	// 币种限制（只限制开仓）
+	SymbolBlacklist  []string         // 黑名单
+	SymbolWhitelist  []string         // 白名单（为空不限制）
+	SymbolCooldowns  []SymbolCooldown // 启动时恢复的冷却期
+	StopLossCooldown time.Duration    // 止损后自动冷却时长（0 表示不自动冷却）
+
+	// 决策日志存储（nil 表示写入 decision_logs/<ID> 目录下的JSON文件）
+	DecisionStore logger.DecisionStore
+
+	// 交易账本（nil 表示写入 trade_ledger/<ID>.json）
+	TradeLedger logger.TradeLedger
 }
 
 // AutoTrader 自动交易器
@@ -83,13 +108,14 @@ type AutoTrader struct {
 	exchange              string // 交易平台名称
 	config                AutoTraderConfig
 	trader                Trader // 使用Trader接口（支持多平台）
-	mcpClient             *mcp.Client
+	mcpClient             mcp.AIClient
 	decisionLogger        *logger.DecisionLogger // 决策日志记录器
 	initialBalance        float64
 	dailyPnL              float64
 	customPrompt          string   // 自定义交易策略prompt
 	overrideBasePrompt    bool     // 是否覆盖基础prompt
 	systemPromptTemplate  string   // 系统提示词模板名称
+	decisionPipeline      string   // 决策流程名称
 	defaultCoins          []string // 默认币种列表（从数据库获取）
 	tradingCoins          []string // 实际交易币种列表
 	lastResetTime         time.Time
@@ -98,6 +124,13 @@ type AutoTrader struct {
 	startTime             time.Time        // 系统启动时间
 	callCount             int              // AI调用次数
 	positionFirstSeenTime map[string]int64 // 持仓首次出现时间 (symbol_side -> timestamp毫秒)
+	cot                   *cotBroadcaster  // 实时思维链广播（流式输出与中止）
+	coinPool              *pool.Pool       // 交易员自己的币种池（信号源地址来自所属用户的配置）
+
+	symbolRules      *SymbolRules                     // 币种黑白名单与冷却期
+	positionLastSeen map[string]decision.PositionInfo // 持仓最近一次的快照 (symbol_side -> 持仓)，用于识别止损和记录平仓
+	ledger           logger.TradeLedger               // 交易账本
+	executionMu      sync.Mutex                       // 决策执行锁：AI周期与手动决策（MCP等）串行执行，避免同一币种重复开仓
 }
 
 // NewAutoTrader 创建自动交易器
@@ -142,10 +175,13 @@ func NewAutoTrader(config AutoTraderConfig) (*AutoTrader, error) {
 		}
 	}
 
-	// 初始化币种池API
-	if config.CoinPoolAPIURL != "" {
-		pool.SetCoinPoolAPI(config.CoinPoolAPIURL)
-	}
+	// 初始化交易员自己的币种池（不同用户的信号源地址互不影响）
+	coinPool := pool.New(config.ID, pool.CoinPoolConfig{
+		APIURL:          config.CoinPoolAPIURL,
+		OITopAPIURL:     config.OITopAPIURL,
+		RefreshInterval: config.ScanInterval,
+		Universe:        config.Universe,
+	})
 
 	// 设置默认交易平台
 	if config.Exchange == "" {
@@ -188,9 +224,24 @@ func NewAutoTrader(config AutoTraderConfig) (*AutoTrader, error) {
 		return nil, fmt.Errorf("初始金额必须大于0，请在配置中设置InitialBalance")
 	}
 
-	// 初始化决策日志记录器（使用trader ID创建独立目录）
-	logDir := fmt.Sprintf("decision_logs/%s", config.ID)
-	decisionLogger := logger.NewDecisionLogger(logDir)
+	// 初始化决策日志记录器（未指定存储时使用trader ID创建独立目录）
+	var decisionLogger *logger.DecisionLogger
+	if config.DecisionStore != nil {
+		decisionLogger = logger.NewDecisionLoggerWithStore(config.DecisionStore)
+	} else {
+		decisionLogger = logger.NewDecisionLogger(fmt.Sprintf("decision_logs/%s", config.ID))
+	}
+
+	// 初始化交易账本（交易表现统计以账本为准）
+	ledger := config.TradeLedger
+	if ledger == nil {
+		fileLedger, err := logger.NewFileTradeLedger(fmt.Sprintf("trade_ledger/%s.json", config.ID))
+		if err != nil {
+			return nil, fmt.Errorf("初始化交易账本失败: %w", err)
+		}
+		ledger = fileLedger
+	}
+	decisionLogger.SetTradeLedger(ledger)
 
 	// 设置默认系统提示词模板
 	systemPromptTemplate := config.SystemPromptTemplate
@@ -198,7 +249,7 @@ func NewAutoTrader(config AutoTraderConfig) (*AutoTrader, error) {
 		systemPromptTemplate = "default" // 默认使用 default 模板
 	}
 
-	return &AutoTrader{
+	at := &AutoTrader{
 		id:                    config.ID,
 		name:                  config.Name,
 		aiModel:               config.AIModel,
@@ -209,6 +260,7 @@ func NewAutoTrader(config AutoTraderConfig) (*AutoTrader, error) {
 		decisionLogger:        decisionLogger,
 		initialBalance:        config.InitialBalance,
 		systemPromptTemplate:  systemPromptTemplate,
+		decisionPipeline:      config.DecisionPipeline,
 		defaultCoins:          config.DefaultCoins,
 		tradingCoins:          config.TradingCoins,
 		lastResetTime:         time.Now(),
@@ -216,7 +268,15 @@ func NewAutoTrader(config AutoTraderConfig) (*AutoTrader, error) {
 		callCount:             0,
 		isRunning:             false,
 		positionFirstSeenTime: make(map[string]int64),
-	}, nil
+		cot:                   newCoTBroadcaster(config.ID),
+		coinPool:              coinPool,
+		symbolRules:           NewSymbolRules(config.SymbolBlacklist, config.SymbolWhitelist, config.SymbolCooldowns, config.StopLossCooldown),
+		positionLastSeen:      make(map[string]decision.PositionInfo),
+		ledger:                ledger,
+	}
+	at.backfillLedger()
+
+	return at, nil
 }
 
 // Run 运行自动交易主循环
@@ -250,6 +310,7 @@ func (at *AutoTrader) Run() error {
 // Stop 停止自动交易
 func (at *AutoTrader) Stop() {
 	at.isRunning = false
+	at.coinPool.Stop()
 	log.Println("⏹ 自动交易系统停止")
 }
 
@@ -257,9 +318,9 @@ func (at *AutoTrader) Stop() {
 func (at *AutoTrader) runCycle() error {
 	at.callCount++
 
-	log.Printf("\n" + strings.Repeat("=", 70))
+	log.Print("\n" + strings.Repeat("=", 70))
 	log.Printf("⏰ %s - AI决策周期 #%d", time.Now().Format("2006-01-02 15:04:05"), at.callCount)
-	log.Printf(strings.Repeat("=", 70))
+	log.Print(strings.Repeat("=", 70))
 
 	// 创建决策记录
 	record := &logger.DecisionRecord{
@@ -325,18 +386,41 @@ func (at *AutoTrader) runCycle() error {
 		ctx.Account.TotalEquity, ctx.Account.AvailableBalance, ctx.Account.PositionCount)
 
 	// 4. 调用AI获取完整决策
-	log.Printf("🤖 正在请求AI分析并决策... [模板: %s]", at.systemPromptTemplate)
-	decision, err := decision.GetFullDecisionWithCustomPrompt(ctx, at.mcpClient, at.customPrompt, at.overrideBasePrompt, at.systemPromptTemplate)
+	log.Printf("🤖 正在请求AI分析并决策... [模板: %s, 流程: %s]", at.systemPromptTemplate, at.GetDecisionPipeline())
+	aiClient, finishAI := at.beginAIDecision()
+	decision, err := decision.GetFullDecisionWithPipeline(ctx, aiClient, at.decisionPipeline, at.customPrompt, at.overrideBasePrompt, at.systemPromptTemplate)
+	finishAI(err)
 
 	// 即使有错误，也保存思维链、决策和输入prompt（用于debug）
 	if decision != nil {
 		record.SystemPrompt = decision.SystemPrompt // 保存系统提示词
 		record.InputPrompt = decision.UserPrompt
 		record.CoTTrace = decision.CoTTrace
+		record.Pipeline = decision.Pipeline
+		for _, stage := range decision.Stages {
+			record.PipelineStages = append(record.PipelineStages, logger.PipelineStageRecord{
+				Role:         stage.Role,
+				Template:     stage.Template,
+				SystemPrompt: stage.SystemPrompt,
+				UserPrompt:   stage.UserPrompt,
+				Response:     stage.Response,
+				DurationMs:   stage.DurationMs,
+				Error:        stage.Error,
+			})
+		}
 		if len(decision.Decisions) > 0 {
 			decisionJSON, _ := json.MarshalIndent(decision.Decisions, "", "  ")
 			record.DecisionJSON = string(decisionJSON)
 		}
+		if stats := decision.CallStats; stats != nil {
+			record.AIQueueWaitMs = stats.QueueWait.Milliseconds()
+			record.AIAttempts = stats.Attempts
+			if stats.QueueWait >= time.Second || stats.Attempts > 1 {
+				log.Printf("⏳ AI请求排队 %v，请求 %d 次", stats.QueueWait.Round(time.Millisecond), stats.Attempts)
+				record.ExecutionLog = append(record.ExecutionLog,
+					fmt.Sprintf("⏳ AI请求排队 %v，请求 %d 次", stats.QueueWait.Round(time.Millisecond), stats.Attempts))
+			}
+		}
 	}
 
 	if err != nil {
@@ -346,19 +430,19 @@ func (at *AutoTrader) runCycle() error {
 		// 打印系统提示词和AI思维链（即使有错误，也要输出以便调试）
 		if decision != nil {
 			if decision.SystemPrompt != "" {
-				log.Printf("\n" + strings.Repeat("=", 70))
+				log.Print("\n" + strings.Repeat("=", 70))
 				log.Printf("📋 系统提示词 [模板: %s] (错误情况)", at.systemPromptTemplate)
 				log.Println(strings.Repeat("=", 70))
 				log.Println(decision.SystemPrompt)
-				log.Printf(strings.Repeat("=", 70) + "\n")
+				log.Print(strings.Repeat("=", 70) + "\n")
 			}
 
 			if decision.CoTTrace != "" {
-				log.Printf("\n" + strings.Repeat("-", 70))
+				log.Print("\n" + strings.Repeat("-", 70))
 				log.Println("💭 AI思维链分析（错误情况）:")
 				log.Println(strings.Repeat("-", 70))
 				log.Println(decision.CoTTrace)
-				log.Printf(strings.Repeat("-", 70) + "\n")
+				log.Print(strings.Repeat("-", 70) + "\n")
 			}
 		}
 
@@ -367,18 +451,18 @@ func (at *AutoTrader) runCycle() error {
 	}
 
 	// // 5. 打印系统提示词
-	// log.Printf("\n" + strings.Repeat("=", 70))
+	// log.Print("\n" + strings.Repeat("=", 70))
 	// log.Printf("📋 系统提示词 [模板: %s]", at.systemPromptTemplate)
 	// log.Println(strings.Repeat("=", 70))
 	// log.Println(decision.SystemPrompt)
-	// log.Printf(strings.Repeat("=", 70) + "\n")
+	// log.Print(strings.Repeat("=", 70) + "\n")
 
 	// 6. 打印AI思维链
-	// log.Printf("\n" + strings.Repeat("-", 70))
+	// log.Print("\n" + strings.Repeat("-", 70))
 	// log.Println("💭 AI思维链分析:")
 	// log.Println(strings.Repeat("-", 70))
 	// log.Println(decision.CoTTrace)
-	// log.Printf(strings.Repeat("-", 70) + "\n")
+	// log.Print(strings.Repeat("-", 70) + "\n")
 
 	// 7. 打印AI决策
 	// log.Printf("📋 AI决策列表 (%d 个):\n", len(decision.Decisions))
@@ -400,7 +484,9 @@ func (at *AutoTrader) runCycle() error {
 	}
 	log.Println()
 
-	// 执行决策并记录结果
+	// 执行决策并记录结果（与手动决策串行）
+	at.executionMu.Lock()
+	defer at.executionMu.Unlock()
 	for _, d := range sortedDecisions {
 		actionRecord := logger.DecisionAction{
 			Action:    d.Action,
@@ -419,6 +505,7 @@ func (at *AutoTrader) runCycle() error {
 		} else {
 			actionRecord.Success = true
 			record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("✓ %s %s 成功", d.Symbol, d.Action))
+			at.recordTrade(&d, &actionRecord, "ai")
 			// 成功执行后短暂延迟
 			time.Sleep(1 * time.Second)
 		}
@@ -430,6 +517,7 @@ func (at *AutoTrader) runCycle() error {
 	if err := at.decisionLogger.LogDecision(record); err != nil {
 		log.Printf("⚠ 保存决策记录失败: %v", err)
 	}
+	at.linkTrades(record)
 
 	return nil
 }
@@ -522,6 +610,7 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 			MarginUsed:       marginUsed,
 			UpdateTime:       updateTime,
 		})
+		at.positionLastSeen[posKey] = positionInfos[len(positionInfos)-1]
 	}
 
 	// 清理已平仓的持仓记录
@@ -530,12 +619,25 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 			delete(at.positionFirstSeenTime, key)
 		}
 	}
+	// 持仓消失（止损/止盈单成交或主动平仓），亏损消失的视为止损并按配置冷却
+	var vanished []decision.PositionInfo
+	for key, last := range at.positionLastSeen {
+		if currentPositionKeys[key] {
+			continue
+		}
+		delete(at.positionLastSeen, key)
+		at.symbolRules.onPositionClosed(last.Symbol, last.Side, last.UnrealizedPnL)
+		vanished = append(vanished, last)
+	}
+	// 同步交易账本（决策平仓已在执行时记录，这里只处理交易所侧的平仓和账本外的持仓）
+	at.syncLedgerPositions(positionInfos, vanished)
 
 	// 3. 获取交易员的候选币种池
 	candidateCoins, err := at.getCandidateCoins()
 	if err != nil {
 		return nil, fmt.Errorf("获取候选币种失败: %w", err)
 	}
+	candidateCoins = at.filterTradableCandidates(candidateCoins)
 
 	// 4. 计算总盈亏
 	totalPnL := totalEquity - at.initialBalance
@@ -565,6 +667,12 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 		CallCount:       at.callCount,
 		BTCETHLeverage:  at.config.BTCETHLeverage,  // 使用配置的杠杆倍数
 		AltcoinLeverage: at.config.AltcoinLeverage, // 使用配置的杠杆倍数
+		Timeframes:      at.resolveTimeframes(),
+		Indicators:      at.resolveIndicators(),
+		MarketProvider:  at.marketProvider(),
+		CoinPool:        at.coinPool,
+		MinOIValue:      at.minOIValue(),
+		SymbolCheck:     at.symbolRules.Check,
 		Account: decision.AccountInfo{
 			TotalEquity:      totalEquity,
 			AvailableBalance: availableBalance,
@@ -584,6 +692,12 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 
 // executeDecisionWithRecord 执行AI决策并记录详细信息
 func (at *AutoTrader) executeDecisionWithRecord(decision *decision.Decision, actionRecord *logger.DecisionAction) error {
+	if decision.Action == "open_long" || decision.Action == "open_short" {
+		if err := at.symbolRules.Check(decision.Symbol); err != nil {
+			return err
+		}
+	}
+
 	switch decision.Action {
 	case "open_long":
 		return at.executeOpenLongWithRecord(decision, actionRecord)
@@ -616,7 +730,7 @@ func (at *AutoTrader) executeOpenLongWithRecord(decision *decision.Decision, act
 	}
 
 	// 获取当前价格
-	marketData, err := market.Get(decision.Symbol)
+	marketData, err := market.GetWithOptions(decision.Symbol, market.Options{Provider: at.marketProvider()})
 	if err != nil {
 		return err
 	}
@@ -626,6 +740,11 @@ func (at *AutoTrader) executeOpenLongWithRecord(decision *decision.Decision, act
 	actionRecord.Quantity = quantity
 	actionRecord.Price = marketData.CurrentPrice
 
+	// 根据订单簿深度估算滑点
+	if err := at.checkSlippage(decision.Symbol, true, decision.PositionSizeUSD, actionRecord); err != nil {
+		return err
+	}
+
 	// 设置仓位模式
 	if err := at.trader.SetMarginMode(decision.Symbol, at.config.IsCrossMargin); err != nil {
 		log.Printf("  ⚠️ 设置仓位模式失败: %v", err)
@@ -639,7 +758,7 @@ func (at *AutoTrader) executeOpenLongWithRecord(decision *decision.Decision, act
 	}
 
 	// 记录订单ID
-	if orderID, ok := order["orderId"].(int64); ok {
+	if orderID := orderIDOf(order); orderID > 0 {
 		actionRecord.OrderID = orderID
 	}
 
@@ -675,7 +794,7 @@ func (at *AutoTrader) executeOpenShortWithRecord(decision *decision.Decision, ac
 	}
 
 	// 获取当前价格
-	marketData, err := market.Get(decision.Symbol)
+	marketData, err := market.GetWithOptions(decision.Symbol, market.Options{Provider: at.marketProvider()})
 	if err != nil {
 		return err
 	}
@@ -685,6 +804,11 @@ func (at *AutoTrader) executeOpenShortWithRecord(decision *decision.Decision, ac
 	actionRecord.Quantity = quantity
 	actionRecord.Price = marketData.CurrentPrice
 
+	// 根据订单簿深度估算滑点
+	if err := at.checkSlippage(decision.Symbol, false, decision.PositionSizeUSD, actionRecord); err != nil {
+		return err
+	}
+
 	// 设置仓位模式
 	if err := at.trader.SetMarginMode(decision.Symbol, at.config.IsCrossMargin); err != nil {
 		log.Printf("  ⚠️ 设置仓位模式失败: %v", err)
@@ -698,7 +822,7 @@ func (at *AutoTrader) executeOpenShortWithRecord(decision *decision.Decision, ac
 	}
 
 	// 记录订单ID
-	if orderID, ok := order["orderId"].(int64); ok {
+	if orderID := orderIDOf(order); orderID > 0 {
 		actionRecord.OrderID = orderID
 	}
 
@@ -724,7 +848,7 @@ func (at *AutoTrader) executeCloseLongWithRecord(decision *decision.Decision, ac
 	log.Printf("  🔄 平多仓: %s", decision.Symbol)
 
 	// 获取当前价格
-	marketData, err := market.Get(decision.Symbol)
+	marketData, err := market.GetWithOptions(decision.Symbol, market.Options{Provider: at.marketProvider()})
 	if err != nil {
 		return err
 	}
@@ -735,9 +859,10 @@ func (at *AutoTrader) executeCloseLongWithRecord(decision *decision.Decision, ac
 	if err != nil {
 		return err
 	}
+	at.symbolRules.markClosed(decision.Symbol, "long")
 
 	// 记录订单ID
-	if orderID, ok := order["orderId"].(int64); ok {
+	if orderID := orderIDOf(order); orderID > 0 {
 		actionRecord.OrderID = orderID
 	}
 
@@ -750,7 +875,7 @@ func (at *AutoTrader) executeCloseShortWithRecord(decision *decision.Decision, a
 	log.Printf("  🔄 平空仓: %s", decision.Symbol)
 
 	// 获取当前价格
-	marketData, err := market.Get(decision.Symbol)
+	marketData, err := market.GetWithOptions(decision.Symbol, market.Options{Provider: at.marketProvider()})
 	if err != nil {
 		return err
 	}
@@ -761,9 +886,10 @@ func (at *AutoTrader) executeCloseShortWithRecord(decision *decision.Decision, a
 	if err != nil {
 		return err
 	}
+	at.symbolRules.markClosed(decision.Symbol, "short")
 
 	// 记录订单ID
-	if orderID, ok := order["orderId"].(int64); ok {
+	if orderID := orderIDOf(order); orderID > 0 {
 		actionRecord.OrderID = orderID
 	}
 
@@ -771,6 +897,70 @@ func (at *AutoTrader) executeCloseShortWithRecord(decision *decision.Decision, a
 	return nil
 }
 
+// ExecuteManualDecision 执行外部（如MCP工具）提交的手动决策
+// 与AI决策走同一套校验与执行流程，并写入决策日志以便复盘
+func (at *AutoTrader) ExecuteManualDecision(d *decision.Decision, source string) (*logger.DecisionAction, error) {
+	d.Symbol = normalizeSymbol(d.Symbol)
+
+	// 与AI决策周期的执行阶段串行，账户状态和持仓检查在锁内进行
+	at.executionMu.Lock()
+	defer at.executionMu.Unlock()
+
+	record := &logger.DecisionRecord{
+		ExecutionLog: []string{fmt.Sprintf("🖐 手动操作（来源: %s）", source)},
+		Success:      true,
+	}
+
+	account, err := at.GetAccountInfo()
+	if err != nil {
+		return nil, fmt.Errorf("获取账户信息失败: %w", err)
+	}
+	totalEquity, _ := account["total_equity"].(float64)
+	record.AccountState = logger.AccountSnapshot{
+		TotalBalance:          totalEquity,
+		AvailableBalance:      account["available_balance"].(float64),
+		TotalUnrealizedProfit: account["total_pnl"].(float64),
+		PositionCount:         account["position_count"].(int),
+		MarginUsedPct:         account["margin_used_pct"].(float64),
+	}
+
+	if err := decision.ValidateDecision(d, totalEquity, at.config.BTCETHLeverage, at.config.AltcoinLeverage); err != nil {
+		return nil, fmt.Errorf("决策校验失败: %w", err)
+	}
+
+	decisionJSON, _ := json.MarshalIndent([]decision.Decision{*d}, "", "  ")
+	record.DecisionJSON = string(decisionJSON)
+	record.CoTTrace = d.Reasoning
+
+	actionRecord := logger.DecisionAction{
+		Action:    d.Action,
+		Symbol:    d.Symbol,
+		Leverage:  d.Leverage,
+		Timestamp: time.Now(),
+	}
+
+	log.Printf("🖐 [%s] 执行手动决策（来源: %s）: %s %s", at.name, source, d.Symbol, d.Action)
+	execErr := at.executeDecisionWithRecord(d, &actionRecord)
+	if execErr != nil {
+		actionRecord.Error = execErr.Error()
+		record.Success = false
+		record.ErrorMessage = fmt.Sprintf("手动决策执行失败: %v", execErr)
+		record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("❌ %s %s 失败: %v", d.Symbol, d.Action, execErr))
+	} else {
+		actionRecord.Success = true
+		record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("✓ %s %s 成功", d.Symbol, d.Action))
+		at.recordTrade(d, &actionRecord, source)
+	}
+	record.Decisions = append(record.Decisions, actionRecord)
+
+	if err := at.decisionLogger.LogDecision(record); err != nil {
+		log.Printf("⚠ 保存决策记录失败: %v", err)
+	}
+	at.linkTrades(record)
+
+	return &actionRecord, execErr
+}
+
 // GetID 获取trader ID
 func (at *AutoTrader) GetID() string {
 	return at.id
@@ -806,11 +996,102 @@ func (at *AutoTrader) SetSystemPromptTemplate(templateName string) {
 	at.systemPromptTemplate = templateName
 }
 
+// SetAIClient 替换AI客户端（如回放客户端，用于离线复现与测试）
+func (at *AutoTrader) SetAIClient(client mcp.AIClient) {
+	at.mcpClient = client
+}
+
 // GetSystemPromptTemplate 获取当前系统提示词模板名称
 func (at *AutoTrader) GetSystemPromptTemplate() string {
 	return at.systemPromptTemplate
 }
 
+// SetDecisionPipeline 设置决策流程
+func (at *AutoTrader) SetDecisionPipeline(pipeline string) {
+	at.decisionPipeline = pipeline
+}
+
+// GetDecisionPipeline 获取当前决策流程名称（未配置时为 single）
+func (at *AutoTrader) GetDecisionPipeline() string {
+	if at.decisionPipeline == "" {
+		return decision.PipelineSingle
+	}
+	return at.decisionPipeline
+}
+
+// resolveTimeframes 本周期需要的额外K线周期：交易员配置优先，否则使用提示词模板声明的周期
+func (at *AutoTrader) resolveTimeframes() []market.Timeframe {
+	if len(at.config.Timeframes) > 0 {
+		return at.config.Timeframes
+	}
+	if template, err := decision.GetPromptTemplate(at.systemPromptTemplate); err == nil {
+		return template.Timeframes
+	}
+	return nil
+}
+
+// marketProvider 交易员所在交易所的行情来源
+func (at *AutoTrader) marketProvider() market.Provider {
+	return market.ProviderFor(at.exchange, at.config.HyperliquidTestnet)
+}
+
+// resolveIndicators 本周期需要的额外技术指标（由提示词模板声明）
+func (at *AutoTrader) resolveIndicators() []indicator.Spec {
+	if template, err := decision.GetPromptTemplate(at.systemPromptTemplate); err == nil {
+		return template.Indicators
+	}
+	return nil
+}
+
+// beginAIDecision 为本周期的AI决策准备客户端：流式推送思维链并支持中止
+// 返回的 finish 必须在决策结束后调用
+func (at *AutoTrader) beginAIDecision() (mcp.AIClient, func(err error)) {
+	aiCtx, cancel := context.WithCancel(context.Background())
+	sc, ok := at.mcpClient.(mcp.StreamingClient)
+	if ok {
+		at.cot.begin(at.callCount, cancel)
+	} else {
+		// 不支持流式的客户端（如回放）：无法中止，只推送开始/结束事件
+		at.cot.begin(at.callCount, nil)
+	}
+
+	finish := func(err error) {
+		switch {
+		case aiCtx.Err() != nil:
+			at.cot.end(CoTEventAborted, "AI请求已被中止")
+		case err != nil:
+			at.cot.end(CoTEventError, err.Error())
+		default:
+			at.cot.end(CoTEventDone, "")
+		}
+		cancel()
+	}
+
+	if !ok {
+		return at.mcpClient, finish
+	}
+	return &streamingAIClient{
+		client: sc,
+		ctx:    aiCtx,
+		cot:    at.cot,
+		stream: mcp.StreamingEnabled(),
+	}, finish
+}
+
+// SubscribeCoT 订阅实时思维链，返回事件通道和取消订阅函数
+func (at *AutoTrader) SubscribeCoT() (<-chan CoTEvent, func()) {
+	return at.cot.subscribe()
+}
+
+// AbortAIRequest 中止进行中的AI决策请求，没有进行中的请求时返回false
+func (at *AutoTrader) AbortAIRequest() bool {
+	aborted := at.cot.abort()
+	if aborted {
+		log.Printf("⏹  [%s] 用户中止了进行中的AI请求", at.name)
+	}
+	return aborted
+}
+
 // GetDecisionLogger 获取决策日志记录器
 func (at *AutoTrader) GetDecisionLogger() *logger.DecisionLogger {
 	return at.decisionLogger
@@ -824,19 +1105,20 @@ func (at *AutoTrader) GetStatus() map[string]interface{} {
 	}
 
 	return map[string]interface{}{
-		"trader_id":       at.id,
-		"trader_name":     at.name,
-		"ai_model":        at.aiModel,
-		"exchange":        at.exchange,
-		"is_running":      at.isRunning,
-		"start_time":      at.startTime.Format(time.RFC3339),
-		"runtime_minutes": int(time.Since(at.startTime).Minutes()),
-		"call_count":      at.callCount,
-		"initial_balance": at.initialBalance,
-		"scan_interval":   at.config.ScanInterval.String(),
-		"stop_until":      at.stopUntil.Format(time.RFC3339),
-		"last_reset_time": at.lastResetTime.Format(time.RFC3339),
-		"ai_provider":     aiProvider,
+		"trader_id":         at.id,
+		"trader_name":       at.name,
+		"ai_model":          at.aiModel,
+		"exchange":          at.exchange,
+		"is_running":        at.isRunning,
+		"start_time":        at.startTime.Format(time.RFC3339),
+		"runtime_minutes":   int(time.Since(at.startTime).Minutes()),
+		"call_count":        at.callCount,
+		"initial_balance":   at.initialBalance,
+		"scan_interval":     at.config.ScanInterval.String(),
+		"stop_until":        at.stopUntil.Format(time.RFC3339),
+		"last_reset_time":   at.lastResetTime.Format(time.RFC3339),
+		"ai_provider":       aiProvider,
+		"decision_pipeline": at.GetDecisionPipeline(),
 	}
 }
 
@@ -1011,8 +1293,35 @@ func sortDecisionsByPriority(decisions []decision.Decision) []decision.Decision
 	return sorted
 }
 
-// getCandidateCoins 获取交易员的候选币种列表
+// SymbolRules 交易员的币种黑白名单与冷却期（运行中修改立即生效）
+func (at *AutoTrader) SymbolRules() *SymbolRules {
+	return at.symbolRules
+}
+
+// getCandidateCoins 获取交易员的候选币种列表（已剔除黑名单、白名单以外和冷却中的币种）
 func (at *AutoTrader) getCandidateCoins() ([]decision.CandidateCoin, error) {
+	candidates, err := at.collectCandidateCoins()
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := make([]decision.CandidateCoin, 0, len(candidates))
+	for _, coin := range candidates {
+		if err := at.symbolRules.Check(coin.Symbol); err != nil {
+			log.Printf("🚫 [%s] 剔除候选币种: %v", at.name, err)
+			continue
+		}
+		allowed = append(allowed, coin)
+	}
+	return allowed, nil
+}
+
+// collectCandidateCoins 按配置收集候选币种（自定义币种、可交易池、默认币种或币种池信号源）
+func (at *AutoTrader) collectCandidateCoins() ([]decision.CandidateCoin, error) {
+	if len(at.tradingCoins) == 0 && at.config.Universe != nil {
+		// 启用了自动可交易池：可交易池与其他启用的信号源按权重合并
+		return at.mergedCandidateCoins()
+	}
 	if len(at.tradingCoins) == 0 {
 		// 使用数据库配置的默认币种列表
 		var candidateCoins []decision.CandidateCoin
@@ -1030,26 +1339,8 @@ func (at *AutoTrader) getCandidateCoins() ([]decision.CandidateCoin, error) {
 				at.name, len(candidateCoins), at.defaultCoins)
 			return candidateCoins, nil
 		} else {
-			// 如果数据库中没有配置默认币种，则使用AI500+OI Top作为fallback
-			const ai500Limit = 20 // AI500取前20个评分最高的币种
-
-			mergedPool, err := pool.GetMergedCoinPool(ai500Limit)
-			if err != nil {
-				return nil, fmt.Errorf("获取合并币种池失败: %w", err)
-			}
-
-			// 构建候选币种列表（包含来源信息）
-			for _, symbol := range mergedPool.AllSymbols {
-				sources := mergedPool.SymbolSources[symbol]
-				candidateCoins = append(candidateCoins, decision.CandidateCoin{
-					Symbol:  symbol,
-					Sources: sources, // "ai500" 和/或 "oi_top"
-				})
-			}
-
-			log.Printf("📋 [%s] 数据库无默认币种配置，使用AI500+OI Top: AI500前%d + OI_Top20 = 总计%d个候选币种",
-				at.name, ai500Limit, len(candidateCoins))
-			return candidateCoins, nil
+			// 如果数据库中没有配置默认币种，则使用币种池信号源（AI500、OI Top等按权重合并）作为fallback
+			return at.mergedCandidateCoins()
 		}
 	} else {
 		// 使用自定义币种列表
@@ -1069,6 +1360,38 @@ func (at *AutoTrader) getCandidateCoins() ([]decision.CandidateCoin, error) {
 	}
 }
 
+// mergedCandidateCoins 币种池各信号源（AI500、OI Top、可交易池等）按权重合并后的候选币种
+func (at *AutoTrader) mergedCandidateCoins() ([]decision.CandidateCoin, error) {
+	const ai500Limit = 20 // AI500取前20个评分最高的币种
+
+	mergedPool, err := at.coinPool.GetMergedCoinPool(ai500Limit)
+	if err != nil {
+		return nil, fmt.Errorf("获取合并币种池失败: %w", err)
+	}
+
+	// 构建候选币种列表（包含来源信息）
+	var candidateCoins []decision.CandidateCoin
+	for _, symbol := range mergedPool.AllSymbols {
+		candidateCoins = append(candidateCoins, decision.CandidateCoin{
+			Symbol:  symbol,
+			Sources: mergedPool.SymbolSources[symbol], // "ai500"、"oi_top"、"universe" 等启用的信号源
+			Score:   mergedPool.SymbolScores[symbol],
+		})
+	}
+
+	log.Printf("📋 [%s] 使用币种池信号源: %v = 总计%d个候选币种",
+		at.name, mergedPool.SourceCounts, len(candidateCoins))
+	return candidateCoins, nil
+}
+
+// minOIValue 候选币种的持仓价值下限（交易员的可交易池条件，未启用时使用全局默认条件）
+func (at *AutoTrader) minOIValue() float64 {
+	if at.config.Universe != nil {
+		return at.config.Universe.MinOIValue
+	}
+	return pool.UniverseDefaults().MinOIValue
+}
+
 // normalizeSymbol 标准化币种符号（确保以USDT结尾）
 func normalizeSymbol(symbol string) string {
 	// 转为大写
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"nofx/market"
 	"strconv"
 	"sync"
 	"time"
@@ -27,6 +28,8 @@ type FuturesTrader struct {
 
 	// 缓存有效期（15秒）
 	cacheDuration time.Duration
+
+	symbols symbolRegistryCache // 可交易交易对
 }
 
 // NewFuturesTrader 创建合约交易器
@@ -528,6 +531,83 @@ func (t *FuturesTrader) SetTakeProfit(symbol string, positionSide string, quanti
 	return nil
 }
 
+// SymbolRegistry 币安当前可交易的USDT永续合约
+func (t *FuturesTrader) SymbolRegistry() (*market.SymbolRegistry, error) {
+	return t.symbols.get(func() (*market.SymbolRegistry, error) {
+		exchangeInfo, err := t.client.NewExchangeInfoService().Do(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("获取交易规则失败: %w", err)
+		}
+		mapping := make(map[string]string, len(exchangeInfo.Symbols))
+		for _, s := range exchangeInfo.Symbols {
+			if s.Status == "TRADING" && s.ContractType == futures.ContractTypePerpetual && s.QuoteAsset == "USDT" {
+				mapping[s.Symbol] = s.Symbol
+			}
+		}
+		return market.NewSymbolRegistry("binance", mapping), nil
+	})
+}
+
+// binanceTradeWindow 币安成交查询的最大时间跨度
+const binanceTradeWindow = 7 * 24 * time.Hour
+
+// GetFills 获取成交明细（只统计以USDT计的手续费）
+func (t *FuturesTrader) GetFills(symbol string, start, end time.Time) ([]Fill, error) {
+	if end.Sub(start) > binanceTradeWindow {
+		start = end.Add(-binanceTradeWindow)
+	}
+	trades, err := t.client.NewListAccountTradeService().
+		Symbol(symbol).
+		StartTime(start.UnixMilli()).
+		EndTime(end.UnixMilli()).
+		Limit(1000).
+		Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("获取成交明细失败: %w", err)
+	}
+
+	fills := make([]Fill, 0, len(trades))
+	for _, trade := range trades {
+		price, _ := strconv.ParseFloat(trade.Price, 64)
+		quantity, _ := strconv.ParseFloat(trade.Quantity, 64)
+		fee := 0.0
+		if trade.CommissionAsset == "USDT" {
+			fee, _ = strconv.ParseFloat(trade.Commission, 64)
+		}
+		fills = append(fills, Fill{
+			OrderID:      trade.OrderID,
+			Buy:          trade.Buyer,
+			PositionSide: string(trade.PositionSide),
+			Price:        price,
+			Quantity:     quantity,
+			Fee:          fee,
+			Time:         time.UnixMilli(trade.Time),
+		})
+	}
+	return fills, nil
+}
+
+// GetFundingFees 获取资金费净额
+func (t *FuturesTrader) GetFundingFees(symbol string, start, end time.Time) (float64, error) {
+	incomes, err := t.client.NewGetIncomeHistoryService().
+		Symbol(symbol).
+		IncomeType("FUNDING_FEE").
+		StartTime(start.UnixMilli()).
+		EndTime(end.UnixMilli()).
+		Limit(1000).
+		Do(context.Background())
+	if err != nil {
+		return 0, fmt.Errorf("获取资金费记录失败: %w", err)
+	}
+
+	total := 0.0
+	for _, income := range incomes {
+		amount, _ := strconv.ParseFloat(income.Income, 64)
+		total += amount
+	}
+	return total, nil
+}
+
 // GetSymbolPrecision 获取交易对的数量精度
 func (t *FuturesTrader) GetSymbolPrecision(symbol string) (int, error) {
 	exchangeInfo, err := t.client.NewExchangeInfoService().Do(context.Background())
@@ -0,0 +1,187 @@
+package trader
+
+import (
+	"context"
+	"nofx/mcp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 实时思维链事件类型
+const (
+	CoTEventSnapshot = "snapshot" // 订阅时推送当前已生成的内容
+	CoTEventStart    = "start"    // 一次AI调用开始（多阶段流程中每个阶段一次）
+	CoTEventDelta    = "delta"    // 增量内容
+	CoTEventRestart  = "restart"  // 请求重试，之前的内容作废
+	CoTEventDone     = "done"     // 本周期的AI决策完成
+	CoTEventError    = "error"    // AI决策失败
+	CoTEventAborted  = "aborted"  // 被用户中止
+)
+
+// cotSubscriberBuffer 每个订阅者的事件缓冲，消费过慢时丢弃增量，不阻塞交易周期
+const cotSubscriberBuffer = 256
+
+// CoTEvent 实时思维链事件
+type CoTEvent struct {
+	Type      string    `json:"type"`
+	TraderID  string    `json:"trader_id"`
+	Cycle     int       `json:"cycle"`          // 决策周期编号
+	Call      int       `json:"call,omitempty"` // 本周期内第几次AI调用
+	Content   string    `json:"content,omitempty"`
+	Reasoning string    `json:"reasoning,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// cotBroadcaster 把一个交易员正在进行的AI输出广播给所有订阅者
+type cotBroadcaster struct {
+	traderID string
+
+	mu          sync.Mutex
+	subscribers map[chan CoTEvent]struct{}
+	active      bool
+	cycle       int
+	call        int
+	content     strings.Builder
+	reasoning   strings.Builder
+	cancel      context.CancelFunc
+}
+
+func newCoTBroadcaster(traderID string) *cotBroadcaster {
+	return &cotBroadcaster{
+		traderID:    traderID,
+		subscribers: make(map[chan CoTEvent]struct{}),
+	}
+}
+
+// subscribe 订阅实时思维链，返回事件通道和取消订阅函数
+// 如果当前有进行中的AI调用，先推送一条 snapshot 事件
+func (b *cotBroadcaster) subscribe() (<-chan CoTEvent, func()) {
+	ch := make(chan CoTEvent, cotSubscriberBuffer)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	if b.active {
+		ch <- b.event(CoTEventSnapshot, b.content.String(), b.reasoning.String(), "")
+	}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+		})
+	}
+}
+
+// begin 开始一个周期的AI决策，cancel 用于中止
+func (b *cotBroadcaster) begin(cycle int, cancel context.CancelFunc) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.active = true
+	b.cycle = cycle
+	b.call = 0
+	b.cancel = cancel
+	b.content.Reset()
+	b.reasoning.Reset()
+}
+
+// startCall 本周期内的一次新AI调用
+func (b *cotBroadcaster) startCall() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.call++
+	b.content.Reset()
+	b.reasoning.Reset()
+	b.publish(b.event(CoTEventStart, "", "", ""))
+}
+
+// onDelta 接收 mcp 流式增量（mcp.StreamHandler）
+func (b *cotBroadcaster) onDelta(delta mcp.StreamDelta) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if delta.Restart {
+		b.content.Reset()
+		b.reasoning.Reset()
+		b.publish(b.event(CoTEventRestart, "", "", ""))
+		return
+	}
+	b.content.WriteString(delta.Content)
+	b.reasoning.WriteString(delta.Reasoning)
+	b.publish(b.event(CoTEventDelta, delta.Content, delta.Reasoning, ""))
+}
+
+// end 结束本周期的AI决策
+func (b *cotBroadcaster) end(eventType, errMsg string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.active = false
+	b.cancel = nil
+	b.publish(b.event(eventType, "", "", errMsg))
+}
+
+// abort 中止进行中的AI调用，没有进行中的调用时返回false
+func (b *cotBroadcaster) abort() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.active || b.cancel == nil {
+		return false
+	}
+	b.cancel()
+	return true
+}
+
+// event 构建事件（需持有锁）
+func (b *cotBroadcaster) event(eventType, content, reasoning, errMsg string) CoTEvent {
+	return CoTEvent{
+		Type:      eventType,
+		TraderID:  b.traderID,
+		Cycle:     b.cycle,
+		Call:      b.call,
+		Content:   content,
+		Reasoning: reasoning,
+		Error:     errMsg,
+		Timestamp: time.Now(),
+	}
+}
+
+// publish 非阻塞地推送给所有订阅者（需持有锁）
+func (b *cotBroadcaster) publish(ev CoTEvent) {
+	for ch := range b.subscribers {
+		select {
+		case ch <- ev:
+		default:
+			// 订阅者消费过慢，丢弃该事件
+		}
+	}
+}
+
+// streamingAIClient 决策周期内使用的AI客户端：流式调用并把增量推送给订阅者，支持中止
+type streamingAIClient struct {
+	client mcp.StreamingClient
+	ctx    context.Context
+	cot    *cotBroadcaster
+	stream bool // false 时使用普通请求（仍可中止）
+}
+
+func (s *streamingAIClient) CallWithMessages(systemPrompt, userPrompt string) (string, error) {
+	result, _, err := s.CallWithMessagesStats(systemPrompt, userPrompt)
+	return result, err
+}
+
+func (s *streamingAIClient) CallWithMessagesStats(systemPrompt, userPrompt string) (string, *mcp.CallStats, error) {
+	s.cot.startCall()
+	var onDelta mcp.StreamHandler
+	if s.stream {
+		onDelta = s.cot.onDelta
+	}
+	result, stats, err := s.client.CallWithMessagesStream(s.ctx, systemPrompt, userPrompt, onDelta)
+	if err == nil && !s.stream {
+		// 非流式请求一次性推送完整内容
+		s.cot.onDelta(mcp.StreamDelta{Content: result})
+	}
+	return result, stats, err
+}
@@ -1,11 +1,15 @@
 package trader
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/http"
+	"nofx/market"
 	"strconv"
+	"time"
 
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/sonirico/go-hyperliquid"
@@ -16,8 +20,11 @@ type HyperliquidTrader struct {
 	exchange      *hyperliquid.Exchange
 	ctx           context.Context
 	walletAddr    string
+	apiURL        string
 	meta          *hyperliquid.Meta // 缓存meta信息（包含精度等）
 	isCrossMargin bool              // 是否为全仓模式
+
+	symbols symbolRegistryCache // 可交易币种（标准交易对 <-> Hyperliquid币种名）
 }
 
 // NewHyperliquidTrader 创建Hyperliquid交易器
@@ -63,13 +70,58 @@ func NewHyperliquidTrader(privateKeyHex string, walletAddr string, testnet bool)
 		return nil, fmt.Errorf("获取meta信息失败: %w", err)
 	}
 
-	return &HyperliquidTrader{
+	t := &HyperliquidTrader{
 		exchange:      exchange,
 		ctx:           ctx,
 		walletAddr:    walletAddr,
+		apiURL:        apiURL,
 		meta:          meta,
 		isCrossMargin: true, // 默认使用全仓模式
-	}, nil
+	}
+	t.symbols.set(hyperliquidRegistry(meta))
+	return t, nil
+}
+
+// SymbolRegistry Hyperliquid当前可交易的币种（来自meta，已下架的币种除外）
+func (t *HyperliquidTrader) SymbolRegistry() (*market.SymbolRegistry, error) {
+	return t.symbols.get(func() (*market.SymbolRegistry, error) {
+		meta, err := t.exchange.Info().Meta(t.ctx)
+		if err != nil {
+			return nil, fmt.Errorf("获取meta信息失败: %w", err)
+		}
+		return hyperliquidRegistry(meta), nil
+	})
+}
+
+// hyperliquidRegistry 根据meta构建交易对注册表
+func hyperliquidRegistry(meta *hyperliquid.Meta) *market.SymbolRegistry {
+	mapping := make(map[string]string, len(meta.Universe))
+	for _, asset := range meta.Universe {
+		if !asset.IsDelisted {
+			mapping[market.HyperliquidCanonical(asset.Name)] = asset.Name
+		}
+	}
+	return market.NewSymbolRegistry("hyperliquid", mapping)
+}
+
+// coin 标准交易对对应的Hyperliquid币种名（优先使用注册表，例如 "1000PEPEUSDT" -> "kPEPE"）
+func (t *HyperliquidTrader) coin(symbol string) string {
+	if registry := t.symbols.cached(); registry != nil {
+		if coin, ok := registry.VenueSymbol(symbol); ok {
+			return coin
+		}
+	}
+	return market.HyperliquidCoin(symbol)
+}
+
+// canonicalSymbol Hyperliquid币种名对应的标准交易对
+func (t *HyperliquidTrader) canonicalSymbol(coin string) string {
+	if registry := t.symbols.cached(); registry != nil {
+		if symbol, ok := registry.Canonical(coin); ok {
+			return symbol
+		}
+	}
+	return market.HyperliquidCanonical(coin)
 }
 
 // GetBalance 获取账户余额
@@ -146,8 +198,8 @@ func (t *HyperliquidTrader) GetPositions() ([]map[string]interface{}, error) {
 
 		posMap := make(map[string]interface{})
 
-		// 标准化symbol格式（Hyperliquid使用如"BTC"，我们转换为"BTCUSDT"）
-		symbol := position.Coin + "USDT"
+		// 标准化symbol格式（Hyperliquid使用如"BTC"、"kPEPE"，转换为"BTCUSDT"、"1000PEPEUSDT"）
+		symbol := t.canonicalSymbol(position.Coin)
 		posMap["symbol"] = symbol
 
 		// 持仓数量和方向
@@ -204,7 +256,7 @@ func (t *HyperliquidTrader) SetMarginMode(symbol string, isCrossMargin bool) err
 // SetLeverage 设置杠杆
 func (t *HyperliquidTrader) SetLeverage(symbol string, leverage int) error {
 	// Hyperliquid symbol格式（去掉USDT后缀）
-	coin := convertSymbolToHyperliquid(symbol)
+	coin := t.coin(symbol)
 
 	// 调用UpdateLeverage (leverage int, name string, isCross bool)
 	// 第三个参数: true=全仓模式, false=逐仓模式
@@ -230,7 +282,7 @@ func (t *HyperliquidTrader) OpenLong(symbol string, quantity float64, leverage i
 	}
 
 	// Hyperliquid symbol格式
-	coin := convertSymbolToHyperliquid(symbol)
+	coin := t.coin(symbol)
 
 	// 获取当前价格（用于市价单）
 	price, err := t.GetMarketPrice(symbol)
@@ -288,7 +340,7 @@ func (t *HyperliquidTrader) OpenShort(symbol string, quantity float64, leverage
 	}
 
 	// Hyperliquid symbol格式
-	coin := convertSymbolToHyperliquid(symbol)
+	coin := t.coin(symbol)
 
 	// 获取当前价格
 	price, err := t.GetMarketPrice(symbol)
@@ -355,7 +407,7 @@ func (t *HyperliquidTrader) CloseLong(symbol string, quantity float64) (map[stri
 	}
 
 	// Hyperliquid symbol格式
-	coin := convertSymbolToHyperliquid(symbol)
+	coin := t.coin(symbol)
 
 	// 获取当前价格
 	price, err := t.GetMarketPrice(symbol)
@@ -427,7 +479,7 @@ func (t *HyperliquidTrader) CloseShort(symbol string, quantity float64) (map[str
 	}
 
 	// Hyperliquid symbol格式
-	coin := convertSymbolToHyperliquid(symbol)
+	coin := t.coin(symbol)
 
 	// 获取当前价格
 	price, err := t.GetMarketPrice(symbol)
@@ -479,7 +531,7 @@ func (t *HyperliquidTrader) CloseShort(symbol string, quantity float64) (map[str
 
 // CancelAllOrders 取消该币种的所有挂单
 func (t *HyperliquidTrader) CancelAllOrders(symbol string) error {
-	coin := convertSymbolToHyperliquid(symbol)
+	coin := t.coin(symbol)
 
 	// 获取所有挂单
 	openOrders, err := t.exchange.Info().OpenOrders(t.ctx, t.walletAddr)
@@ -503,7 +555,7 @@ func (t *HyperliquidTrader) CancelAllOrders(symbol string) error {
 
 // GetMarketPrice 获取市场价格
 func (t *HyperliquidTrader) GetMarketPrice(symbol string) (float64, error) {
-	coin := convertSymbolToHyperliquid(symbol)
+	coin := t.coin(symbol)
 
 	// 获取所有市场价格
 	allMids, err := t.exchange.Info().AllMids(t.ctx)
@@ -525,7 +577,7 @@ func (t *HyperliquidTrader) GetMarketPrice(symbol string) (float64, error) {
 
 // SetStopLoss 设置止损单
 func (t *HyperliquidTrader) SetStopLoss(symbol string, positionSide string, quantity, stopPrice float64) error {
-	coin := convertSymbolToHyperliquid(symbol)
+	coin := t.coin(symbol)
 
 	isBuy := positionSide == "SHORT" // 空仓止损=买入，多仓止损=卖出
 
@@ -562,7 +614,7 @@ func (t *HyperliquidTrader) SetStopLoss(symbol string, positionSide string, quan
 
 // SetTakeProfit 设置止盈单
 func (t *HyperliquidTrader) SetTakeProfit(symbol string, positionSide string, quantity, takeProfitPrice float64) error {
-	coin := convertSymbolToHyperliquid(symbol)
+	coin := t.coin(symbol)
 
 	isBuy := positionSide == "SHORT" // 空仓止盈=买入，多仓止盈=卖出
 
@@ -597,9 +649,83 @@ func (t *HyperliquidTrader) SetTakeProfit(symbol string, positionSide string, qu
 	return nil
 }
 
+// GetFills 获取成交明细（Hyperliquid下单不返回订单ID，账本按买卖方向匹配成交）
+func (t *HyperliquidTrader) GetFills(symbol string, start, end time.Time) ([]Fill, error) {
+	endMs := end.UnixMilli()
+	hlFills, err := t.exchange.Info().UserFillsByTime(t.ctx, t.walletAddr, start.UnixMilli(), &endMs)
+	if err != nil {
+		return nil, fmt.Errorf("获取成交明细失败: %w", err)
+	}
+
+	coin := t.coin(symbol)
+	var fills []Fill
+	for _, fill := range hlFills {
+		if fill.Coin != coin {
+			continue
+		}
+		price, _ := strconv.ParseFloat(fill.Price, 64)
+		quantity, _ := strconv.ParseFloat(fill.Size, 64)
+		fee, _ := strconv.ParseFloat(fill.Fee, 64)
+		fills = append(fills, Fill{
+			OrderID:  fill.Oid,
+			Buy:      fill.Side == "B",
+			Price:    price,
+			Quantity: quantity,
+			Fee:      fee,
+			Time:     time.UnixMilli(fill.Time),
+		})
+	}
+	return fills, nil
+}
+
+// GetFundingFees 获取资金费净额
+// SDK 的 UserFundingHistory 没有解析资金费金额，这里直接请求 /info 的 userFunding
+func (t *HyperliquidTrader) GetFundingFees(symbol string, start, end time.Time) (float64, error) {
+	payload, _ := json.Marshal(map[string]interface{}{
+		"type":      "userFunding",
+		"user":      t.walletAddr,
+		"startTime": start.UnixMilli(),
+		"endTime":   end.UnixMilli(),
+	})
+	req, err := http.NewRequestWithContext(t.ctx, http.MethodPost, t.apiURL+"/info", bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("获取资金费记录失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("获取资金费记录失败: HTTP %d", resp.StatusCode)
+	}
+
+	var records []struct {
+		Delta struct {
+			Coin string `json:"coin"`
+			USDC string `json:"usdc"`
+		} `json:"delta"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&records); err != nil {
+		return 0, fmt.Errorf("解析资金费记录失败: %w", err)
+	}
+
+	coin := t.coin(symbol)
+	total := 0.0
+	for _, record := range records {
+		if record.Delta.Coin != coin {
+			continue
+		}
+		amount, _ := strconv.ParseFloat(record.Delta.USDC, 64)
+		total += amount
+	}
+	return total, nil
+}
+
 // FormatQuantity 格式化数量到正确的精度
 func (t *HyperliquidTrader) FormatQuantity(symbol string, quantity float64) (string, error) {
-	coin := convertSymbolToHyperliquid(symbol)
+	coin := t.coin(symbol)
 	szDecimals := t.getSzDecimals(coin)
 
 	// 使用szDecimals格式化数量
@@ -677,16 +803,6 @@ func (t *HyperliquidTrader) roundPriceToSigfigs(price float64) float64 {
 	return rounded
 }
 
-// convertSymbolToHyperliquid 将标准symbol转换为Hyperliquid格式
-// 例如: "BTCUSDT" -> "BTC"
-func convertSymbolToHyperliquid(symbol string) string {
-	// 去掉USDT后缀
-	if len(symbol) > 4 && symbol[len(symbol)-4:] == "USDT" {
-		return symbol[:len(symbol)-4]
-	}
-	return symbol
-}
-
 // absFloat 返回浮点数的绝对值
 func absFloat(x float64) float64 {
 	if x < 0 {
@@ -0,0 +1,61 @@
+package trader
+
+import (
+	"fmt"
+	"log"
+	"nofx/logger"
+	"nofx/market"
+	"sync"
+)
+
+// DefaultMaxSlippagePct 默认开仓允许的最大预估滑点（%）
+const DefaultMaxSlippagePct = 1.0
+
+var (
+	slippageMu     sync.RWMutex
+	maxSlippagePct = DefaultMaxSlippagePct
+)
+
+// SetMaxSlippagePct 设置开仓允许的最大预估滑点（%），<=0 表示不检查
+func SetMaxSlippagePct(pct float64) {
+	slippageMu.Lock()
+	maxSlippagePct = pct
+	slippageMu.Unlock()
+	if pct > 0 {
+		log.Printf("🔧 开仓最大预估滑点: %.2f%%", pct)
+	} else {
+		log.Printf("🔧 开仓滑点检查已关闭")
+	}
+}
+
+// MaxSlippagePct 当前开仓允许的最大预估滑点（%）
+func MaxSlippagePct() float64 {
+	slippageMu.RLock()
+	defer slippageMu.RUnlock()
+	return maxSlippagePct
+}
+
+// checkSlippage 下市价单前根据订单簿估算滑点，超过上限时拒绝开仓
+// 订单簿获取失败不阻止交易，仅记录警告
+func (at *AutoTrader) checkSlippage(symbol string, buy bool, notional float64, actionRecord *logger.DecisionAction) error {
+	limit := MaxSlippagePct()
+	if limit <= 0 || notional <= 0 {
+		return nil
+	}
+
+	estimate, err := market.EstimateSlippage(at.marketProvider(), symbol, buy, notional)
+	if err != nil {
+		log.Printf("  ⚠️ 无法估算滑点，跳过检查: %v", err)
+		return nil
+	}
+	actionRecord.EstimatedSlippagePct = estimate.SlippagePct
+
+	if !estimate.Filled {
+		return fmt.Errorf("❌ %s 订单簿深度不足以成交 %.2f USDT，拒绝开仓", symbol, notional)
+	}
+	log.Printf("  📊 预估滑点: %.3f%% (成交均价 %.4f)", estimate.SlippagePct, estimate.AvgPrice)
+	if estimate.SlippagePct > limit {
+		return fmt.Errorf("❌ %s 预估滑点 %.3f%% 超过上限 %.2f%%，拒绝开仓", symbol, estimate.SlippagePct, limit)
+	}
+	return nil
+}
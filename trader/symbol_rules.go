@@ -0,0 +1,206 @@
+package trader
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SymbolCooldown 币种冷却期：到期前禁止开仓
+type SymbolCooldown struct {
+	Symbol string    `json:"symbol"`
+	Until  time.Time `json:"until"`
+	Reason string    `json:"reason"`
+}
+
+// SymbolRulesSnapshot 币种限制的当前状态（API展示用）
+type SymbolRulesSnapshot struct {
+	Blacklist               []string         `json:"blacklist"`
+	Whitelist               []string         `json:"whitelist"`
+	Cooldowns               []SymbolCooldown `json:"cooldowns"`
+	StopLossCooldownMinutes int              `json:"stop_loss_cooldown_minutes"`
+}
+
+// SymbolRules 交易员的币种黑名单、白名单和冷却期，运行中可随时修改
+// 只限制开仓，已有持仓仍可正常平仓
+type SymbolRules struct {
+	mu               sync.RWMutex
+	blacklist        map[string]bool
+	whitelist        map[string]bool // 为空表示不限制
+	cooldowns        map[string]SymbolCooldown
+	stopLossCooldown time.Duration // 止损后自动冷却的时长，0 表示不自动冷却
+
+	// onCooldown 新增冷却期后的回调（由 manager 设置，用于持久化）
+	onCooldown func(cooldown SymbolCooldown)
+
+	closedByDecision map[string]bool // 本交易员主动平仓的持仓 (symbol_side)，消失时不视为止损
+}
+
+// NewSymbolRules 创建币种限制
+func NewSymbolRules(blacklist, whitelist []string, cooldowns []SymbolCooldown, stopLossCooldown time.Duration) *SymbolRules {
+	r := &SymbolRules{
+		cooldowns:        make(map[string]SymbolCooldown),
+		closedByDecision: make(map[string]bool),
+	}
+	r.SetLists(blacklist, whitelist)
+	r.SetStopLossCooldown(stopLossCooldown)
+	for _, cooldown := range cooldowns {
+		cooldown.Symbol = normalizeSymbol(cooldown.Symbol)
+		if time.Now().Before(cooldown.Until) {
+			r.cooldowns[cooldown.Symbol] = cooldown
+		}
+	}
+	return r
+}
+
+// ParseSymbolList 解析逗号分隔的币种列表（标准化为USDT交易对，去重）
+func ParseSymbolList(list string) []string {
+	var symbols []string
+	seen := make(map[string]bool)
+	for _, symbol := range strings.Split(list, ",") {
+		if symbol = strings.TrimSpace(symbol); symbol == "" {
+			continue
+		}
+		symbol = normalizeSymbol(symbol)
+		if !seen[symbol] {
+			seen[symbol] = true
+			symbols = append(symbols, symbol)
+		}
+	}
+	return symbols
+}
+
+func symbolSet(symbols []string) map[string]bool {
+	set := make(map[string]bool, len(symbols))
+	for _, symbol := range symbols {
+		set[normalizeSymbol(symbol)] = true
+	}
+	return set
+}
+
+// SetLists 替换黑名单和白名单
+func (r *SymbolRules) SetLists(blacklist, whitelist []string) {
+	r.mu.Lock()
+	r.blacklist, r.whitelist = symbolSet(blacklist), symbolSet(whitelist)
+	r.mu.Unlock()
+}
+
+// SetStopLossCooldown 设置止损后自动冷却的时长
+func (r *SymbolRules) SetStopLossCooldown(d time.Duration) {
+	r.mu.Lock()
+	r.stopLossCooldown = d
+	r.mu.Unlock()
+}
+
+// SetCooldownHook 设置新增冷却期的回调
+func (r *SymbolRules) SetCooldownHook(fn func(cooldown SymbolCooldown)) {
+	r.mu.Lock()
+	r.onCooldown = fn
+	r.mu.Unlock()
+}
+
+// AddCooldown 禁止 symbol 开仓 d 时长（已有更长的冷却期时保留原冷却期）
+func (r *SymbolRules) AddCooldown(symbol string, d time.Duration, reason string) SymbolCooldown {
+	cooldown := SymbolCooldown{Symbol: normalizeSymbol(symbol), Until: time.Now().Add(d), Reason: reason}
+
+	r.mu.Lock()
+	if existing, ok := r.cooldowns[cooldown.Symbol]; ok && existing.Until.After(cooldown.Until) {
+		r.mu.Unlock()
+		return existing
+	}
+	r.cooldowns[cooldown.Symbol] = cooldown
+	hook := r.onCooldown
+	r.mu.Unlock()
+
+	if hook != nil {
+		hook(cooldown)
+	}
+	return cooldown
+}
+
+// RemoveCooldown 解除冷却期，返回是否存在
+func (r *SymbolRules) RemoveCooldown(symbol string) bool {
+	symbol = normalizeSymbol(symbol)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.cooldowns[symbol]
+	delete(r.cooldowns, symbol)
+	return ok
+}
+
+// Check 检查是否允许开仓 symbol，不允许时返回原因
+func (r *SymbolRules) Check(symbol string) error {
+	symbol = normalizeSymbol(symbol)
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.blacklist[symbol] {
+		return fmt.Errorf("%s 在黑名单中，禁止开仓", symbol)
+	}
+	if len(r.whitelist) > 0 && !r.whitelist[symbol] {
+		return fmt.Errorf("%s 不在白名单中，禁止开仓", symbol)
+	}
+	if cooldown, ok := r.cooldowns[symbol]; ok && time.Now().Before(cooldown.Until) {
+		return fmt.Errorf("%s 冷却中（%s），%s 前禁止开仓",
+			symbol, cooldown.Reason, cooldown.Until.Local().Format("01-02 15:04"))
+	}
+	return nil
+}
+
+// Snapshot 当前限制（不含已过期的冷却期）
+func (r *SymbolRules) Snapshot() SymbolRulesSnapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	snapshot := SymbolRulesSnapshot{
+		Blacklist:               sortedKeys(r.blacklist),
+		Whitelist:               sortedKeys(r.whitelist),
+		Cooldowns:               []SymbolCooldown{},
+		StopLossCooldownMinutes: int(r.stopLossCooldown / time.Minute),
+	}
+	for symbol, cooldown := range r.cooldowns {
+		if time.Now().After(cooldown.Until) {
+			delete(r.cooldowns, symbol)
+			continue
+		}
+		snapshot.Cooldowns = append(snapshot.Cooldowns, cooldown)
+	}
+	sort.Slice(snapshot.Cooldowns, func(i, j int) bool {
+		return snapshot.Cooldowns[i].Until.Before(snapshot.Cooldowns[j].Until)
+	})
+	return snapshot
+}
+
+// markClosed 记录主动平仓（AI或手动决策），之后该持仓消失不触发止损冷却
+func (r *SymbolRules) markClosed(symbol, side string) {
+	r.mu.Lock()
+	r.closedByDecision[symbol+"_"+side] = true
+	r.mu.Unlock()
+}
+
+// onPositionClosed 持仓消失时调用：不是主动平仓且最后一次看到时亏损，视为止损并自动冷却
+func (r *SymbolRules) onPositionClosed(symbol, side string, lastPnL float64) {
+	key := symbol + "_" + side
+	r.mu.Lock()
+	closedByDecision := r.closedByDecision[key]
+	delete(r.closedByDecision, key)
+	d := r.stopLossCooldown
+	r.mu.Unlock()
+	if closedByDecision || d <= 0 || lastPnL >= 0 {
+		return
+	}
+	cooldown := r.AddCooldown(symbol, d, fmt.Sprintf("%s止损", side))
+	log.Printf("🧊 %s %s 仓位亏损平仓，冷却至 %s", symbol, side, cooldown.Until.Local().Format("01-02 15:04"))
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
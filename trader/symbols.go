@@ -0,0 +1,87 @@
+package trader
+
+import (
+	"log"
+	"nofx/decision"
+	"nofx/market"
+	"sync"
+	"time"
+)
+
+// symbolRegistryTTL 交易所交易对注册表的刷新间隔
+const symbolRegistryTTL = time.Hour
+
+// SymbolLister 能提供交易对注册表的交易器
+// 候选币种来自币安的信号源，进入提示词之前按注册表剔除交易所不支持的交易对
+type SymbolLister interface {
+	// SymbolRegistry 交易所当前可交易的交易对（标准交易对 <-> 交易所交易对）
+	SymbolRegistry() (*market.SymbolRegistry, error)
+}
+
+// symbolRegistryCache 交易对注册表缓存，过期后重新加载；加载失败时继续使用旧注册表
+type symbolRegistryCache struct {
+	mu        sync.Mutex
+	registry  *market.SymbolRegistry
+	fetchedAt time.Time
+}
+
+func (c *symbolRegistryCache) get(load func() (*market.SymbolRegistry, error)) (*market.SymbolRegistry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.registry != nil && time.Since(c.fetchedAt) < symbolRegistryTTL {
+		return c.registry, nil
+	}
+
+	registry, err := load()
+	if err != nil {
+		if c.registry != nil {
+			log.Printf("⚠️  刷新 %s 交易对列表失败，继续使用旧列表: %v", c.registry.Exchange(), err)
+			return c.registry, nil
+		}
+		return nil, err
+	}
+	c.registry, c.fetchedAt = registry, time.Now()
+	return registry, nil
+}
+
+// cached 已加载的注册表（未加载时返回 nil，不发起请求）
+func (c *symbolRegistryCache) cached() *market.SymbolRegistry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.registry
+}
+
+// set 写入从其他请求顺带得到的注册表
+func (c *symbolRegistryCache) set(registry *market.SymbolRegistry) {
+	c.mu.Lock()
+	c.registry, c.fetchedAt = registry, time.Now()
+	c.mu.Unlock()
+}
+
+// filterTradableCandidates 剔除交易所不支持的候选币种
+// 交易器不提供注册表或注册表获取失败时不做过滤
+func (at *AutoTrader) filterTradableCandidates(candidates []decision.CandidateCoin) []decision.CandidateCoin {
+	lister, ok := at.trader.(SymbolLister)
+	if !ok {
+		return candidates
+	}
+	registry, err := lister.SymbolRegistry()
+	if err != nil {
+		log.Printf("⚠️  [%s] 获取交易对列表失败，跳过候选币种可交易检查: %v", at.name, err)
+		return candidates
+	}
+
+	tradable := make([]decision.CandidateCoin, 0, len(candidates))
+	var dropped []string
+	for _, coin := range candidates {
+		if registry.Tradable(coin.Symbol) {
+			tradable = append(tradable, coin)
+		} else {
+			dropped = append(dropped, coin.Symbol)
+		}
+	}
+	if len(dropped) > 0 {
+		log.Printf("⚠️  [%s] %s 不支持以下候选币种，已剔除: %v", at.name, registry.Exchange(), dropped)
+	}
+	return tradable
+}
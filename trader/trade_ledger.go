@@ -0,0 +1,360 @@
+package trader
+
+import (
+	"encoding/json"
+	"log"
+	"nofx/decision"
+	"nofx/logger"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fillLookback 查询下单成交时向前多取的时间（容忍本机与交易所的时钟偏差）
+const fillLookback = time.Minute
+
+// Fill 一笔成交
+type Fill struct {
+	OrderID      int64
+	Buy          bool
+	PositionSide string // LONG/SHORT（双向持仓模式），单向持仓为 BOTH 或空
+	Price        float64
+	Quantity     float64
+	Fee          float64 // 手续费（USDT，正数为支出）
+	Time         time.Time
+}
+
+// FillReporter 能查询成交明细和资金费的交易器
+// 交易账本用它记录真实成交均价、手续费和资金费；不支持的交易器使用下单时的市场价，手续费和资金费记为0
+type FillReporter interface {
+	// GetFills symbol 在 [start, end] 内的成交
+	GetFills(symbol string, start, end time.Time) ([]Fill, error)
+	// GetFundingFees symbol 在 [start, end] 内的资金费净额（USDT，收入为正）
+	GetFundingFees(symbol string, start, end time.Time) (float64, error)
+}
+
+var (
+	_ FillReporter = (*FuturesTrader)(nil)
+	_ FillReporter = (*AsterTrader)(nil)
+	_ FillReporter = (*HyperliquidTrader)(nil)
+)
+
+// orderIDOf 从下单结果中取订单ID（各交易所返回的类型不同）
+func orderIDOf(order map[string]interface{}) int64 {
+	switch id := order["orderId"].(type) {
+	case int64:
+		return id
+	case int:
+		return int64(id)
+	case float64:
+		return int64(id)
+	case json.Number:
+		n, _ := id.Int64()
+		return n
+	case string:
+		n, _ := strconv.ParseInt(id, 10, 64)
+		return n
+	}
+	return 0
+}
+
+// fillSummary 一组成交的汇总
+type fillSummary struct {
+	price    float64 // 成交均价
+	quantity float64
+	fee      float64
+	lastTime time.Time
+}
+
+// orderFills 汇总某个订单的成交：有订单ID时按订单ID匹配，否则按买卖方向（双向持仓时还按持仓方向 side）匹配 since 之后的成交
+// maxQuantity > 0 时按时间顺序最多汇总该数量（避免把同方向的其他成交算进来）
+func (at *AutoTrader) orderFills(symbol string, orderID int64, buy bool, side string, since time.Time, maxQuantity float64) (fillSummary, bool) {
+	reporter, ok := at.trader.(FillReporter)
+	if !ok {
+		return fillSummary{}, false
+	}
+	fills, err := reporter.GetFills(symbol, since.Add(-fillLookback), time.Now())
+	if err != nil {
+		log.Printf("⚠️  [%s] 查询 %s 成交明细失败: %v", at.name, symbol, err)
+		return fillSummary{}, false
+	}
+
+	sort.SliceStable(fills, func(i, j int) bool { return fills[i].Time.Before(fills[j].Time) })
+	var summary fillSummary
+	var notional float64
+	for _, fill := range fills {
+		if orderID > 0 && fill.OrderID != orderID {
+			continue
+		}
+		if orderID <= 0 && (fill.Buy != buy || fill.Time.Before(since.Add(-fillLookback))) {
+			continue
+		}
+		if (fill.PositionSide == "LONG" || fill.PositionSide == "SHORT") && fill.PositionSide != strings.ToUpper(side) {
+			continue
+		}
+		if maxQuantity > 0 && summary.quantity+fill.Quantity > maxQuantity {
+			remaining := maxQuantity - summary.quantity
+			if remaining <= 0 {
+				break
+			}
+			fill.Fee *= remaining / fill.Quantity
+			fill.Quantity = remaining
+		}
+		summary.quantity += fill.Quantity
+		summary.fee += fill.Fee
+		notional += fill.Price * fill.Quantity
+		if fill.Time.After(summary.lastTime) {
+			summary.lastTime = fill.Time
+		}
+	}
+	if summary.quantity <= 0 {
+		return fillSummary{}, false
+	}
+	summary.price = notional / summary.quantity
+	return summary, true
+}
+
+// fundingFees 持仓期间的资金费净额
+func (at *AutoTrader) fundingFees(symbol string, start, end time.Time) float64 {
+	reporter, ok := at.trader.(FillReporter)
+	if !ok {
+		return 0
+	}
+	funding, err := reporter.GetFundingFees(symbol, start, end)
+	if err != nil {
+		log.Printf("⚠️  [%s] 查询 %s 资金费失败: %v", at.name, symbol, err)
+		return 0
+	}
+	return funding
+}
+
+// findOpenTrade 账本中 symbol/side 的未平仓交易
+func (at *AutoTrader) findOpenTrade(symbol, side string) *logger.Trade {
+	trades, err := at.ledger.OpenTrades()
+	if err != nil {
+		log.Printf("⚠️  [%s] 读取未平仓交易失败: %v", at.name, err)
+		return nil
+	}
+	for _, trade := range trades {
+		if trade.Symbol == symbol && trade.Side == side {
+			return trade
+		}
+	}
+	return nil
+}
+
+// recordTrade 决策执行成功后更新交易账本（source 为 ai 或手动决策来源）
+func (at *AutoTrader) recordTrade(d *decision.Decision, actionRecord *logger.DecisionAction, source string) {
+	switch d.Action {
+	case "open_long", "open_short":
+		at.recordTradeOpen(d, actionRecord, source)
+	case "close_long", "close_short":
+		reason := logger.ExitAIClose
+		if source != "ai" {
+			reason = logger.ExitManual
+		}
+		at.recordTradeClose(actionRecord, reason)
+	}
+}
+
+// recordTradeOpen 记录开仓，成交价和手续费以交易所成交明细为准
+func (at *AutoTrader) recordTradeOpen(d *decision.Decision, actionRecord *logger.DecisionAction, source string) {
+	side := "long"
+	if d.Action == "open_short" {
+		side = "short"
+	}
+	trade := &logger.Trade{
+		Symbol:       d.Symbol,
+		Side:         side,
+		Status:       logger.TradeOpen,
+		Quantity:     actionRecord.Quantity,
+		Leverage:     d.Leverage,
+		EntryPrice:   actionRecord.Price,
+		EntryTime:    actionRecord.Timestamp,
+		EntryOrderID: actionRecord.OrderID,
+		StopLoss:     d.StopLoss,
+		TakeProfit:   d.TakeProfit,
+		OpenSource:   source,
+	}
+	if fills, ok := at.orderFills(d.Symbol, actionRecord.OrderID, side == "long", side, actionRecord.Timestamp, 0); ok {
+		trade.EntryPrice, trade.Quantity, trade.Fees = fills.price, fills.quantity, fills.fee
+		trade.EntryTime = fills.lastTime
+	}
+
+	if err := at.ledger.OpenTrade(trade); err != nil {
+		log.Printf("⚠️  [%s] 记录开仓交易失败: %v", at.name, err)
+		return
+	}
+	actionRecord.TradeID = trade.ID
+	log.Printf("📒 [%s] 交易 #%d 开仓: %s %s %.4f @ %.4f", at.name, trade.ID, trade.Symbol, side, trade.Quantity, trade.EntryPrice)
+}
+
+// recordTradeClose 记录决策平仓
+func (at *AutoTrader) recordTradeClose(actionRecord *logger.DecisionAction, reason string) {
+	side := "long"
+	if actionRecord.Action == "close_short" {
+		side = "short"
+	}
+	trade := at.findOpenTrade(actionRecord.Symbol, side)
+	if trade == nil {
+		return
+	}
+	trade.ExitOrderID = actionRecord.OrderID
+
+	// 平多是卖出，平空是买入
+	exitPrice, exitTime := actionRecord.Price, actionRecord.Timestamp
+	if fills, ok := at.orderFills(trade.Symbol, actionRecord.OrderID, side == "short", side, actionRecord.Timestamp, 0); ok {
+		exitPrice, exitTime = fills.price, fills.lastTime
+		trade.Fees += fills.fee
+	}
+	at.closeTrade(trade, exitPrice, exitTime, reason)
+	actionRecord.TradeID = trade.ID
+}
+
+// closeTrade 补充资金费后平仓并写入账本
+func (at *AutoTrader) closeTrade(trade *logger.Trade, exitPrice float64, exitTime time.Time, reason string) {
+	trade.Funding = at.fundingFees(trade.Symbol, trade.EntryTime, exitTime)
+	trade.Close(exitPrice, exitTime, reason)
+	if err := at.ledger.UpdateTrade(trade); err != nil {
+		log.Printf("⚠️  [%s] 记录平仓交易失败: %v", at.name, err)
+		return
+	}
+	log.Printf("📒 [%s] 交易 #%d 平仓 (%s): %s %s @ %.4f，净盈亏 %+.2f USDT",
+		at.name, trade.ID, reason, trade.Symbol, trade.Side, trade.ExitPrice, trade.NetPnL)
+}
+
+// syncLedgerPositions 根据交易所持仓同步账本：
+// 没有对应交易的持仓（本系统之外开仓或账本启用前的持仓）补记为开仓；
+// 没有对应持仓但账本中仍未平仓的交易（止损/止盈单成交、强平、在交易所手动平仓或重启期间平仓）记为平仓，
+// vanished 是上个周期还在、本周期消失的持仓，用于取平仓时的标记价格和强平价
+func (at *AutoTrader) syncLedgerPositions(positions []decision.PositionInfo, vanished []decision.PositionInfo) {
+	openTrades, err := at.ledger.OpenTrades()
+	if err != nil {
+		log.Printf("⚠️  [%s] 读取未平仓交易失败: %v", at.name, err)
+		return
+	}
+	tradeByKey := make(map[string]*logger.Trade, len(openTrades))
+	for _, trade := range openTrades {
+		tradeByKey[trade.Symbol+"_"+trade.Side] = trade
+	}
+
+	live := make(map[string]bool, len(positions))
+	for _, pos := range positions {
+		live[pos.Symbol+"_"+pos.Side] = true
+		if _, ok := tradeByKey[pos.Symbol+"_"+pos.Side]; ok {
+			continue
+		}
+		trade := &logger.Trade{
+			Symbol:     pos.Symbol,
+			Side:       pos.Side,
+			Status:     logger.TradeOpen,
+			Quantity:   pos.Quantity,
+			Leverage:   pos.Leverage,
+			EntryPrice: pos.EntryPrice,
+			EntryTime:  time.UnixMilli(pos.UpdateTime),
+			OpenSource: "detected",
+		}
+		if err := at.ledger.OpenTrade(trade); err != nil {
+			log.Printf("⚠️  [%s] 补记持仓 %s %s 失败: %v", at.name, pos.Symbol, pos.Side, err)
+			continue
+		}
+		log.Printf("📒 [%s] 补记持仓为交易 #%d: %s %s %.4f @ %.4f", at.name, trade.ID, pos.Symbol, pos.Side, pos.Quantity, pos.EntryPrice)
+	}
+
+	lastSeen := make(map[string]decision.PositionInfo, len(vanished))
+	for _, pos := range vanished {
+		lastSeen[pos.Symbol+"_"+pos.Side] = pos
+	}
+	for key, trade := range tradeByKey {
+		// 刚开仓的交易可能晚于本次持仓查询（例如并发的手动决策），留到下个周期再判断
+		if live[key] || time.Since(trade.EntryTime) < fillLookback {
+			continue
+		}
+		pos, ok := lastSeen[key]
+		if !ok {
+			pos = decision.PositionInfo{Symbol: trade.Symbol, Side: trade.Side, MarkPrice: trade.EntryPrice}
+			if price, err := at.trader.GetMarketPrice(trade.Symbol); err == nil {
+				pos.MarkPrice = price
+			}
+		}
+		exitPrice, exitTime := pos.MarkPrice, time.Now()
+		if fills, ok := at.orderFills(trade.Symbol, 0, trade.Side == "short", trade.Side, trade.EntryTime.Add(time.Second), trade.Quantity); ok {
+			exitPrice, exitTime = fills.price, fills.lastTime
+			trade.Fees += fills.fee
+		}
+		at.closeTrade(trade, exitPrice, exitTime, exitReason(trade, pos, exitPrice))
+	}
+}
+
+// exitReasonTolerance 判断平仓价触及止损/止盈/强平价时允许的偏差（滑点）
+const exitReasonTolerance = 0.005
+
+// exitReason 根据平仓价推断非决策平仓的原因
+func exitReason(trade *logger.Trade, pos decision.PositionInfo, exitPrice float64) string {
+	// reached 价格是否已到达 level（多仓向下、空仓向上为不利方向）
+	reached := func(level float64, adverse bool) bool {
+		if level <= 0 {
+			return false
+		}
+		if (trade.Side == "long") == adverse {
+			return exitPrice <= level*(1+exitReasonTolerance)
+		}
+		return exitPrice >= level*(1-exitReasonTolerance)
+	}
+
+	switch {
+	case reached(pos.LiquidationPrice, true):
+		return logger.ExitLiquidation
+	case reached(trade.StopLoss, true):
+		return logger.ExitStopLoss
+	case reached(trade.TakeProfit, false):
+		return logger.ExitTakeProfit
+	default:
+		return logger.ExitManual
+	}
+}
+
+// linkTrades 决策记录保存后，把本周期开平仓的交易关联到决策周期
+func (at *AutoTrader) linkTrades(record *logger.DecisionRecord) {
+	for _, action := range record.Decisions {
+		if action.TradeID == 0 {
+			continue
+		}
+		open := action.Action == "open_long" || action.Action == "open_short"
+		if err := at.ledger.LinkDecision(action.TradeID, record.CycleNumber, open); err != nil {
+			log.Printf("⚠️  [%s] 关联交易 #%d 与决策周期失败: %v", at.name, action.TradeID, err)
+		}
+	}
+}
+
+// backfillLedger 账本为空时从历史决策日志重建已平仓交易
+func (at *AutoTrader) backfillLedger() {
+	if count, err := at.ledger.Count(); err != nil || count > 0 {
+		return
+	}
+	builder := logger.NewTradeBuilder()
+	err := at.decisionLogger.WalkRecords(time.Time{}, time.Now(), func(record *logger.DecisionRecord) error {
+		builder.Add(record)
+		return nil
+	})
+	if err != nil {
+		log.Printf("⚠️  [%s] 读取决策日志失败: %v", at.name, err)
+		return
+	}
+	trades := builder.Trades()
+	for _, trade := range trades {
+		if err := at.ledger.OpenTrade(trade); err != nil {
+			log.Printf("⚠️  [%s] 从决策日志重建交易失败: %v", at.name, err)
+			return
+		}
+	}
+	if len(trades) > 0 {
+		log.Printf("📒 [%s] 已从决策日志重建 %d 笔历史交易", at.name, len(trades))
+	}
+}
+
+// TradeLedger 交易账本
+func (at *AutoTrader) TradeLedger() logger.TradeLedger {
+	return at.ledger
+}